@@ -13,8 +13,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
 	"github.com/ipfs/go-bitswap/internal/testutil"
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-block-format/coding/rlnc"
 	protocol "github.com/libp2p/go-libp2p-core/protocol"
 
 	bitswap "github.com/ipfs/go-bitswap"
@@ -34,6 +37,7 @@ type distFunc func(b *testing.B, provs []testinstance.Instance, blocks []blocks.
 type runStats struct {
 	DupsRcvd uint64
 	BlksRcvd uint64
+	DataRcvd uint64
 	MsgSent  uint64
 	MsgRecd  uint64
 	Time     time.Duration
@@ -310,6 +314,205 @@ func BenchmarkDatacenterMultiLeechMultiSeed(b *testing.B) {
 	printResults(benchmarkLog)
 }
 
+type lossBench struct {
+	name string
+	loss func(rng *rand.Rand) tn.LossModel
+}
+
+var lossBenches = []lossBench{
+	lossBench{"NoLoss", func(rng *rand.Rand) tn.LossModel { return nil }},
+	// independent losses
+	lossBench{"Bernoulli5", func(rng *rand.Rand) tn.LossModel { return tn.BernoulliLoss(0.05, rng) }},
+	lossBench{"Bernoulli20", func(rng *rand.Rand) tn.LossModel { return tn.BernoulliLoss(0.2, rng) }},
+	// bursts of 5 lost messages on average, 10% of messages lost
+	lossBench{"Bursty10", func(rng *rand.Rand) tn.LossModel { return tn.GilbertElliottLoss(0.022, 0.2, 0, 1, rng) }},
+}
+
+const lossySymbols = 64
+const lossySeedCount = 3
+
+// minLossyCompletion is the share of the blocks of the lossy benchmarks
+// below which a fetch is considered broken rather than slowed down by the
+// loss.
+const minLossyCompletion = 0.9
+
+// BenchmarkLossyCoded fetches the same data from three seeds on lossy links,
+// as plain blocks with GetBlocks and as a generation of "nc" coded blocks
+// with GetBlocksC, with the seeds recoding.
+func BenchmarkLossyCoded(b *testing.B) {
+	benchmarkLog = nil
+	benchmarkSeed, err := strconv.ParseInt(os.Getenv("BENCHMARK_SEED"), 10, 64)
+	var randomGen *rand.Rand = nil
+	if err == nil {
+		randomGen = rand.New(rand.NewSource(benchmarkSeed))
+	}
+
+	fixedDelay := delay.Fixed(10 * time.Millisecond)
+	// Lost wants and blocks are only asked for again on rebroadcast.
+	bsOpts := []bitswap.Option{
+		bitswap.RebroadcastDelay(delay.Fixed(200 * time.Millisecond)),
+		bitswap.WithRecoding(true),
+	}
+
+	for _, lb := range lossBenches {
+		imp := tn.Impairments{
+			Loss:         lb.loss(randomGen),
+			Reorder:      0.1,
+			ReorderDelay: 20 * time.Millisecond,
+			Rng:          randomGen,
+		}
+		b.Run(lb.name+"-Plain", func(b *testing.B) {
+			subtestLossyFetch(b, fixedDelay, imp, bsOpts, false)
+		})
+		b.Run(lb.name+"-Coded", func(b *testing.B) {
+			subtestLossyFetch(b, fixedDelay, imp, bsOpts, true)
+		})
+	}
+
+	out, _ := json.MarshalIndent(benchmarkLog, "", "  ")
+	_ = ioutil.WriteFile("tmp/lossy-benchmark.json", out, 0666)
+	printResults(benchmarkLog)
+}
+
+func subtestLossyFetch(b *testing.B, d delay.D, imp tn.Impairments, bsOpts []bitswap.Option, coded bool) {
+	// Fetches may give up before every block made it through the loss:
+	// the share of blocks fetched is reported, and only fails the
+	// benchmark below minLossyCompletion.
+	fetched := 0
+	for i := 0; i < b.N; i++ {
+		net := tn.LossyVirtualNetwork(mockrouting.NewServer(), d, imp)
+
+		ig := testinstance.NewTestInstanceGenerator(net, nil, bsOpts)
+
+		instances := ig.Instances(lossySeedCount + 1)
+		fetcher := instances[lossySeedCount]
+		seeds := instances[:lossySeedCount]
+		blks := testutil.GenerateBlocksOfSize(lossySymbols, stdBlockSize)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		start := time.Now()
+		if coded {
+			parent := distributeCoded(b, seeds, blks)
+			fetched += fetchCoded(ctx, b, fetcher.Exchange, parent, lossySymbols)
+		} else {
+			root := testutil.GenerateBlocksOfSize(1, rootBlockSize)[0]
+			allToAll(b, seeds, append([]blocks.Block{root}, blks...))
+			fetched += fetchPlain(ctx, b, fetcher.Exchange, root.Cid(), blks)
+		}
+		cancel()
+
+		st, err := fetcher.Exchange.Stat()
+		if err != nil {
+			b.Fatal(err)
+		}
+		nst := fetcher.Adapter.Stats()
+		benchmarkLog = append(benchmarkLog, runStats{
+			Time:     time.Since(start),
+			MsgRecd:  nst.MessagesRecvd,
+			MsgSent:  nst.MessagesSent,
+			DupsRcvd: st.DupBlksReceived,
+			BlksRcvd: st.BlocksReceived,
+			DataRcvd: st.DataReceived,
+			Name:     b.Name(),
+		})
+		ig.Close()
+	}
+	completion := float64(fetched) / float64(b.N*lossySymbols)
+	b.ReportMetric(completion, "completion")
+	if completion < minLossyCompletion {
+		b.Fatalf("fetched %.2f of the blocks, expected at least %.2f", completion, minLossyCompletion)
+	}
+}
+
+// distributeCoded codes the data of blks in a single generation and gives
+// every seed the generation parent and a full rank of its own packets. It
+// returns the CID of the generation parent.
+func distributeCoded(b *testing.B, seeds []testinstance.Instance, blks []blocks.Block) cid.Cid {
+	var data []byte
+	for _, blk := range blks {
+		data = append(data, blk.RawData()...)
+	}
+	enc, err := rlnc.NewEncoder(data, len(blks), stdBlockSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	header := &coding.Header{
+		Scheme:         "nc",
+		Field:          "gf256",
+		GenerationSize: len(blks),
+		SymbolSize:     stdBlockSize,
+		Length:         uint64(len(data)),
+		Checksums:      enc.Checksums().Marshal(),
+	}
+	parent := blocks.NewBlock(codedleaf.FromPacket(header.Marshal()))
+
+	for _, seed := range seeds {
+		if err := seed.Blockstore().Put(parent); err != nil {
+			b.Fatal(err)
+		}
+		for range blks {
+			leaf := blocks.NewBlock(codedleaf.FromPacket(enc.Encode().Marshal()))
+			blk, err := blocks.NewCodedBlockWithCid(leaf.RawData(), leaf.Cid(), parent.Cid())
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := seed.Exchange.HasBlock(blk); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return parent.Cid()
+}
+
+// fetchPlain fetches a root block, then its children with a single
+// GetBlocks() call. It returns the number of children fetched.
+func fetchPlain(ctx context.Context, b *testing.B, bs *bitswap.Bitswap, root cid.Cid, blks []blocks.Block) int {
+	ses := bs.NewSession(ctx)
+	if _, err := ses.GetBlock(ctx, root); err != nil {
+		b.Fatal(err)
+	}
+
+	ks := make([]cid.Cid, 0, len(blks))
+	for _, blk := range blks {
+		ks = append(ks, blk.Cid())
+	}
+	out, err := ses.GetBlocks(ctx, ks)
+	if err != nil {
+		b.Fatal(err)
+	}
+	received := 0
+	for range out {
+		received++
+	}
+	if received != len(ks) {
+		b.Logf("fetched %d of %d blocks", received, len(ks))
+	}
+	return received
+}
+
+// fetchCoded fetches a generation parent, then count coded blocks of the
+// generation with a single GetBlocksC() call. It returns the number of coded
+// blocks fetched.
+func fetchCoded(ctx context.Context, b *testing.B, bs *bitswap.Bitswap, parent cid.Cid, count int) int {
+	ses := bs.NewSession(ctx).(*bssession.Session)
+	if _, err := ses.GetBlock(ctx, parent); err != nil {
+		b.Fatal(err)
+	}
+
+	out, err := ses.GetBlocksC(ctx, parent, "nc", count)
+	if err != nil {
+		b.Fatal(err)
+	}
+	received := 0
+	for range out {
+		received++
+	}
+	if received != count {
+		b.Logf("fetched %d of %d coded blocks", received, count)
+	}
+	return received
+}
+
 func subtestDistributeAndFetch(b *testing.B, numnodes, numblks int, d delay.D, bstoreLatency time.Duration, df distFunc, ff fetchFunc) {
 	for i := 0; i < b.N; i++ {
 		net := tn.VirtualNetwork(mockrouting.NewServer(), d)
@@ -385,6 +588,7 @@ func runDistributionMulti(b *testing.B, fetchers []testinstance.Instance, seeds
 			MsgSent:  nst.MessagesSent,
 			DupsRcvd: st.DupBlksReceived,
 			BlksRcvd: st.BlocksReceived,
+			DataRcvd: st.DataReceived,
 			Name:     b.Name(),
 		}
 		benchmarkLog = append(benchmarkLog, stats)
@@ -429,6 +633,7 @@ func runDistribution(b *testing.B, instances []testinstance.Instance, blocks []b
 		MsgSent:  nst.MessagesSent,
 		DupsRcvd: st.DupBlksReceived,
 		BlksRcvd: st.BlocksReceived,
+		DataRcvd: st.DataReceived,
 		Name:     b.Name(),
 	}
 	benchmarkLog = append(benchmarkLog, stats)
@@ -647,6 +852,7 @@ func printResults(rs []runStats) {
 		rcvd := 0.0
 		dups := 0.0
 		blks := 0.0
+		data := 0.0
 		elpd := 0.0
 		for i := 0; i < len(rs); i++ {
 			if rs[i].Name == name {
@@ -655,6 +861,7 @@ func printResults(rs []runStats) {
 				rcvd += float64(rs[i].MsgRecd)
 				dups += float64(rs[i].DupsRcvd)
 				blks += float64(rs[i].BlksRcvd)
+				data += float64(rs[i].DataRcvd)
 				elpd += float64(rs[i].Time)
 			}
 		}
@@ -662,13 +869,15 @@ func printResults(rs []runStats) {
 		rcvd /= float64(count)
 		dups /= float64(count)
 		blks /= float64(count)
+		data /= float64(count)
 
 		label := fmt.Sprintf("%s (%d runs / %.2fs):", name, count, elpd/1000000000.0)
-		fmt.Printf("%-75s %s: sent %d, recv %d, dups %d / %d\n",
+		fmt.Printf("%-75s %s: sent %d, recv %d, dups %d / %d, data %d\n",
 			label,
 			fmtDuration(time.Duration(int64(math.Round(elpd/float64(count))))),
 			int64(math.Round(sent)), int64(math.Round(rcvd)),
-			int64(math.Round(dups)), int64(math.Round(blks)))
+			int64(math.Round(dups)), int64(math.Round(blks)),
+			int64(math.Round(data)))
 	}
 }
 
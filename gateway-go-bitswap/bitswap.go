@@ -6,7 +6,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"os"
 
 	"sync"
 	"time"
@@ -15,6 +14,7 @@ import (
 
 	deciface "github.com/ipfs/go-bitswap/decision"
 	bsbpm "github.com/ipfs/go-bitswap/internal/blockpresencemanager"
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
 	decision "github.com/ipfs/go-bitswap/internal/decision"
 	bsgetter "github.com/ipfs/go-bitswap/internal/getter"
 	bsmq "github.com/ipfs/go-bitswap/internal/messagequeue"
@@ -27,8 +27,16 @@ import (
 	bsspm "github.com/ipfs/go-bitswap/internal/sessionpeermanager"
 	bsmsg "github.com/ipfs/go-bitswap/message"
 	bsnet "github.com/ipfs/go-bitswap/network"
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	// Register the built-in coding schemes.
+	_ "github.com/ipfs/go-block-format/coding/rlnc"
+	_ "github.com/ipfs/go-block-format/coding/rs"
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	exchange "github.com/ipfs/go-ipfs-exchange-interface"
 	logging "github.com/ipfs/go-log"
@@ -36,7 +44,6 @@ import (
 	process "github.com/jbenet/goprocess"
 	procctx "github.com/jbenet/goprocess/context"
 	peer "github.com/libp2p/go-libp2p-core/peer"
-	ipld "github.com/ipfs/go-ipld-format"
 
 )
 
@@ -121,6 +128,55 @@ func WithScoreLedger(scoreLedger deciface.ScoreLedger) Option {
 	}
 }
 
+// WithNDNConsumer makes the decision engine fetch blocks that peers want but
+// that are not in the local blockstore from the NDN network, using c and
+// naming blocks with names.
+func WithNDNConsumer(c *ndn.Consumer, names *cidname.Mapper) Option {
+	return func(bs *Bitswap) {
+		bs.ndnConsumer = c
+		bs.ndnNames = names
+	}
+}
+
+// WithCodedIndex makes bitswap record the coded blocks it receives in idx,
+// the index the rest of the node reads them from. Without it they are only
+// recorded in memory.
+func WithCodedIndex(idx coding.Index) Option {
+	return func(bs *Bitswap) {
+		bs.codedIndex = idx
+	}
+}
+
+// WithRecoding makes the decision engine answer the coded wants of the
+// coding schemes that can be recoded, like "nc", with new random
+// combinations of the coded blocks in the coded index, so that every
+// peer gets packets nobody else got, instead of forwarding coded blocks as
+// they are received.
+func WithRecoding(enabled bool) Option {
+	return func(bs *Bitswap) {
+		bs.recoding = enabled
+	}
+}
+
+// WithCodedVerification makes bitswap check the "nc" coded blocks it
+// receives against the checksums in the header of their generation parent.
+// Blocks failing the check are dropped, and the peers sending them are
+// reported to the score ledger.
+func WithCodedVerification(enabled bool) Option {
+	return func(bs *Bitswap) {
+		bs.codedVerification = enabled
+	}
+}
+
+// WithChecksumKey sets the key unmasking the keyed checksums of the
+// generations, shared with their publisher, see chunker.KeyedSplitter.
+// Without it, blocks of generations with keyed checksums are not verified.
+func WithChecksumKey(key []byte) Option {
+	return func(bs *Bitswap) {
+		bs.checksumKey = key
+	}
+}
+
 // New initializes a BitSwap instance that communicates over the provided
 // BitSwapNetwork. This function registers the returned instance as the network
 // delegate. Runs until context is cancelled or bitswap.Close is called.
@@ -195,6 +251,7 @@ func New(parent context.Context, network bsnet.BitSwapNetwork,
 		pqm:              pqm,
 		sm:                      sm,
 		sim:                     sim,
+		bpm:                     bpm,
 		notif:                   notif,
 		counters:                new(counters),
 		dupMetric:               dupHist,
@@ -213,6 +270,18 @@ func New(parent context.Context, network bsnet.BitSwapNetwork,
 
 	// Set up decision engine
 	bs.engine = decision.NewEngine(bstore, bs.engineBstoreWorkerCount, network.ConnectionManager(), network.Self(), bs.engineScoreLedger)
+	if bs.ndnConsumer != nil {
+		bs.engine.SetNDNConsumer(bs.ndnConsumer, bs.ndnNames)
+	}
+	if bs.codedIndex == nil {
+		bs.codedIndex = coding.NewIndex(dssync.MutexWrap(ds.NewMapDatastore()))
+	}
+	if bs.recoding {
+		bs.engine.SetCodedIndex(bs.codedIndex)
+	}
+	if bs.codedVerification {
+		bs.verifier = codedleaf.NewVerifier(bstore, bs.checksumKey)
+	}
 
 	bs.pqm.Startup()
 	network.SetDelegate(bs)
@@ -282,6 +351,10 @@ type Bitswap struct {
 	// in which CIDs
 	sim *bssim.SessionInterestManager
 
+	// the BlockPresenceManager keeps track of which peers have which blocks,
+	// and the ranks of their coded blocks
+	bpm *bsbpm.BlockPresenceManager
+
 	// whether or not to make provide announcements
 	provideEnabled bool
 
@@ -297,8 +370,19 @@ type Bitswap struct {
 	// the score ledger used by the decision engine
 	engineScoreLedger deciface.ScoreLedger
 
-	// wrting cid to coding file
-	codingLk sync.Mutex
+	// used by the decision engine to fetch blocks missing locally over NDN
+	ndnConsumer *ndn.Consumer
+	ndnNames    *cidname.Mapper
+
+	// records the coded blocks received, and the ones the decision engine
+	// recodes from
+	codedIndex coding.Index
+	recoding   bool
+
+	// checks the coded blocks received when codedVerification is set
+	codedVerification bool
+	checksumKey       []byte
+	verifier          *codedleaf.Verifier
 }
 
 type counters struct {
@@ -380,6 +464,17 @@ func (bs *Bitswap) receiveBlocksFrom(ctx context.Context, from peer.ID, blks []b
 
 	// If blocks came from the network
 	if from != "" {
+		// Verify coded blocks before they count towards the coded wants
+		// and the rank of their generation.
+		if bs.verifier != nil {
+			wantedc = bs.verifyCoded(from, wantedc)
+			blks = make([]blocks.Block, 0, len(wanted)+len(wantedc))
+			blks = append(blks, wanted...)
+			for _, b := range wantedc {
+				blks = append(blks, b)
+			}
+		}
+
 		var notWanted []blocks.Block
 		//fmt.Println("Debug: Peers ID: ", from)
 		wanted, notWanted, _ = bs.sim.SplitWantedUnwanted(blks)
@@ -396,19 +491,6 @@ func (bs *Bitswap) receiveBlocksFrom(ctx context.Context, from peer.ID, blks []b
 			}
 		for _, b := range wantedc {
 			log.Debugf("[recv] coded block in wantlist; cid=%s, peer=%s", b.Parent(), from)
-			if !bs.sim.HasDecoder(b.Parent()){
-				root, err := bs.blockstore.Get(b.Parent())
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				rootn, err := ipld.Decode(root)
-				symbols:=len(rootn.Links())
-				blockSize := rootn.Links()[0].Size-14
-
-				bs.sim.
-					CreateDecoder(b.Parent(), symbols, int(blockSize))
-			}
 		}
 		wantedc, _ = bs.sim.SplitWantedUnwantedC(wantedc)
 	}
@@ -499,53 +581,111 @@ func (bs *Bitswap) receiveBlocksFrom(ctx context.Context, from peer.ID, blks []b
 		}
 	}
 
+	// Coded blocks held until their generation parent arrived can be
+	// verified now.
+	if bs.verifier != nil {
+		for _, b := range wanted {
+			bs.releaseCoded(ctx, b.Cid())
+		}
+	}
+
 	return nil
 }
 
 
+// putCoded writes coded blocks to the blockstore and records them in the
+// coded index under their parent, with the coding the other blocks of
+// their parent were recorded with, or else the coding they were wanted
+// with.
 func (bs *Bitswap) putCoded(blks []*blocks.CodedBlock) error {
-	b := make([]blocks.Block, len(blks))
-	for i := range blks {
-	//	fmt.Println("Debug: put coded blocks len", len(blks[i].RawData()))
-		b[i] = blks[i]
+	if len(blks) == 0 {
+		return nil
 	}
-	err := bs.blockstore.PutMany(b)
-	if err != nil {
+	children := make(map[cid.Cid][]cid.Cid)
+	for _, blk := range blks {
+		children[blk.Parent()] = append(children[blk.Parent()], blk.Cid())
+	}
+	if err := bs.blockstore.PutMany(codedBlocks(blks)); err != nil {
 		return err
 	}
-	bs.codingLk.Lock()
-	defer bs.codingLk.Unlock()
-
-	files:=make(map[cid.Cid] *os.File)
-
-	for _,b := range blks {
-		f, ok := files[b.Parent()]
-		if  !ok {
-			f, err= os.OpenFile(os.Getenv("IPFS_PATH") + "/nc/" + b.Parent().String(), os.O_APPEND|os.O_WRONLY, 0644)
-			if err!=nil{
-				_ = os.Mkdir(os.Getenv("IPFS_PATH")+"/nc", 0755)
-				os.Create(os.Getenv("IPFS_PATH") + "/nc/" + b.Parent().String())
-				f, err= os.OpenFile(os.Getenv("IPFS_PATH") + "/nc/" + b.Parent().String(), os.O_APPEND|os.O_WRONLY, 0644)
-				if err != nil {
-					return err
-				}
-			}
-			files[b.Parent()]=f
-			defer  f.Close()
+	for parent, cids := range children {
+		// Blocks announced with HasBlock were usually recorded by
+		// whoever added them. Wants that ended since the blocks were
+		// received leave no coding: network coding is the default.
+		coding, ok := bs.indexedCoding(parent)
+		if !ok {
+			coding, ok = bs.sim.Coding(parent)
 		}
-
-		fstat, err:= os.Stat(os.Getenv("IPFS_PATH")+"/nc/"+b.Parent().String())
-		has := fstat.Size()/47
-		fmt.Println("Debug: bitswap size before add ", has)
-		_, err = fmt.Fprintln(f, b.Cid().String())
-		if err != nil {
-			fmt.Println("Debug: error writing cid to file", err)
+		if !ok {
+			coding = "nc"
+		}
+		if err := bs.codedIndex.Add(parent, coding, cids...); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// indexedCoding returns the coding the coded blocks of parent are recorded
+// with in the coded index, if there are any.
+func (bs *Bitswap) indexedCoding(parent cid.Cid) (string, bool) {
+	for _, name := range coding.Names() {
+		if n, err := bs.codedIndex.Count(parent, name); err == nil && n > 0 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// codedBlocks returns blks as plain blocks.
+func codedBlocks(blks []*blocks.CodedBlock) []blocks.Block {
+	b := make([]blocks.Block, len(blks))
+	for i, blk := range blks {
+		b[i] = blk
+	}
+	return b
+}
+
+// verifyCoded drops the coded blocks that fail verification, reporting the
+// peer that sent them to the decision engine, and to the sessions wanting
+// the blocks, which stop sending it wants. Blocks whose generation parent
+// has not arrived yet are held by the verifier, see releaseCoded.
+func (bs *Bitswap) verifyCoded(from peer.ID, blks []*blocks.CodedBlock) []*blocks.CodedBlock {
+	valid := blks[:0]
+	var invalid []cid.Cid
+	for _, b := range blks {
+		err := bs.verifier.Verify(b)
+		if err == codedleaf.ErrParentMissing && !bs.verifier.Hold(from, b) {
+			// The parent may have arrived in the meantime.
+			err = bs.verifier.Verify(b)
+		}
+		switch {
+		case err == codedleaf.ErrParentMissing:
+			log.Debugf("[recv] coded block waiting for its parent; cid=%s, parent=%s, peer=%s", b.Cid(), b.Parent(), from)
+		case err != nil:
+			log.Warnf("[recv] invalid coded block; cid=%s, parent=%s, peer=%s: %s", b.Cid(), b.Parent(), from, err)
+			invalid = append(invalid, b.Parent())
+		default:
+			valid = append(valid, b)
+		}
+	}
+	if len(invalid) > 0 {
+		bs.engine.ReceivedInvalidBlocks(from, len(invalid))
+		bs.sm.ReceivedInvalidFrom(from, invalid)
+	}
+	return valid
+}
+
+// releaseCoded receives again the coded blocks the verifier held until
+// parent arrived, now that they can be verified.
+func (bs *Bitswap) releaseCoded(ctx context.Context, parent cid.Cid) {
+	for from, blks := range bs.verifier.Release(parent) {
+		if err := bs.receiveBlocksFrom(ctx, from, blks, nil, nil); err != nil {
+			log.Debugf("receiving the coded blocks held for %s: %s", parent, err)
+		}
+	}
+}
+
 // ReceiveMessage is called by the network interface when a new message is
 // received.
 func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg.BitSwapMessage) {
@@ -572,6 +712,17 @@ func (bs *Bitswap) ReceiveMessage(ctx context.Context, p peer.ID, incoming bsmsg
 		}
 	}
 
+	// Coded HAVE / DONT_HAVEs are about the coded blocks of parents, not
+	// the parents: only their ranks are recorded, for the sessions to split
+	// their coded wants by rank
+	if codedHaves := incoming.CodedHaves(); len(codedHaves) > 0 {
+		ranks := make(map[cid.Cid]int, len(codedHaves))
+		for _, bp := range codedHaves {
+			ranks[bp.Cid] = bp.Rank
+		}
+		bs.sm.ReceiveRanks(p, ranks)
+	}
+
 	haves := incoming.Haves()
 	dontHaves := incoming.DontHaves()
 	if len(iblocks) > 0 || len(haves) > 0 || len(dontHaves) > 0 {
@@ -0,0 +1,71 @@
+package bitswap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	bitswap "github.com/ipfs/go-bitswap"
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	pb "github.com/ipfs/go-bitswap/message/pb"
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	"github.com/ipfs/go-bitswap/ndn/ndntest"
+	testinstance "github.com/ipfs/go-bitswap/testinstance"
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func hasDontHave(log []logItem, from peer.ID, c cid.Cid) bool {
+	for _, item := range log {
+		if item.dir != 'r' || item.pid != from {
+			continue
+		}
+		for _, dh := range item.msg.DontHaves() {
+			if dh.Equals(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestNDNFailureSendsDontHave(t *testing.T) {
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	nacked := blocks.NewBlock([]byte("no route on ndn"))
+	unanswered := blocks.NewBlock([]byte("nobody answers on ndn"))
+	fwd.Serve(cidname.Default.Name(unanswered.Cid()), func(*ndn.Interest) *ndn.Data { return nil })
+
+	consumer := ndn.NewConsumer(fwd.Dial, ndn.InterestLifetime(50*time.Millisecond), ndn.Retries(0))
+	defer consumer.Close()
+
+	vnet := getVirtualNetwork()
+	ig := testinstance.NewTestInstanceGenerator(vnet, nil, []bitswap.Option{bitswap.WithNDNConsumer(consumer, cidname.Default)})
+	defer ig.Close()
+
+	instances := ig.Instances(2)
+	gateway, requester := instances[0], instances[1]
+	wiretap := new(mockWireTap)
+	bitswap.EnableWireTap(wiretap)(requester.Exchange)
+
+	// Broadcast want-haves do not ask for DONT_HAVEs, so the gateway is
+	// sent want-blocks that do, as a session does once it knows the peer.
+	for _, blk := range []blocks.Block{nacked, unanswered} {
+		msg := bsmsg.New(false)
+		msg.AddEntry(blk.Cid(), 1, pb.Message_Wantlist_Block, true)
+		if err := requester.Adapter.SendMessage(context.Background(), gateway.Peer, msg); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for !hasDontHave(wiretap.getLog(), gateway.Peer, blk.Cid()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected a DONT_HAVE for %s from the gateway", blk.Cid())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	bitswap "github.com/ipfs/go-bitswap"
 	deciface "github.com/ipfs/go-bitswap/decision"
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
 	decision "github.com/ipfs/go-bitswap/internal/decision"
 	bssession "github.com/ipfs/go-bitswap/internal/session"
 	bsmsg "github.com/ipfs/go-bitswap/message"
@@ -17,11 +18,16 @@ import (
 	testinstance "github.com/ipfs/go-bitswap/testinstance"
 	tn "github.com/ipfs/go-bitswap/testnet"
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-block-format/coding/rlnc"
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	detectrace "github.com/ipfs/go-detect-race"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	blocksutil "github.com/ipfs/go-ipfs-blocksutil"
 	delay "github.com/ipfs/go-ipfs-delay"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
 	mockrouting "github.com/ipfs/go-ipfs-routing/mock"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	p2ptestutil "github.com/libp2p/go-libp2p-netutil"
@@ -176,6 +182,210 @@ func TestUnwantedBlockNotAdded(t *testing.T) {
 	}
 }
 
+// codedGeneration returns the parent of a generation of two symbols with
+// checksums, its encoder and a function making coded blocks of it.
+func codedGeneration(t *testing.T) (blocks.Block, *rlnc.Encoder, func(*rlnc.Packet) *blocks.CodedBlock) {
+	data := make([]byte, 2*100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	// The systematic packets are the source symbols, which are never
+	// linearly dependent.
+	enc, err := rlnc.NewEncoder(data, 2, 100, rlnc.Systematic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &coding.Header{
+		Scheme:         "nc",
+		Field:          "gf256",
+		GenerationSize: 2,
+		SymbolSize:     100,
+		Length:         200,
+		Checksums:      enc.Checksums().Marshal(),
+	}
+	parent := blocks.NewBlock(codedleaf.FromPacket(header.Marshal()))
+	coded := func(p *rlnc.Packet) *blocks.CodedBlock {
+		leaf := blocks.NewBlock(codedleaf.FromPacket(p.Marshal()))
+		b, err := blocks.NewCodedBlockWithCid(leaf.RawData(), leaf.Cid(), parent.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	return parent, enc, coded
+}
+
+// Tests that a polluted coded block is dropped before it counts towards the
+// coded want and the rank of its generation: the generation has two
+// symbols and two blocks are wanted, so both valid blocks must still be
+// accepted after it.
+func TestPollutedCodedBlockDropped(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.WithCodedVerification(true)})
+	defer ig.Close()
+
+	peers := ig.Instances(2)
+	sender := peers[0]
+	receiver := peers[1]
+
+	parent, enc, coded := codedGeneration(t)
+	if err := receiver.Blockstore().Put(parent); err != nil {
+		t.Fatal(err)
+	}
+	p, err := enc.EncodeWith([]byte{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Symbol[0] ^= 0xff
+	polluted := coded(p)
+	good := []*blocks.CodedBlock{coded(enc.Encode()), coded(enc.Encode())}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ses := receiver.Exchange.NewSession(ctx).(exchange.FetcherC)
+	out, err := ses.GetBlocksC(ctx, parent.Cid(), "nc", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Let the session record the coded want.
+	time.Sleep(50 * time.Millisecond)
+
+	msg := bsmsg.New(false)
+	msg.AddBlock(polluted)
+	receiver.Exchange.ReceiveMessage(ctx, sender.Peer, msg)
+	if has, err := receiver.Blockstore().Has(polluted.Cid()); err != nil || has {
+		t.Fatal("polluted block added to block store")
+	}
+
+	msg = bsmsg.New(false)
+	for _, b := range good {
+		msg.AddBlock(b)
+	}
+	receiver.Exchange.ReceiveMessage(ctx, sender.Peer, msg)
+
+	received := cid.NewSet()
+	for received.Len() < len(good) {
+		select {
+		case b, ok := <-out:
+			if !ok {
+				t.Fatalf("received %d of %d valid blocks", received.Len(), len(good))
+			}
+			received.Add(b.Cid())
+		case <-ctx.Done():
+			t.Fatalf("received %d of %d valid blocks", received.Len(), len(good))
+		}
+	}
+	for _, b := range good {
+		if !received.Has(b.Cid()) {
+			t.Fatalf("valid block %s not received", b.Cid())
+		}
+	}
+}
+
+// Tests that a coded block received before its generation parent is held
+// until the parent arrives, and only then verified and delivered.
+func TestCodedBlockHeldForParent(t *testing.T) {
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.WithCodedVerification(true)})
+	defer ig.Close()
+
+	peers := ig.Instances(2)
+	sender := peers[0]
+	receiver := peers[1]
+
+	parent, enc, coded := codedGeneration(t)
+	blk := coded(enc.Encode())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ses := receiver.Exchange.NewSession(ctx).(exchange.FetcherC)
+	out, err := ses.GetBlocksC(ctx, parent.Cid(), "nc", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Let the session record the coded want.
+	time.Sleep(50 * time.Millisecond)
+
+	msg := bsmsg.New(false)
+	msg.AddBlock(blk)
+	receiver.Exchange.ReceiveMessage(ctx, sender.Peer, msg)
+	if has, err := receiver.Blockstore().Has(blk.Cid()); err != nil || has {
+		t.Fatal("unverified block added to block store")
+	}
+
+	if err := receiver.Exchange.HasBlock(parent); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-out:
+		if !b.Cid().Equals(blk.Cid()) {
+			t.Fatalf("expected %s, got %s", blk.Cid(), b.Cid())
+		}
+	case <-ctx.Done():
+		t.Fatal("held block not received once its parent arrived")
+	}
+}
+
+// Tests that a coded block announced with HasBlock keeps the coding the
+// blocks of its parent were indexed with by whoever added them, and that
+// blocks of parents nobody indexed are recorded as network coded.
+func TestHasBlockKeepsCoding(t *testing.T) {
+	idx := coding.NewIndex(dssync.MutexWrap(ds.NewMapDatastore()))
+	net := tn.VirtualNetwork(mockrouting.NewServer(), delay.Fixed(kNetworkDelay))
+	ig := testinstance.NewTestInstanceGenerator(net, nil, []bitswap.Option{bitswap.WithCodedIndex(idx)})
+	defer ig.Close()
+	inst := ig.Next()
+
+	parent := blocks.NewBlock([]byte("parent"))
+	leaf := blocks.NewBlock([]byte("reed-solomon shard"))
+	blk, err := blocks.NewCodedBlockWithCid(leaf.RawData(), leaf.Cid(), parent.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Blockstore().Put(blk); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Add(parent.Cid(), "rs", blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Exchange.HasBlock(blk); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := idx.Count(parent.Cid(), "nc"); err != nil || n != 0 {
+		t.Fatalf("expected no nc blocks, got %d (%v)", n, err)
+	}
+	if n, err := idx.Count(parent.Cid(), "rs"); err != nil || n != 1 {
+		t.Fatalf("expected 1 rs block, got %d (%v)", n, err)
+	}
+
+	// Another shard of the same parent, not indexed yet.
+	leaf = blocks.NewBlock([]byte("another reed-solomon shard"))
+	if blk, err = blocks.NewCodedBlockWithCid(leaf.RawData(), leaf.Cid(), parent.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Exchange.HasBlock(blk); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := idx.Count(parent.Cid(), "rs"); err != nil || n != 2 {
+		t.Fatalf("expected 2 rs blocks, got %d (%v)", n, err)
+	}
+
+	other := blocks.NewBlock([]byte("other parent"))
+	leaf = blocks.NewBlock([]byte("packet"))
+	if blk, err = blocks.NewCodedBlockWithCid(leaf.RawData(), leaf.Cid(), other.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.Exchange.HasBlock(blk); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := idx.Count(other.Cid(), "nc"); err != nil || n != 1 {
+		t.Fatalf("expected 1 nc block, got %d (%v)", n, err)
+	}
+}
+
 // Tests that a received block is returned to the client and stored in the
 // blockstore in the following scenario:
 // - the want for the block has been requested by the client
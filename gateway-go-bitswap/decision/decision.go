@@ -8,5 +8,8 @@ type Receipt = intdec.Receipt
 // Expose ScoreLedger externally
 type ScoreLedger = intdec.ScoreLedger
 
+// Expose InvalidBlocksLedger externally
+type InvalidBlocksLedger = intdec.InvalidBlocksLedger
+
 // Expose ScorePeerFunc externally
 type ScorePeerFunc = intdec.ScorePeerFunc
@@ -12,11 +12,15 @@ import (
 type BlockPresenceManager struct {
 	sync.RWMutex
 	presence map[cid.Cid]map[peer.ID]bool
+	// ranks holds how many innovative coded blocks of a parent each peer
+	// has, from coded HAVEs
+	ranks map[cid.Cid]map[peer.ID]int
 }
 
 func New() *BlockPresenceManager {
 	return &BlockPresenceManager{
 		presence: make(map[cid.Cid]map[peer.ID]bool),
+		ranks:    make(map[cid.Cid]map[peer.ID]int),
 	}
 }
 
@@ -34,6 +38,30 @@ func (bpm *BlockPresenceManager) ReceiveFrom(p peer.ID, haves []cid.Cid, dontHav
 	}
 }
 
+// ReceiveRanks is called when a peer tells us how many innovative coded
+// blocks of parents it has
+func (bpm *BlockPresenceManager) ReceiveRanks(p peer.ID, ranks map[cid.Cid]int) {
+	bpm.Lock()
+	defer bpm.Unlock()
+
+	for c, rank := range ranks {
+		if _, ok := bpm.ranks[c]; !ok {
+			bpm.ranks[c] = make(map[peer.ID]int)
+		}
+		bpm.ranks[c][p] = rank
+	}
+}
+
+// PeerRank returns the last rank the given peer sent for the coded blocks of
+// the given cid, and whether it sent one
+func (bpm *BlockPresenceManager) PeerRank(p peer.ID, c cid.Cid) (int, bool) {
+	bpm.RLock()
+	defer bpm.RUnlock()
+
+	rank, ok := bpm.ranks[c][p]
+	return rank, ok
+}
+
 func (bpm *BlockPresenceManager) updateBlockPresence(p peer.ID, c cid.Cid, present bool) {
 	_, ok := bpm.presence[c]
 	if !ok {
@@ -107,6 +135,7 @@ func (bpm *BlockPresenceManager) RemoveKeys(ks []cid.Cid) {
 
 	for _, c := range ks {
 		delete(bpm.presence, c)
+		delete(bpm.ranks, c)
 	}
 }
 
@@ -237,3 +237,36 @@ func TestAllPeersDoNotHaveBlock(t *testing.T) {
 		}
 	}
 }
+
+func TestPeerRank(t *testing.T) {
+	bpm := New()
+
+	peers := testutil.GeneratePeers(2)
+	p0 := peers[0]
+	p1 := peers[1]
+	c0 := testutil.GenerateCids(1)[0]
+
+	if _, ok := bpm.PeerRank(p0, c0); ok {
+		t.Fatal("Expected no rank before a coded HAVE")
+	}
+
+	bpm.ReceiveRanks(p0, map[cid.Cid]int{c0: 4})
+	bpm.ReceiveRanks(p1, map[cid.Cid]int{c0: 0})
+	if rank, ok := bpm.PeerRank(p0, c0); !ok || rank != 4 {
+		t.Fatal("Expected rank 4, got", rank)
+	}
+	if rank, ok := bpm.PeerRank(p1, c0); !ok || rank != 0 {
+		t.Fatal("Expected rank 0, got", rank)
+	}
+
+	// A later coded HAVE replaces the rank
+	bpm.ReceiveRanks(p0, map[cid.Cid]int{c0: 9})
+	if rank, _ := bpm.PeerRank(p0, c0); rank != 9 {
+		t.Fatal("Expected rank 9, got", rank)
+	}
+
+	bpm.RemoveKeys([]cid.Cid{c0})
+	if _, ok := bpm.PeerRank(p0, c0); ok {
+		t.Fatal("Expected the rank to be removed with the key")
+	}
+}
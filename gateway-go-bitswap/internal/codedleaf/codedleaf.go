@@ -0,0 +1,106 @@
+// Package codedleaf frames the packets of coded blocks.
+//
+// Coded blocks are unixfs file leaves, dag-pb nodes without links whose
+// Data is a unixfs Data message of type File carrying one packet: an rlnc
+// packet for "nc", a Reed-Solomon shard for "rs". They are framed by hand to
+// keep unixfs and merkledag out of bitswap.
+//
+// The generation parents of coded files are framed the same way, with the
+// coded file header as their data and links to the coded blocks. A Verifier
+// checks coded blocks against the checksums of their generation parent.
+package codedleaf
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/ipfs/go-block-format/coding"
+)
+
+// ErrBadLeaf is returned for coded blocks that are not unixfs file leaves.
+var ErrBadLeaf = errors.New("coded block is not a unixfs file leaf")
+
+const (
+	pbNodeData     = 1
+	unixfsType     = 1
+	unixfsData     = 2
+	unixfsFilesize = 3
+	unixfsTypeFile = 2
+)
+
+// Packet returns the packet carried by a coded block.
+func Packet(b []byte) ([]byte, error) {
+	data, err := pbBytesField(b, pbNodeData)
+	if err != nil {
+		return nil, err
+	}
+	return pbBytesField(data, unixfsData)
+}
+
+// Header returns the coded file header carried by a generation parent.
+func Header(b []byte) (*coding.Header, error) {
+	data, err := Packet(b)
+	if err != nil {
+		return nil, err
+	}
+	return coding.UnmarshalHeader(data)
+}
+
+// FromPacket returns a coded block carrying packet, encoded the way unixfs
+// encodes file leaves.
+func FromPacket(packet []byte) []byte {
+	data := pbVarintField(nil, unixfsType, unixfsTypeFile)
+	data = pbBytes(data, unixfsData, packet)
+	data = pbVarintField(data, unixfsFilesize, uint64(len(packet)))
+	return pbBytes(nil, pbNodeData, data)
+}
+
+// pbBytesField returns the value of the first length-delimited field num of
+// the protobuf message b.
+func pbBytesField(b []byte, num uint64) ([]byte, error) {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, ErrBadLeaf
+		}
+		b = b[n:]
+
+		switch key & 7 {
+		case 0: // varint
+			_, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, ErrBadLeaf
+			}
+			b = b[n:]
+		case 2: // length-delimited
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return nil, ErrBadLeaf
+			}
+			v := b[n : n+int(l)]
+			if key>>3 == num {
+				return v, nil
+			}
+			b = b[n+int(l):]
+		default:
+			return nil, ErrBadLeaf
+		}
+	}
+	return nil, ErrBadLeaf
+}
+
+func pbVarintField(b []byte, num, v uint64) []byte {
+	b = pbUvarint(b, num<<3)
+	return pbUvarint(b, v)
+}
+
+func pbBytes(b []byte, num uint64, v []byte) []byte {
+	b = pbUvarint(b, num<<3|2)
+	b = pbUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func pbUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
@@ -0,0 +1,27 @@
+package codedleaf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFraming(t *testing.T) {
+	// The framing of a 256KiB unixfs leaf.
+	packet := make([]byte, 256*1024)
+	leaf := FromPacket(packet)
+	if len(leaf) != len(packet)+14 || !bytes.Equal(leaf[10:len(leaf)-4], packet) {
+		t.Fatal("unexpected unixfs leaf framing")
+	}
+
+	out, err := Packet(leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, packet) {
+		t.Fatal("packet differs")
+	}
+
+	if _, err := Packet(leaf[:20]); err != ErrBadLeaf {
+		t.Fatalf("expected ErrBadLeaf, got %v", err)
+	}
+}
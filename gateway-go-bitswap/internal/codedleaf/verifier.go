@@ -0,0 +1,157 @@
+package codedleaf
+
+import (
+	"errors"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding/rlnc"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// maxVerifiedParents bounds the number of generations whose checksums are
+// kept, each taking eight times the symbol size.
+const maxVerifiedParents = 8
+
+// maxHeldBlocks bounds the number of blocks held until their generation
+// parent arrives.
+const maxHeldBlocks = 256
+
+// ErrParentMissing is returned by Verify for blocks whose generation parent
+// is not in the blockstore yet.
+var ErrParentMissing = errors.New("codedleaf: generation parent missing")
+
+// heldBlock is a block waiting for its generation parent.
+type heldBlock struct {
+	from peer.ID
+	blk  *blocks.CodedBlock
+}
+
+// Verifier checks "nc" coded blocks against the checksums published in the
+// header of their generation parent, so that polluted packets are dropped
+// before they reach the blockstore and the decoders.
+//
+// Blocks whose parent has no checksums, or keyed checksums without the
+// verifier's key, cannot be checked and pass. Blocks whose parent we do not
+// hold yet fail with ErrParentMissing; they can be held until the parent
+// arrives, see Hold and Release.
+type Verifier struct {
+	bs  bstore.Blockstore
+	key []byte
+
+	lk sync.Mutex
+	// checksums of the generation parents seen, nil for parents without
+	// checksums.
+	checksums map[cid.Cid]*rlnc.Checksums
+	// held are the blocks waiting for their parent, by parent.
+	held  map[cid.Cid][]heldBlock
+	nheld int
+}
+
+// NewVerifier returns a Verifier reading generation parents from bs, and
+// unmasking keyed checksums with key, which may be nil.
+func NewVerifier(bs bstore.Blockstore, key []byte) *Verifier {
+	return &Verifier{
+		bs:        bs,
+		key:       key,
+		checksums: make(map[cid.Cid]*rlnc.Checksums),
+		held:      make(map[cid.Cid][]heldBlock),
+	}
+}
+
+// Verify returns an error if b is not a valid packet of its generation,
+// rlnc.ErrPolluted when it is not the combination its coefficients claim,
+// and ErrParentMissing when its parent is not in the blockstore.
+func (v *Verifier) Verify(b *blocks.CodedBlock) error {
+	checksums, err := v.parentChecksums(b.Parent())
+	if err != nil || checksums == nil {
+		return err
+	}
+	data, err := Packet(b.RawData())
+	if err != nil {
+		return err
+	}
+	p, err := rlnc.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	return checksums.Verify(p)
+}
+
+// parentChecksums returns the checksums in the header of parent, or nil
+// when there are none.
+func (v *Verifier) parentChecksums(parent cid.Cid) (*rlnc.Checksums, error) {
+	v.lk.Lock()
+	checksums, ok := v.checksums[parent]
+	v.lk.Unlock()
+	if ok {
+		return checksums, nil
+	}
+
+	blk, err := v.bs.Get(parent)
+	if err == bstore.ErrNotFound {
+		// The parent may come later: do not remember it.
+		return nil, ErrParentMissing
+	} else if err != nil {
+		return nil, err
+	}
+	if h, err := Header(blk.RawData()); err == nil && h.Scheme == "nc" && len(h.Checksums) > 0 {
+		if checksums, err = rlnc.UnmarshalChecksums(h.Checksums, h.SymbolSize, v.key); err != nil {
+			// The parent is content addressed: blocks cannot be
+			// blamed for its checksums, nor for keyed checksums
+			// we cannot unmask.
+			checksums = nil
+		}
+	}
+
+	v.lk.Lock()
+	if len(v.checksums) >= maxVerifiedParents {
+		for c := range v.checksums {
+			delete(v.checksums, c)
+			break
+		}
+	}
+	v.checksums[parent] = checksums
+	v.lk.Unlock()
+	return checksums, nil
+}
+
+// Hold keeps b, received from from, until Release is called with its
+// parent. It returns false when b is not held, because its parent arrived
+// since Verify was called, or because too many blocks are held already.
+func (v *Verifier) Hold(from peer.ID, b *blocks.CodedBlock) bool {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	// Checked under the lock, so that a parent stored before Release is
+	// called cannot be missed.
+	if has, err := v.bs.Has(b.Parent()); err != nil || has {
+		return false
+	}
+	if v.nheld >= maxHeldBlocks {
+		return false
+	}
+	v.held[b.Parent()] = append(v.held[b.Parent()], heldBlock{from: from, blk: b})
+	v.nheld++
+	return true
+}
+
+// Release returns the blocks held for parent, by the peer they were received
+// from, and forgets them. It is called once parent is in the blockstore.
+func (v *Verifier) Release(parent cid.Cid) map[peer.ID][]blocks.Block {
+	v.lk.Lock()
+	held := v.held[parent]
+	delete(v.held, parent)
+	v.nheld -= len(held)
+	v.lk.Unlock()
+
+	if len(held) == 0 {
+		return nil
+	}
+	byPeer := make(map[peer.ID][]blocks.Block)
+	for _, h := range held {
+		byPeer[h.from] = append(byPeer[h.from], h.blk)
+	}
+	return byPeer
+}
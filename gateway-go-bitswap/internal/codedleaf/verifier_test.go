@@ -0,0 +1,119 @@
+package codedleaf
+
+import (
+	"math/rand"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-block-format/coding/rlnc"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func TestVerifier(t *testing.T) {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	v := NewVerifier(bs, nil)
+
+	data := make([]byte, 8*100)
+	rand.Read(data)
+	enc, err := rlnc.NewEncoder(data, 8, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &coding.Header{
+		Scheme:         "nc",
+		Field:          "gf256",
+		GenerationSize: 8,
+		SymbolSize:     100,
+		Length:         800,
+		Checksums:      enc.Checksums().Marshal(),
+	}
+	parent := blocks.NewBlock(FromPacket(header.Marshal()))
+
+	coded := func(p *rlnc.Packet) *blocks.CodedBlock {
+		leaf := blocks.NewBlock(FromPacket(p.Marshal()))
+		b, err := blocks.NewCodedBlockWithCid(leaf.RawData(), leaf.Cid(), parent.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	good := coded(enc.Encode())
+	p := enc.Encode()
+	p.Symbol[0] ^= 0xff
+	polluted := coded(p)
+
+	// Without the parent, nothing can be checked: the block is held until
+	// the parent arrives.
+	if err := v.Verify(polluted); err != ErrParentMissing {
+		t.Fatalf("expected ErrParentMissing, got %v", err)
+	}
+	if !v.Hold("peer", polluted) {
+		t.Fatal("expected the block to be held")
+	}
+
+	if err := bs.Put(parent); err != nil {
+		t.Fatal(err)
+	}
+	if v.Hold("peer", good) {
+		t.Fatal("expected the block not to be held once the parent arrived")
+	}
+	held := v.Release(parent.Cid())
+	if len(held) != 1 || len(held["peer"]) != 1 || !held["peer"][0].Cid().Equals(polluted.Cid()) {
+		t.Fatalf("expected the held block back, got %v", held)
+	}
+	if held := v.Release(parent.Cid()); len(held) != 0 {
+		t.Fatalf("expected no more held blocks, got %v", held)
+	}
+	if err := v.Verify(good); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(polluted); err != rlnc.ErrPolluted {
+		t.Fatalf("expected rlnc.ErrPolluted, got %v", err)
+	}
+	other, _ := rlnc.NewEncoder(data, 4, 200)
+	if err := v.Verify(coded(other.Encode())); err != rlnc.ErrSymbolCount {
+		t.Fatalf("expected rlnc.ErrSymbolCount, got %v", err)
+	}
+}
+
+func TestVerifierKeyedChecksums(t *testing.T) {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	key := []byte("checksum key")
+
+	data := make([]byte, 8*100)
+	rand.Read(data)
+	enc, err := rlnc.NewEncoder(data, 8, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &coding.Header{
+		Scheme:         "nc",
+		Field:          "gf256",
+		GenerationSize: 8,
+		SymbolSize:     100,
+		Length:         800,
+		Checksums:      enc.KeyedChecksums(key).Marshal(),
+	}
+	parent := blocks.NewBlock(FromPacket(header.Marshal()))
+	if err := bs.Put(parent); err != nil {
+		t.Fatal(err)
+	}
+	p := enc.Encode()
+	p.Symbol[0] ^= 0xff
+	leaf := blocks.NewBlock(FromPacket(p.Marshal()))
+	polluted, err := blocks.NewCodedBlockWithCid(leaf.RawData(), leaf.Cid(), parent.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewVerifier(bs, key).Verify(polluted); err != rlnc.ErrPolluted {
+		t.Fatalf("expected rlnc.ErrPolluted, got %v", err)
+	}
+	// Without the key, the checksums cannot be used.
+	if err := NewVerifier(bs, nil).Verify(polluted); err != nil {
+		t.Fatalf("expected the block to pass unverified, got %v", err)
+	}
+}
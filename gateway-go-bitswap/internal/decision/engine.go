@@ -2,18 +2,18 @@
 package decision
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/exec"
-	"strings"
 	"sync"
 	"time"
+
 	"github.com/google/uuid"
 
 	bsmsg "github.com/ipfs/go-bitswap/message"
 	pb "github.com/ipfs/go-bitswap/message/pb"
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
 	wl "github.com/ipfs/go-bitswap/wantlist"
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
@@ -23,8 +23,6 @@ import (
 	"github.com/ipfs/go-peertaskqueue/peertask"
 	process "github.com/jbenet/goprocess"
 	peer "github.com/libp2p/go-libp2p-core/peer"
-	ipld "github.com/ipfs/go-ipld-format"
-
 )
 
 // TODO consider taking responsibility for other types of requests. For
@@ -83,6 +81,10 @@ const (
 	taskWorkerCount = 8
 )
 
+// errNDNHashMismatch is returned when content fetched from NDN does not
+// hash to the CID it was requested for.
+var errNDNHashMismatch = errors.New("NDN content does not match its CID")
+
 // Envelope contains a message for a Peer.
 type Envelope struct {
 	// Peer is the intended recipient.
@@ -125,6 +127,13 @@ type ScoreLedger interface {
 	Stop()
 }
 
+// InvalidBlocksLedger is implemented by score ledgers that account for the
+// blocks peers sent us that failed verification.
+type InvalidBlocksLedger interface {
+	// Increments the invalid blocks counter for the given peer.
+	AddToInvalidBlocks(p peer.ID, n int)
+}
+
 // Engine manages sending requested blocks to peers.
 type Engine struct {
 	// peerRequestQueue is a priority queue of requests received from peers.
@@ -144,8 +153,22 @@ type Engine struct {
 	outbox chan (<-chan *Envelope)
 
 	bsm *blockstoreManager
-	bs *bstore.Blockstore
-
+	bs  bstore.Blockstore
+
+	// ndn retrieves blocks that peers want but that are not in the
+	// blockstore. When nil, missing blocks are answered with DONT_HAVE.
+	ndn      *ndn.Consumer
+	ndnNames *cidname.Mapper
+	// ndnMisses holds the blocks recently not found on NDN, which are
+	// answered with DONT_HAVE right away.
+	ndnMisses *negativeCache
+	// ndnFetches aggregates the wants of all peers for the blocks being
+	// fetched from NDN.
+	ndnFetches *ndnFetches
+
+	// recoder answers coded wants with new combinations of the coded
+	// blocks we hold. When nil, coded blocks are forwarded as received.
+	recoder *recoder
 
 	peerTagger PeerTagger
 
@@ -198,7 +221,10 @@ func newEngine(bs bstore.Blockstore, bstoreWorkerCount int, peerTagger PeerTagge
 		taskWorkerCount:                 taskWorkerCount,
 		sendDontHaves:                   true,
 		self:                            self,
-		bs: 							 &bs,
+		bs:                              bs,
+		ndnNames:                        cidname.Default,
+		ndnMisses:                       newNegativeCache(ndnNegativeCacheTTL),
+		ndnFetches:                      newNDNFetches(),
 	}
 	e.tagQueued = fmt.Sprintf(tagFormat, "queued", uuid.New().String())
 	e.tagUseful = fmt.Sprintf(tagFormat, "useful", uuid.New().String())
@@ -220,6 +246,20 @@ func (e *Engine) SetSendDontHaves(send bool) {
 	e.sendDontHaves = send
 }
 
+// SetNDNConsumer makes the engine fetch blocks that peers want but that are
+// not in the blockstore from NDN, naming them with names.
+func (e *Engine) SetNDNConsumer(c *ndn.Consumer, names *cidname.Mapper) {
+	e.ndn = c
+	e.ndnNames = names
+}
+
+// SetCodedIndex makes the engine answer coded wants with blocks recoded
+// from the coded blocks idx lists, instead of forwarding the coded blocks
+// it receives unchanged.
+func (e *Engine) SetCodedIndex(idx CodedIndex) {
+	e.recoder = newRecoder(e.bs, idx)
+}
+
 // Starts the score ledger. Before start the function checks and,
 // if it is unset, initializes the scoreLedger with the default
 // implementation.
@@ -362,11 +402,14 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 			} else {
 				td := t.Data.(*taskDataCoded)
 				if td.IsWantBlock {
-					blockCids = append(blockCids, c)
+					// Recoded blocks are not in the blockstore.
+					if td.Block == nil {
+						blockCids = append(blockCids, c)
+					}
 					blockTasksCoded[c] = td
 				} else {
-					// Add HAVES to the message
-					msg.AddHave(c)
+					// Add coded HAVEs with our rank to the message
+					msg.AddCodedHave(c, td.Coding, td.Rank)
 				}
 
 			}
@@ -397,17 +440,22 @@ func (e *Engine) nextEnvelope(ctx context.Context) (*Envelope, error) {
 			}
 		}
 
-		for c, _ := range blockTasksCoded {
+		for c, tdc := range blockTasksCoded {
+			if tdc.Block != nil {
+				msg.AddBlock(tdc.Block)
+				continue
+			}
 			blk := blks[c]
-			if tdc, ok := blockTasksCoded[c]; ok{
-				blk, err = blocks.NewCodedBlockWithCid(blk.RawData(), blk.Cid(), tdc.Parent)
-				if err != nil {
-					log.Errorw("not sending coded block", err)
-					continue
-				}
-				msg.AddBlock(blk)
-				fmt.Println("Debug: sending coded block for", tdc.Parent, "to", p)
+			if blk == nil {
+				continue
+			}
+			cblk, err := blocks.NewCodedBlockWithCid(blk.RawData(), blk.Cid(), tdc.Parent)
+			if err != nil {
+				log.Errorw("not sending coded block", err)
+				continue
 			}
+			msg.AddBlock(cblk)
+			fmt.Println("Debug: sending coded block for", tdc.Parent, "to", p)
 		}
 
 
@@ -508,14 +556,17 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 	// If the peer sent a full wantlist, replace the ledger's wantlist
 	if m.Full() {
 		l.wantList = wl.New()
+		e.ndnFetches.retain(p, wantKs)
 	}
 
 	var activeEntries []peertask.Task
+	var ndnWants []bsmsg.Entry
+	var recodeWants []recodeWant
 
 	// Remove cancelled blocks from the queue
 	for _, entry := range cancels {
 		fmt.Println("Debug: cancel entry, coding", entry.Coding)
-		if entry.Coding =="nc"{
+		if entry.Coding != "" {
 			fmt.Println("Debug: received coded cancel, cid",entry.Cid,", count ",entry.Count)
 			l.CancelCodedWant(entry.Cid, entry.Count)
 			e.peerRequestQueue.RemoveCoded(entry.Cid, p, entry.Count)
@@ -525,6 +576,7 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 		if l.CancelWant(entry.Cid) {
 			e.peerRequestQueue.Remove(entry.Cid, p)
 		}
+		e.ndnFetches.cancel(p, entry.Cid)
 	}
 
 	// For each want-have / want-block
@@ -532,126 +584,33 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 		c := entry.Cid
 		blockSize, found := blockSizes[entry.Cid]
 
-		// Add each want-have / want-block to the ledger
-		l.Wants(c, entry.Priority, entry.WantType, entry.Coding, entry.Count)
-
-		// send haves for all blocks
-		// no prefetch
-		isWantBlock := entry.WantType == pb.Message_Wantlist_Block
-		if !isWantBlock{
-			newWorkExists = true
-			entrySize := bsmsg.BlockPresenceSize(c)
-
-			activeEntries = append(activeEntries, peertask.Task{
-				Topic:    c,
-				Priority: int(entry.Priority),
-				Work:     entrySize,
-				Data: &taskData{
-					BlockSize:    blockSize,
-					HaveBlock:    true,
-					IsWantBlock:  isWantBlock,
-					SendDontHave: entry.SendDontHave,
-				},
-			})
-		} else {
-			fmt.Println("Received want block", c)
-
-			blockSize, found = blockSizes[entry.Cid]
-
-			if found {
-				entrySize := blockSize
-
-				activeEntries = append(activeEntries, peertask.Task{
-					Topic:    c,
-					Priority: int(entry.Priority),
-					Work:     entrySize,
-					Data: &taskData{
-						BlockSize:    blockSize,
-						HaveBlock:    true,
-						IsWantBlock:  isWantBlock,
-						SendDontHave: entry.SendDontHave, //mars todo add coded block count
-					},
-				})
-
-			continue
+		// Coded wants are answered with our rank and recoded blocks, made
+		// off the message handling path.
+		if e.recoder != nil && e.recoder.canRecode(entry.Coding) && entry.Count > 0 && entry.WantType == pb.Message_Wantlist_Block {
+			if w, ok := e.codedWant(p, l, entry); ok {
+				recodeWants = append(recodeWants, w)
 			}
-
-			go func() {
-				fmt.Println("Starting go goutine")
-				fmt.Println(c.String())
-				cmd := exec.Command("python", "/home/<>/projects/mars_docker/cid_to_ipld.py", c.String())
-				out, err := cmd.Output()
-				fmt.Println("convert cid to ipld")
-				ipld := strings.TrimSpace(string(out))
-				fmt.Println(string(ipld), err)
-
-				fmt.Println("ndn get")
-				cmd = exec.Command("/home/<>/projects/ndn/ndn-cxx/build/examples/consumer_ipfs", ipld)
-				out, err = cmd.Output() //routine
-				fmt.Println(string(out), err)
-
-				path := "/home/<>/.ipfs/tmp/"+ipld+".data"
-
-				dat, err := os.ReadFile(path)
-				if err != nil {
-					fmt.Println(err)
-					return
-				}
-
-				fmt.Println("create block")
-				b := blocks.NewBlock(dat)
-
-				bss:=*e.bs
-				bl:=make([]blocks.Block, 1)
-				bl[0]=b
-				err=bss.PutMany(bl)
-				if err!=nil{
-					fmt.Println("Debug: error putting recoded blocks")
-					fmt.Println(e)
-				}
-
-				fmt.Println("new block", b.Cid())
-				blockSizes, err = e.bsm.getBlockSizes(ctx, wantKs.Keys())
-				if err != nil {
-					log.Info("aborting message processing", err)
-					return
-				}
-
-				fmt.Println(" make entry")
-				entrySize, _ := blockSizes[entry.Cid]
-
-				fmt.Println("entry size: ", entrySize)
-
-				task := peertask.Task{
-					Topic:    c,
-					Priority: int(entry.Priority),
-					Work:     entrySize,
-					Data: &taskData{
-						BlockSize:    blockSize,
-						HaveBlock:    true,
-						IsWantBlock:  isWantBlock,
-						SendDontHave: entry.SendDontHave,
-					},
-				}
-				e.peerRequestQueue.PushTasks(p, task)
-				}()
+			continue
 		}
-		continue
-
 
+		// Add each want-have / want-block to the ledger
+		l.Wants(c, entry.Priority, entry.WantType, entry.Coding, entry.Count, entry.Rank)
 
 		// If the block was not found
 		if !found {
 			log.Debugw("Bitswap engine: block not found", "local", e.self, "from", p, "cid", entry.Cid, "sendDontHave", entry.SendDontHave)
 
+			// Blocks that are not in the blockstore are fetched from NDN,
+			// the want is answered once the fetch completes. Blocks NDN
+			// recently failed to return are answered right away.
+			if e.ndn != nil && !e.ndnMisses.has(c) {
+				ndnWants = append(ndnWants, entry)
+				continue
+			}
+
 			// Only add the task to the queue if the requester wants a DONT_HAVE
 			if e.sendDontHaves && entry.SendDontHave {
 				newWorkExists = true
-				isWantBlock := false
-				if entry.WantType == pb.Message_Wantlist_Block {
-					isWantBlock = true
-				}
-
 				activeEntries = append(activeEntries, peertask.Task{
 					Topic:    c,
 					Priority: int(entry.Priority),
@@ -659,45 +618,138 @@ func (e *Engine) MessageReceived(ctx context.Context, p peer.ID, m bsmsg.BitSwap
 					Data: &taskData{
 						BlockSize:    0,
 						HaveBlock:    false,
-						IsWantBlock:  isWantBlock,
+						IsWantBlock:  entry.WantType == pb.Message_Wantlist_Block,
 						SendDontHave: entry.SendDontHave,
 					},
 				})
 			}
-		} else {
-			// The block was found, add it to the queue
-			newWorkExists = true
+			continue
+		}
+
+		// The block was found, add it to the queue
+		newWorkExists = true
+		activeEntries = append(activeEntries, e.foundTask(p, entry, blockSize))
+	}
+
+	// Push entries onto the request queue
+	if len(activeEntries) > 0 {
+		e.peerRequestQueue.PushTasks(p, activeEntries...)
+	}
+
+	if len(ndnWants) > 0 {
+		e.fetchFromNDN(ctx, p, ndnWants)
+	}
 
-			isWantBlock := e.sendAsBlock(entry.WantType, blockSize)
+	if len(recodeWants) > 0 {
+		go e.sendRecoded(p, recodeWants)
+	}
+}
 
-			
-			log.Debugw("Bitswap engine: block found", "local", e.self, "from", p, "cid", entry.Cid, "isWantBlock", isWantBlock)
+// recodeWant asks for count recoded blocks of parent.
+type recodeWant struct {
+	parent   cid.Cid
+	coding   string
+	priority int32
+	count    int
+	// have is set when the requester is told our rank for parent.
+	have bool
+}
 
-			// entrySize is the amount of space the entry takes up in the
-			// message we send to the recipient. If we're sending a block, the
-			// entrySize is the size of the block. Otherwise it's the size of
-			// a block presence entry.
-			entrySize := blockSize
-			if !isWantBlock {
-				entrySize = bsmsg.BlockPresenceSize(c)
+// codedWant records the coded want of p in its ledger, and returns the
+// blocks to recode on top of the ones already queued for p. The count is
+// capped by the rank of p: once p holds as many innovative blocks as the
+// generation has symbols, it needs no more and the want is dropped.
+func (e *Engine) codedWant(p peer.ID, l *ledger, entry bsmsg.Entry) (recodeWant, bool) {
+	queued := 0
+	if prev, ok := l.WantListContains(entry.Cid); ok && prev.Coding == entry.Coding {
+		queued = prev.Count
+	}
+
+	count := entry.Count
+	if size := e.recoder.generationSize(entry.Cid); size > 0 && count > size-entry.Rank {
+		count = size - entry.Rank
+	}
+	if count <= 0 {
+		log.Debugw("Bitswap engine: requester has full rank", "local", e.self, "from", p, "parent", entry.Cid, "rank", entry.Rank)
+		l.CancelWant(entry.Cid)
+		e.peerRequestQueue.RemoveCoded(entry.Cid, p, queued)
+		return recodeWant{}, false
+	}
+	l.Wants(entry.Cid, entry.Priority, entry.WantType, entry.Coding, count, entry.Rank)
+
+	// The requester replaces its want as it collects blocks: only make up
+	// the difference with what is already queued.
+	w := recodeWant{parent: entry.Cid, coding: entry.Coding, priority: entry.Priority, have: true}
+	if count < queued {
+		e.peerRequestQueue.RemoveCoded(entry.Cid, p, queued-count)
+	} else {
+		w.count = count - queued
+	}
+	return w, true
+}
+
+// sendRecoded queues new recoded blocks for p, as many of each parent as p
+// asked for and we can make, after a coded HAVE with our rank when asked.
+func (e *Engine) sendRecoded(p peer.ID, wants []recodeWant) {
+	pushed := false
+	for _, w := range wants {
+		if w.have {
+			rank, err := e.recoder.rank(w.parent, w.coding)
+			if err != nil {
+				log.Errorf("getting the rank of %s: %s", w.parent, err)
+				continue
 			}
-			activeEntries = append(activeEntries, peertask.Task{
-				Topic:    c,
-				Priority: int(entry.Priority),
-				Work:     entrySize,
-				Data: &taskData{
-					BlockSize:    blockSize,
-					HaveBlock:    true,
-					IsWantBlock:  isWantBlock,
-					SendDontHave: entry.SendDontHave, //mars todo add coded block count
+			e.peerRequestQueue.PushTasks(p, peertask.Task{
+				Topic:    w.parent,
+				Priority: int(w.priority),
+				Work:     bsmsg.BlockPresenceSize(w.parent),
+				Data: &taskDataCoded{
+					taskData: taskData{
+						HaveBlock:    true,
+						IsWantBlock:  false,
+						SendDontHave: false,
+					},
+					Coding: w.coding,
+					Parent: w.parent,
+					Rank:   rank,
 				},
 			})
+			pushed = true
+		}
+		if w.count == 0 {
+			continue
 		}
-	}
 
-	// Push entries onto the request queue
-	if len(activeEntries) > 0 {
-		e.peerRequestQueue.PushTasks(p, activeEntries...)
+		blks, err := e.recoder.recode(w.parent, w.coding, w.count)
+		if err != nil {
+			log.Errorf("recoding blocks of %s: %s", w.parent, err)
+			continue
+		}
+		log.Debugw("Bitswap engine: recoded blocks", "local", e.self, "to", p, "parent", w.parent, "count", len(blks))
+
+		for _, blk := range blks {
+			size := len(blk.RawData())
+			e.peerRequestQueue.PushTasks(p, peertask.Task{
+				Topic:    blk.Cid(),
+				Priority: int(w.priority),
+				Work:     size,
+				Data: &taskDataCoded{
+					taskData: taskData{
+						BlockSize:    size,
+						HaveBlock:    true,
+						IsWantBlock:  true,
+						SendDontHave: false,
+					},
+					Coding: w.coding,
+					Parent: w.parent,
+					Block:  blk,
+				},
+			})
+			pushed = true
+		}
+	}
+	if pushed {
+		e.signalNewWork()
 	}
 }
 
@@ -715,20 +767,148 @@ func (e *Engine) splitWantsCancels(es []bsmsg.Entry) ([]bsmsg.Entry, []bsmsg.Ent
 	return wants, cancels
 }
 
+// fetchFromNDN retrieves blocks that a peer asked for but that are not in
+// the blockstore from the NDN network. Wants for a block that is already
+// being fetched, for this peer or another one, join the pending fetch
+// instead of sending another Interest.
+func (e *Engine) fetchFromNDN(ctx context.Context, p peer.ID, entries []bsmsg.Entry) {
+	for _, entry := range entries {
+		fctx, f := e.ndnFetches.want(ctx, p, entry)
+		if f == nil {
+			log.Debugw("Bitswap engine: joined pending NDN fetch", "local", e.self, "from", p, "cid", entry.Cid)
+			continue
+		}
+		go e.runNDNFetch(fctx, entry.Cid, f)
+	}
+}
+
+// runNDNFetch fetches c from NDN, checks it against its CID, stores it and
+// queues it for every peer waiting for it. A block that cannot be fetched,
+// because of a Nack, a timeout or wrong content, is remembered for a while
+// and answered with DONT_HAVE to the peers that asked for it.
+func (e *Engine) runNDNFetch(ctx context.Context, c cid.Cid, f *ndnFetch) {
+	blk, err := e.fetchBlockFromNDN(ctx, c)
+	// The fetch did not fail if we gave up on it.
+	gaveUp := ctx.Err() != nil
+	waiters := e.ndnFetches.done(c, f)
+	failed := false
+	if err != nil {
+		log.Debugw("Bitswap engine: NDN fetch failed", "local", e.self, "cid", c, "error", err)
+		if gaveUp {
+			return
+		}
+		e.ndnMisses.add(c)
+		failed = true
+	} else if err := e.bs.Put(blk); err != nil {
+		log.Errorf("storing block fetched from NDN: %s", err)
+		failed = true
+	}
+	if len(waiters) == 0 {
+		return
+	}
+	log.Debugw("Bitswap engine: NDN fetch done", "local", e.self, "cid", c, "failed", failed, "peers", len(waiters))
+
+	pushed := false
+	for p, entry := range waiters {
+		if failed {
+			if !e.sendDontHaves || !entry.SendDontHave {
+				continue
+			}
+			e.peerRequestQueue.PushTasks(p, peertask.Task{
+				Topic:    c,
+				Priority: int(entry.Priority),
+				Work:     bsmsg.BlockPresenceSize(c),
+				Data: &taskData{
+					BlockSize:    0,
+					HaveBlock:    false,
+					IsWantBlock:  entry.WantType == pb.Message_Wantlist_Block,
+					SendDontHave: entry.SendDontHave,
+				},
+			})
+		} else {
+			e.peerRequestQueue.PushTasks(p, e.foundTask(p, entry, len(blk.RawData())))
+		}
+		pushed = true
+	}
+	if pushed {
+		e.signalNewWork()
+	}
+}
+
+// foundTask returns the task answering the want of p for a block of
+// blockSize bytes that is in the blockstore: the block itself, or a HAVE
+// for a want-have of a block too large to be sent in its place.
+func (e *Engine) foundTask(p peer.ID, entry bsmsg.Entry, blockSize int) peertask.Task {
+	isWantBlock := e.sendAsBlock(entry.WantType, blockSize)
+	log.Debugw("Bitswap engine: block found", "local", e.self, "from", p, "cid", entry.Cid, "isWantBlock", isWantBlock)
+
+	// entrySize is the amount of space the entry takes up in the
+	// message we send to the recipient. If we're sending a block, the
+	// entrySize is the size of the block. Otherwise it's the size of
+	// a block presence entry.
+	entrySize := blockSize
+	if !isWantBlock {
+		entrySize = bsmsg.BlockPresenceSize(entry.Cid)
+	}
+	return peertask.Task{
+		Topic:    entry.Cid,
+		Priority: int(entry.Priority),
+		Work:     entrySize,
+		Data: &taskData{
+			BlockSize:    blockSize,
+			HaveBlock:    true,
+			IsWantBlock:  isWantBlock,
+			SendDontHave: entry.SendDontHave,
+		},
+	}
+}
+
+// fetchBlockFromNDN retrieves the segments of a block, reassembles it and
+// verifies it against the multihash of c.
+func (e *Engine) fetchBlockFromNDN(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	data, err := e.ndn.FetchSegmented(ctx, e.ndnNames.Name(c))
+	if err != nil {
+		return nil, err
+	}
+	chk, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !chk.Equals(c) {
+		return nil, errNDNHashMismatch
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// ReceivedInvalidBlocks is called when n blocks received from a peer failed
+// verification and were dropped. The peer is reported to the score ledger if
+// it is an InvalidBlocksLedger, which lowers its score.
+func (e *Engine) ReceivedInvalidBlocks(from peer.ID, n int) {
+	if l, ok := e.scoreLedger.(InvalidBlocksLedger); ok {
+		l.AddToInvalidBlocks(from, n)
+	}
+}
+
 // ReceiveFrom is called when new blocks are received and added to the block
 // store, meaning there may be peers who want those blocks, so we should send
 // the blocks to them.
 //
 // This function also updates the receive side of the ledger.
 func (e *Engine) ReceiveFrom(from peer.ID, blks []blocks.Block, haves []cid.Cid, blkc []*blocks.CodedBlock) {
-        if len(blks) == 0 {
-	 	return
-	}
-
         if len(blks)+len(blkc) == 0 {
 		return
 	}
 
+	// The blocks we recode from changed.
+	if e.recoder != nil {
+		for _, blk := range blks {
+			e.recoder.invalidate(blk.Cid())
+		}
+		for _, blk := range blkc {
+			e.recoder.invalidate(blk.Parent())
+		}
+	}
+
 	if from != "" {
 		l := e.findOrCreate(from)
 		l.lk.Lock()
@@ -755,6 +935,7 @@ func (e *Engine) ReceiveFrom(from peer.ID, blks []blocks.Block, haves []cid.Cid,
 
 	// Check each peer to see if it wants one of the blocks we received
 	work := false
+	recodes := make(map[peer.ID][]recodeWant)
 	e.lock.RLock()
 
 	for p, l := range e.ledgerMap {
@@ -789,10 +970,33 @@ func (e *Engine) ReceiveFrom(from peer.ID, blks []blocks.Block, haves []cid.Cid,
 			}
 		}
 
+		// With a recoder, every block received of a coding that can be
+		// recoded, like "nc", lets us send one more innovative block of its
+		// parent, and raises the rank we tell the requester. Blocks of other
+		// codings are forwarded unchanged.
+		if e.recoder != nil {
+			counts := make(map[cid.Cid]int)
+			for _, b := range blkc {
+				counts[b.Parent()]++
+			}
+			for k, n := range counts {
+				if entry, ok := l.WantListContains(k); ok && entry.Count > 0 && e.recoder.canRecode(entry.Coding) {
+					if n > entry.Count {
+						n = entry.Count
+					}
+					recodes[p] = append(recodes[p], recodeWant{k, entry.Coding, entry.Priority, n, true})
+				}
+			}
+		}
+
 		for _, b := range blkc {
 			k := b.Parent()
 
-			if entry, ok := l.WantListContains(k); ok && entry.Count > 0 {
+			entry, ok := l.WantListContains(k)
+			if e.recoder != nil && e.recoder.canRecode(entry.Coding) {
+				continue
+			}
+			if ok && entry.Count > 0 {
 				work = true
 
 				blockSize := len(b.RawData())
@@ -813,7 +1017,7 @@ func (e *Engine) ReceiveFrom(from peer.ID, blks []blocks.Block, haves []cid.Cid,
 							HaveBlock:    true,
 							IsWantBlock:  isWantBlock,
 							SendDontHave: false,},
-						Coding: "nc",
+						Coding: entry.Coding,
 						Parent: k,
 					},
 				})
@@ -824,6 +1028,10 @@ func (e *Engine) ReceiveFrom(from peer.ID, blks []blocks.Block, haves []cid.Cid,
 	}
 	e.lock.RUnlock()
 
+	for p, wants := range recodes {
+		go e.sendRecoded(p, wants)
+	}
+
 	if work {
 		e.signalNewWork()
 	}
@@ -845,6 +1053,11 @@ func (e *Engine) MessageSent(p peer.ID, m bsmsg.BitSwapMessage) {
 	// Remove sent blocks from the want list for the peer
 	for _, block := range m.Blocks() {
 		e.scoreLedger.AddToSentBytes(l.Partner, len(block.RawData()))
+		// A coded block counts towards the coded want for its parent.
+		if cb, ok := block.(*blocks.CodedBlock); ok {
+			l.wantList.RemoveC(cb.Parent(), 1)
+			continue
+		}
 		l.wantList.RemoveType(block.Cid(), pb.Message_Wantlist_Block)
 	}
 
@@ -855,6 +1068,11 @@ func (e *Engine) MessageSent(p peer.ID, m bsmsg.BitSwapMessage) {
 			l.wantList.RemoveType(bp.Cid, pb.Message_Wantlist_Have)
 		}
 	}
+	for _, bp := range m.CodedHaves() {
+		if bp.Type == pb.Message_Have {
+			l.wantList.RemoveType(bp.Cid, pb.Message_Wantlist_Have)
+		}
+	}
 }
 
 // PeerConnected is called when a new peer connects, meaning we should start
@@ -877,6 +1095,7 @@ func (e *Engine) PeerDisconnected(p peer.ID) {
 	defer e.lock.Unlock()
 
 	delete(e.ledgerMap, p)
+	e.ndnFetches.retain(p, nil)
 
 	e.scoreLedger.PeerDisconnected(p)
 }
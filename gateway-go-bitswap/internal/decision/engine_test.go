@@ -13,6 +13,10 @@ import (
 	"github.com/ipfs/go-bitswap/internal/testutil"
 	message "github.com/ipfs/go-bitswap/message"
 	pb "github.com/ipfs/go-bitswap/message/pb"
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	"github.com/ipfs/go-bitswap/ndn/ndntest"
+	"github.com/ipfs/go-bitswap/wantlist"
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
@@ -123,7 +127,7 @@ func TestConsistentAccounting(t *testing.T) {
 
 		sender.Engine.MessageSent(receiver.Peer, m)
 		receiver.Engine.MessageReceived(ctx, sender.Peer, m)
-		receiver.Engine.ReceiveFrom(sender.Peer, m.Blocks(), nil)
+		receiver.Engine.ReceiveFrom(sender.Peer, m.Blocks(), nil, nil)
 	}
 
 	// Ensure sender records the change
@@ -899,7 +903,7 @@ func TestSendReceivedBlocksToPeersThatWantThem(t *testing.T) {
 	if err := bs.PutMany([]blocks.Block{blks[0], blks[2]}); err != nil {
 		t.Fatal(err)
 	}
-	e.ReceiveFrom(otherPeer, []blocks.Block{blks[0], blks[2]}, []cid.Cid{})
+	e.ReceiveFrom(otherPeer, []blocks.Block{blks[0], blks[2]}, []cid.Cid{}, nil)
 	_, env = getNextEnvelope(e, next, 5*time.Millisecond)
 	if env == nil {
 		t.Fatal("expected envelope")
@@ -962,7 +966,7 @@ func TestSendDontHave(t *testing.T) {
 	if err := bs.PutMany(blks); err != nil {
 		t.Fatal(err)
 	}
-	e.ReceiveFrom(otherPeer, blks, []cid.Cid{})
+	e.ReceiveFrom(otherPeer, blks, []cid.Cid{}, nil)
 
 	// Envelope should contain 2 HAVEs / 2 blocks
 	_, env = getNextEnvelope(e, next, 10*time.Millisecond)
@@ -981,6 +985,207 @@ func TestSendDontHave(t *testing.T) {
 	}
 }
 
+func TestFetchMissingBlockFromNDN(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	partner := libp2ptest.RandPeerIDFatal(t)
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	// Default sized chunks need many segments.
+	blks := testutil.GenerateBlocksOfSize(2, 256*1024)
+	fwd.ServeSegmented(cidname.Default.Name(blks[0].Cid()), blks[0].RawData())
+	// The second block is served with the wrong content.
+	fwd.ServeSegmented(cidname.Default.Name(blks[1].Cid()), blks[0].RawData())
+
+	e := newEngine(bs, 4, &fakePeerTagger{}, "localhost", 0, NewTestScoreLedger(shortTerm, nil))
+	e.SetNDNConsumer(ndn.NewConsumer(fwd.Dial), cidname.Default)
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	msg := message.New(false)
+	msg.AddEntry(blks[0].Cid(), 2, pb.Message_Wantlist_Block, true)
+	msg.AddEntry(blks[1].Cid(), 1, pb.Message_Wantlist_Block, true)
+	e.MessageReceived(ctx, partner, msg)
+
+	sent, presences := collectSent(t, e, partner, 2)
+	if _, ok := sent[blks[0].Cid()]; !ok || len(sent) != 1 {
+		t.Fatal("expected the block fetched from NDN")
+	}
+	if len(presences) != 1 || presences[blks[1].Cid()] != pb.Message_DontHave {
+		t.Fatal("expected DONT_HAVE for the block with mismatching content")
+	}
+	if has, _ := bs.Has(blks[0].Cid()); !has {
+		t.Fatal("expected block fetched from NDN to be stored")
+	}
+	if has, _ := bs.Has(blks[1].Cid()); has {
+		t.Fatal("block with mismatching content must not be stored")
+	}
+}
+
+func TestNDNFailuresSendDontHave(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	partner := libp2ptest.RandPeerIDFatal(t)
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	// blks[0] and blks[3] have no route, blks[1] is Nacked as a duplicate
+	// and nobody answers for blks[2].
+	blks := testutil.GenerateBlocksOfSize(4, 1024)
+	fwd.Nack(cidname.Default.Name(blks[1].Cid()), ndn.NackDuplicate)
+	fwd.Serve(cidname.Default.Name(blks[2].Cid()), func(*ndn.Interest) *ndn.Data { return nil })
+
+	e := newEngine(bs, 4, &fakePeerTagger{}, "localhost", 0, NewTestScoreLedger(shortTerm, nil))
+	e.SetNDNConsumer(ndn.NewConsumer(fwd.Dial, ndn.InterestLifetime(50*time.Millisecond), ndn.Retries(0)), cidname.Default)
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	msg := message.New(false)
+	msg.AddEntry(blks[0].Cid(), 4, pb.Message_Wantlist_Block, true)
+	msg.AddEntry(blks[1].Cid(), 3, pb.Message_Wantlist_Block, true)
+	msg.AddEntry(blks[2].Cid(), 2, pb.Message_Wantlist_Block, true)
+	// No DONT_HAVE was asked for this one.
+	msg.AddEntry(blks[3].Cid(), 1, pb.Message_Wantlist_Block, false)
+	e.MessageReceived(ctx, partner, msg)
+
+	sent, presences := collectSent(t, e, partner, 3)
+	if len(sent) != 0 {
+		t.Fatal("expected no blocks")
+	}
+	if len(presences) != 3 {
+		t.Fatal("expected DONT_HAVEs for the blocks NDN failed to fetch")
+	}
+	for _, blk := range blks[:3] {
+		if presences[blk.Cid()] != pb.Message_DontHave {
+			t.Fatal("expected DONT_HAVEs for the blocks NDN failed to fetch")
+		}
+	}
+
+	// The failures are cached: asking again, for the block or whether it
+	// is had, gets a DONT_HAVE without a new Interest.
+	first := cidname.Default.Name(blks[0].Cid()).Append(ndn.SegmentComponent(0))
+	interests := fwd.Interests(first)
+
+	msg = message.New(false)
+	msg.AddEntry(blks[0].Cid(), 2, pb.Message_Wantlist_Block, true)
+	msg.AddEntry(blks[1].Cid(), 1, pb.Message_Wantlist_Have, true)
+	e.MessageReceived(ctx, partner, msg)
+
+	_, presences = collectSent(t, e, partner, 2)
+	if len(presences) != 2 || presences[blks[0].Cid()] != pb.Message_DontHave || presences[blks[1].Cid()] != pb.Message_DontHave {
+		t.Fatal("expected DONT_HAVEs from the negative cache")
+	}
+	if n := fwd.Interests(first); n != interests {
+		t.Fatalf("expected no new Interest, got %d", n-interests)
+	}
+}
+
+func TestAggregateNDNFetches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	partners := []peer.ID{libp2ptest.RandPeerIDFatal(t), libp2ptest.RandPeerIDFatal(t)}
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	// The Data is held back until both peers asked for the block.
+	blk := testutil.GenerateBlocksOfSize(1, 1024)[0]
+	name := cidname.Default.Name(blk.Cid())
+	obj := &ndn.Segmented{Name: name, Content: blk.RawData()}
+	release := make(chan struct{})
+	fwd.Serve(name, func(i *ndn.Interest) *ndn.Data {
+		<-release
+		_, seg, _ := ndn.SplitSegmentName(i.Name)
+		return obj.Segment(seg)
+	})
+
+	e := newEngine(bs, 4, &fakePeerTagger{}, "localhost", 0, NewTestScoreLedger(shortTerm, nil))
+	e.SetNDNConsumer(ndn.NewConsumer(fwd.Dial), cidname.Default)
+	e.StartWorkers(ctx, process.WithTeardown(func() error { return nil }))
+
+	for _, p := range partners {
+		msg := message.New(false)
+		msg.AddEntry(blk.Cid(), 1, pb.Message_Wantlist_Block, true)
+		e.MessageReceived(ctx, p, msg)
+	}
+	if n := e.ndnFetches.len(); n != 1 {
+		t.Fatalf("expected 1 pending fetch, got %d", n)
+	}
+	close(release)
+
+	received := make(map[peer.ID]bool)
+	var next envChan
+	for len(received) < len(partners) {
+		var env *Envelope
+		next, env = getNextEnvelope(e, next, 5*time.Second)
+		if env == nil {
+			t.Fatal("expected the block to be sent to both peers")
+		}
+		if sent := env.Message.Blocks(); len(sent) != 1 || !sent[0].Cid().Equals(blk.Cid()) {
+			t.Fatal("expected the block fetched from NDN")
+		}
+		received[env.Peer] = true
+		env.Sent()
+	}
+	if n := fwd.Interests(name.Append(ndn.SegmentComponent(0))); n != 1 {
+		t.Fatalf("expected a single Interest, got %d", n)
+	}
+	if n := e.ndnFetches.len(); n != 0 {
+		t.Fatalf("expected no pending fetch, got %d", n)
+	}
+}
+
+func TestNDNFetchCancelsAreCounted(t *testing.T) {
+	fetches := newNDNFetches()
+	c := testutil.GenerateCids(1)[0]
+	entry := message.Entry{Entry: wantlist.Entry{Cid: c}}
+	p1, p2 := libp2ptest.RandPeerIDFatal(t), libp2ptest.RandPeerIDFatal(t)
+
+	ctx, f := fetches.want(context.Background(), p1, entry)
+	if f == nil {
+		t.Fatal("expected a new fetch")
+	}
+	if _, f2 := fetches.want(context.Background(), p2, entry); f2 != nil {
+		t.Fatal("expected the second want to join the pending fetch")
+	}
+
+	fetches.cancel(p1, c)
+	if ctx.Err() != nil || fetches.len() != 1 {
+		t.Fatal("expected the fetch to go on while a peer waits for it")
+	}
+	// A full wantlist without the block cancels the want.
+	fetches.retain(p2, cid.NewSet())
+	if ctx.Err() == nil || fetches.len() != 0 {
+		t.Fatal("expected the fetch to be canceled once nobody waits for it")
+	}
+	if waiters := fetches.done(c, f); waiters != nil {
+		t.Fatal("expected no waiters for a canceled fetch")
+	}
+}
+
+func TestNegativeCacheExpires(t *testing.T) {
+	nc := newNegativeCache(20 * time.Millisecond)
+	c := testutil.GenerateCids(1)[0]
+	if nc.has(c) {
+		t.Fatal("expected an empty cache")
+	}
+	nc.add(c)
+	if !nc.has(c) {
+		t.Fatal("expected the CID to be cached")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if nc.has(c) {
+		t.Fatal("expected the entry to expire")
+	}
+}
+
 func TestWantlistForPeer(t *testing.T) {
 	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
 	partner := libp2ptest.RandPeerIDFatal(t)
@@ -1132,6 +1337,33 @@ func partnerCancels(e *Engine, keys []string, partner peer.ID) {
 	e.MessageReceived(context.Background(), partner, cancels)
 }
 
+// collectSent receives the envelopes sent to p until n blocks and block
+// presences arrived. It does not wait for more: the task worker whose
+// envelope channel it took would keep the next envelope from later calls.
+func collectSent(t *testing.T, e *Engine, p peer.ID, n int) (map[cid.Cid]blocks.Block, map[cid.Cid]pb.Message_BlockPresenceType) {
+	sent := make(map[cid.Cid]blocks.Block)
+	presences := make(map[cid.Cid]pb.Message_BlockPresenceType)
+	var next envChan
+	for len(sent)+len(presences) < n {
+		var env *Envelope
+		next, env = getNextEnvelope(e, next, time.Second)
+		if env == nil {
+			t.Fatal("expected envelope")
+		}
+		if env.Peer != p {
+			t.Fatal("expected message to peer")
+		}
+		for _, blk := range env.Message.Blocks() {
+			sent[blk.Cid()] = blk
+		}
+		for _, bp := range env.Message.BlockPresences() {
+			presences[bp.Cid] = bp.Type
+		}
+		env.Sent()
+	}
+	return sent, presences
+}
+
 type envChan <-chan *Envelope
 
 func getNextEnvelope(e *Engine, next envChan, t time.Duration) (envChan, *Envelope) {
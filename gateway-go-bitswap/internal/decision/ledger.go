@@ -28,10 +28,10 @@ type ledger struct {
 	lk sync.RWMutex
 }
 
-func (l *ledger) Wants(k cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType,coding string, count int) {
+func (l *ledger) Wants(k cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, coding string, count int, rank int) {
 	log.Debugf("peer %s wants %s", l.Partner, k)
 	if count > 0 {
-		l.wantList.AddC(k, priority, wantType, coding, count)
+		l.wantList.AddC(k, priority, wantType, coding, count, rank)
 	} else {
 		l.wantList.Add(k, priority, wantType)
 	}
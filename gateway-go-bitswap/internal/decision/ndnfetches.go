@@ -0,0 +1,106 @@
+package decision
+
+import (
+	"context"
+	"sync"
+
+	bsmsg "github.com/ipfs/go-bitswap/message"
+	pb "github.com/ipfs/go-bitswap/message/pb"
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ndnFetches is the table of blocks being fetched from NDN. Like an NDN PIT
+// it collapses concurrent wants for one block, from any number of peers,
+// into a single fetch whose result goes to every peer still waiting. A fetch
+// is canceled once the last peer waiting for it cancels its want.
+type ndnFetches struct {
+	lk      sync.Mutex
+	pending map[cid.Cid]*ndnFetch
+}
+
+// ndnFetch is a pending fetch and the wants of the peers waiting for it.
+type ndnFetch struct {
+	waiters map[peer.ID]bsmsg.Entry
+	cancel  context.CancelFunc
+}
+
+func newNDNFetches() *ndnFetches {
+	return &ndnFetches{pending: make(map[cid.Cid]*ndnFetch)}
+}
+
+// want adds p to the peers waiting for the block of entry. If the block is
+// not being fetched yet, it returns the new fetch and the context to run it
+// with, otherwise nil.
+func (t *ndnFetches) want(ctx context.Context, p peer.ID, entry bsmsg.Entry) (context.Context, *ndnFetch) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if f, ok := t.pending[entry.Cid]; ok {
+		// A want-have does not replace a want-block for the same block.
+		if prev, ok := f.waiters[p]; !ok || prev.WantType != pb.Message_Wantlist_Block {
+			f.waiters[p] = entry
+		}
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	f := &ndnFetch{
+		waiters: map[peer.ID]bsmsg.Entry{p: entry},
+		cancel:  cancel,
+	}
+	t.pending[entry.Cid] = f
+	return ctx, f
+}
+
+// cancel removes p from the peers waiting for c, and cancels the fetch once
+// nobody waits for it anymore.
+func (t *ndnFetches) cancel(p peer.ID, c cid.Cid) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	t.removeWaiter(p, c)
+}
+
+// retain cancels the wants of p for the blocks that are not in keep, or all
+// of them if keep is nil.
+func (t *ndnFetches) retain(p peer.ID, keep *cid.Set) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	for c, f := range t.pending {
+		if _, ok := f.waiters[p]; ok && (keep == nil || !keep.Has(c)) {
+			t.removeWaiter(p, c)
+		}
+	}
+}
+
+func (t *ndnFetches) removeWaiter(p peer.ID, c cid.Cid) {
+	f, ok := t.pending[c]
+	if !ok {
+		return
+	}
+	delete(f.waiters, p)
+	if len(f.waiters) == 0 {
+		f.cancel()
+		delete(t.pending, c)
+	}
+}
+
+// done removes the completed fetch f of c and returns the wants of the peers
+// waiting for it. It returns nil if the fetch was canceled.
+func (t *ndnFetches) done(c cid.Cid, f *ndnFetch) map[peer.ID]bsmsg.Entry {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	f.cancel()
+	if t.pending[c] != f {
+		return nil
+	}
+	delete(t.pending, c)
+	return f.waiters
+}
+
+// len returns the number of blocks being fetched.
+func (t *ndnFetches) len() int {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	return len(t.pending)
+}
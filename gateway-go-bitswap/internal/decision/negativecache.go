@@ -0,0 +1,64 @@
+package decision
+
+import (
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+const (
+	// ndnNegativeCacheTTL is how long a block that could not be fetched
+	// from NDN is answered with DONT_HAVE without asking NDN again.
+	ndnNegativeCacheTTL = 10 * time.Second
+
+	// negativeCachePruneSize is the number of entries above which expired
+	// entries are dropped when adding a new one.
+	negativeCachePruneSize = 1024
+)
+
+// negativeCache remembers for a while the blocks that could not be found.
+type negativeCache struct {
+	lk      sync.Mutex
+	ttl     time.Duration
+	expires map[cid.Cid]time.Time
+}
+
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	return &negativeCache{
+		ttl:     ttl,
+		expires: make(map[cid.Cid]time.Time),
+	}
+}
+
+// add records that c could not be found.
+func (nc *negativeCache) add(c cid.Cid) {
+	nc.lk.Lock()
+	defer nc.lk.Unlock()
+
+	now := time.Now()
+	if len(nc.expires) >= negativeCachePruneSize {
+		for k, exp := range nc.expires {
+			if now.After(exp) {
+				delete(nc.expires, k)
+			}
+		}
+	}
+	nc.expires[c] = now.Add(nc.ttl)
+}
+
+// has reports whether c could not be found within the last ttl.
+func (nc *negativeCache) has(c cid.Cid) bool {
+	nc.lk.Lock()
+	defer nc.lk.Unlock()
+
+	exp, ok := nc.expires[c]
+	if !ok {
+		return false
+	}
+	if time.Now().After(exp) {
+		delete(nc.expires, c)
+		return false
+	}
+	return true
+}
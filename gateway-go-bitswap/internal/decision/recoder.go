@@ -0,0 +1,245 @@
+package decision
+
+import (
+	"sync"
+
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	// Register the built-in coding schemes.
+	_ "github.com/ipfs/go-block-format/coding/rlnc"
+	_ "github.com/ipfs/go-block-format/coding/rs"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+// CodedIndex lists the coded blocks held for a parent block.
+type CodedIndex interface {
+	// Coded returns the CIDs of the blocks coded from parent with coding.
+	// It returns no CIDs, and no error, when there are none.
+	Coded(parent cid.Cid, coding string) ([]cid.Cid, error)
+}
+
+// maxRecodeGenerations is the number of generations whose packets the
+// recoder keeps in memory.
+const maxRecodeGenerations = 64
+
+// recoder makes new coded blocks of a parent out of the ones in the
+// blockstore, for the coding schemes that are a coding.Recoder, like "nc".
+// Every block it returns is a fresh random combination, so peers asking for
+// the same parent never get the same packets, and each of them is innovative
+// as long as the requester has fewer packets than we do.
+//
+// The innovative packets of the last generations recoded from are kept in
+// memory, with a decoder telling their rank, so that the blockstore is not
+// read for every want. They are dropped whenever a block of the generation
+// is received.
+type recoder struct {
+	bs    bstore.Blockstore
+	index CodedIndex
+
+	lk          sync.Mutex
+	generations map[cid.Cid]map[string]*recodeGeneration
+	// order lists the parents of generations from the oldest loaded.
+	order []cid.Cid
+}
+
+// recodeGeneration holds the innovative coded blocks of a parent.
+type recodeGeneration struct {
+	load sync.Once
+	err  error
+
+	header  *coding.Header
+	decoder coding.Decoder
+	packets [][]byte
+	prefix  cid.Prefix
+}
+
+func newRecoder(bs bstore.Blockstore, index CodedIndex) *recoder {
+	return &recoder{
+		bs:          bs,
+		index:       index,
+		generations: make(map[cid.Cid]map[string]*recodeGeneration),
+	}
+}
+
+// canRecode reports whether blocks coded with cd can be recoded.
+func (r *recoder) canRecode(cd string) bool {
+	_, ok := recoderFor(cd)
+	return ok
+}
+
+func recoderFor(cd string) (coding.Recoder, bool) {
+	scheme, err := coding.Lookup(cd)
+	if err != nil {
+		return nil, false
+	}
+	rc, ok := scheme.(coding.Recoder)
+	return rc, ok
+}
+
+// header returns the header of parent, or nil when we do not hold it.
+func (r *recoder) header(parent cid.Cid) *coding.Header {
+	blk, err := r.bs.Get(parent)
+	if err != nil {
+		return nil
+	}
+	h, err := codedleaf.Header(blk.RawData())
+	if err != nil {
+		return nil
+	}
+	return h
+}
+
+// generationSize returns the number of source symbols of the generation of
+// parent, or 0 when we do not hold its header.
+func (r *recoder) generationSize(parent cid.Cid) int {
+	if h := r.header(parent); h != nil {
+		return h.GenerationSize
+	}
+	return 0
+}
+
+// invalidate drops the packets kept for parent, after receiving a block
+// coded from it or parent itself.
+func (r *recoder) invalidate(parent cid.Cid) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if _, ok := r.generations[parent]; !ok {
+		return
+	}
+	delete(r.generations, parent)
+	for i, c := range r.order {
+		if c == parent {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// generation returns the innovative blocks of parent coded with cd, reading
+// them from the blockstore unless they are kept in memory.
+func (r *recoder) generation(parent cid.Cid, cd string) (*recodeGeneration, error) {
+	r.lk.Lock()
+	gens, ok := r.generations[parent]
+	if !ok {
+		if len(r.order) == maxRecodeGenerations {
+			delete(r.generations, r.order[0])
+			r.order = r.order[1:]
+		}
+		gens = make(map[string]*recodeGeneration)
+		r.generations[parent] = gens
+		r.order = append(r.order, parent)
+	}
+	g, ok := gens[cd]
+	if !ok {
+		g = &recodeGeneration{}
+		gens[cd] = g
+	}
+	r.lk.Unlock()
+
+	g.load.Do(func() {
+		g.err = r.load(g, parent, cd)
+	})
+	return g, g.err
+}
+
+// load reads the blocks of parent coded with cd from the blockstore, and
+// keeps those that are innovative.
+func (r *recoder) load(g *recodeGeneration, parent cid.Cid, cd string) error {
+	scheme, err := coding.Lookup(cd)
+	if err != nil {
+		return err
+	}
+	cids, err := r.index.Coded(parent, cd)
+	if err != nil {
+		return err
+	}
+
+	// The header of the parent, when we hold it, tells which generation
+	// the packets must belong to. Otherwise the first packet does.
+	g.header = r.header(parent)
+	if g.header != nil {
+		if g.decoder, err = scheme.NewDecoder(g.header); err != nil {
+			return err
+		}
+	}
+	for _, c := range cids {
+		blk, err := r.bs.Get(c)
+		if err == bstore.ErrNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		data, err := codedleaf.Packet(blk.RawData())
+		if err == nil && g.decoder == nil {
+			g.decoder, err = scheme.DecoderFor(data)
+		}
+		if err != nil {
+			log.Debugw("Bitswap engine: not recoding block", "cid", c, "error", err)
+			continue
+		}
+		innovative, err := g.decoder.Add(data)
+		if err != nil {
+			log.Debugw("Bitswap engine: not recoding block", "cid", c, "error", err)
+			continue
+		}
+		if !innovative {
+			continue
+		}
+		if len(g.packets) == 0 {
+			g.prefix = c.Prefix()
+		}
+		g.packets = append(g.packets, data)
+	}
+	return nil
+}
+
+// rank returns the rank of the blocks of parent coded with cd we hold,
+// that is the number of innovative blocks we can recode.
+func (r *recoder) rank(parent cid.Cid, cd string) (int, error) {
+	g, err := r.generation(parent, cd)
+	if err != nil || g.decoder == nil {
+		return 0, err
+	}
+	return g.decoder.Rank(), nil
+}
+
+// recode returns up to n recoded blocks of parent coded with cd. It returns
+// fewer when the blocks of parent we hold have a lower rank, as more would
+// not be innovative, and none when we hold none or cd cannot be recoded.
+func (r *recoder) recode(parent cid.Cid, cd string, n int) ([]*blocks.CodedBlock, error) {
+	rc, ok := recoderFor(cd)
+	if !ok {
+		return nil, nil
+	}
+	g, err := r.generation(parent, cd)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.packets) == 0 {
+		return nil, nil
+	}
+
+	recoded, err := rc.Recode(g.header, g.packets, n)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*blocks.CodedBlock, 0, len(recoded))
+	for _, p := range recoded {
+		leaf := codedleaf.FromPacket(p)
+		c, err := g.prefix.Sum(leaf)
+		if err != nil {
+			return nil, err
+		}
+		blk, err := blocks.NewCodedBlockWithCid(leaf, c, parent)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, blk)
+	}
+	return out, nil
+}
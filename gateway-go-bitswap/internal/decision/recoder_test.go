@@ -0,0 +1,161 @@
+package decision
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-block-format/coding/rlnc"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+type fakeCodedIndex map[cid.Cid][]cid.Cid
+
+func (idx fakeCodedIndex) Coded(parent cid.Cid, coding string) ([]cid.Cid, error) {
+	if coding != "nc" {
+		return nil, nil
+	}
+	return idx[parent], nil
+}
+
+func TestRecode(t *testing.T) {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	parent := blocks.NewBlock([]byte("parent")).Cid()
+	idx := fakeCodedIndex{}
+
+	data := make([]byte, 8*100)
+	rand.Read(data)
+	enc, err := rlnc.NewEncoder(data, 8, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var held []*rlnc.Packet
+	for i := 0; i < 5; i++ {
+		p := enc.Encode()
+		held = append(held, p)
+		blk := blocks.NewBlock(codedleaf.FromPacket(p.Marshal()))
+		if err := bs.Put(blk); err != nil {
+			t.Fatal(err)
+		}
+		idx[parent] = append(idx[parent], blk.Cid())
+	}
+
+	r := newRecoder(bs, idx)
+	blks, err := r.recode(parent, "nc", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No more than we hold would be innovative.
+	if len(blks) != 5 {
+		t.Fatalf("expected 5 recoded blocks, got %d", len(blks))
+	}
+
+	// Every recoded block is a combination of the held ones.
+	dec, _ := rlnc.NewDecoder(8, 100)
+	for _, p := range held {
+		dec.Add(p)
+	}
+	seen := cid.NewSet()
+	for _, blk := range blks {
+		if blk.Parent() != parent {
+			t.Fatal("recoded block has the wrong parent")
+		}
+		if !seen.Visit(blk.Cid()) {
+			t.Fatal("expected different recoded blocks")
+		}
+		if has, _ := bs.Has(blk.Cid()); has {
+			t.Fatal("expected a new block")
+		}
+		chk, err := blk.Cid().Prefix().Sum(blk.RawData())
+		if err != nil || !chk.Equals(blk.Cid()) {
+			t.Fatal("recoded block does not match its CID")
+		}
+		data, err := codedleaf.Packet(blk.RawData())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := rlnc.Unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if innovative, err := dec.Add(p); err != nil || innovative {
+			t.Fatalf("recoded block is not a combination of the held ones: %t, %v", innovative, err)
+		}
+	}
+
+	// With the parent held, its header tells the packets of another
+	// generation apart, even when they come first.
+	other, _ := rlnc.NewEncoder(data, 10, 80)
+	stray := blocks.NewBlock(codedleaf.FromPacket(other.Encode().Marshal()))
+	if err := bs.Put(stray); err != nil {
+		t.Fatal(err)
+	}
+	idx[parent] = append([]cid.Cid{stray.Cid()}, idx[parent]...)
+	header := &coding.Header{Scheme: "nc", Field: "gf256", GenerationSize: 8, SymbolSize: 100, Length: 800}
+	parentBlk := blocks.NewBlock(codedleaf.FromPacket(header.Marshal()))
+	if err := bs.Put(parentBlk); err != nil {
+		t.Fatal(err)
+	}
+	idx[parentBlk.Cid()] = idx[parent]
+	blks, err = r.recode(parentBlk.Cid(), "nc", 10)
+	if err != nil || len(blks) != 5 {
+		t.Fatalf("expected 5 recoded blocks, got %d, %v", len(blks), err)
+	}
+
+	if size := r.generationSize(parentBlk.Cid()); size != 8 {
+		t.Fatalf("expected a generation size of 8, got %d", size)
+	}
+	if size := r.generationSize(parent); size != 0 {
+		t.Fatalf("expected no generation size without a header, got %d", size)
+	}
+	if rank, err := r.rank(parentBlk.Cid(), "nc"); err != nil || rank != 5 {
+		t.Fatalf("expected rank 5, got %d, %v", rank, err)
+	}
+
+	// Blocks that are not innovative do not raise the rank.
+	for _, blk := range blks[:2] {
+		if err := bs.Put(blk); err != nil {
+			t.Fatal(err)
+		}
+		idx[parentBlk.Cid()] = append(idx[parentBlk.Cid()], blk.Cid())
+	}
+	r.invalidate(parentBlk.Cid())
+	if rank, err := r.rank(parentBlk.Cid(), "nc"); err != nil || rank != 5 {
+		t.Fatalf("expected recoded blocks to keep rank 5, got %d, %v", rank, err)
+	}
+
+	// The blocks are kept in memory until a block of the generation is
+	// received.
+	p := enc.Encode()
+	blk := blocks.NewBlock(codedleaf.FromPacket(p.Marshal()))
+	if err := bs.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+	idx[parentBlk.Cid()] = append(idx[parentBlk.Cid()], blk.Cid())
+	if rank, err := r.rank(parentBlk.Cid(), "nc"); err != nil || rank != 5 {
+		t.Fatalf("expected the kept rank 5, got %d, %v", rank, err)
+	}
+	r.invalidate(parentBlk.Cid())
+	if rank, err := r.rank(parentBlk.Cid(), "nc"); err != nil || rank != 6 {
+		t.Fatalf("expected rank 6 once invalidated, got %d, %v", rank, err)
+	}
+
+	blks, err = r.recode(blocks.NewBlock([]byte("other")).Cid(), "nc", 3)
+	if err != nil || len(blks) != 0 {
+		t.Fatalf("expected no blocks for an unknown parent, got %d, %v", len(blks), err)
+	}
+
+	// Only the schemes that are a coding.Recoder are recoded.
+	if !r.canRecode("nc") || r.canRecode("rs") || r.canRecode("unknown") {
+		t.Fatal("expected only nc to be recoded")
+	}
+	blks, err = r.recode(parentBlk.Cid(), "rs", 3)
+	if err != nil || len(blks) != 0 {
+		t.Fatalf("expected no rs blocks to be recoded, got %d, %v", len(blks), err)
+	}
+}
@@ -53,6 +53,10 @@ type scoreledger struct {
 	// exchangeCount is the number of exchanges with this peer
 	exchangeCount uint64
 
+	// invalidBlocks is the number of blocks received from this peer that
+	// failed verification.
+	invalidBlocks uint64
+
 	// the record lock
 	lock sync.RWMutex
 }
@@ -86,6 +90,13 @@ func (l *scoreledger) AddToReceivedBytes(n int) {
 	l.bytesRecv += uint64(n)
 }
 
+// Increments the invalid blocks counter.
+func (l *scoreledger) AddToInvalidBlocks(n int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.invalidBlocks += uint64(n)
+}
+
 // Returns the Receipt for this ledger record.
 func (l *scoreledger) Receipt() *Receipt {
 	l.lock.RLock()
@@ -100,6 +111,8 @@ func (l *scoreledger) Receipt() *Receipt {
 	}
 }
 
+var _ InvalidBlocksLedger = (*DefaultScoreLedger)(nil)
+
 // DefaultScoreLedger is used by Engine as the default ScoreLedger.
 type DefaultScoreLedger struct {
 	// the score func
@@ -132,7 +145,9 @@ type DefaultScoreLedger struct {
 //
 // To calculate the final score, we sum the short-term and long-term scores then
 // adjust it ±25% based on our debt ratio. Peers that have historically been
-// more useful to us than we are to them get the highest score.
+// more useful to us than we are to them get the highest score. Peers that
+// sent us blocks failing verification get a fraction of it, smaller the more
+// of them they sent.
 func (dsl *DefaultScoreLedger) scoreWorker() {
 	ticker := time.NewTicker(dsl.peerSampleInterval)
 	defer ticker.Stop()
@@ -189,7 +204,7 @@ func (dsl *DefaultScoreLedger) scoreWorker() {
 			} else {
 				lscore = float64(l.bytesRecv) / float64(l.bytesRecv+l.bytesSent)
 			}
-			score := int((l.shortScore + l.longScore) * (lscore*.5 + .75))
+			score := int((l.shortScore + l.longScore) * (lscore*.5 + .75) / float64(1+l.invalidBlocks))
 
 			// Avoid updating the connection manager unless there's a change. This can be expensive.
 			if l.score != score {
@@ -308,6 +323,12 @@ func (dsl *DefaultScoreLedger) AddToReceivedBytes(p peer.ID, n int) {
 	l.AddToReceivedBytes(n)
 }
 
+// Increments the invalid blocks counter for the given peer.
+func (dsl *DefaultScoreLedger) AddToInvalidBlocks(p peer.ID, n int) {
+	l := dsl.findOrCreate(p)
+	l.AddToInvalidBlocks(n)
+}
+
 // PeerConnected should be called when a new peer connects, meaning
 // we should open accounting.
 func (dsl *DefaultScoreLedger) PeerConnected(p peer.ID) {
@@ -2,6 +2,7 @@ package decision
 
 import (
 	"fmt"
+	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-peertaskqueue/peertask"
 )
@@ -24,6 +25,20 @@ type taskDataCoded struct {
 	taskData
 	Coding string
 	Parent cid.Cid
+	// Block is the block to send when it was recoded for this task, and
+	// so is not in the blockstore.
+	Block *blocks.CodedBlock
+	// Rank is the number of coded blocks of Parent we hold, sent with a
+	// coded HAVE.
+	Rank int
+}
+
+// dataOf returns the task data of a coded or uncoded task.
+func dataOf(task peertask.Task) *taskData {
+	if td, ok := task.Data.(*taskDataCoded); ok {
+		return &td.taskData
+	}
+	return task.Data.(*taskData)
 }
 
 
@@ -39,7 +54,7 @@ func (*taskMerger) HasNewInfo(task peertask.Task, existing []peertask.Task) bool
 	haveSize := false
 	isWantBlock := false
 	for _, et := range existing {
-		etd := et.Data.(*taskData)
+		etd := dataOf(et)
 		if etd.HaveBlock {
 			haveSize = true
 		}
@@ -51,7 +66,7 @@ func (*taskMerger) HasNewInfo(task peertask.Task, existing []peertask.Task) bool
 
 	// If there is no active want-block and the new task is a want-block,
 	// the new task is better
-	newTaskData := task.Data.(*taskData)
+	newTaskData := dataOf(task)
 	if !isWantBlock && newTaskData.IsWantBlock {
 		return true
 	}
@@ -68,14 +83,15 @@ func (*taskMerger) HasNewInfo(task peertask.Task, existing []peertask.Task) bool
 // The request queue uses Merge to merge a newly pushed task with an existing
 // task with the same Topic (CID)
 func (*taskMerger) Merge(task peertask.Task, existing *peertask.Task) {
-	var newTask *taskData
-	if nt ,ok :=task.Data.(*taskDataCoded); ok{
-		newTask=&nt.taskData
-	} else {	newTask = task.Data.(*taskData)}
-	var existingTask *taskData
-	if nt ,ok :=existing.Data.(*taskDataCoded); ok{
-		existingTask=&nt.taskData
-	} else {	existingTask = existing.Data.(*taskData)}
+	newTask := dataOf(task)
+	existingTask := dataOf(*existing)
+
+	// A coded HAVE carries our latest rank
+	if nt, ok := task.Data.(*taskDataCoded); ok {
+		if et, ok := existing.Data.(*taskDataCoded); ok && nt.Rank > et.Rank {
+			et.Rank = nt.Rank
+		}
+	}
 
 
 	// If we now have block size information, update the task with
@@ -104,12 +120,12 @@ func (*taskMerger) Merge(task peertask.Task, existing *peertask.Task) {
 	}
 }
 
-// Returns Parent CID if task is coded
+// Returns Parent CID if task sends a coded block
 func (*taskMerger) GetParent(task peertask.Task) (peertask.Topic, bool) {
 	d, ok := task.Data.(*taskDataCoded)
 	var p peertask.Topic
-	if !ok{
-		return p, ok
+	if !ok || !d.IsWantBlock {
+		return p, false
 	}
 	fmt.Println("Debug: tm-getparent", d.Parent, ok)
 	return d.Parent, ok
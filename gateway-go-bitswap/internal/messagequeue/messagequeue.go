@@ -126,9 +126,9 @@ func (r *recallWantlist) Add(c cid.Cid, priority int32, wtype pb.Message_Wantlis
 }
 
 // Add want to the pending list
-func (r *recallWantlist) AddC(c cid.Cid, priority int32, wtype pb.Message_Wantlist_WantType, coding string, count int) {
+func (r *recallWantlist) AddC(c cid.Cid, priority int32, wtype pb.Message_Wantlist_WantType, coding string, count int, rank int) {
 //	fmt.Println("Debug: mw-addc", count)
-	r.pending.AddC(c, priority, wtype, coding, count)
+	r.pending.AddC(c, priority, wtype, coding, count, rank)
 }
 
 // Remove wants from both the pending list and the list of sent wants
@@ -320,12 +320,13 @@ func (mq *MessageQueue) AddWants(wantBlocks []cid.Cid, wantHaves []cid.Cid, want
 	for _, c := range wantCodeds {
 //		fmt.Println("Debug: mq-addwants cid to codedwants", c)
 		mq.codedWants.Add(c.Cid)
-		mq.peerWants.AddC(c.Cid, mq.priority, pb.Message_Wantlist_Block, c.Coding, c.Count)
+		mq.peerWants.AddC(c.Cid, mq.priority, pb.Message_Wantlist_Block, c.Coding, c.Count, c.Rank)
 		mq.priority--
 
 		// We're adding a want-block for the cid, so clear any pending cancel
 		// for the cid
 		mq.cancels.Remove(c.Cid)
+		mq.codedCancels.Remove(c.Cid)
 	}
 
 	// Schedule a message send
@@ -379,9 +380,9 @@ func (mq *MessageQueue) AddCancels(cancelKs []cid.Cid) {
 	}
 }
 
-func (mq *MessageQueue) AddCancelC(key cid.Cid, count int) {
-	// MARS todo add coded cancel
-	mq.codedCancels.AddC(key, mq.priority, pb.Message_Wantlist_Block, "nc", count)
+// AddCancelC adds a cancel for count coded blocks of key with coding.
+func (mq *MessageQueue) AddCancelC(key cid.Cid, coding string, count int) {
+	mq.codedCancels.AddC(key, mq.priority, pb.Message_Wantlist_Block, coding, count, 0)
 	mq.signalWorkReady()
 }
 
@@ -787,7 +788,7 @@ func (mq *MessageQueue) extractOutgoingMessage(supportsHave bool) (bsmsg.BitSwap
 		if e.Count > 0 {
 			mq.codedWants.Remove(e.Cid)
 		}
-		msgSize += mq.msg.AddCodedEntry(e.Cid, e.Priority, e.WantType, true, e.Coding, e.Count)
+		msgSize += mq.msg.AddCodedEntry(e.Cid, e.Priority, e.WantType, true, e.Coding, e.Count, e.Rank)
 		sentPeerEntries++
 
 		if msgSize >= mq.maxMessageSize {
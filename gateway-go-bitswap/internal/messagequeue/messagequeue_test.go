@@ -197,8 +197,8 @@ func TestSendingMessagesDeduped(t *testing.T) {
 	wantBlocks := testutil.GenerateCids(10)
 
 	messageQueue.Startup()
-	messageQueue.AddWants(wantBlocks, wantHaves)
-	messageQueue.AddWants(wantBlocks, wantHaves)
+	messageQueue.AddWants(wantBlocks, wantHaves, nil)
+	messageQueue.AddWants(wantBlocks, wantHaves, nil)
 	messages := collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 
 	if totalEntriesLength(messages) != len(wantHaves)+len(wantBlocks) {
@@ -218,8 +218,8 @@ func TestSendingMessagesPartialDupe(t *testing.T) {
 	wantBlocks := testutil.GenerateCids(10)
 
 	messageQueue.Startup()
-	messageQueue.AddWants(wantBlocks[:8], wantHaves[:8])
-	messageQueue.AddWants(wantBlocks[3:], wantHaves[3:])
+	messageQueue.AddWants(wantBlocks[:8], wantHaves[:8], nil)
+	messageQueue.AddWants(wantBlocks[3:], wantHaves[3:], nil)
 	messages := collectMessages(ctx, t, messagesSent, 20*time.Millisecond)
 
 	if totalEntriesLength(messages) != len(wantHaves)+len(wantBlocks) {
@@ -243,8 +243,8 @@ func TestSendingMessagesPriority(t *testing.T) {
 	wantBlocks := append(wantBlocks1, wantBlocks2...)
 
 	messageQueue.Startup()
-	messageQueue.AddWants(wantBlocks1, wantHaves1)
-	messageQueue.AddWants(wantBlocks2, wantHaves2)
+	messageQueue.AddWants(wantBlocks1, wantHaves1, nil)
+	messageQueue.AddWants(wantBlocks2, wantHaves2, nil)
 	messages := collectMessages(ctx, t, messagesSent, 20*time.Millisecond)
 
 	if totalEntriesLength(messages) != len(wantHaves)+len(wantBlocks) {
@@ -308,7 +308,7 @@ func TestCancelOverridesPendingWants(t *testing.T) {
 	cancels := []cid.Cid{wantBlocks[0], wantHaves[0]}
 
 	messageQueue.Startup()
-	messageQueue.AddWants(wantBlocks, wantHaves)
+	messageQueue.AddWants(wantBlocks, wantHaves, nil)
 	messageQueue.AddCancels(cancels)
 	messages := collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 
@@ -360,7 +360,7 @@ func TestWantOverridesPendingCancels(t *testing.T) {
 	messageQueue.Startup()
 
 	// Add 1 want-block and 2 want-haves
-	messageQueue.AddWants(wantBlocks, wantHaves)
+	messageQueue.AddWants(wantBlocks, wantHaves, nil)
 
 	messages := collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 	if totalEntriesLength(messages) != len(wantBlocks)+len(wantHaves) {
@@ -370,7 +370,7 @@ func TestWantOverridesPendingCancels(t *testing.T) {
 	// Cancel existing wants
 	messageQueue.AddCancels(cids)
 	// Override one cancel with a want-block (before cancel is sent to network)
-	messageQueue.AddWants(cids[:1], []cid.Cid{})
+	messageQueue.AddWants(cids[:1], []cid.Cid{}, nil)
 
 	messages = collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 	if totalEntriesLength(messages) != 3 {
@@ -433,7 +433,7 @@ func TestWantlistRebroadcast(t *testing.T) {
 	// interfere with the next message collection), then send out some
 	// regular wants and collect them
 	messageQueue.SetRebroadcastInterval(1 * time.Second)
-	messageQueue.AddWants(wantBlocks, wantHaves)
+	messageQueue.AddWants(wantBlocks, wantHaves, nil)
 	messages = collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 	if len(messages) != 1 {
 		t.Fatal("wrong number of messages were rebroadcast")
@@ -500,7 +500,7 @@ func TestSendingLargeMessages(t *testing.T) {
 	messageQueue := newMessageQueue(ctx, peerID, fakenet, maxMsgSize, sendErrorBackoff, maxValidLatency, dhtm)
 
 	messageQueue.Startup()
-	messageQueue.AddWants(wantBlocks, []cid.Cid{})
+	messageQueue.AddWants(wantBlocks, []cid.Cid{}, nil)
 	messages := collectMessages(ctx, t, messagesSent, 100*time.Millisecond)
 
 	// want-block has size 44, so with maxMsgSize 44 * 3 (3 want-blocks), then if
@@ -551,7 +551,7 @@ func TestSendToPeerThatDoesntSupportHave(t *testing.T) {
 	// Check regular want-haves and want-blocks
 	wbs := testutil.GenerateCids(10)
 	whs := testutil.GenerateCids(10)
-	messageQueue.AddWants(wbs, whs)
+	messageQueue.AddWants(wbs, whs, nil)
 	messages = collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 
 	if len(messages) != 1 {
@@ -581,7 +581,7 @@ func TestSendToPeerThatDoesntSupportHaveMonitorsTimeouts(t *testing.T) {
 	messageQueue.Startup()
 
 	wbs := testutil.GenerateCids(10)
-	messageQueue.AddWants(wbs, nil)
+	messageQueue.AddWants(wbs, nil, nil)
 	collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 
 	// Check want-blocks are added to DontHaveTimeoutMgr
@@ -614,11 +614,11 @@ func TestResponseReceived(t *testing.T) {
 	cids := testutil.GenerateCids(10)
 
 	// Add some wants and wait 10ms
-	messageQueue.AddWants(cids[:5], nil)
+	messageQueue.AddWants(cids[:5], nil, nil)
 	collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 
 	// Add some wants and wait another 10ms
-	messageQueue.AddWants(cids[5:8], nil)
+	messageQueue.AddWants(cids[5:8], nil, nil)
 	collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 
 	// Receive a response for some of the wants from both groups
@@ -654,7 +654,7 @@ func TestResponseReceivedAppliesForFirstResponseOnly(t *testing.T) {
 	cids := testutil.GenerateCids(2)
 
 	// Add some wants and wait 10ms
-	messageQueue.AddWants(cids, nil)
+	messageQueue.AddWants(cids, nil, nil)
 	collectMessages(ctx, t, messagesSent, 10*time.Millisecond)
 
 	// Receive a response for the wants
@@ -699,12 +699,12 @@ func TestResponseReceivedDiscardsOutliers(t *testing.T) {
 	cids := testutil.GenerateCids(4)
 
 	// Add some wants and wait 20ms
-	messageQueue.AddWants(cids[:2], nil)
+	messageQueue.AddWants(cids[:2], nil, nil)
 	collectMessages(ctx, t, messagesSent, 20*time.Millisecond)
 
 	// Add some more wants and wait long enough that the first wants will be
 	// outside the maximum valid latency, but the second wants will be inside
-	messageQueue.AddWants(cids[2:], nil)
+	messageQueue.AddWants(cids[2:], nil, nil)
 	collectMessages(ctx, t, messagesSent, maxValLatency-10*time.Millisecond)
 
 	// Receive a response for the wants
@@ -788,7 +788,7 @@ func BenchmarkMessageQueue(b *testing.B) {
 		// Alternately add either a few wants or a lot of broadcast wants
 		if rand.Intn(2) == 0 {
 			wants := testutil.GenerateCids(10)
-			qs[i].AddWants(wants[:2], wants[2:])
+			qs[i].AddWants(wants[:2], wants[2:], nil)
 		} else {
 			wants := testutil.GenerateCids(60)
 			qs[i].AddBroadcastWantHaves(wants)
@@ -16,7 +16,7 @@ type PeerQueue interface {
 	AddBroadcastWantHaves([]cid.Cid)
 	AddWants([]cid.Cid, []cid.Cid, []CodedWant)
 	AddCancels([]cid.Cid)
-	AddCancelC(cid.Cid, int)
+	AddCancelC(cid.Cid, string, int)
 	ResponseReceived(ks []cid.Cid)
 	Startup()
 	Shutdown()
@@ -162,14 +162,14 @@ func (pm *PeerManager) SendCancels(ctx context.Context, cancelKs []cid.Cid) {
 	pm.pwm.sendCancels(cancelKs)
 }
 
-// SendCancels sends cancels for the given key and amount to all peers who had previously
-// received a coded want for the key.
-func (pm *PeerManager) SendCancelC(ctx context.Context, p peer.ID, cancelK cid.Cid, count int) {
+// SendCancelC sends a cancel for the given key, coding and amount to a peer
+// that had previously received a coded want for the key.
+func (pm *PeerManager) SendCancelC(ctx context.Context, p peer.ID, cancelK cid.Cid, coding string, count int) {
 	pm.pqLk.Lock()
 	defer pm.pqLk.Unlock()
 
 	if _, ok := pm.peerQueues[p]; ok {
-		pm.pwm.sendCancelC(p, cancelK, count)
+		pm.pwm.sendCancelC(p, cancelK, coding, count)
 	}
 }
 
@@ -30,12 +30,14 @@ func (fp *mockPeerQueue) Shutdown() {}
 func (fp *mockPeerQueue) AddBroadcastWantHaves(whs []cid.Cid) {
 	fp.msgs <- msg{fp.p, nil, whs, nil}
 }
-func (fp *mockPeerQueue) AddWants(wbs []cid.Cid, whs []cid.Cid) {
+func (fp *mockPeerQueue) AddWants(wbs []cid.Cid, whs []cid.Cid, wcs []CodedWant) {
 	fp.msgs <- msg{fp.p, wbs, whs, nil}
 }
 func (fp *mockPeerQueue) AddCancels(cs []cid.Cid) {
 	fp.msgs <- msg{fp.p, nil, nil, cs}
 }
+func (fp *mockPeerQueue) AddCancelC(c cid.Cid, coding string, count int) {
+}
 func (fp *mockPeerQueue) ResponseReceived(ks []cid.Cid) {
 }
 
@@ -192,7 +194,7 @@ func TestSendWants(t *testing.T) {
 	cids := testutil.GenerateCids(4)
 
 	peerManager.Connected(peer1)
-	peerManager.SendWants(ctx, peer1, []cid.Cid{cids[0]}, []cid.Cid{cids[2]})
+	peerManager.SendWants(ctx, peer1, []cid.Cid{cids[0]}, []cid.Cid{cids[2]}, nil)
 	collected := collectMessages(msgs, 2*time.Millisecond)
 
 	if len(collected[peer1].wantHaves) != 1 {
@@ -202,7 +204,7 @@ func TestSendWants(t *testing.T) {
 		t.Fatal("Expected want-block to be sent to peer")
 	}
 
-	peerManager.SendWants(ctx, peer1, []cid.Cid{cids[0], cids[1]}, []cid.Cid{cids[2], cids[3]})
+	peerManager.SendWants(ctx, peer1, []cid.Cid{cids[0], cids[1]}, []cid.Cid{cids[2], cids[3]}, nil)
 	collected = collectMessages(msgs, 2*time.Millisecond)
 
 	// First want-have and want-block should be filtered (because they were
@@ -230,7 +232,7 @@ func TestSendCancels(t *testing.T) {
 	peerManager.Connected(peer2)
 
 	// Send 2 want-blocks and 1 want-have to peer1
-	peerManager.SendWants(ctx, peer1, []cid.Cid{cids[0], cids[1]}, []cid.Cid{cids[2]})
+	peerManager.SendWants(ctx, peer1, []cid.Cid{cids[0], cids[1]}, []cid.Cid{cids[2]}, nil)
 
 	// Clear messages
 	collectMessages(msgs, 2*time.Millisecond)
@@ -326,10 +328,11 @@ type benchPeerQueue struct {
 func (*benchPeerQueue) Startup()  {}
 func (*benchPeerQueue) Shutdown() {}
 
-func (*benchPeerQueue) AddBroadcastWantHaves(whs []cid.Cid)   {}
-func (*benchPeerQueue) AddWants(wbs []cid.Cid, whs []cid.Cid) {}
-func (*benchPeerQueue) AddCancels(cs []cid.Cid)               {}
-func (*benchPeerQueue) ResponseReceived(ks []cid.Cid)         {}
+func (*benchPeerQueue) AddBroadcastWantHaves(whs []cid.Cid)                    {}
+func (*benchPeerQueue) AddWants(wbs []cid.Cid, whs []cid.Cid, wcs []CodedWant) {}
+func (*benchPeerQueue) AddCancels(cs []cid.Cid)                                {}
+func (*benchPeerQueue) AddCancelC(c cid.Cid, coding string, count int)         {}
+func (*benchPeerQueue) ResponseReceived(ks []cid.Cid)                          {}
 
 // Simplistic benchmark to allow us to stress test
 func BenchmarkPeerManager(b *testing.B) {
@@ -363,7 +366,7 @@ func BenchmarkPeerManager(b *testing.B) {
 		r := rand.Intn(8)
 		if r == 0 {
 			wants := testutil.GenerateCids(10)
-			peerManager.SendWants(ctx, peers[i], wants[:2], wants[2:])
+			peerManager.SendWants(ctx, peers[i], wants[:2], wants[2:], nil)
 			wanted = append(wanted, wants...)
 		} else if r == 1 {
 			wants := testutil.GenerateCids(30)
@@ -47,6 +47,8 @@ type CodedWant struct{
 	Cid cid.Cid
 	Count int
 	Coding string
+	// Rank is the number of innovative coded blocks of Cid we hold.
+	Rank int
 }
 
 
@@ -364,7 +366,7 @@ func (pwm *peerWantManager) sendCancels(cancelKs []cid.Cid) {
 	}
 }
 
-func (pwm *peerWantManager) sendCancelC(p peer.ID, c cid.Cid, count int) {
+func (pwm *peerWantManager) sendCancelC(p peer.ID, c cid.Cid, coding string, count int) {
 	pws, ok := pwm.peerWants[p]
 	if !ok {
 		// In practice this should never happen
@@ -372,7 +374,7 @@ func (pwm *peerWantManager) sendCancelC(p peer.ID, c cid.Cid, count int) {
 		return
 	}
 
-	pws.peerQueue.AddCancelC(c, count)
+	pws.peerQueue.AddCancelC(c, coding, count)
 
 }
 
@@ -39,13 +39,15 @@ func (mpq *mockPQ) Shutdown() {}
 func (mpq *mockPQ) AddBroadcastWantHaves(whs []cid.Cid) {
 	mpq.bcst = append(mpq.bcst, whs...)
 }
-func (mpq *mockPQ) AddWants(wbs []cid.Cid, whs []cid.Cid) {
+func (mpq *mockPQ) AddWants(wbs []cid.Cid, whs []cid.Cid, wcs []CodedWant) {
 	mpq.wbs = append(mpq.wbs, wbs...)
 	mpq.whs = append(mpq.whs, whs...)
 }
 func (mpq *mockPQ) AddCancels(cs []cid.Cid) {
 	mpq.cancels = append(mpq.cancels, cs...)
 }
+func (mpq *mockPQ) AddCancelC(c cid.Cid, coding string, count int) {
+}
 func (mpq *mockPQ) ResponseReceived(ks []cid.Cid) {
 }
 
@@ -139,7 +141,7 @@ func TestPWMBroadcastWantHaves(t *testing.T) {
 	wantBlocks := []cid.Cid{cids4[0], cids4[2]}
 	p0 := peers[0]
 	p1 := peers[1]
-	pwm.sendWants(p0, wantBlocks, []cid.Cid{})
+	pwm.sendWants(p0, wantBlocks, []cid.Cid{}, nil)
 
 	pwm.broadcastWantHaves(cids4)
 	pq0 := peerQueues[p0].(*mockPQ)
@@ -198,7 +200,7 @@ func TestPWMSendWants(t *testing.T) {
 
 	// Send 2 want-blocks and 2 want-haves to p0
 	clearSent(peerQueues)
-	pwm.sendWants(p0, cids, cids2)
+	pwm.sendWants(p0, cids, cids2, nil)
 	if !testutil.MatchKeysIgnoreOrder(pq0.wbs, cids) {
 		t.Fatal("Expected 2 want-blocks")
 	}
@@ -212,7 +214,7 @@ func TestPWMSendWants(t *testing.T) {
 	clearSent(peerQueues)
 	cids3 := testutil.GenerateCids(2)
 	cids4 := testutil.GenerateCids(2)
-	pwm.sendWants(p0, append(cids3, cids[0]), append(cids4, cids2[0]))
+	pwm.sendWants(p0, append(cids3, cids[0]), append(cids4, cids2[0]), nil)
 	if !testutil.MatchKeysIgnoreOrder(pq0.wbs, cids3) {
 		t.Fatal("Expected 2 want-blocks")
 	}
@@ -224,7 +226,7 @@ func TestPWMSendWants(t *testing.T) {
 	clearSent(peerQueues)
 	cids5 := testutil.GenerateCids(1)
 	newWantBlockOldWantHave := append(cids5, cids2[0])
-	pwm.sendWants(p0, newWantBlockOldWantHave, []cid.Cid{})
+	pwm.sendWants(p0, newWantBlockOldWantHave, []cid.Cid{}, nil)
 	// If a want was sent as a want-have, it should be ok to now send it as a
 	// want-block
 	if !testutil.MatchKeysIgnoreOrder(pq0.wbs, newWantBlockOldWantHave) {
@@ -238,7 +240,7 @@ func TestPWMSendWants(t *testing.T) {
 	clearSent(peerQueues)
 	cids6 := testutil.GenerateCids(1)
 	newWantHaveOldWantBlock := append(cids6, cids[0])
-	pwm.sendWants(p0, []cid.Cid{}, newWantHaveOldWantBlock)
+	pwm.sendWants(p0, []cid.Cid{}, newWantHaveOldWantBlock, nil)
 	// If a want was previously sent as a want-block, it should not be
 	// possible to now send it as a want-have
 	if !testutil.MatchKeysIgnoreOrder(pq0.whs, cids6) {
@@ -249,7 +251,7 @@ func TestPWMSendWants(t *testing.T) {
 	}
 
 	// Send 2 want-blocks and 2 want-haves to p1
-	pwm.sendWants(p1, cids, cids2)
+	pwm.sendWants(p1, cids, cids2, nil)
 	if !testutil.MatchKeysIgnoreOrder(pq1.wbs, cids) {
 		t.Fatal("Expected 2 want-blocks")
 	}
@@ -281,10 +283,10 @@ func TestPWMSendCancels(t *testing.T) {
 	pq1 := peerQueues[p1].(*mockPQ)
 
 	// Send 2 want-blocks and 2 want-haves to p0
-	pwm.sendWants(p0, wb1, wh1)
+	pwm.sendWants(p0, wb1, wh1, nil)
 	// Send 3 want-blocks and 3 want-haves to p1
 	// (1 overlapping want-block / want-have with p0)
-	pwm.sendWants(p1, append(wb2, wb1[1]), append(wh2, wh1[1]))
+	pwm.sendWants(p1, append(wb2, wb1[1]), append(wh2, wh1[1]), nil)
 
 	if !testutil.MatchKeysIgnoreOrder(pwm.getWantBlocks(), allwb) {
 		t.Fatal("Expected 4 cids to be wanted")
@@ -353,7 +355,7 @@ func TestStats(t *testing.T) {
 	pwm.addPeer(pq, p0)
 
 	// Send 2 want-blocks and 2 want-haves to p0
-	pwm.sendWants(p0, cids, cids2)
+	pwm.sendWants(p0, cids, cids2, nil)
 
 	if g.count != 4 {
 		t.Fatal("Expected 4 wants")
@@ -364,7 +366,7 @@ func TestStats(t *testing.T) {
 
 	// Send 1 old want-block and 2 new want-blocks to p0
 	cids3 := testutil.GenerateCids(2)
-	pwm.sendWants(p0, append(cids3, cids[0]), []cid.Cid{})
+	pwm.sendWants(p0, append(cids3, cids[0]), []cid.Cid{}, nil)
 
 	if g.count != 6 {
 		t.Fatal("Expected 6 wants")
@@ -452,11 +454,11 @@ func TestStatsOverlappingWantBlockWantHave(t *testing.T) {
 	pwm.addPeer(&mockPQ{}, p1)
 
 	// Send 2 want-blocks and 2 want-haves to p0
-	pwm.sendWants(p0, cids, cids2)
+	pwm.sendWants(p0, cids, cids2, nil)
 
 	// Send opposite:
 	// 2 want-haves and 2 want-blocks to p1
-	pwm.sendWants(p1, cids2, cids)
+	pwm.sendWants(p1, cids2, cids, nil)
 
 	if g.count != 4 {
 		t.Fatal("Expected 4 wants")
@@ -491,11 +493,11 @@ func TestStatsRemovePeerOverlappingWantBlockWantHave(t *testing.T) {
 	pwm.addPeer(&mockPQ{}, p1)
 
 	// Send 2 want-blocks and 2 want-haves to p0
-	pwm.sendWants(p0, cids, cids2)
+	pwm.sendWants(p0, cids, cids2, nil)
 
 	// Send opposite:
 	// 2 want-haves and 2 want-blocks to p1
-	pwm.sendWants(p1, cids2, cids)
+	pwm.sendWants(p1, cids2, cids, nil)
 
 	if g.count != 4 {
 		t.Fatal("Expected 4 wants")
@@ -42,7 +42,7 @@ type PeerManager interface {
 	BroadcastWantHaves(context.Context, []cid.Cid)
 	// SendCancels tells the PeerManager to send cancels to all peers
 	SendCancels(context.Context, []cid.Cid)
-	SendCancelC(context.Context, peer.ID, cid.Cid, int)
+	SendCancelC(context.Context, peer.ID, cid.Cid, string, int)
 }
 
 // SessionManager manages all the sessions
@@ -231,6 +231,19 @@ func (s *Session) ReceiveFrom(from peer.ID, ks []cid.Cid, haves []cid.Cid, dontH
 	}
 }
 
+// ReceivedInvalidFrom is called when a peer sent blocks of the session that
+// failed verification. Wants are not sent to the peer anymore.
+func (s *Session) ReceivedInvalidFrom(from peer.ID) {
+	log.Infof("peer %s sent invalid blocks, removing from session %d", from, s.id)
+	s.sws.ReceivedInvalidFrom(from)
+}
+
+// ReceiveRanksFrom is called when a peer sent the ranks of its coded blocks
+// of parents, which the session may want.
+func (s *Session) ReceiveRanksFrom(from peer.ID, parents []cid.Cid) {
+	s.sws.UpdateRanks(from, parents)
+}
+
 func (s *Session) logReceiveFrom(from peer.ID, interestedKs []cid.Cid, haves []cid.Cid, dontHaves []cid.Cid) {
 	// Save some CPU cycles if log level is higher than debug
 	if ce := sflog.Check(zap.DebugLevel, "Bitswap <- rcv message"); ce == nil {
@@ -404,6 +417,8 @@ func (s *Session) broadcast(ctx context.Context, wants []cid.Cid) {
 	// any blocks for a while) then broadcast all pending wants
 	if wants == nil {
 		wants = s.sw.PrepareBroadcast()
+		// and send the coded wants again
+		s.sws.ResendC()
 	}
 
 	// Broadcast a want-have for the live wants to everyone we're connected to
@@ -552,7 +567,7 @@ func (s *Session) wantBlocksC(ctx context.Context, key cid.Cid, coding string, c
 	if count > 0 {
 		// Inform the SessionInterestManager that this session is interested in the keys
 		s.sim.RecordSessionInterest(s.id, []cid.Cid{key})
-		s.sim.RecordSessionInterestC(s.id, key, count)
+		s.sim.RecordSessionInterestC(s.id, key, coding, count)
 		// Tell the sessionWants tracker that that the wants have been requested
 		//s.sw.BlocksRequested(newks)
 		// Tell the sessionWantSender that the blocks have been requested
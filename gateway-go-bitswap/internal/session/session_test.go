@@ -136,16 +136,18 @@ func newFakePeerManager() *fakePeerManager {
 	}
 }
 
-func (pm *fakePeerManager) RegisterSession(peer.ID, bspm.Session)                    {}
-func (pm *fakePeerManager) UnregisterSession(uint64)                                 {}
-func (pm *fakePeerManager) SendWants(context.Context, peer.ID, []cid.Cid, []cid.Cid) {}
+func (pm *fakePeerManager) RegisterSession(peer.ID, bspm.Session) {}
+func (pm *fakePeerManager) UnregisterSession(uint64)              {}
+func (pm *fakePeerManager) SendWants(context.Context, peer.ID, []cid.Cid, []cid.Cid, []bspm.CodedWant) {
+}
 func (pm *fakePeerManager) BroadcastWantHaves(ctx context.Context, cids []cid.Cid) {
 	select {
 	case pm.wantReqs <- wantReq{cids}:
 	case <-ctx.Done():
 	}
 }
-func (pm *fakePeerManager) SendCancels(ctx context.Context, cancels []cid.Cid) {}
+func (pm *fakePeerManager) SendCancels(ctx context.Context, cancels []cid.Cid)         {}
+func (pm *fakePeerManager) SendCancelC(context.Context, peer.ID, cid.Cid, string, int) {}
 
 func TestSessionGetBlocks(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
@@ -190,7 +192,7 @@ func TestSessionGetBlocks(t *testing.T) {
 	peers := testutil.GeneratePeers(5)
 	for i, p := range peers {
 		blk := blks[testutil.IndexOf(blks, receivedWantReq.cids[i])]
-		session.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{blk.Cid()}, []cid.Cid{})
+		session.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{blk.Cid()}, []cid.Cid{}, nil)
 	}
 
 	time.Sleep(10 * time.Millisecond)
@@ -201,29 +203,29 @@ func TestSessionGetBlocks(t *testing.T) {
 	}
 
 	// Verify session still wants received blocks
-	_, unwanted := sim.SplitWantedUnwanted(blks)
+	_, unwanted, _ := sim.SplitWantedUnwanted(blks)
 	if len(unwanted) > 0 {
 		t.Fatal("all blocks should still be wanted")
 	}
 
 	// Simulate receiving DONT_HAVE for a CID
-	session.ReceiveFrom(peers[0], []cid.Cid{}, []cid.Cid{}, []cid.Cid{blks[0].Cid()})
+	session.ReceiveFrom(peers[0], []cid.Cid{}, []cid.Cid{}, []cid.Cid{blks[0].Cid()}, nil)
 
 	time.Sleep(10 * time.Millisecond)
 
 	// Verify session still wants received blocks
-	_, unwanted = sim.SplitWantedUnwanted(blks)
+	_, unwanted, _ = sim.SplitWantedUnwanted(blks)
 	if len(unwanted) > 0 {
 		t.Fatal("all blocks should still be wanted")
 	}
 
 	// Simulate receiving block for a CID
-	session.ReceiveFrom(peers[1], []cid.Cid{blks[0].Cid()}, []cid.Cid{}, []cid.Cid{})
+	session.ReceiveFrom(peers[1], []cid.Cid{blks[0].Cid()}, []cid.Cid{}, []cid.Cid{}, nil)
 
 	time.Sleep(10 * time.Millisecond)
 
 	// Verify session no longer wants received block
-	wanted, unwanted := sim.SplitWantedUnwanted(blks)
+	wanted, unwanted, _ := sim.SplitWantedUnwanted(blks)
 	if len(unwanted) != 1 || !unwanted[0].Cid().Equals(blks[0].Cid()) {
 		t.Fatal("session wants block that has already been received")
 	}
@@ -281,7 +283,7 @@ func TestSessionFindMorePeers(t *testing.T) {
 	p := testutil.GeneratePeers(1)[0]
 
 	blk := blks[0]
-	session.ReceiveFrom(p, []cid.Cid{blk.Cid()}, []cid.Cid{}, []cid.Cid{})
+	session.ReceiveFrom(p, []cid.Cid{blk.Cid()}, []cid.Cid{}, []cid.Cid{}, nil)
 
 	// The session should now time out waiting for a response and broadcast
 	// want-haves again
@@ -584,7 +586,7 @@ func TestSessionReceiveMessageAfterCtxCancel(t *testing.T) {
 
 	// Simulate receiving block for a CID
 	peer := testutil.GeneratePeers(1)[0]
-	session.ReceiveFrom(peer, []cid.Cid{blks[0].Cid()}, []cid.Cid{}, []cid.Cid{})
+	session.ReceiveFrom(peer, []cid.Cid{blks[0].Cid()}, []cid.Cid{}, []cid.Cid{}, nil)
 
 	time.Sleep(5 * time.Millisecond)
 
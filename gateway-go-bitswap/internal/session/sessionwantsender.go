@@ -50,6 +50,8 @@ type update struct {
 	dontHaves []cid.Cid
 	// number and cid of received coded blocks
 	ksc map[cid.Cid]int
+	// parents the peer sent coded HAVE / DONT_HAVE for
+	ranked []cid.Cid
 }
 
 // peerAvailability indicates a peer's connection state
@@ -76,6 +78,12 @@ type change struct {
 
 	// cancel coded want
 	cancelc []peermanager.CodedWant
+
+	// coded wants must be sent again
+	resendc bool
+
+	// peer sent invalid blocks
+	invalid peer.ID
 }
 
 
@@ -106,6 +114,8 @@ type sessionWantSender struct {
 	wants map[cid.Cid]*wantInfo
 	// Keeps track of how many consecutive DONT_HAVEs a peer has sent
 	peerConsecutiveDontHaves map[peer.ID]int
+	// Peers that sent invalid blocks, which are never sent wants again
+	invalidPeers map[peer.ID]struct{}
 	// Tracks which peers we have send want-block to
 	swbt *sentWantBlocksTracker
 	// Tracks the number of blocks each peer sent us
@@ -136,6 +146,7 @@ func newSessionWantSender(sid uint64, pm PeerManager, spm SessionPeerManager, ca
 		changes:                  make(chan change, changesBufferSize),
 		wants:                    make(map[cid.Cid]*wantInfo),
 		peerConsecutiveDontHaves: make(map[peer.ID]int),
+		invalidPeers:             make(map[peer.ID]struct{}),
 		swbt:                     newSentWantBlocksTracker(),
 		peerRspTrkr:              newPeerResponseTracker(),
 
@@ -191,6 +202,12 @@ func (sws *sessionWantSender) CancelC(key cid.Cid, coding string, count int) {
 	sws.addChange(change{cancelc: sl})
 }
 
+// ResendC is called when the session hasn't received blocks for a while: the
+// coded wants, or the coded blocks sent for them, may have been lost, so they
+// are split across the peers and sent again.
+func (sws *sessionWantSender) ResendC() {
+	sws.addChange(change{resendc: true})
+}
 
 // Update is called when the session receives a message with incoming blocks
 // or HAVE / DONT_HAVE
@@ -201,7 +218,19 @@ func (sws *sessionWantSender) Update(from peer.ID, ks []cid.Cid, haves []cid.Cid
 	}
 
 	sws.addChange(change{
-		update: update{from, ks, haves, dontHaves, ksc},
+		update: update{from, ks, haves, dontHaves, ksc, nil},
+	})
+}
+
+// UpdateRanks is called when the session receives coded HAVE / DONT_HAVEs,
+// whose ranks are in the BlockPresenceManager
+func (sws *sessionWantSender) UpdateRanks(from peer.ID, parents []cid.Cid) {
+	if len(parents) == 0 {
+		return
+	}
+
+	sws.addChange(change{
+		update: update{from: from, ranked: parents},
 	})
 }
 
@@ -214,6 +243,12 @@ func (sws *sessionWantSender) SignalAvailability(p peer.ID, isAvailable bool) {
 	sws.addChangeNonBlocking(change{availability: availability})
 }
 
+// ReceivedInvalidFrom is called when a peer sent blocks that failed
+// verification. The peer is removed from the session for good.
+func (sws *sessionWantSender) ReceivedInvalidFrom(p peer.ID) {
+	sws.addChangeNonBlocking(change{invalid: p})
+}
+
 // Run is the main loop for processing incoming changes
 func (sws *sessionWantSender) Run() {
 	for {
@@ -314,12 +349,25 @@ func (sws *sessionWantSender) onChange(changes []change) {
 //			cancels = append(cancels, c)
 		}
 
+		if chng.resendc {
+			sws.resendWantsC()
+		}
+
+		if chng.invalid != "" {
+			sws.invalidPeers[chng.invalid] = struct{}{}
+			availability[chng.invalid] = false
+		}
+
 		// Consolidate updates and changes to availability
 		if chng.update.from != "" {
+			if _, ok := sws.invalidPeers[chng.update.from]; ok {
+				// Only the blocks it sent, which were verified, count.
+				chng.update.haves, chng.update.dontHaves, chng.update.ranked = nil, nil, nil
+			}
 			fmt.Println("Debug: sws-update new peer", chng.update.from, len(chng.update.ks), len(chng.update.haves))
 			// If the update includes blocks or haves, treat it as signaling that
 			// the peer is available
-			if len(chng.update.ks) > 0 || len(chng.update.haves) > 0 || len(chng.update.ksc) > 0 {
+			if len(chng.update.ks) > 0 || len(chng.update.haves) > 0 || len(chng.update.ksc) > 0 || sws.hasCodedBlocks(chng.update) {
 				p := chng.update.from
 				availability[p] = true
 
@@ -366,6 +414,9 @@ func (sws *sessionWantSender) processAvailability(availability map[peer.ID]bool)
 	var newlyUnavailable []peer.ID
 	for p, isNowAvailable := range availability {
 		stateChange := false
+		if _, ok := sws.invalidPeers[p]; ok {
+			isNowAvailable = false
+		}
 		if isNowAvailable {
 			isNewPeer := sws.spm.AddPeer(p)
 			if isNewPeer {
@@ -417,7 +468,12 @@ func (sws *sessionWantSender) trackWantC(c peermanager.CodedWant) {
 		if sws.wants[c.Cid].coding == "" || sws.wants[c.Cid].coding == c.Coding {
 			sws.wants[c.Cid].coding	= c.Coding
 			// MARS todo: lock
-			sws.wants[c.Cid].count += c.Count
+			// A generation needs the same number of packets however
+			// many times it is asked for.
+			if c.Count > sws.wants[c.Cid].count {
+				sws.wants[c.Cid].count = c.Count
+				sws.wants[c.Cid].resplit = true
+			}
 		}
 		return
 	}
@@ -437,6 +493,19 @@ func (sws *sessionWantSender) trackWantC(c peermanager.CodedWant) {
 	}
 }
 
+// resendWantsC forgets the shares of the coded wants sent to peers, so that
+// they are sent again.
+func (sws *sessionWantSender) resendWantsC() {
+	for _, wi := range sws.wants {
+		if wi.coding == "" || wi.count == 0 {
+			continue
+		}
+		for p := range wi.sentToC {
+			delete(wi.sentToC, p)
+		}
+		wi.resplit = true
+	}
+}
 
 // untrackWant removes an entry from the map of CID -> want info
 func (sws *sessionWantSender) untrackWant(c cid.Cid) {
@@ -456,7 +525,7 @@ func (sws *sessionWantSender) untrackWantC(c peermanager.CodedWant) {
 		ks := []cid.Cid{c.Cid}
 		sws.canceller.CancelSessionWants(sws.sessionID, ks)
 		for p,_ := range wi.sentToC{
-			sws.pm.SendCancelC(sws.ctx, p, c.Cid, wi.total)
+			sws.pm.SendCancelC(sws.ctx, p, c.Cid, wi.coding, wi.total)
 		}
 	} else {
 		wi.count = wi.count - c.Count
@@ -471,7 +540,9 @@ func (sws *sessionWantSender) processUpdates(updates []update) []cid.Cid {
 	blkCids := cid.NewSet()
 	for _, upd := range updates {
 		for k, c := range upd.ksc{
-			sws.CancelC(k,"nc",c)
+			if wi, ok := sws.wants[k]; ok {
+				sws.CancelC(k, wi.coding, c)
+			}
 		}
 		for _, c := range upd.ks {
 			blkCids.Add(c)
@@ -530,7 +601,6 @@ func (sws *sessionWantSender) processUpdates(updates []update) []cid.Cid {
 	// Process received HAVEs
 	for _, upd := range updates {
 		for _, c := range upd.haves {
-
 			wi, ok :=sws.wants[c]
 			// If we haven't already received a block for the want or key is coded want
 			if (ok && wi.count > 0) || !blkCids.Has(c) {
@@ -544,6 +614,20 @@ func (sws *sessionWantSender) processUpdates(updates []update) []cid.Cid {
 		}
 	}
 
+	// Process received coded HAVE / DONT_HAVEs, which tell how many coded
+	// blocks the peer holds
+	for _, upd := range updates {
+		for _, c := range upd.ranked {
+			sws.updateWantRank(c, upd.from)
+
+			if rank, _ := sws.bpm.PeerRank(upd.from, c); rank > 0 {
+				// Clear the consecutive DONT_HAVE count for the peer
+				delete(sws.peerConsecutiveDontHaves, upd.from)
+				delete(prunePeers, upd.from)
+			}
+		}
+	}
+
 	// If any peers have sent us too many consecutive DONT_HAVEs, remove them
 	// from the session
 	for p := range prunePeers {
@@ -656,39 +740,17 @@ func (sws *sessionWantSender) sendNextWants(newlyAvailable []peer.ID) {
 
 		// We already sent a want-block to a peer and haven't yet received a
 		// response yet
-		if wi.sentTo != "" && !(wi.coding == "nc" && wi.count>0) {
+		if wi.sentTo != "" && !(wi.coding != "" && wi.count>0) {
 			continue
 		}
 
 //		fmt.Println("Debug: sws-sendnextwants-coding", wi.coding)
-		if wi.coding=="nc"{
-			fmt.Println("Debug: sws-snw trying to send coded want", len(wi.sentToC), len(sws.spm.Peers()))
-//			fmt.Println("Debug: found coded entry")
-			if len(wi.sentToC) == 0{
-				pc:=len(sws.spm.Peers())
-				sendtoc:=wi.count/pc+1
-				sendtoc=wi.count
-
- 				fmt.Println("Debug: sending coded wants for", sendtoc, "packets to", len(sws.spm.Peers()))
-				fmt.Println(sws.spm.Peers())
-
-				for _,pe := range sws.spm.Peers(){
-					(*toSend.forPeer(pe).wantCodeds)[peermanager.CodedWant{Cid: c, Coding: wi.coding, Count: sendtoc}]=struct{}{}
-					wi.sentToC[pe]=sendtoc
-	//					fmt.Println("Debug: to ", pe)
-				}
-			} else if len(newlyAvailable) > 0 {
-				pc:=len(sws.spm.Peers())
-				sendtoc:=wi.count/pc+1
-				sendtoc=wi.count
-
-				fmt.Println("Debug: sending new coded wants for", sendtoc, "packets to", len(newlyAvailable))
-				fmt.Println(newlyAvailable)
-
-				for _,pe := range newlyAvailable{
-					(*toSend.forPeer(pe).wantCodeds)[peermanager.CodedWant{Cid: c, Coding: wi.coding, Count: sendtoc}]=struct{}{}
-					wi.sentToC[pe]=sendtoc
-				}
+		if wi.coding != "" {
+			// Split the coded blocks we still need across the peers when
+			// we first ask for them, and again when peers come along or
+			// tell us their rank.
+			if len(wi.sentToC) == 0 || len(newlyAvailable) > 0 || wi.resplit {
+				sws.splitWantC(c, wi, toSend)
 			}
 			continue
 		}
@@ -725,6 +787,87 @@ func (sws *sessionWantSender) sendNextWants(newlyAvailable []peer.ID) {
 	sws.sendWants(toSend)
 }
 
+// splitWantC updates the coded wants for c of the peers whose share of the
+// coded blocks we still need changed, cancelling those of the peers that are
+// left without a share.
+func (sws *sessionWantSender) splitWantC(c cid.Cid, wi *wantInfo, toSend allWants) {
+	wi.resplit = false
+
+	// Every coded block received in the session was innovative, as only
+	// those are stored.
+	rank := wi.total - wi.count
+	for p, n := range splitCoded(wi.count, sws.spm.Peers(), wi.ranks) {
+		sent, ok := wi.sentToC[p]
+		if ok && sent == n {
+			continue
+		}
+		if n == 0 {
+			if ok {
+				sws.pm.SendCancelC(sws.ctx, p, c, wi.coding, wi.total)
+				delete(wi.sentToC, p)
+			}
+			continue
+		}
+		cw := peermanager.CodedWant{Cid: c, Coding: wi.coding, Count: n, Rank: rank}
+		(*toSend.forPeer(p).wantCodeds)[cw] = struct{}{}
+		wi.sentToC[p] = n
+	}
+}
+
+// splitCoded splits count coded blocks across peers, given the ranks the
+// peers told us. Each peer whose rank is unknown is asked for an even share,
+// so it answers with its rank. The rest is split across the peers with a
+// known rank in proportion to it, asking no peer for more blocks than its
+// rank, as more would not be innovative: peers without coded blocks get
+// nothing. What they cannot cover goes to the peers whose rank is unknown.
+func splitCoded(count int, peers []peer.ID, ranks map[peer.ID]int) map[peer.ID]int {
+	shares := make(map[peer.ID]int, len(peers))
+	if count <= 0 || len(peers) == 0 {
+		return shares
+	}
+
+	var unknown []peer.ID
+	total := 0
+	for _, p := range peers {
+		shares[p] = 0
+		if r, ok := ranks[p]; ok {
+			total += r
+		} else {
+			unknown = append(unknown, p)
+		}
+	}
+
+	even := (count + len(peers) - 1) / len(peers)
+	rest := count - even*len(unknown)
+	if rest < 0 {
+		rest = 0
+	}
+	covered := 0
+	if total > 0 {
+		for _, p := range peers {
+			r, ok := ranks[p]
+			if !ok || r == 0 {
+				continue
+			}
+			n := (rest*r + total - 1) / total
+			if n > r {
+				n = r
+			}
+			shares[p] = n
+			covered += n
+		}
+	}
+
+	extra := 0
+	if len(unknown) > 0 && covered < rest {
+		extra = (rest - covered + len(unknown) - 1) / len(unknown)
+	}
+	for _, p := range unknown {
+		shares[p] = even + extra
+	}
+	return shares
+}
+
 // sendWants sends want-have and want-blocks to the appropriate peers
 func (sws *sessionWantSender) sendWants(sends allWants) {
 	// For each peer we're sending a request to
@@ -830,6 +973,34 @@ func (sws *sessionWantSender) updateWantBlockPresence(c cid.Cid, p peer.ID) {
 	}
 }
 
+// hasCodedBlocks indicates whether the update has a coded HAVE, telling the
+// peer holds coded blocks of a parent
+func (sws *sessionWantSender) hasCodedBlocks(upd update) bool {
+	for _, c := range upd.ranked {
+		if rank, _ := sws.bpm.PeerRank(upd.from, c); rank > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// updateWantRank is called when a coded HAVE / DONT_HAVE may have been
+// received for the given want / peer
+func (sws *sessionWantSender) updateWantRank(c cid.Cid, p peer.ID) {
+	wi, ok := sws.wants[c]
+	if !ok || wi.coding == "" {
+		return
+	}
+	rank, ok := sws.bpm.PeerRank(p, c)
+	if !ok {
+		return
+	}
+	if prev, ok := wi.ranks[p]; !ok || prev != rank {
+		wi.ranks[p] = rank
+		wi.resplit = true
+	}
+}
+
 // Which peer was the want sent to
 func (sws *sessionWantSender) getWantSentTo(c cid.Cid) (peer.ID, bool) {
 	if wi, ok := sws.wants[c]; ok {
@@ -872,6 +1043,10 @@ type wantInfo struct {
 	count int
 	total int
 	sentToC map[peer.ID]int
+	// The rank each peer told us for a coded want
+	ranks map[peer.ID]int
+	// true if the coded want must be split across peers again
+	resplit bool
 }
 
 // func newWantInfo(prt *peerResponseTracker, c cid.Cid, startIndex int) *wantInfo {
@@ -881,6 +1056,7 @@ func newWantInfo(prt *peerResponseTracker) *wantInfo {
 		peerRspTrkr:   prt,
 		exhausted:     false,
 		sentToC: make(map[peer.ID]int),
+		ranks:   make(map[peer.ID]int),
 	}
 }
 
@@ -904,6 +1080,12 @@ func (wi *wantInfo) removePeer(p peer.ID) {
 		wi.sentTo = ""
 	}
 	delete(wi.blockPresence, p)
+	// Its share of the coded blocks goes to the other peers.
+	delete(wi.ranks, p)
+	if _, ok := wi.sentToC[p]; ok {
+		delete(wi.sentToC, p)
+		wi.resplit = true
+	}
 	wi.calculateBestPeer()
 }
 
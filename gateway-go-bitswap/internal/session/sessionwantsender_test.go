@@ -19,9 +19,10 @@ type sentWants struct {
 	p          peer.ID
 	wantHaves  *cid.Set
 	wantBlocks *cid.Set
+	wantCodeds []bspm.CodedWant
 }
 
-func (sw *sentWants) add(wantBlocks []cid.Cid, wantHaves []cid.Cid) {
+func (sw *sentWants) add(wantBlocks []cid.Cid, wantHaves []cid.Cid, wantCodeds []bspm.CodedWant) {
 	sw.Lock()
 	defer sw.Unlock()
 
@@ -33,7 +34,7 @@ func (sw *sentWants) add(wantBlocks []cid.Cid, wantHaves []cid.Cid) {
 			sw.wantHaves.Add(c)
 		}
 	}
-
+	sw.wantCodeds = append(sw.wantCodeds, wantCodeds...)
 }
 func (sw *sentWants) wantHavesKeys() []cid.Cid {
 	sw.Lock()
@@ -45,6 +46,11 @@ func (sw *sentWants) wantBlocksKeys() []cid.Cid {
 	defer sw.Unlock()
 	return sw.wantBlocks.Keys()
 }
+func (sw *sentWants) codedWants() []bspm.CodedWant {
+	sw.Lock()
+	defer sw.Unlock()
+	return append([]bspm.CodedWant{}, sw.wantCodeds...)
+}
 
 type mockPeerManager struct {
 	lk           sync.Mutex
@@ -76,11 +82,12 @@ func (pm *mockPeerManager) has(p peer.ID, sid uint64) bool {
 	return false
 }
 
-func (*mockPeerManager) UnregisterSession(uint64)                      {}
-func (*mockPeerManager) BroadcastWantHaves(context.Context, []cid.Cid) {}
-func (*mockPeerManager) SendCancels(context.Context, []cid.Cid)        {}
+func (*mockPeerManager) UnregisterSession(uint64)                                   {}
+func (*mockPeerManager) BroadcastWantHaves(context.Context, []cid.Cid)              {}
+func (*mockPeerManager) SendCancels(context.Context, []cid.Cid)                     {}
+func (*mockPeerManager) SendCancelC(context.Context, peer.ID, cid.Cid, string, int) {}
 
-func (pm *mockPeerManager) SendWants(ctx context.Context, p peer.ID, wantBlocks []cid.Cid, wantHaves []cid.Cid) {
+func (pm *mockPeerManager) SendWants(ctx context.Context, p peer.ID, wantBlocks []cid.Cid, wantHaves []cid.Cid, wantCodeds []bspm.CodedWant) {
 	pm.lk.Lock()
 	defer pm.lk.Unlock()
 
@@ -89,7 +96,7 @@ func (pm *mockPeerManager) SendWants(ctx context.Context, p peer.ID, wantBlocks
 		sw = &sentWants{p: p, wantHaves: cid.NewSet(), wantBlocks: cid.NewSet()}
 		pm.peerSends[p] = sw
 	}
-	sw.add(wantBlocks, wantHaves)
+	sw.add(wantBlocks, wantHaves, wantCodeds)
 }
 
 func (pm *mockPeerManager) waitNextWants() map[peer.ID]*sentWants {
@@ -159,7 +166,7 @@ func TestSendWants(t *testing.T) {
 	blkCids0 := cids[0:2]
 	spm.Add(blkCids0)
 	// peerA: HAVE cid0
-	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	peerSends := pm.waitNextWants()
@@ -199,7 +206,7 @@ func TestSendsWantBlockToOnePeerOnly(t *testing.T) {
 	blkCids0 := cids[0:2]
 	spm.Add(blkCids0)
 	// peerA: HAVE cid0
-	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	peerSends := pm.waitNextWants()
@@ -218,7 +225,7 @@ func TestSendsWantBlockToOnePeerOnly(t *testing.T) {
 	pm.clearWants()
 
 	// peerB: HAVE cid0
-	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	peerSends = pm.waitNextWants()
@@ -258,7 +265,7 @@ func TestReceiveBlock(t *testing.T) {
 	// add cid0, cid1
 	spm.Add(cids)
 	// peerA: HAVE cid0
-	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	peerSends := pm.waitNextWants()
@@ -278,10 +285,10 @@ func TestReceiveBlock(t *testing.T) {
 
 	// peerA: block cid0, DONT_HAVE cid1
 	bpm.ReceiveFrom(peerA, []cid.Cid{}, []cid.Cid{cids[1]})
-	spm.Update(peerA, []cid.Cid{cids[0]}, []cid.Cid{}, []cid.Cid{cids[1]})
+	spm.Update(peerA, []cid.Cid{cids[0]}, []cid.Cid{}, []cid.Cid{cids[1]}, nil)
 	// peerB: HAVE cid0, cid1
 	bpm.ReceiveFrom(peerB, cids, []cid.Cid{})
-	spm.Update(peerB, []cid.Cid{}, cids, []cid.Cid{})
+	spm.Update(peerB, []cid.Cid{}, cids, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	peerSends = pm.waitNextWants()
@@ -352,7 +359,7 @@ func TestRegisterSessionWithPeerManager(t *testing.T) {
 	go spm.Run()
 
 	// peerA: HAVE cid0
-	spm.Update(peerA, nil, cids[:1], nil)
+	spm.Update(peerA, nil, cids[:1], nil, nil)
 
 	// Wait for processing to complete
 	time.Sleep(10 * time.Millisecond)
@@ -363,7 +370,7 @@ func TestRegisterSessionWithPeerManager(t *testing.T) {
 	}
 
 	// peerB: block cid1
-	spm.Update(peerB, cids[1:], nil, nil)
+	spm.Update(peerB, cids[1:], nil, nil, nil)
 
 	// Wait for processing to complete
 	time.Sleep(10 * time.Millisecond)
@@ -397,7 +404,7 @@ func TestProtectConnFirstPeerToSendWantedBlock(t *testing.T) {
 	spm.Add(cids[:1])
 
 	// peerA: block cid0
-	spm.Update(peerA, cids[:1], nil, nil)
+	spm.Update(peerA, cids[:1], nil, nil, nil)
 
 	// Wait for processing to complete
 	time.Sleep(10 * time.Millisecond)
@@ -408,7 +415,7 @@ func TestProtectConnFirstPeerToSendWantedBlock(t *testing.T) {
 	}
 
 	// peerB: block cid0
-	spm.Update(peerB, cids[:1], nil, nil)
+	spm.Update(peerB, cids[:1], nil, nil, nil)
 
 	// Wait for processing to complete
 	time.Sleep(10 * time.Millisecond)
@@ -419,7 +426,7 @@ func TestProtectConnFirstPeerToSendWantedBlock(t *testing.T) {
 	}
 
 	// peerC: block cid1
-	spm.Update(peerC, cids[1:], nil, nil)
+	spm.Update(peerC, cids[1:], nil, nil, nil)
 
 	// Wait for processing to complete
 	time.Sleep(10 * time.Millisecond)
@@ -450,7 +457,7 @@ func TestPeerUnavailable(t *testing.T) {
 	// add cid0, cid1
 	spm.Add(cids)
 	// peerA: HAVE cid0
-	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	peerSends := pm.waitNextWants()
@@ -469,7 +476,7 @@ func TestPeerUnavailable(t *testing.T) {
 	pm.clearWants()
 
 	// peerB: HAVE cid0
-	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	peerSends = pm.waitNextWants()
@@ -497,6 +504,115 @@ func TestPeerUnavailable(t *testing.T) {
 	}
 }
 
+func TestPeerSentInvalidBlocks(t *testing.T) {
+	cids := testutil.GenerateCids(2)
+	peers := testutil.GeneratePeers(2)
+	peerA := peers[0]
+	peerB := peers[1]
+	sid := uint64(1)
+	pm := newMockPeerManager()
+	fpm := newFakeSessionPeerManager()
+	swc := newMockSessionMgr()
+	bpm := bsbpm.New()
+	onSend := func(peer.ID, []cid.Cid, []cid.Cid) {}
+	onPeersExhausted := func([]cid.Cid) {}
+	spm := newSessionWantSender(sid, pm, fpm, swc, bpm, onSend, onPeersExhausted)
+	defer spm.Shutdown()
+
+	go spm.Run()
+
+	// add cid0, cid1
+	spm.Add(cids)
+	// peerA: HAVE cid0
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
+
+	// Wait for processing to complete
+	peerSends := pm.waitNextWants()
+
+	// Should have sent
+	// peerA: want-block cid0, cid1
+	sw, ok := peerSends[peerA]
+	if !ok || !testutil.MatchKeysIgnoreOrder(sw.wantBlocksKeys(), cids) {
+		t.Fatal("Expected want-blocks sent to peer A")
+	}
+	pm.clearWants()
+
+	// peerB: HAVE cid0
+	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
+	pm.waitNextWants()
+	pm.clearWants()
+
+	// peerA sends invalid blocks
+	spm.ReceivedInvalidFrom(peerA)
+
+	// Should now have sent want-block cid0, cid1 to peerB
+	peerSends = pm.waitNextWants()
+	sw, ok = peerSends[peerB]
+	if !ok || !testutil.MatchKeysIgnoreOrder(sw.wantBlocksKeys(), cids) {
+		t.Fatal("Expected want-blocks sent to peer B")
+	}
+	if fpm.HasPeer(peerA) {
+		t.Fatal("Expected peer A to be removed from the session")
+	}
+	pm.clearWants()
+
+	// peerA: HAVE cid1, which does not bring it back
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[1]}, []cid.Cid{}, nil)
+	spm.SignalAvailability(peerA, true)
+
+	if _, ok := pm.waitNextWants()[peerA]; ok {
+		t.Fatal("Expected no wants sent to peer A")
+	}
+	if fpm.HasPeer(peerA) {
+		t.Fatal("Expected peer A to stay out of the session")
+	}
+}
+
+func TestResendCodedWants(t *testing.T) {
+	cids := testutil.GenerateCids(1)
+	peers := testutil.GeneratePeers(1)
+	peerA := peers[0]
+	sid := uint64(1)
+	pm := newMockPeerManager()
+	fpm := newFakeSessionPeerManager()
+	swc := newMockSessionMgr()
+	bpm := bsbpm.New()
+	onSend := func(peer.ID, []cid.Cid, []cid.Cid) {}
+	onPeersExhausted := func([]cid.Cid) {}
+	spm := newSessionWantSender(sid, pm, fpm, swc, bpm, onSend, onPeersExhausted)
+	defer spm.Shutdown()
+
+	go spm.Run()
+
+	// want 4 coded blocks of cid0
+	spm.AddC(cids[0], "nc", 4)
+	// peerA: HAVE cid0
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
+
+	// Should have sent
+	// peerA: want 4 coded blocks of cid0
+	sw, ok := pm.waitNextWants()[peerA]
+	if !ok || len(sw.codedWants()) != 1 || sw.codedWants()[0].Count != 4 {
+		t.Fatal("Expected coded want sent to peer A")
+	}
+	pm.clearWants()
+
+	// peerA: HAVE cid0 again, which changes nothing
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
+	if sw, ok := pm.waitNextWants()[peerA]; ok && len(sw.codedWants()) > 0 {
+		t.Fatal("Expected no coded want sent to peer A")
+	}
+
+	// The coded want or its blocks were lost
+	spm.ResendC()
+
+	// Should have sent the coded want again
+	sw, ok = pm.waitNextWants()[peerA]
+	if !ok || len(sw.codedWants()) != 1 || sw.codedWants()[0].Count != 4 {
+		t.Fatal("Expected coded want sent to peer A again")
+	}
+}
+
 func TestPeersExhausted(t *testing.T) {
 	cids := testutil.GenerateCids(3)
 	peers := testutil.GeneratePeers(2)
@@ -520,11 +636,11 @@ func TestPeersExhausted(t *testing.T) {
 	// peerA: HAVE cid0
 	bpm.ReceiveFrom(peerA, []cid.Cid{cids[0]}, []cid.Cid{})
 	// Note: this also registers peer A as being available
-	spm.Update(peerA, []cid.Cid{cids[0]}, []cid.Cid{}, []cid.Cid{})
+	spm.Update(peerA, []cid.Cid{cids[0]}, []cid.Cid{}, []cid.Cid{}, nil)
 
 	// peerA: DONT_HAVE cid1
 	bpm.ReceiveFrom(peerA, []cid.Cid{}, []cid.Cid{cids[1]})
-	spm.Update(peerA, []cid.Cid{}, []cid.Cid{}, []cid.Cid{cids[1]})
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{}, []cid.Cid{cids[1]}, nil)
 
 	time.Sleep(5 * time.Millisecond)
 
@@ -540,11 +656,11 @@ func TestPeersExhausted(t *testing.T) {
 	// peerB: HAVE cid0
 	bpm.ReceiveFrom(peerB, []cid.Cid{cids[0]}, []cid.Cid{})
 	// Note: this also registers peer B as being available
-	spm.Update(peerB, []cid.Cid{cids[0]}, []cid.Cid{}, []cid.Cid{})
+	spm.Update(peerB, []cid.Cid{cids[0]}, []cid.Cid{}, []cid.Cid{}, nil)
 
 	// peerB: DONT_HAVE cid1, cid2
 	bpm.ReceiveFrom(peerB, []cid.Cid{}, []cid.Cid{cids[1], cids[2]})
-	spm.Update(peerB, []cid.Cid{}, []cid.Cid{}, []cid.Cid{cids[1], cids[2]})
+	spm.Update(peerB, []cid.Cid{}, []cid.Cid{}, []cid.Cid{cids[1], cids[2]}, nil)
 
 	// Wait for processing to complete
 	pm.waitNextWants()
@@ -558,7 +674,7 @@ func TestPeersExhausted(t *testing.T) {
 
 	// peerA: DONT_HAVE cid2
 	bpm.ReceiveFrom(peerA, []cid.Cid{}, []cid.Cid{cids[2]})
-	spm.Update(peerA, []cid.Cid{}, []cid.Cid{}, []cid.Cid{cids[2]})
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{}, []cid.Cid{cids[2]}, nil)
 
 	// Wait for processing to complete
 	pm.waitNextWants()
@@ -597,15 +713,15 @@ func TestPeersExhaustedLastWaitingPeerUnavailable(t *testing.T) {
 	// peerA: HAVE cid0
 	bpm.ReceiveFrom(peerA, []cid.Cid{cids[0]}, []cid.Cid{})
 	// Note: this also registers peer A as being available
-	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 	// peerB: HAVE cid0
 	bpm.ReceiveFrom(peerB, []cid.Cid{cids[0]}, []cid.Cid{})
 	// Note: this also registers peer B as being available
-	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 
 	// peerA: DONT_HAVE cid1
 	bpm.ReceiveFrom(peerA, []cid.Cid{}, []cid.Cid{cids[1]})
-	spm.Update(peerA, []cid.Cid{}, []cid.Cid{}, []cid.Cid{cids[0]})
+	spm.Update(peerA, []cid.Cid{}, []cid.Cid{}, []cid.Cid{cids[0]}, nil)
 
 	time.Sleep(5 * time.Millisecond)
 
@@ -644,11 +760,11 @@ func TestPeersExhaustedAllPeersUnavailable(t *testing.T) {
 	spm.Add(cids)
 
 	// peerA: receive block for cid0 (and register peer A with sessionWantSender)
-	spm.Update(peerA, []cid.Cid{cids[0]}, []cid.Cid{}, []cid.Cid{})
+	spm.Update(peerA, []cid.Cid{cids[0]}, []cid.Cid{}, []cid.Cid{}, nil)
 	// peerB: HAVE cid1
 	bpm.ReceiveFrom(peerB, []cid.Cid{cids[0]}, []cid.Cid{})
 	// Note: this also registers peer B as being available
-	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{})
+	spm.Update(peerB, []cid.Cid{}, []cid.Cid{cids[0]}, []cid.Cid{}, nil)
 
 	time.Sleep(5 * time.Millisecond)
 
@@ -684,7 +800,7 @@ func TestConsecutiveDontHaveLimit(t *testing.T) {
 	spm.Add(cids)
 
 	// Receive a block from peer (adds it to the session)
-	spm.Update(p, cids[:1], []cid.Cid{}, []cid.Cid{})
+	spm.Update(p, cids[:1], []cid.Cid{}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	time.Sleep(10 * time.Millisecond)
@@ -697,7 +813,7 @@ func TestConsecutiveDontHaveLimit(t *testing.T) {
 	// Receive DONT_HAVEs from peer that do not exceed limit
 	for _, c := range cids[1:peerDontHaveLimit] {
 		bpm.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{c})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c}, nil)
 	}
 
 	// Wait for processing to complete
@@ -711,7 +827,7 @@ func TestConsecutiveDontHaveLimit(t *testing.T) {
 	// Receive DONT_HAVEs from peer that exceed limit
 	for _, c := range cids[peerDontHaveLimit:] {
 		bpm.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{c})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c}, nil)
 	}
 
 	// Wait for processing to complete
@@ -742,7 +858,7 @@ func TestConsecutiveDontHaveLimitInterrupted(t *testing.T) {
 	spm.Add(cids)
 
 	// Receive a block from peer (adds it to the session)
-	spm.Update(p, cids[:1], []cid.Cid{}, []cid.Cid{})
+	spm.Update(p, cids[:1], []cid.Cid{}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	time.Sleep(5 * time.Millisecond)
@@ -758,17 +874,17 @@ func TestConsecutiveDontHaveLimitInterrupted(t *testing.T) {
 	for _, c := range cids[1:peerDontHaveLimit] {
 		// DONT_HAVEs
 		bpm.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{c})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c}, nil)
 	}
 	for _, c := range cids[peerDontHaveLimit : peerDontHaveLimit+1] {
 		// HAVEs
 		bpm.ReceiveFrom(p, []cid.Cid{c}, []cid.Cid{})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{c}, []cid.Cid{})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{c}, []cid.Cid{}, nil)
 	}
 	for _, c := range cids[peerDontHaveLimit+1:] {
 		// DONT_HAVEs
 		bpm.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{c})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c}, nil)
 	}
 
 	// Wait for processing to complete
@@ -799,7 +915,7 @@ func TestConsecutiveDontHaveReinstateAfterRemoval(t *testing.T) {
 	spm.Add(cids)
 
 	// Receive a block from peer (adds it to the session)
-	spm.Update(p, cids[:1], []cid.Cid{}, []cid.Cid{})
+	spm.Update(p, cids[:1], []cid.Cid{}, []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	time.Sleep(5 * time.Millisecond)
@@ -812,7 +928,7 @@ func TestConsecutiveDontHaveReinstateAfterRemoval(t *testing.T) {
 	// Receive DONT_HAVEs from peer that exceed limit
 	for _, c := range cids[1 : peerDontHaveLimit+2] {
 		bpm.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{c})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c}, nil)
 	}
 
 	// Wait for processing to complete
@@ -825,7 +941,7 @@ func TestConsecutiveDontHaveReinstateAfterRemoval(t *testing.T) {
 
 	// Receive a HAVE from peer (adds it back into the session)
 	bpm.ReceiveFrom(p, cids[:1], []cid.Cid{})
-	spm.Update(p, []cid.Cid{}, cids[:1], []cid.Cid{})
+	spm.Update(p, []cid.Cid{}, cids[:1], []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	time.Sleep(10 * time.Millisecond)
@@ -840,7 +956,7 @@ func TestConsecutiveDontHaveReinstateAfterRemoval(t *testing.T) {
 	// Receive DONT_HAVEs from peer that don't exceed limit
 	for _, c := range cids2[1:peerDontHaveLimit] {
 		bpm.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{c})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c}, nil)
 	}
 
 	// Wait for processing to complete
@@ -854,7 +970,7 @@ func TestConsecutiveDontHaveReinstateAfterRemoval(t *testing.T) {
 	// Receive DONT_HAVEs from peer that exceed limit
 	for _, c := range cids2[peerDontHaveLimit:] {
 		bpm.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{c})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c}, nil)
 	}
 
 	// Wait for processing to complete
@@ -886,7 +1002,7 @@ func TestConsecutiveDontHaveDontRemoveIfHasWantedBlock(t *testing.T) {
 
 	// Receive a HAVE from peer (adds it to the session)
 	bpm.ReceiveFrom(p, cids[:1], []cid.Cid{})
-	spm.Update(p, []cid.Cid{}, cids[:1], []cid.Cid{})
+	spm.Update(p, []cid.Cid{}, cids[:1], []cid.Cid{}, nil)
 
 	// Wait for processing to complete
 	time.Sleep(10 * time.Millisecond)
@@ -899,7 +1015,7 @@ func TestConsecutiveDontHaveDontRemoveIfHasWantedBlock(t *testing.T) {
 	// Receive DONT_HAVEs from peer that exceed limit
 	for _, c := range cids[1 : peerDontHaveLimit+5] {
 		bpm.ReceiveFrom(p, []cid.Cid{}, []cid.Cid{c})
-		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c})
+		spm.Update(p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{c}, nil)
 	}
 
 	// Wait for processing to complete
@@ -911,3 +1027,38 @@ func TestConsecutiveDontHaveDontRemoveIfHasWantedBlock(t *testing.T) {
 		t.Fatal("Expected peer to be available")
 	}
 }
+
+func TestSplitCoded(t *testing.T) {
+	peers := testutil.GeneratePeers(3)
+	p0 := peers[0]
+	p1 := peers[1]
+	p2 := peers[2]
+
+	type testcase struct {
+		count int
+		ranks map[peer.ID]int
+		exp   map[peer.ID]int
+	}
+	testcases := []testcase{
+		// No ranks known yet: an even share each
+		testcase{10, map[peer.ID]int{}, map[peer.ID]int{p0: 4, p1: 4, p2: 4}},
+		// Shares in proportion to the rank, and an even share to the peer
+		// whose rank is unknown
+		testcase{10, map[peer.ID]int{p0: 8, p1: 2}, map[peer.ID]int{p0: 5, p1: 2, p2: 4}},
+		// A peer is asked for no more than its rank, and a peer without
+		// coded blocks for nothing
+		testcase{10, map[peer.ID]int{p0: 3, p1: 0, p2: 1}, map[peer.ID]int{p0: 3, p1: 0, p2: 1}},
+		// What the ranked peers cannot cover goes to the unknown peer
+		testcase{10, map[peer.ID]int{p0: 1, p1: 1}, map[peer.ID]int{p0: 1, p1: 1, p2: 8}},
+		testcase{0, map[peer.ID]int{}, map[peer.ID]int{}},
+	}
+
+	for i, tc := range testcases {
+		shares := splitCoded(tc.count, peers, tc.ranks)
+		for _, p := range peers {
+			if shares[p] != tc.exp[p] {
+				t.Fatalf("test case %d failed: expected %v, got %v", i, tc.exp, shares)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package sessioninterestmanager
+
+import (
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
+	"github.com/ipfs/go-block-format/coding"
+	// Register the built-in coding schemes.
+	_ "github.com/ipfs/go-block-format/coding/rlnc"
+	_ "github.com/ipfs/go-block-format/coding/rs"
+)
+
+// codedRank tells the coded blocks of a parent that raise the rank of what
+// was received apart from those that do not, so that only innovative blocks
+// count towards coded wants. The packets describe their generation, so the
+// decoder is made from the first one.
+type codedRank struct {
+	coding string
+
+	dec coding.Decoder
+}
+
+func newCodedRank(coding string) *codedRank {
+	return &codedRank{coding: coding}
+}
+
+// add reports whether the coded block b is innovative.
+func (r *codedRank) add(b []byte) (bool, error) {
+	payload, err := codedleaf.Packet(b)
+	if err != nil {
+		return false, err
+	}
+
+	if r.dec == nil {
+		scheme, err := coding.Lookup(r.coding)
+		if err != nil {
+			return false, err
+		}
+		if r.dec, err = scheme.DecoderFor(payload); err != nil {
+			return false, err
+		}
+	}
+	return r.dec.Add(payload)
+}
+
+// rank returns the number of innovative blocks received.
+func (r *codedRank) rank() int {
+	if r.dec == nil {
+		return 0
+	}
+	return r.dec.Rank()
+}
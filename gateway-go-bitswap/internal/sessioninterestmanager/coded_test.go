@@ -0,0 +1,59 @@
+package sessioninterestmanager
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding/rlnc"
+	"github.com/ipfs/go-block-format/coding/rs"
+	cid "github.com/ipfs/go-cid"
+)
+
+func codedBlock(t *testing.T, packet []byte, parent cid.Cid) *blocks.CodedBlock {
+	b := blocks.NewBlock(codedleaf.FromPacket(packet))
+	cb, err := blocks.NewCodedBlockWithCid(b.RawData(), b.Cid(), parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cb
+}
+
+func TestSplitWantedUnwantedC(t *testing.T) {
+	sim := New()
+	ses := uint64(1)
+	ncParent := blocks.NewBlock([]byte("nc parent")).Cid()
+	rsParent := blocks.NewBlock([]byte("rs parent")).Cid()
+	sim.RecordSessionInterestC(ses, ncParent, "nc", 3)
+	sim.RecordSessionInterestC(ses, rsParent, "rs", 2)
+
+	data := make([]byte, 300)
+	ncEnc, _ := rlnc.NewEncoder(data, 3, 100, rlnc.Systematic())
+	rsEnc, _ := rs.NewEncoder(data, 2, 2)
+	p0 := ncEnc.Encode()
+	s0, _ := rsEnc.Shard(0)
+	s3, _ := rsEnc.Shard(3)
+
+	blks := []*blocks.CodedBlock{
+		codedBlock(t, p0.Marshal(), ncParent),
+		// The same packet twice is not innovative.
+		codedBlock(t, p0.Marshal(), ncParent),
+		codedBlock(t, s0.Marshal(), rsParent),
+		codedBlock(t, s3.Marshal(), rsParent),
+	}
+	wanted, notWanted := sim.SplitWantedUnwantedC(blks)
+	if len(wanted) != 3 || len(notWanted) != 1 {
+		t.Fatalf("expected 3 innovative blocks, got %d wanted and %d not wanted", len(wanted), len(notWanted))
+	}
+
+	if coding, ok := sim.Coding(rsParent); !ok || coding != "rs" {
+		t.Fatalf("expected rs coded wants, got %q", coding)
+	}
+	// Both rs blocks were wanted, none is any more.
+	if sim.IsCodedInterest(rsParent) {
+		t.Fatal("expected the rs want to be complete")
+	}
+	if !sim.IsCodedInterest(ncParent) {
+		t.Fatal("expected 2 more nc blocks to be wanted")
+	}
+}
@@ -6,14 +6,21 @@ import (
 
 	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
 	)
 
+var log = logging.Logger("bs:sim")
+
 // SessionInterestManager records the CIDs that each session is interested in.
 type SessionInterestManager struct {
 	lk    sync.RWMutex
 	wants map[cid.Cid]map[uint64]bool
 	cwants map[cid.Cid]map[uint64]int
-	
+
+	// decoders tracks the rank of the coded blocks received for the
+	// parents of coded wants.
+	decodingkLk sync.Mutex
+	decoders    map[cid.Cid]*codedRank
 }
 
 // New initializes a new SessionInterestManager.
@@ -29,7 +36,7 @@ func New() *SessionInterestManager {
 		// the block as they may have other blocks the session is interested in.
 		wants: make(map[cid.Cid]map[uint64]bool),
 		cwants: make(map[cid.Cid]map[uint64]int),
-
+		decoders: make(map[cid.Cid]*codedRank),
 	}
 }
 
@@ -50,10 +57,18 @@ func (sim *SessionInterestManager) RecordSessionInterest(ses uint64, ks []cid.Ci
 	}
 }
 
-func (sim *SessionInterestManager) RecordSessionInterestC(ses uint64, k cid.Cid, count int) {
+// RecordSessionInterestC records that the session wants count more coded
+// blocks of k with coding.
+func (sim *SessionInterestManager) RecordSessionInterestC(ses uint64, k cid.Cid, coding string, count int) {
 	sim.lk.Lock()
 	defer sim.lk.Unlock()
 
+	sim.decodingkLk.Lock()
+	if _, ok := sim.decoders[k]; !ok {
+		sim.decoders[k] = newCodedRank(coding)
+	}
+	sim.decodingkLk.Unlock()
+
 	if want, ok := sim.cwants[k]; ok {
 		if _, ok := want[ses]; ok{
 			want[ses] += count
@@ -93,7 +108,6 @@ func (sim *SessionInterestManager) RemoveSession(ses uint64) []cid.Cid {
 	for _, k := range deletedKs{
 		fmt.Println("Debug: sim-removedecoder", k)
 		delete(sim.decoders, k)
-		delete(sim.decoderdata, k)
 	}
 
 	return deletedKs
@@ -144,7 +158,6 @@ func (sim *SessionInterestManager) RemoveSessionInterested(ses uint64, ks []cid.
 	defer sim.decodingkLk.Unlock()
 	for _,k:=range deletedKs{
 		delete(sim.decoders, k)
-		delete(sim.decoderdata, k)
 	}
 
 	return deletedKs
@@ -252,15 +265,21 @@ func (sim *SessionInterestManager) InterestedSessions(blks []cid.Cid, haves []ci
 	return ses
 }
 
-func (sim *SessionInterestManager) HasDecoder(cid cid.Cid) bool {
+// Coding returns the coding of the coded wants for parent, if there are
+// any.
+func (sim *SessionInterestManager) Coding(parent cid.Cid) (string, bool) {
 	sim.decodingkLk.Lock()
 	defer sim.decodingkLk.Unlock()
-	_, ok := sim.decoders[cid]
-	return ok
+	dc, ok := sim.decoders[parent]
+	if !ok {
+		return "", false
+	}
+	return dc.coding, true
 }
 
-
-
+// When bitswap receives coded blocks it calls SplitWantedUnwantedC() to
+// discard the ones that are not innovative, and count the others towards
+// the coded wants of their parent.
 func (sim *SessionInterestManager) SplitWantedUnwantedC(blks []*blocks.CodedBlock) ([]*blocks.CodedBlock, []*blocks.CodedBlock) {
 	sim.decodingkLk.Lock()
 	defer sim.decodingkLk.Unlock()
@@ -278,14 +297,12 @@ func (sim *SessionInterestManager) SplitWantedUnwantedC(blks []*blocks.CodedBloc
 		if _, ok= sim.cwants[b.Parent()]; !ok{
 			continue
 		}
-		//data := sim.decoderdata[b.Parent()]
-		//dc.SetSymbolsStorage(&data)
-
-		d := b.RawData()
-		d = d[10:len(d)-4]
-		r := dc.Rank()
-		dc.ConsumePayload(&d)
-		if dc.Rank() > r {
+		r := dc.rank()
+		innovative, err := dc.add(b.RawData())
+		if err != nil {
+			log.Debugf("not a %s block of %s: %s", dc.coding, b.Parent(), err)
+		}
+		if innovative {
 			wantedBlks = append(wantedBlks, b)
 			want:=sim.cwants[b.Parent()]
 			for ses, co:= range want{
@@ -302,7 +319,7 @@ func (sim *SessionInterestManager) SplitWantedUnwantedC(blks []*blocks.CodedBloc
 			notWantedBlks = append(notWantedBlks, b)
                         fmt.Println("Debug: sim-split: linear dependant block", b.Cid())
 		}
-		fmt.Println("Debug: sim-splitc: decoder ranks", r, dc.Rank())
+		fmt.Println("Debug: sim-splitc: decoder ranks", r, dc.rank())
 	}
 	return wantedBlks, notWantedBlks
 }
@@ -22,6 +22,8 @@ type Session interface {
 	exchange.Fetcher
 	ID() uint64
 	ReceiveFrom(peer.ID, []cid.Cid, []cid.Cid, []cid.Cid, map[cid.Cid]int)
+	ReceivedInvalidFrom(peer.ID)
+	ReceiveRanksFrom(peer.ID, []cid.Cid)
 	Shutdown()
 }
 
@@ -179,6 +181,56 @@ func (sm *SessionManager) ReceiveFrom(ctx context.Context, p peer.ID, blks []cid
 	sm.peerManager.SendCancels(ctx, cancel)
 }
 
+// ReceivedInvalidFrom is called when blocks coded from parents, received from
+// p, failed verification. The sessions wanting them stop sending wants to p.
+func (sm *SessionManager) ReceivedInvalidFrom(p peer.ID, parents []cid.Cid) {
+	blksc := make(map[cid.Cid]int, len(parents))
+	for _, c := range parents {
+		blksc[c]++
+	}
+	for _, id := range sm.sessionInterestManager.InterestedSessions(nil, nil, nil, blksc) {
+		sm.sessLk.RLock()
+		if sm.sessions == nil { // check if SessionManager was shutdown
+			sm.sessLk.RUnlock()
+			return
+		}
+		sess, ok := sm.sessions[id]
+		sm.sessLk.RUnlock()
+
+		if ok {
+			sess.ReceivedInvalidFrom(p)
+		}
+	}
+}
+
+// ReceiveRanks is called when a peer sends coded HAVE / DONT_HAVEs, telling
+// how many innovative coded blocks of parents it holds. The ranks are
+// recorded apart from the HAVE / DONT_HAVEs of the parents, and the sessions
+// wanting coded blocks of the parents are told about them.
+func (sm *SessionManager) ReceiveRanks(p peer.ID, ranks map[cid.Cid]int) {
+	sm.blockPresenceManager.ReceiveRanks(p, ranks)
+
+	blksc := make(map[cid.Cid]int, len(ranks))
+	parents := make([]cid.Cid, 0, len(ranks))
+	for c := range ranks {
+		blksc[c] = 1
+		parents = append(parents, c)
+	}
+	for _, id := range sm.sessionInterestManager.InterestedSessions(nil, nil, nil, blksc) {
+		sm.sessLk.RLock()
+		if sm.sessions == nil { // check if SessionManager was shutdown
+			sm.sessLk.RUnlock()
+			return
+		}
+		sess, ok := sm.sessions[id]
+		sm.sessLk.RUnlock()
+
+		if ok {
+			sess.ReceiveRanksFrom(p, parents)
+		}
+	}
+}
+
 // CancelSessionWants is called when a session cancels wants because a call to
 // GetBlocks() is cancelled
 func (sm *SessionManager) CancelSessionWants(sesid uint64, wants []cid.Cid) {
@@ -25,6 +25,8 @@ type fakeSession struct {
 	ks         []cid.Cid
 	wantBlocks []cid.Cid
 	wantHaves  []cid.Cid
+	invalid    []peer.ID
+	ranked     []cid.Cid
 	id         uint64
 	pm         *fakeSesPeerManager
 	sm         bssession.SessionManager
@@ -40,11 +42,17 @@ func (*fakeSession) GetBlocks(context.Context, []cid.Cid) (<-chan blocks.Block,
 func (fs *fakeSession) ID() uint64 {
 	return fs.id
 }
-func (fs *fakeSession) ReceiveFrom(p peer.ID, ks []cid.Cid, wantBlocks []cid.Cid, wantHaves []cid.Cid) {
+func (fs *fakeSession) ReceiveFrom(p peer.ID, ks []cid.Cid, wantBlocks []cid.Cid, wantHaves []cid.Cid, ksc map[cid.Cid]int) {
 	fs.ks = append(fs.ks, ks...)
 	fs.wantBlocks = append(fs.wantBlocks, wantBlocks...)
 	fs.wantHaves = append(fs.wantHaves, wantHaves...)
 }
+func (fs *fakeSession) ReceivedInvalidFrom(p peer.ID) {
+	fs.invalid = append(fs.invalid, p)
+}
+func (fs *fakeSession) ReceiveRanksFrom(p peer.ID, parents []cid.Cid) {
+	fs.ranked = append(fs.ranked, parents...)
+}
 func (fs *fakeSession) Shutdown() {
 	fs.sm.RemoveSession(fs.id)
 }
@@ -65,10 +73,11 @@ type fakePeerManager struct {
 	cancels []cid.Cid
 }
 
-func (*fakePeerManager) RegisterSession(peer.ID, bspm.Session)                    {}
-func (*fakePeerManager) UnregisterSession(uint64)                                 {}
-func (*fakePeerManager) SendWants(context.Context, peer.ID, []cid.Cid, []cid.Cid) {}
-func (*fakePeerManager) BroadcastWantHaves(context.Context, []cid.Cid)            {}
+func (*fakePeerManager) RegisterSession(peer.ID, bspm.Session)                                      {}
+func (*fakePeerManager) UnregisterSession(uint64)                                                   {}
+func (*fakePeerManager) SendWants(context.Context, peer.ID, []cid.Cid, []cid.Cid, []bspm.CodedWant) {}
+func (*fakePeerManager) BroadcastWantHaves(context.Context, []cid.Cid)                              {}
+func (*fakePeerManager) SendCancelC(context.Context, peer.ID, cid.Cid, string, int)                 {}
 func (fpm *fakePeerManager) SendCancels(ctx context.Context, cancels []cid.Cid) {
 	fpm.lk.Lock()
 	defer fpm.lk.Unlock()
@@ -129,21 +138,21 @@ func TestReceiveFrom(t *testing.T) {
 	sim.RecordSessionInterest(firstSession.ID(), []cid.Cid{block.Cid()})
 	sim.RecordSessionInterest(thirdSession.ID(), []cid.Cid{block.Cid()})
 
-	sm.ReceiveFrom(ctx, p, []cid.Cid{block.Cid()}, []cid.Cid{}, []cid.Cid{})
+	sm.ReceiveFrom(ctx, p, []cid.Cid{block.Cid()}, []cid.Cid{}, []cid.Cid{}, nil)
 	if len(firstSession.ks) == 0 ||
 		len(secondSession.ks) > 0 ||
 		len(thirdSession.ks) == 0 {
 		t.Fatal("should have received blocks but didn't")
 	}
 
-	sm.ReceiveFrom(ctx, p, []cid.Cid{}, []cid.Cid{block.Cid()}, []cid.Cid{})
+	sm.ReceiveFrom(ctx, p, []cid.Cid{}, []cid.Cid{block.Cid()}, []cid.Cid{}, nil)
 	if len(firstSession.wantBlocks) == 0 ||
 		len(secondSession.wantBlocks) > 0 ||
 		len(thirdSession.wantBlocks) == 0 {
 		t.Fatal("should have received want-blocks but didn't")
 	}
 
-	sm.ReceiveFrom(ctx, p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{block.Cid()})
+	sm.ReceiveFrom(ctx, p, []cid.Cid{}, []cid.Cid{}, []cid.Cid{block.Cid()}, nil)
 	if len(firstSession.wantHaves) == 0 ||
 		len(secondSession.wantHaves) > 0 ||
 		len(thirdSession.wantHaves) == 0 {
@@ -155,6 +164,69 @@ func TestReceiveFrom(t *testing.T) {
 	}
 }
 
+func TestReceivedInvalidFrom(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	notif := notifications.New()
+	defer notif.Shutdown()
+	sim := bssim.New()
+	bpm := bsbpm.New()
+	pm := &fakePeerManager{}
+	sm := New(ctx, sessionFactory, sim, peerManagerFactory, bpm, pm, notif, "")
+
+	p := peer.ID(fmt.Sprint(123))
+	parent := blocks.NewBlock([]byte("parent"))
+
+	firstSession := sm.NewSession(ctx, time.Second, delay.Fixed(time.Minute)).(*fakeSession)
+	secondSession := sm.NewSession(ctx, time.Second, delay.Fixed(time.Minute)).(*fakeSession)
+
+	sim.RecordSessionInterest(firstSession.ID(), []cid.Cid{parent.Cid()})
+	sim.RecordSessionInterestC(firstSession.ID(), parent.Cid(), "nc", 4)
+
+	sm.ReceivedInvalidFrom(p, []cid.Cid{parent.Cid(), parent.Cid()})
+	if len(firstSession.invalid) != 1 || firstSession.invalid[0] != p || len(secondSession.invalid) > 0 {
+		t.Fatal("expected only the session wanting the blocks to learn about the peer")
+	}
+}
+
+func TestReceiveRanks(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	notif := notifications.New()
+	defer notif.Shutdown()
+	sim := bssim.New()
+	bpm := bsbpm.New()
+	pm := &fakePeerManager{}
+	sm := New(ctx, sessionFactory, sim, peerManagerFactory, bpm, pm, notif, "")
+
+	p := peer.ID(fmt.Sprint(123))
+	parent := blocks.NewBlock([]byte("parent"))
+
+	firstSession := sm.NewSession(ctx, time.Second, delay.Fixed(time.Minute)).(*fakeSession)
+	secondSession := sm.NewSession(ctx, time.Second, delay.Fixed(time.Minute)).(*fakeSession)
+
+	sim.RecordSessionInterest(firstSession.ID(), []cid.Cid{parent.Cid()})
+	sim.RecordSessionInterestC(firstSession.ID(), parent.Cid(), "nc", 4)
+
+	// A coded DONT_HAVE tells the peer holds no coded blocks of the parent,
+	// not that it lacks the parent.
+	sm.ReceiveRanks(p, map[cid.Cid]int{parent.Cid(): 0})
+	if rank, ok := bpm.PeerRank(p, parent.Cid()); !ok || rank != 0 {
+		t.Fatal("expected the rank to be recorded")
+	}
+	if bpm.PeerDoesNotHaveBlock(p, parent.Cid()) || bpm.PeerHasBlock(p, parent.Cid()) {
+		t.Fatal("expected the coded DONT_HAVE not to be a DONT_HAVE for the parent")
+	}
+	if len(firstSession.wantHaves) > 0 || len(firstSession.wantBlocks) > 0 {
+		t.Fatal("expected the session not to receive a HAVE / DONT_HAVE")
+	}
+	if len(firstSession.ranked) != 1 || !firstSession.ranked[0].Equals(parent.Cid()) || len(secondSession.ranked) > 0 {
+		t.Fatal("expected only the session wanting the coded blocks to learn about the rank")
+	}
+}
+
 func TestReceiveBlocksWhenManagerShutdown(t *testing.T) {
 	ctx := context.Background()
 	ctx, cancel := context.WithCancel(ctx)
@@ -182,7 +254,7 @@ func TestReceiveBlocksWhenManagerShutdown(t *testing.T) {
 	// wait for sessions to get removed
 	time.Sleep(10 * time.Millisecond)
 
-	sm.ReceiveFrom(ctx, p, []cid.Cid{block.Cid()}, []cid.Cid{}, []cid.Cid{})
+	sm.ReceiveFrom(ctx, p, []cid.Cid{block.Cid()}, []cid.Cid{}, []cid.Cid{}, nil)
 	if len(firstSession.ks) > 0 ||
 		len(secondSession.ks) > 0 ||
 		len(thirdSession.ks) > 0 {
@@ -217,7 +289,7 @@ func TestReceiveBlocksWhenSessionContextCancelled(t *testing.T) {
 	// wait for sessions to get removed
 	time.Sleep(10 * time.Millisecond)
 
-	sm.ReceiveFrom(ctx, p, []cid.Cid{block.Cid()}, []cid.Cid{}, []cid.Cid{})
+	sm.ReceiveFrom(ctx, p, []cid.Cid{block.Cid()}, []cid.Cid{}, []cid.Cid{}, nil)
 	if len(firstSession.ks) == 0 ||
 		len(secondSession.ks) > 0 ||
 		len(thirdSession.ks) == 0 {
@@ -241,7 +313,7 @@ func TestShutdown(t *testing.T) {
 	cids := []cid.Cid{block.Cid()}
 	firstSession := sm.NewSession(ctx, time.Second, delay.Fixed(time.Minute)).(*fakeSession)
 	sim.RecordSessionInterest(firstSession.ID(), cids)
-	sm.ReceiveFrom(ctx, p, []cid.Cid{}, []cid.Cid{}, cids)
+	sm.ReceiveFrom(ctx, p, []cid.Cid{}, []cid.Cid{}, cids, nil)
 
 	if !bpm.HasKey(block.Cid()) {
 		t.Fatal("expected cid to be added to block presence manager")
@@ -33,6 +33,10 @@ type BitSwapMessage interface {
 	Haves() []cid.Cid
 	// DontHaves returns the Cids for each DONT_HAVE
 	DontHaves() []cid.Cid
+	// CodedHaves returns the coded HAVE / DONT_HAVE in the message, with the
+	// rank of the sender for each parent. They are about the coded blocks of
+	// the parents, not the parents, and are not among the HAVE / DONT_HAVE
+	CodedHaves() []BlockPresence
 	// PendingBytes returns the number of outstanding bytes of data that the
 	// engine has yet to send to the client (because they didn't fit in this
 	// message)
@@ -41,7 +45,9 @@ type BitSwapMessage interface {
 	// AddEntry adds an entry to the Wantlist.
 	AddEntry(key cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, sendDontHave bool) int
 
-	AddCodedEntry(k cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, sendDontHave bool, coding string, count int) int
+	// AddCodedEntry adds a want for count coded blocks of k to the Wantlist,
+	// along with the number of innovative coded blocks of k we hold.
+	AddCodedEntry(k cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, sendDontHave bool, coding string, count int, rank int) int
 
 	// Cancel adds a CANCEL for the given CID to the message
 	// Returns the size of the CANCEL entry in the protobuf
@@ -69,6 +75,9 @@ type BitSwapMessage interface {
 	AddHave(cid.Cid)
 	// AddDontHave adds a DONT_HAVE for the given Cid to the message
 	AddDontHave(cid.Cid)
+	// AddCodedHave adds a HAVE for the coded blocks of the given Cid with the
+	// number of innovative ones we hold, or a DONT_HAVE if we hold none
+	AddCodedHave(c cid.Cid, coding string, rank int)
 	// SetPendingBytes sets the number of bytes of data that are yet to be sent
 	// to the client (because they didn't fit in this message)
 	SetPendingBytes(int32)
@@ -95,10 +104,14 @@ type Exportable interface {
 	ToNetV1(w io.Writer) error
 }
 
-// BlockPresence represents a HAVE / DONT_HAVE for a given Cid
+// BlockPresence represents a HAVE / DONT_HAVE for a given Cid. A coded
+// presence is about the coded blocks of the Cid, and tells how many
+// innovative ones the sender holds.
 type BlockPresence struct {
-	Cid  cid.Cid
-	Type pb.Message_BlockPresenceType
+	Cid    cid.Cid
+	Type   pb.Message_BlockPresenceType
+	Coding string
+	Rank   int
 }
 
 // Entry is a wantlist entry in a Bitswap message, with flags indicating
@@ -131,6 +144,7 @@ func (e *Entry) ToPB() pb.Message_Wantlist_Entry {
 		// mars todo remove coding
 		Coding:   []byte(e.Coding),
 		Count:    int32(e.Count),
+		Rank:     int32(e.Rank),
 	}
 }
 
@@ -157,6 +171,9 @@ type impl struct {
 	wantlist       map[cid.Cid]*Entry
 	blocks         map[cid.Cid]blocks.Block
 	blockPresences map[cid.Cid]pb.Message_BlockPresenceType
+	// codedPresences holds the coded HAVE / DONT_HAVE, apart from the
+	// block presences of the same cids
+	codedPresences map[cid.Cid]BlockPresence
 	pendingBytes   int32
 }
 
@@ -171,6 +188,7 @@ func newMsg(full bool) *impl {
 		wantlist:       make(map[cid.Cid]*Entry),
 		blocks:         make(map[cid.Cid]blocks.Block),
 		blockPresences: make(map[cid.Cid]pb.Message_BlockPresenceType),
+		codedPresences: make(map[cid.Cid]BlockPresence),
 	}
 }
 
@@ -186,6 +204,9 @@ func (m *impl) Clone() BitSwapMessage {
 	for k := range m.blockPresences {
 		msg.blockPresences[k] = m.blockPresences[k]
 	}
+	for k := range m.codedPresences {
+		msg.codedPresences[k] = m.codedPresences[k]
+	}
 	msg.pendingBytes = m.pendingBytes
 	return msg
 }
@@ -202,6 +223,9 @@ func (m *impl) Reset(full bool) {
 	for k := range m.blockPresences {
 		delete(m.blockPresences, k)
 	}
+	for k := range m.codedPresences {
+		delete(m.codedPresences, k)
+	}
 	m.pendingBytes = 0
 }
 
@@ -214,7 +238,7 @@ func newMessageFromProto(pbm pb.Message) (BitSwapMessage, error) {
 			return nil, errCidMissing
 		}
 		//fmt.Println("Debug: receive message", "cid", e.Block.Cid , "coding", e.Coding ,"count", e.Count)
-		m.addEntry(e.Block.Cid, e.Priority, e.Cancel, e.WantType, e.SendDontHave,  string(e.Coding), int(e.Count))
+		m.addEntry(e.Block.Cid, e.Priority, e.Cancel, e.WantType, e.SendDontHave, string(e.Coding), int(e.Count), int(e.Rank))
 	}
 
 	// deprecated
@@ -255,6 +279,10 @@ func newMessageFromProto(pbm pb.Message) (BitSwapMessage, error) {
 		if !bi.Cid.Cid.Defined() {
 			return nil, errCidMissing
 		}
+		if len(bi.Coding) > 0 {
+			m.AddCodedHave(bi.Cid.Cid, string(bi.Coding), int(bi.Rank))
+			continue
+		}
 		m.AddBlockPresence(bi.Cid.Cid, bi.Type)
 	}
 
@@ -268,7 +296,7 @@ func (m *impl) Full() bool {
 }
 
 func (m *impl) Empty() bool {
-	return len(m.blocks) == 0 && len(m.wantlist) == 0 && len(m.blockPresences) == 0
+	return len(m.blocks) == 0 && len(m.wantlist) == 0 && len(m.blockPresences) == 0 && len(m.codedPresences) == 0
 }
 
 func (m *impl) Wantlist() []Entry {
@@ -290,7 +318,7 @@ func (m *impl) Blocks() []blocks.Block {
 func (m *impl) BlockPresences() []BlockPresence {
 	bps := make([]BlockPresence, 0, len(m.blockPresences))
 	for c, t := range m.blockPresences {
-		bps = append(bps, BlockPresence{c, t})
+		bps = append(bps, BlockPresence{Cid: c, Type: t})
 	}
 	return bps
 }
@@ -303,6 +331,14 @@ func (m *impl) DontHaves() []cid.Cid {
 	return m.getBlockPresenceByType(pb.Message_DontHave)
 }
 
+func (m *impl) CodedHaves() []BlockPresence {
+	bps := make([]BlockPresence, 0, len(m.codedPresences))
+	for _, bp := range m.codedPresences {
+		bps = append(bps, bp)
+	}
+	return bps
+}
+
 func (m *impl) getBlockPresenceByType(t pb.Message_BlockPresenceType) []cid.Cid {
 	cids := make([]cid.Cid, 0, len(m.blockPresences))
 	for c, bpt := range m.blockPresences {
@@ -326,24 +362,23 @@ func (m *impl) Remove(k cid.Cid) {
 }
 
 func (m *impl) Cancel(k cid.Cid) int {
-	return m.addEntry(k, 0, true, pb.Message_Wantlist_Block, false, "", 0)
+	return m.addEntry(k, 0, true, pb.Message_Wantlist_Block, false, "", 0, 0)
 }
 
 func (m *impl) CancelCoded(k cid.Cid, coding string, count int) int {
-	// todo hardcoded coding format
-	return m.addEntry(k, 0, true, pb.Message_Wantlist_Block, false, "nc", count)
+	return m.addEntry(k, 0, true, pb.Message_Wantlist_Block, false, coding, count, 0)
 }
 
 func (m *impl) AddEntry(k cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, sendDontHave bool) int {
-	return m.addEntry(k, priority, false, wantType, sendDontHave, "", 0)
+	return m.addEntry(k, priority, false, wantType, sendDontHave, "", 0, 0)
 }
 
 
-func (m *impl) AddCodedEntry(k cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, sendDontHave bool, coding string, count int) int {
-	return m.addEntry(k, priority, false, wantType, sendDontHave, coding, count)
+func (m *impl) AddCodedEntry(k cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, sendDontHave bool, coding string, count int, rank int) int {
+	return m.addEntry(k, priority, false, wantType, sendDontHave, coding, count, rank)
 }
 
-func (m *impl) addEntry(c cid.Cid, priority int32, cancel bool, wantType pb.Message_Wantlist_WantType, sendDontHave bool, coding string, count int) int {
+func (m *impl) addEntry(c cid.Cid, priority int32, cancel bool, wantType pb.Message_Wantlist_WantType, sendDontHave bool, coding string, count int, rank int) int {
 	fmt.Println("Debug: m-addentry, cid:", c, " cancel:", cancel, " Type:", wantType, " count:", count, "coding:", coding)
 	e, exists := m.wantlist[c]
 	if exists {
@@ -361,7 +396,12 @@ func (m *impl) addEntry(c cid.Cid, priority int32, cancel bool, wantType pb.Mess
 		// MARS message
 		if coding!=""{
 			e.Coding = coding
-			if (e.Cancel && cancel) || (!e.Cancel && !cancel) {
+			if !e.Cancel && !cancel {
+				// A coded want replaces the previous one, the requester
+				// knows best how many blocks it still needs.
+				e.Count = count
+				e.Rank = rank
+			} else if e.Cancel && cancel {
 				e.Count += count
 			} else {
 				c := e.Count - count
@@ -394,6 +434,7 @@ func (m *impl) addEntry(c cid.Cid, priority int32, cancel bool, wantType pb.Mess
 			WantType: wantType,
 			Coding:   coding,
 			Count:    count,
+			Rank:     rank,
 		},
 		SendDontHave: sendDontHave,
 		Cancel:       cancel,
@@ -406,6 +447,7 @@ func (m *impl) addEntry(c cid.Cid, priority int32, cancel bool, wantType pb.Mess
 
 func (m *impl) AddBlock(b blocks.Block) {
 	delete(m.blockPresences, b.Cid())
+	delete(m.codedPresences, b.Cid())
 	m.blocks[b.Cid()] = b
 }
 
@@ -424,6 +466,17 @@ func (m *impl) AddDontHave(c cid.Cid) {
 	m.AddBlockPresence(c, pb.Message_DontHave)
 }
 
+func (m *impl) AddCodedHave(c cid.Cid, coding string, rank int) {
+	if _, ok := m.blocks[c]; ok {
+		return
+	}
+	t := pb.Message_Have
+	if rank == 0 {
+		t = pb.Message_DontHave
+	}
+	m.codedPresences[c] = BlockPresence{c, t, coding, rank}
+}
+
 func (m *impl) Size() int {
 	size := 0
 	for _, block := range m.blocks {
@@ -432,6 +485,10 @@ func (m *impl) Size() int {
 	for c := range m.blockPresences {
 		size += BlockPresenceSize(c)
 	}
+	for _, bp := range m.codedPresences {
+		bpb := bp.ToPB()
+		size += bpb.Size()
+	}
 	for _, e := range m.wantlist {
 		size += e.Size()
 	}
@@ -446,6 +503,16 @@ func BlockPresenceSize(c cid.Cid) int {
 	}).Size()
 }
 
+// ToPB returns the block presence in protobuf form
+func (bp *BlockPresence) ToPB() pb.Message_BlockPresence {
+	return pb.Message_BlockPresence{
+		Cid:    pb.Cid{Cid: bp.Cid},
+		Type:   bp.Type,
+		Coding: []byte(bp.Coding),
+		Rank:   int32(bp.Rank),
+	}
+}
+
 // FromNet generates a new BitswapMessage from incoming data on an io.Reader.
 func FromNet(r io.Reader) (BitSwapMessage, error) {
 	reader := msgio.NewVarintReaderSize(r, network.MessageSizeMax)
@@ -513,13 +580,16 @@ func (m *impl) ToProtoV1() *pb.Message {
 		})
 	}
 
-	pbm.BlockPresences = make([]pb.Message_BlockPresence, 0, len(m.blockPresences))
+	pbm.BlockPresences = make([]pb.Message_BlockPresence, 0, len(m.blockPresences)+len(m.codedPresences))
 	for c, t := range m.blockPresences {
 		pbm.BlockPresences = append(pbm.BlockPresences, pb.Message_BlockPresence{
 			Cid:  pb.Cid{Cid: c},
 			Type: t,
 		})
 	}
+	for _, bp := range m.codedPresences {
+		pbm.BlockPresences = append(pbm.BlockPresences, bp.ToPB())
+	}
 
 	pbm.PendingBytes = m.PendingBytes()
 
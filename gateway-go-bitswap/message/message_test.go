@@ -241,6 +241,76 @@ func TestBlockPresences(t *testing.T) {
 	}
 }
 
+func TestCodedPresences(t *testing.T) {
+	b1 := blocks.NewBlock([]byte("foo"))
+	b2 := blocks.NewBlock([]byte("bar"))
+	msg := New(true)
+	msg.AddCodedHave(b1.Cid(), "nc", 12)
+	msg.AddCodedHave(b2.Cid(), "nc", 0)
+	msg.AddCodedEntry(b1.Cid(), 1, pb.Message_Wantlist_Block, true, "nc", 20, 4)
+
+	buf := new(bytes.Buffer)
+	if err := msg.ToNetV1(buf); err != nil {
+		t.Fatal(err)
+	}
+	m2, err := FromNet(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m2.Haves()) != 0 || len(m2.DontHaves()) != 0 {
+		t.Fatal("expected coded presences not to be HAVE / DONT_HAVEs")
+	}
+	ranks := make(map[cid.Cid]int)
+	for _, bp := range m2.CodedHaves() {
+		if bp.Coding != "nc" {
+			t.Fatal("expected coding nc, got", bp.Coding)
+		}
+		if (bp.Rank == 0) != (bp.Type == pb.Message_DontHave) {
+			t.Fatal("expected a coded presence without rank to be a coded DONT_HAVE")
+		}
+		ranks[bp.Cid] = bp.Rank
+	}
+	if len(ranks) != 2 || ranks[b1.Cid()] != 12 || ranks[b2.Cid()] != 0 {
+		t.Fatal("expected the ranks to survive the round trip, got", ranks)
+	}
+
+	e := m2.Wantlist()[0]
+	if e.Coding != "nc" || e.Count != 20 || e.Rank != 4 {
+		t.Fatal("expected the coded want to survive the round trip, got", e.Entry)
+	}
+
+	msg.AddBlockPresence(b1.Cid(), pb.Message_Have)
+	if len(msg.CodedHaves()) != 2 || len(msg.Haves()) != 1 {
+		t.Fatal("expected a HAVE and a coded HAVE of the same cid to be kept apart")
+	}
+
+	msg.AddBlock(b2)
+	if len(msg.CodedHaves()) != 1 {
+		t.Fatal("expected a block to overwrite a coded presence")
+	}
+	msg.AddCodedHave(b2.Cid(), "nc", 3)
+	if len(msg.CodedHaves()) != 1 {
+		t.Fatal("expected a coded presence not to overwrite a block")
+	}
+
+	// A coded presence of a block in the message is dropped.
+	m3 := New(true)
+	m3.AddCodedHave(b1.Cid(), "nc", 3)
+	pbm := m3.ToProtoV1()
+	pbm.Payload = append(pbm.Payload, pb.Message_Block{
+		Data:   b1.RawData(),
+		Prefix: b1.Cid().Prefix().Bytes(),
+	})
+	m4, err := newMessageFromProto(*pbm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m4.CodedHaves()) != 0 || len(m4.Blocks()) != 1 {
+		t.Fatal("expected the block to overwrite its coded presence")
+	}
+}
+
 func TestAddWantlistEntry(t *testing.T) {
 	b := blocks.NewBlock([]byte("foo"))
 	msg := New(true)
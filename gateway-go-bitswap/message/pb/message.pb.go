@@ -209,6 +209,7 @@ type Message_Wantlist_Entry struct {
 	SendDontHave bool                      `protobuf:"varint,5,opt,name=sendDontHave,proto3" json:"sendDontHave,omitempty"`
 	Coding       []byte                    `protobuf:"bytes,6,opt,name=coding,proto3" json:"coding,omitempty"`
 	Count        int32                     `protobuf:"varint,7,opt,name=count,proto3" json:"count,omitempty"`
+	Rank         int32                     `protobuf:"varint,8,opt,name=rank,proto3" json:"rank,omitempty"`
 }
 
 func (m *Message_Wantlist_Entry) Reset()         { *m = Message_Wantlist_Entry{} }
@@ -286,6 +287,13 @@ func (m *Message_Wantlist_Entry) GetCount() int32 {
 	return 0
 }
 
+func (m *Message_Wantlist_Entry) GetRank() int32 {
+	if m != nil {
+		return m.Rank
+	}
+	return 0
+}
+
 type Message_Block struct {
 	Prefix []byte `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
 	Data   []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
@@ -347,8 +355,10 @@ func (m *Message_Block) GetParent() []byte {
 }
 
 type Message_BlockPresence struct {
-	Cid  Cid                       `protobuf:"bytes,1,opt,name=cid,proto3,customtype=Cid" json:"cid"`
-	Type Message_BlockPresenceType `protobuf:"varint,2,opt,name=type,proto3,enum=bitswap.message.pb.Message_BlockPresenceType" json:"type,omitempty"`
+	Cid    Cid                       `protobuf:"bytes,1,opt,name=cid,proto3,customtype=Cid" json:"cid"`
+	Type   Message_BlockPresenceType `protobuf:"varint,2,opt,name=type,proto3,enum=bitswap.message.pb.Message_BlockPresenceType" json:"type,omitempty"`
+	Coding []byte                    `protobuf:"bytes,3,opt,name=coding,proto3" json:"coding,omitempty"`
+	Rank   int32                     `protobuf:"varint,4,opt,name=rank,proto3" json:"rank,omitempty"`
 }
 
 func (m *Message_BlockPresence) Reset()         { *m = Message_BlockPresence{} }
@@ -391,6 +401,20 @@ func (m *Message_BlockPresence) GetType() Message_BlockPresenceType {
 	return Message_Have
 }
 
+func (m *Message_BlockPresence) GetCoding() []byte {
+	if m != nil {
+		return m.Coding
+	}
+	return nil
+}
+
+func (m *Message_BlockPresence) GetRank() int32 {
+	if m != nil {
+		return m.Rank
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterEnum("bitswap.message.pb.Message_BlockPresenceType", Message_BlockPresenceType_name, Message_BlockPresenceType_value)
 	proto.RegisterEnum("bitswap.message.pb.Message_Wantlist_WantType", Message_Wantlist_WantType_name, Message_Wantlist_WantType_value)
@@ -404,41 +428,42 @@ func init() {
 func init() { proto.RegisterFile("message.proto", fileDescriptor_33c57e4bae7b9afd) }
 
 var fileDescriptor_33c57e4bae7b9afd = []byte{
-	// 533 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0xcb, 0x6e, 0xd3, 0x4e,
-	0x14, 0xc6, 0x3d, 0xb1, 0x9d, 0xf8, 0x3f, 0x49, 0xab, 0xfc, 0x47, 0x08, 0x8d, 0x2c, 0xe1, 0xb8,
-	0x11, 0x0b, 0x03, 0xaa, 0x2b, 0x85, 0x27, 0x68, 0xb8, 0x88, 0x8b, 0x90, 0xd0, 0x08, 0x29, 0x6b,
-	0x5f, 0x26, 0xc1, 0xc2, 0x9d, 0x31, 0xf6, 0x84, 0x92, 0x47, 0x60, 0xc7, 0x33, 0xf0, 0x34, 0x5d,
-	0x76, 0x89, 0x58, 0x54, 0x28, 0x79, 0x0c, 0x36, 0x68, 0x8e, 0x27, 0x91, 0x42, 0x11, 0xed, 0x6e,
-	0xbe, 0x33, 0xe7, 0xfb, 0x65, 0xce, 0x77, 0x62, 0x7c, 0x70, 0xc6, 0x9b, 0x26, 0x59, 0xf0, 0xb8,
-	0xaa, 0xa5, 0x92, 0x84, 0xa4, 0x85, 0x6a, 0xce, 0x93, 0x2a, 0xde, 0x95, 0x53, 0xff, 0x78, 0x51,
-	0xa8, 0xf7, 0xcb, 0x34, 0xce, 0xe4, 0xd9, 0xc9, 0x42, 0x2e, 0xe4, 0x09, 0xb4, 0xa6, 0xcb, 0x39,
-	0x28, 0x10, 0x70, 0x6a, 0x11, 0xe3, 0x6f, 0x3d, 0xdc, 0x7b, 0xd3, 0xba, 0xc9, 0x73, 0xec, 0x9d,
-	0x27, 0x42, 0x95, 0x45, 0xa3, 0x28, 0x0a, 0x51, 0xd4, 0x9f, 0xdc, 0x8f, 0xaf, 0xff, 0x42, 0x6c,
-	0xda, 0xe3, 0x99, 0xe9, 0x9d, 0x3a, 0x17, 0x57, 0x23, 0x8b, 0xed, 0xbc, 0xe4, 0x2e, 0xee, 0xa6,
-	0xa5, 0xcc, 0x3e, 0x34, 0xb4, 0x13, 0xda, 0xd1, 0x80, 0x19, 0x45, 0x4e, 0x71, 0xaf, 0x4a, 0x56,
-	0xa5, 0x4c, 0x72, 0x6a, 0x87, 0x76, 0xd4, 0x9f, 0x1c, 0xfd, 0x0b, 0x3f, 0xd5, 0x26, 0xc3, 0xde,
-	0xfa, 0xc8, 0x0c, 0x1f, 0x02, 0xec, 0x6d, 0xcd, 0x1b, 0x2e, 0x32, 0xde, 0x50, 0x07, 0x48, 0x0f,
-	0x6e, 0x24, 0x6d, 0x1d, 0x86, 0xf8, 0x07, 0x86, 0x8c, 0xf1, 0xa0, 0xe2, 0x22, 0x2f, 0xc4, 0x62,
-	0xba, 0x52, 0xbc, 0xa1, 0x6e, 0x88, 0x22, 0x97, 0xed, 0xd5, 0xfc, 0x2f, 0x36, 0xf6, 0xb6, 0x43,
-	0x93, 0x57, 0xb8, 0xc7, 0x85, 0xaa, 0x0b, 0xde, 0x50, 0x04, 0x4f, 0x78, 0x78, 0x9b, 0xac, 0xe2,
-	0x67, 0x42, 0xd5, 0xab, 0xed, 0x54, 0x06, 0x40, 0x08, 0x76, 0xe6, 0xcb, 0xb2, 0xa4, 0x9d, 0x10,
-	0x45, 0x1e, 0x83, 0xb3, 0xff, 0x0b, 0x61, 0x17, 0x9a, 0xc9, 0x11, 0x76, 0xe1, 0xb1, 0xb0, 0x93,
-	0xc1, 0xb4, 0xaf, 0xbd, 0x3f, 0xae, 0x46, 0xf6, 0x93, 0x22, 0x67, 0xed, 0x0d, 0xf1, 0xb1, 0x57,
-	0xd5, 0x85, 0xac, 0x0b, 0xb5, 0x02, 0x88, 0xcb, 0x76, 0x5a, 0x6f, 0x23, 0x4b, 0x44, 0xc6, 0x4b,
-	0x6a, 0x03, 0xde, 0x28, 0xf2, 0xb2, 0xdd, 0xf6, 0xbb, 0x55, 0xc5, 0xa9, 0x13, 0xa2, 0xe8, 0x70,
-	0x72, 0x7c, 0xab, 0x09, 0x66, 0xc6, 0xc4, 0x76, 0x76, 0x1d, 0x5e, 0xc3, 0x45, 0xfe, 0x54, 0x0a,
-	0xf5, 0x22, 0xf9, 0xc4, 0x21, 0x3c, 0x8f, 0xed, 0xd5, 0xe0, 0x19, 0x52, 0x67, 0x49, 0xbb, 0x7a,
-	0x0c, 0x66, 0x14, 0xb9, 0x83, 0xdd, 0x4c, 0x2e, 0x85, 0xa2, 0x3d, 0x78, 0x77, 0x2b, 0xc6, 0xa3,
-	0x36, 0x69, 0xa0, 0xff, 0x87, 0x5d, 0xd8, 0xe0, 0xd0, 0x22, 0x1e, 0x76, 0x34, 0x6c, 0x88, 0xfc,
-	0xd7, 0xa6, 0xa8, 0xb9, 0x55, 0xcd, 0xe7, 0xc5, 0xe7, 0x36, 0x1e, 0x66, 0x94, 0xce, 0x34, 0x4f,
-	0x54, 0x02, 0x71, 0x0c, 0x18, 0x9c, 0xa1, 0x37, 0xa9, 0xb9, 0x50, 0x10, 0x85, 0xee, 0x05, 0xe5,
-	0x7f, 0xc4, 0x07, 0x7b, 0xff, 0x11, 0x72, 0x0f, 0xdb, 0x59, 0x91, 0xff, 0x2d, 0x70, 0x5d, 0x27,
-	0xa7, 0xd8, 0x51, 0x3a, 0xb6, 0xce, 0xcd, 0xb1, 0xed, 0x71, 0x21, 0x36, 0xb0, 0x8e, 0x1f, 0xe1,
-	0xff, 0xaf, 0x5d, 0xed, 0xc6, 0xb3, 0xc8, 0x00, 0x7b, 0xdb, 0xe4, 0x86, 0x68, 0x4a, 0x2f, 0xd6,
-	0x01, 0xba, 0x5c, 0x07, 0xe8, 0xe7, 0x3a, 0x40, 0x5f, 0x37, 0x81, 0x75, 0xb9, 0x09, 0xac, 0xef,
-	0x9b, 0xc0, 0x4a, 0xbb, 0xf0, 0x15, 0x3f, 0xfe, 0x1d, 0x00, 0x00, 0xff, 0xff, 0x40, 0x4d, 0x0a,
-	0x96, 0x19, 0x04, 0x00, 0x00,
+	// 547 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x94, 0xdd, 0x6a, 0xdb, 0x30,
+	0x14, 0xc7, 0xad, 0xd8, 0x4e, 0x3c, 0x25, 0x2d, 0x99, 0x18, 0x43, 0x18, 0xe6, 0xb8, 0x61, 0x17,
+	0xde, 0x46, 0x5d, 0xc8, 0x9e, 0xa0, 0xd9, 0x07, 0xfb, 0x60, 0x30, 0xcc, 0x20, 0xd7, 0xfe, 0x50,
+	0x32, 0x53, 0x57, 0x32, 0xb6, 0xb2, 0x2e, 0x4f, 0xb1, 0xbd, 0x40, 0xdf, 0xa7, 0x97, 0xbd, 0x1c,
+	0xbb, 0x28, 0x23, 0x79, 0x91, 0xa1, 0x63, 0x39, 0x34, 0xeb, 0x68, 0x7b, 0xa7, 0xff, 0xc9, 0xf9,
+	0xff, 0xa5, 0xf3, 0x3b, 0xc1, 0x78, 0xef, 0x94, 0xd5, 0x75, 0xbc, 0x60, 0x61, 0x59, 0x09, 0x29,
+	0x08, 0x49, 0x72, 0x59, 0x9f, 0xc5, 0x65, 0xb8, 0x2d, 0x27, 0xee, 0xe1, 0x22, 0x97, 0x5f, 0x97,
+	0x49, 0x98, 0x8a, 0xd3, 0xa3, 0x85, 0x58, 0x88, 0x23, 0x68, 0x4d, 0x96, 0x73, 0x50, 0x20, 0xe0,
+	0xd4, 0x44, 0x8c, 0x37, 0x3d, 0xdc, 0xfb, 0xd4, 0xb8, 0xc9, 0x5b, 0xec, 0x9c, 0xc5, 0x5c, 0x16,
+	0x79, 0x2d, 0x29, 0xf2, 0x51, 0xd0, 0x9f, 0x3c, 0x0d, 0x6f, 0xde, 0x10, 0xea, 0xf6, 0x70, 0xa6,
+	0x7b, 0xa7, 0xd6, 0xc5, 0xd5, 0xc8, 0x88, 0xb6, 0x5e, 0xf2, 0x18, 0x77, 0x93, 0x42, 0xa4, 0x27,
+	0x35, 0xed, 0xf8, 0x66, 0x30, 0x88, 0xb4, 0x22, 0xc7, 0xb8, 0x57, 0xc6, 0xab, 0x42, 0xc4, 0x19,
+	0x35, 0x7d, 0x33, 0xe8, 0x4f, 0x0e, 0x6e, 0x8b, 0x9f, 0x2a, 0x93, 0xce, 0x6e, 0x7d, 0x64, 0x86,
+	0xf7, 0x21, 0xec, 0x73, 0xc5, 0x6a, 0xc6, 0x53, 0x56, 0x53, 0x0b, 0x92, 0x9e, 0xdd, 0x99, 0xd4,
+	0x3a, 0x74, 0xe2, 0x3f, 0x31, 0x64, 0x8c, 0x07, 0x25, 0xe3, 0x59, 0xce, 0x17, 0xd3, 0x95, 0x64,
+	0x35, 0xb5, 0x7d, 0x14, 0xd8, 0xd1, 0x4e, 0xcd, 0x3d, 0x37, 0xb1, 0xd3, 0x0e, 0x4d, 0x3e, 0xe0,
+	0x1e, 0xe3, 0xb2, 0xca, 0x59, 0x4d, 0x11, 0x3c, 0xe1, 0xf9, 0x7d, 0x58, 0x85, 0x6f, 0xb8, 0xac,
+	0x56, 0xed, 0x54, 0x3a, 0x80, 0x10, 0x6c, 0xcd, 0x97, 0x45, 0x41, 0x3b, 0x3e, 0x0a, 0x9c, 0x08,
+	0xce, 0xee, 0x8f, 0x0e, 0xb6, 0xa1, 0x99, 0x1c, 0x60, 0x1b, 0x1e, 0x0b, 0x3b, 0x19, 0x4c, 0xfb,
+	0xca, 0xfb, 0xfb, 0x6a, 0x64, 0xbe, 0xca, 0xb3, 0xa8, 0xf9, 0x85, 0xb8, 0xd8, 0x29, 0xab, 0x5c,
+	0x54, 0xb9, 0x5c, 0x41, 0x88, 0x1d, 0x6d, 0xb5, 0xda, 0x46, 0x1a, 0xf3, 0x94, 0x15, 0xd4, 0x84,
+	0x78, 0xad, 0xc8, 0xfb, 0x66, 0xdb, 0x5f, 0x56, 0x25, 0xa3, 0x96, 0x8f, 0x82, 0xfd, 0xc9, 0xe1,
+	0xbd, 0x26, 0x98, 0x69, 0x53, 0xb4, 0xb5, 0x2b, 0x78, 0x35, 0xe3, 0xd9, 0x6b, 0xc1, 0xe5, 0xbb,
+	0xf8, 0x1b, 0x03, 0x78, 0x4e, 0xb4, 0x53, 0x83, 0x67, 0x08, 0xc5, 0x92, 0x76, 0xd5, 0x18, 0x91,
+	0x56, 0xe4, 0x11, 0xb6, 0x53, 0xb1, 0xe4, 0x92, 0xf6, 0xe0, 0xdd, 0x8d, 0x50, 0x44, 0xaa, 0x98,
+	0x9f, 0x50, 0x07, 0x8a, 0x70, 0x1e, 0x8f, 0x1a, 0xfa, 0x70, 0xe3, 0x03, 0x6c, 0xc3, 0x56, 0x87,
+	0x06, 0x71, 0xb0, 0xa5, 0x2e, 0x18, 0x22, 0xf7, 0xa3, 0x2e, 0xaa, 0xbb, 0xca, 0x8a, 0xcd, 0xf3,
+	0xef, 0x0d, 0xb2, 0x48, 0x2b, 0x95, 0x9a, 0xc5, 0x32, 0x06, 0x44, 0x83, 0x08, 0xce, 0xd0, 0x1b,
+	0x57, 0x8c, 0x4b, 0x6a, 0xea, 0x5e, 0x50, 0xee, 0x39, 0xc2, 0x7b, 0x3b, 0x7f, 0x1c, 0xf2, 0x04,
+	0x9b, 0x69, 0x9e, 0xfd, 0x6f, 0x0b, 0xaa, 0x4e, 0x8e, 0xb1, 0x25, 0x15, 0xcb, 0xce, 0xdd, 0x2c,
+	0x77, 0x72, 0x81, 0x25, 0x58, 0xaf, 0x31, 0x32, 0x77, 0x18, 0xb5, 0x34, 0xac, 0x6b, 0x34, 0x5e,
+	0xe0, 0x87, 0x37, 0x62, 0xb6, 0x2c, 0x0c, 0x32, 0xc0, 0x4e, 0x8b, 0x7e, 0x88, 0xa6, 0xf4, 0x62,
+	0xed, 0xa1, 0xcb, 0xb5, 0x87, 0xfe, 0xac, 0x3d, 0xf4, 0x73, 0xe3, 0x19, 0x97, 0x1b, 0xcf, 0xf8,
+	0xb5, 0xf1, 0x8c, 0xa4, 0x0b, 0x9f, 0x81, 0x97, 0x7f, 0x07, 0x00, 0x71, 0x36, 0xda, 0xe9, 0x5a,
+	0x04, 0x00, 0x00,
 }
 
 func (m *Message) Marshal() (dAtA []byte, err error) {
@@ -583,6 +608,11 @@ func (m *Message_Wantlist_Entry) MarshalToSizedBuffer(dAtA []byte) (int, error)
 	_ = i
 	var l int
 	_ = l
+	if m.Rank != 0 {
+		i = encodeVarintMessage(dAtA, i, uint64(m.Rank))
+		i--
+		dAtA[i] = 0x40
+	}
 	if m.Count != 0 {
 		i = encodeVarintMessage(dAtA, i, uint64(m.Count))
 		i--
@@ -702,6 +732,18 @@ func (m *Message_BlockPresence) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Rank != 0 {
+		i = encodeVarintMessage(dAtA, i, uint64(m.Rank))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.Coding) > 0 {
+		i -= len(m.Coding)
+		copy(dAtA[i:], m.Coding)
+		i = encodeVarintMessage(dAtA, i, uint64(len(m.Coding)))
+		i--
+		dAtA[i] = 0x1a
+	}
 	if m.Type != 0 {
 		i = encodeVarintMessage(dAtA, i, uint64(m.Type))
 		i--
@@ -808,6 +850,9 @@ func (m *Message_Wantlist_Entry) Size() (n int) {
 	if m.Count != 0 {
 		n += 1 + sovMessage(uint64(m.Count))
 	}
+	if m.Rank != 0 {
+		n += 1 + sovMessage(uint64(m.Rank))
+	}
 	return n
 }
 
@@ -843,6 +888,13 @@ func (m *Message_BlockPresence) Size() (n int) {
 	if m.Type != 0 {
 		n += 1 + sovMessage(uint64(m.Type))
 	}
+	l = len(m.Coding)
+	if l > 0 {
+		n += 1 + l + sovMessage(uint64(l))
+	}
+	if m.Rank != 0 {
+		n += 1 + sovMessage(uint64(m.Rank))
+	}
 	return n
 }
 
@@ -1351,6 +1403,25 @@ func (m *Message_Wantlist_Entry) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Rank", wireType)
+			}
+			m.Rank = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Rank |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessage(dAtA[iNdEx:])
@@ -1605,6 +1676,59 @@ func (m *Message_BlockPresence) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Coding", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthMessage
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthMessage
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Coding = append(m.Coding[:0], dAtA[iNdEx:postIndex]...)
+			if m.Coding == nil {
+				m.Coding = []byte{}
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Rank", wireType)
+			}
+			m.Rank = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowMessage
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Rank |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipMessage(dAtA[iNdEx:])
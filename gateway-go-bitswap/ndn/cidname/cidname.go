@@ -0,0 +1,237 @@
+// Package cidname defines how CIDs are named on NDN.
+//
+// A block is published under a routable prefix followed by its CID. Two
+// encodings of the CID are supported:
+//
+//   - Multibase (the default) puts the CID string in a single generic
+//     component, e.g. /ipfs/QmXoyp... for a CIDv0 or /ipfs/bafy... for a
+//     CIDv1 in the mapper's base.
+//   - Multihash puts the raw multihash in a generic component. A CIDv0 is
+//     named /<prefix>/<multihash>, a CIDv1 /<prefix>/<codec>/<multihash>
+//     where codec is the codec name, or its decimal code if it has none.
+//
+// Names may be followed by typed components, such as a segment number or an
+// implicit digest, which are ignored when mapping a name back to its CID.
+//
+// The coded blocks of a parent block are listed under the parent's name
+// followed by a keyword component holding the coding, e.g. /ipfs/QmXoyp.../32=nc.
+package cidname
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	cid "github.com/ipfs/go-cid"
+	mbase "github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// Encoding selects how a CID is encoded in name components.
+type Encoding int
+
+const (
+	// Multibase names a CID by its string form.
+	Multibase Encoding = iota
+	// Multihash names a CID by its codec and raw multihash.
+	Multihash
+)
+
+// DefaultPrefix is the prefix used by Default.
+var DefaultPrefix = ndn.MustParseName("/ipfs")
+
+// Default maps CIDs under DefaultPrefix using the Multibase encoding.
+var Default = MustNew(DefaultPrefix)
+
+var (
+	// ErrNotUnderPrefix is returned when mapping a name that is not under
+	// the mapper's prefix.
+	ErrNotUnderPrefix = errors.New("cidname: name is not under the prefix")
+
+	// ErrMalformedName is returned when the components after the prefix do
+	// not encode a CID.
+	ErrMalformedName = errors.New("cidname: name does not encode a CID")
+)
+
+// Option configures a Mapper.
+type Option func(*Mapper)
+
+// WithEncoding sets the encoding of CIDs in names.
+func WithEncoding(enc Encoding) Option {
+	return func(m *Mapper) {
+		m.enc = enc
+	}
+}
+
+// WithBase sets the multibase used for CIDv1 strings with the Multibase
+// encoding. CIDv0 strings are always base58btc.
+func WithBase(base mbase.Encoding) Option {
+	return func(m *Mapper) {
+		m.base = base
+	}
+}
+
+// Mapper translates between CIDs and NDN names. It is immutable and safe for
+// concurrent use.
+type Mapper struct {
+	prefix ndn.Name
+	enc    Encoding
+	base   mbase.Encoding
+}
+
+// New returns a Mapper naming CIDs under prefix. It fails if the multibase
+// set with WithBase is not supported.
+func New(prefix ndn.Name, opts ...Option) (*Mapper, error) {
+	m := &Mapper{
+		prefix: prefix,
+		enc:    Multibase,
+		base:   mbase.Base32,
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	if _, err := mbase.NewEncoder(m.base); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MustNew is like New but panics on error. It is intended for package level
+// variables and tests.
+func MustNew(prefix ndn.Name, opts ...Option) *Mapper {
+	m, err := New(prefix, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Prefix returns the routable prefix names are built under.
+func (m *Mapper) Prefix() ndn.Name {
+	return m.prefix
+}
+
+// Name returns the canonical NDN name of c.
+func (m *Mapper) Name(c cid.Cid) ndn.Name {
+	return m.prefix.Append(m.components(c)...)
+}
+
+// FullName returns the name of c followed by the implicit digest of d, the
+// Data packet carrying the block. The result only matches that exact packet.
+func (m *Mapper) FullName(c cid.Cid, d *ndn.Data) ndn.Name {
+	full := d.FullName()
+	return m.Name(c).Append(full[len(full)-1])
+}
+
+// CodedName returns the name listing the blocks coded from parent with
+// coding.
+func (m *Mapper) CodedName(parent cid.Cid, coding string) ndn.Name {
+	return m.Name(parent).Append(ndn.KeywordComponent([]byte(coding)))
+}
+
+// Coding returns the coding of a name built by CodedName, and false if n
+// names a plain block.
+func (m *Mapper) Coding(n ndn.Name) (string, bool) {
+	if !m.prefix.IsPrefixOf(n) {
+		return "", false
+	}
+	for _, comp := range n[len(m.prefix):] {
+		if comp.Type == ndn.ComponentKeyword {
+			return string(comp.Value), true
+		}
+	}
+	return "", false
+}
+
+func (m *Mapper) components(c cid.Cid) []ndn.Component {
+	if m.enc == Multihash {
+		hash := ndn.GenericComponent([]byte(c.Hash()))
+		if c.Version() == 0 {
+			return []ndn.Component{hash}
+		}
+		return []ndn.Component{ndn.GenericComponent([]byte(codecName(c.Type()))), hash}
+	}
+
+	s := c.String()
+	if c.Version() != 0 {
+		// New checked the base, the only error StringOfBase returns.
+		s, _ = c.StringOfBase(m.base)
+	}
+	return []ndn.Component{ndn.GenericComponent([]byte(s))}
+}
+
+// CID returns the CID named by n. Typed components that follow the CID, like
+// a segment number or an implicit digest, are ignored.
+func (m *Mapper) CID(n ndn.Name) (cid.Cid, error) {
+	if !m.prefix.IsPrefixOf(n) {
+		return cid.Undef, ErrNotUnderPrefix
+	}
+	rest := n[len(m.prefix):]
+	for len(rest) > 0 && rest[len(rest)-1].Type != ndn.ComponentGeneric {
+		rest = rest[:len(rest)-1]
+	}
+	for _, comp := range rest {
+		if comp.Type != ndn.ComponentGeneric {
+			return cid.Undef, ErrMalformedName
+		}
+	}
+
+	if m.enc == Multihash {
+		return multihashCID(rest)
+	}
+	if len(rest) != 1 {
+		return cid.Undef, ErrMalformedName
+	}
+	c, err := cid.Decode(string(rest[0].Value))
+	if err != nil {
+		return cid.Undef, ErrMalformedName
+	}
+	return c, nil
+}
+
+func multihashCID(comps []ndn.Component) (cid.Cid, error) {
+	switch len(comps) {
+	case 1:
+		hash, err := mh.Cast(comps[0].Value)
+		if err != nil {
+			return cid.Undef, ErrMalformedName
+		}
+		dec, err := mh.Decode(hash)
+		if err != nil || dec.Code != mh.SHA2_256 || dec.Length != 32 {
+			return cid.Undef, ErrMalformedName
+		}
+		return cid.NewCidV0(hash), nil
+	case 2:
+		codec, err := codecCode(string(comps[0].Value))
+		if err != nil {
+			return cid.Undef, err
+		}
+		hash, err := mh.Cast(comps[1].Value)
+		if err != nil {
+			return cid.Undef, ErrMalformedName
+		}
+		return cid.NewCidV1(codec, hash), nil
+	default:
+		return cid.Undef, ErrMalformedName
+	}
+}
+
+// codecName returns the name of a codec, or its decimal code for codecs
+// without a name.
+func codecName(code uint64) string {
+	if name, ok := cid.CodecToStr[code]; ok {
+		return name
+	}
+	return strconv.FormatUint(code, 10)
+}
+
+func codecCode(name string) (uint64, error) {
+	if code, ok := cid.Codecs[name]; ok {
+		return code, nil
+	}
+	code, err := strconv.ParseUint(name, 10, 64)
+	if err != nil {
+		return 0, ErrMalformedName
+	}
+	return code, nil
+}
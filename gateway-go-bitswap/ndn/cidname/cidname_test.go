@@ -0,0 +1,172 @@
+package cidname
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	cid "github.com/ipfs/go-cid"
+	mbase "github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// randomCid is a CIDv0 or CIDv1 generated by testing/quick.
+type randomCid struct {
+	cid.Cid
+}
+
+var testCodecs = []uint64{cid.Raw, cid.DagProtobuf, cid.DagCBOR, cid.Libp2pKey, cid.GitRaw, 0x300001}
+
+var testHashes = []uint64{mh.SHA2_256, mh.SHA2_512, mh.SHA3_256, mh.IDENTITY}
+
+func (randomCid) Generate(r *rand.Rand, size int) reflect.Value {
+	data := make([]byte, r.Intn(size+1))
+	r.Read(data)
+	if r.Intn(2) == 0 {
+		hash, _ := mh.Sum(data, mh.SHA2_256, -1)
+		return reflect.ValueOf(randomCid{cid.NewCidV0(hash)})
+	}
+	hash, err := mh.Sum(data, testHashes[r.Intn(len(testHashes))], -1)
+	if err != nil {
+		panic(err)
+	}
+	return reflect.ValueOf(randomCid{cid.NewCidV1(testCodecs[r.Intn(len(testCodecs))], hash)})
+}
+
+func testRoundTrip(t *testing.T, m *Mapper) {
+	roundTrip := func(c randomCid) bool {
+		name := m.Name(c.Cid)
+		if !m.Prefix().IsPrefixOf(name) {
+			return false
+		}
+		// The name must also survive the URI and wire encodings.
+		parsed, err := ndn.ParseName(name.String())
+		if err != nil || !parsed.Equal(name) {
+			return false
+		}
+		decoded, err := ndn.DecodeName(name.Encode())
+		if err != nil {
+			return false
+		}
+		back, err := m.CID(decoded)
+		return err == nil && back.Equals(c.Cid) && back.Version() == c.Version()
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultibaseRoundTrip(t *testing.T) {
+	testRoundTrip(t, Default)
+	testRoundTrip(t, MustNew(ndn.MustParseName("/example/gateway"), WithBase(mbase.Base58BTC)))
+	testRoundTrip(t, MustNew(ndn.MustParseName("/example/gateway"), WithBase(mbase.Base64url)))
+}
+
+func TestUnsupportedBase(t *testing.T) {
+	if _, err := New(DefaultPrefix, WithBase(mbase.Encoding('?'))); err == nil {
+		t.Fatal("expected an error for an unsupported multibase")
+	}
+}
+
+func TestMultihashRoundTrip(t *testing.T) {
+	testRoundTrip(t, MustNew(DefaultPrefix, WithEncoding(Multihash)))
+	testRoundTrip(t, MustNew(ndn.Name{}, WithEncoding(Multihash)))
+}
+
+func TestCanonicalNames(t *testing.T) {
+	v0, err := cid.Decode("QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Default.Name(v0).String(); got != "/ipfs/QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG" {
+		t.Fatalf("unexpected CIDv0 name %s", got)
+	}
+	v1 := cid.NewCidV1(cid.Raw, v0.Hash())
+	if got, want := Default.Name(v1).String(), "/ipfs/"+v1.String(); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	m := MustNew(DefaultPrefix, WithEncoding(Multihash))
+	if n := m.Name(v0); len(n) != 2 {
+		t.Fatalf("expected a single component for a CIDv0, got %s", n)
+	}
+	if n := m.Name(v1); len(n) != 3 || string(n[1].Value) != "raw" {
+		t.Fatalf("expected the codec name before the multihash, got %s", n)
+	}
+}
+
+func TestTrailingComponents(t *testing.T) {
+	c, _ := cid.Decode("QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG")
+	d := &ndn.Data{Name: Default.Name(c), Content: []byte("block")}
+
+	for _, n := range []ndn.Name{
+		Default.Name(c).Append(ndn.SegmentComponent(3)),
+		Default.FullName(c, d),
+	} {
+		got, err := Default.CID(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equals(c) {
+			t.Fatalf("expected %s from %s, got %s", c, n, got)
+		}
+	}
+
+	full := Default.FullName(c, d)
+	i := &ndn.Interest{Name: full}
+	if !i.Matches(d) {
+		t.Fatal("expected the full name to match its Data")
+	}
+	other := &ndn.Data{Name: d.Name, Content: []byte("other")}
+	if i.Matches(other) {
+		t.Fatal("expected the full name not to match other Data")
+	}
+}
+
+func TestCodedNames(t *testing.T) {
+	c, _ := cid.Decode("QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG")
+	n := Default.CodedName(c, "nc")
+	if s := n.String(); s != "/ipfs/QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG/32=nc" {
+		t.Fatalf("unexpected coded name %s", s)
+	}
+
+	for _, n := range []ndn.Name{n, n.Append(ndn.SegmentComponent(1))} {
+		got, err := Default.CID(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equals(c) {
+			t.Fatalf("expected %s from %s, got %s", c, n, got)
+		}
+		if coding, ok := Default.Coding(n); !ok || coding != "nc" {
+			t.Fatalf("expected coding nc from %s, got %q", n, coding)
+		}
+	}
+	if _, ok := Default.Coding(Default.Name(c)); ok {
+		t.Fatal("expected no coding in a block name")
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	mhm := MustNew(DefaultPrefix, WithEncoding(Multihash))
+	for _, tc := range []struct {
+		m    *Mapper
+		name string
+		err  error
+	}{
+		{Default, "/other/QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", ErrNotUnderPrefix},
+		{Default, "/ipfs", ErrMalformedName},
+		{Default, "/ipfs/not-a-cid", ErrMalformedName},
+		{Default, "/ipfs/a/b", ErrMalformedName},
+		{Default, "/ipfs/seg=1/QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG", ErrMalformedName},
+		{mhm, "/ipfs/%00%01", ErrMalformedName},
+		{mhm, "/ipfs/no-such-codec/%00%00", ErrMalformedName},
+		{mhm, "/ipfs/a/b/c", ErrMalformedName},
+	} {
+		if _, err := tc.m.CID(ndn.MustParseName(tc.name)); err != tc.err {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package ndn
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSocketPath is the Unix socket a local NFD listens on.
+const DefaultSocketPath = "/run/nfd/nfd.sock"
+
+// DefaultRetries is the number of times a timed out Interest is
+// retransmitted before giving up.
+const DefaultRetries = 2
+
+// DialFunc opens a new face to the forwarder.
+type DialFunc func(ctx context.Context) (*Face, error)
+
+// UnixDialer returns a DialFunc connecting to a forwarder on the Unix socket
+// at path.
+func UnixDialer(path string) DialFunc {
+	return func(ctx context.Context) (*Face, error) {
+		return DialUnix(ctx, path)
+	}
+}
+
+// ConsumerOption configures a Consumer.
+type ConsumerOption func(*Consumer)
+
+// InterestLifetime sets the lifetime of the Interests expressed by the
+// consumer.
+func InterestLifetime(d time.Duration) ConsumerOption {
+	return func(c *Consumer) {
+		c.lifetime = d
+	}
+}
+
+// Retries sets how many times a timed out Interest is retransmitted.
+func Retries(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.retries = n
+	}
+}
+
+// Congestion selects the congestion control algorithm of the consumer.
+func Congestion(alg CongestionAlgorithm) ConsumerOption {
+	return func(c *Consumer) {
+		c.alg = alg
+	}
+}
+
+// InitialWindow sets the number of Interests the consumer may have in flight
+// before it has measured the path.
+func InitialWindow(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.initialWindow = n
+	}
+}
+
+// Consumer retrieves named content from an NDN forwarder. The face to the
+// forwarder is opened lazily and re-opened after it fails, so a consumer can
+// be created before the forwarder is running.
+//
+// All Interests expressed through a consumer share one congestion window:
+// the number of Interests in flight adapts to the Data and losses observed,
+// and timed out Interests are retransmitted after an RTO estimated from the
+// measured round trip times.
+type Consumer struct {
+	dial          DialFunc
+	lifetime      time.Duration
+	retries       int
+	alg           CongestionAlgorithm
+	initialWindow int
+
+	pipe *pipeline
+
+	lk   sync.Mutex
+	face *Face
+}
+
+// NewConsumer creates a consumer that reaches the forwarder through dial.
+func NewConsumer(dial DialFunc, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
+		dial:          dial,
+		lifetime:      DefaultInterestLifetime,
+		retries:       DefaultRetries,
+		alg:           AIMD,
+		initialWindow: DefaultInitialWindow,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	if c.initialWindow < minWindow {
+		c.initialWindow = minWindow
+	}
+	c.pipe = newPipeline(c.alg, c.initialWindow)
+	return c
+}
+
+// Stats returns the statistics of the consumer's pipeline.
+func (c *Consumer) Stats() ConsumerStats {
+	return c.pipe.snapshot()
+}
+
+// getFace returns the current face, dialing the forwarder if there is none.
+func (c *Consumer) getFace(ctx context.Context) (*Face, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.face != nil {
+		select {
+		case <-c.face.Done():
+			c.face = nil
+		default:
+			return c.face, nil
+		}
+	}
+	f, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.face = f
+	return f, nil
+}
+
+// Express sends i and waits for the matching Data. The Interest waits for
+// room in the congestion window first. Interests that time out, or are
+// Nacked for congestion, are retransmitted with a fresh nonce up to the
+// configured number of retries; other Nacks are returned to the caller as a
+// *NackError.
+func (c *Consumer) Express(ctx context.Context, i *Interest) (*Data, error) {
+	if i.Lifetime == 0 {
+		i.Lifetime = c.lifetime
+	}
+	if err := c.pipe.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer c.pipe.release()
+
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		var f *Face
+		f, err = c.getFace(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Nonce = NewNonce()
+		c.pipe.sent(attempt > 0)
+		sent := time.Now()
+		var d *Data
+		d, err = f.express(ctx, i, c.pipe.timeout(i.Lifetime))
+		switch err {
+		case nil:
+			if err := d.Verify(); err != nil {
+				return nil, err
+			}
+			c.pipe.received(time.Since(sent), attempt == 0, len(d.Content))
+			return d, nil
+		case ErrTimeout:
+			c.pipe.timedOut()
+			log.Debugf("interest %s: %s (attempt %d)", i.Name, err, attempt+1)
+			continue
+		case ErrFaceClosed:
+			log.Debugf("interest %s: %s (attempt %d)", i.Name, err, attempt+1)
+			continue
+		}
+		if nerr, ok := err.(*NackError); ok {
+			c.pipe.nacked(nerr.Reason)
+			if nerr.Reason == NackCongestion {
+				continue
+			}
+		}
+		return nil, err
+	}
+	return nil, err
+}
+
+// Fetch retrieves the content of the Data packet named name.
+func (c *Consumer) Fetch(ctx context.Context, name Name) ([]byte, error) {
+	d, err := c.Express(ctx, &Interest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return d.Content, nil
+}
+
+// Close closes the face to the forwarder, if one is open.
+func (c *Consumer) Close() error {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.face == nil {
+		return nil
+	}
+	err := c.face.Close()
+	c.face = nil
+	return err
+}
@@ -0,0 +1,190 @@
+package ndn_test
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/ndntest"
+)
+
+func TestConsumerFetch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+	name := ndn.MustParseName("/ipfs/block")
+	fwd.ServeContent(name, []byte("some content"))
+
+	c := ndn.NewConsumer(fwd.Dial)
+	defer c.Close()
+
+	content, err := c.Fetch(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, []byte("some content")) {
+		t.Fatalf("unexpected content %q", content)
+	}
+}
+
+func TestConsumerNack(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+	fwd.Nack(ndn.MustParseName("/ipfs"), ndn.NackCongestion)
+
+	c := ndn.NewConsumer(fwd.Dial)
+	defer c.Close()
+
+	_, err := c.Fetch(ctx, ndn.MustParseName("/ipfs/block"))
+	nerr, ok := err.(*ndn.NackError)
+	if !ok {
+		t.Fatalf("expected a nack, got %v", err)
+	}
+	if nerr.Reason != ndn.NackCongestion {
+		t.Fatalf("expected congestion, got %s", nerr.Reason)
+	}
+
+	// Without any route the forwarder answers with NoRoute.
+	_, err = c.Fetch(ctx, ndn.MustParseName("/elsewhere"))
+	if nerr, ok := err.(*ndn.NackError); !ok || nerr.Reason != ndn.NackNoRoute {
+		t.Fatalf("expected NoRoute nack, got %v", err)
+	}
+}
+
+func TestConsumerRetransmitsOnTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+	name := ndn.MustParseName("/ipfs/slow")
+	var calls int32
+	fwd.Serve(name, func(i *ndn.Interest) *ndn.Data {
+		// Drop the first two Interests.
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			return nil
+		}
+		return &ndn.Data{Name: i.Name, Content: []byte("late")}
+	})
+
+	c := ndn.NewConsumer(fwd.Dial, ndn.InterestLifetime(50*time.Millisecond), ndn.Retries(2))
+	defer c.Close()
+
+	content, err := c.Fetch(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "late" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if n := fwd.Interests(name); n != 3 {
+		t.Fatalf("expected 3 interests, got %d", n)
+	}
+
+	atomic.StoreInt32(&calls, -100)
+	if _, err := c.Fetch(ctx, name); err != ndn.ErrTimeout {
+		t.Fatalf("expected timeout once retries are exhausted, got %v", err)
+	}
+}
+
+func TestConsumerRedialsClosedFace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+	name := ndn.MustParseName("/ipfs/block")
+	fwd.ServeContent(name, []byte("x"))
+
+	c := ndn.NewConsumer(fwd.Dial)
+	defer c.Close()
+	if _, err := c.Fetch(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop every connection, the consumer must reconnect on its own.
+	fwd.Close()
+	if _, err := c.Fetch(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsumerStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+	name := ndn.MustParseName("/ipfs/big")
+	content := bytes.Repeat([]byte{7}, 64*ndn.DefaultSegmentSize)
+	fwd.ServeSegmented(name, content)
+
+	for _, alg := range []ndn.CongestionAlgorithm{ndn.AIMD, ndn.Cubic} {
+		c := ndn.NewConsumer(fwd.Dial, ndn.Congestion(alg))
+		got, err := c.FetchSegmented(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("%s: reassembled content differs", alg)
+		}
+
+		s := c.Stats()
+		if s.DataReceived != 64 || s.InterestsSent != 64 || s.BytesReceived != uint64(len(content)) {
+			t.Fatalf("%s: unexpected counters %+v", alg, s)
+		}
+		if s.InFlight != 0 {
+			t.Fatalf("%s: expected no Interest in flight, got %d", alg, s.InFlight)
+		}
+		if s.Window <= ndn.DefaultInitialWindow {
+			t.Fatalf("%s: expected the window to grow, got %f", alg, s.Window)
+		}
+		if s.SRTT <= 0 || s.MinRTT <= 0 || s.RTO <= 0 || s.Throughput() <= 0 {
+			t.Fatalf("%s: expected RTT and throughput estimates, got %+v", alg, s)
+		}
+		c.Close()
+	}
+}
+
+func TestConsumerRetransmitsCongestionNacks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+	fwd.Nack(ndn.MustParseName("/ipfs"), ndn.NackCongestion)
+
+	c := ndn.NewConsumer(fwd.Dial, ndn.Retries(2), ndn.InitialWindow(8))
+	defer c.Close()
+
+	name := ndn.MustParseName("/ipfs/congested")
+	if _, err := c.Fetch(ctx, name); err == nil {
+		t.Fatal("expected the fetch to fail")
+	}
+	if n := fwd.Interests(name); n != 3 {
+		t.Fatalf("expected 3 interests, got %d", n)
+	}
+	s := c.Stats()
+	if s.Nacks != 3 || s.Retransmissions != 2 {
+		t.Fatalf("expected Nacked Interests to be retransmitted, got %+v", s)
+	}
+	if s.Window >= 8 {
+		t.Fatalf("expected congestion to shrink the window, got %f", s.Window)
+	}
+
+	// Other Nacks are final.
+	if _, err := c.Fetch(ctx, ndn.MustParseName("/elsewhere")); err == nil {
+		t.Fatal("expected the fetch to fail")
+	}
+	if n := fwd.Interests(ndn.MustParseName("/elsewhere")); n != 1 {
+		t.Fatalf("expected a single interest, got %d", n)
+	}
+}
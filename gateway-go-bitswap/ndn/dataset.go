@@ -0,0 +1,176 @@
+package ndn
+
+import (
+	"context"
+	"fmt"
+)
+
+// FacesListPrefix is the name of the forwarder's faces dataset.
+var FacesListPrefix = MustParseName("/localhost/nfd/faces/list")
+
+// TLV-TYPE numbers of the FaceStatus dataset.
+const (
+	tlvFaceStatus      = 0x80
+	tlvURI             = 0x72
+	tlvLocalURI        = 0x81
+	tlvFaceScope       = 0x84
+	tlvFacePersistency = 0x85
+	tlvLinkType        = 0x86
+	tlvMtu             = 0x89
+	tlvNInInterests    = 0x90
+	tlvNInData         = 0x91
+	tlvNOutInterests   = 0x92
+	tlvNOutData        = 0x93
+	tlvNInBytes        = 0x94
+	tlvNOutBytes       = 0x95
+	tlvNInNacks        = 0x97
+	tlvNOutNacks       = 0x98
+)
+
+// Face scopes.
+const (
+	FaceScopeNonLocal = 0
+	FaceScopeLocal    = 1
+)
+
+// Face persistencies.
+const (
+	FacePersistencyPersistent = 0
+	FacePersistencyOnDemand   = 1
+	FacePersistencyPermanent  = 2
+)
+
+// FaceStatus describes a face of the forwarder and its counters.
+type FaceStatus struct {
+	FaceID      uint64
+	URI         string
+	LocalURI    string
+	Scope       uint64
+	Persistency uint64
+	LinkType    uint64
+	// MTU is zero when the face does not report one.
+	MTU uint64
+
+	NInInterests  uint64
+	NInData       uint64
+	NInNacks      uint64
+	NOutInterests uint64
+	NOutData      uint64
+	NOutNacks     uint64
+	NInBytes      uint64
+	NOutBytes     uint64
+
+	Flags uint64
+}
+
+// Encode returns the wire encoding of the status.
+func (s *FaceStatus) Encode() []byte {
+	v := appendNonNegInt(nil, tlvFaceID, s.FaceID)
+	v = appendTLV(v, tlvURI, []byte(s.URI))
+	v = appendTLV(v, tlvLocalURI, []byte(s.LocalURI))
+	v = appendNonNegInt(v, tlvFaceScope, s.Scope)
+	v = appendNonNegInt(v, tlvFacePersistency, s.Persistency)
+	v = appendNonNegInt(v, tlvLinkType, s.LinkType)
+	if s.MTU != 0 {
+		v = appendNonNegInt(v, tlvMtu, s.MTU)
+	}
+	v = appendNonNegInt(v, tlvNInInterests, s.NInInterests)
+	v = appendNonNegInt(v, tlvNInData, s.NInData)
+	v = appendNonNegInt(v, tlvNInNacks, s.NInNacks)
+	v = appendNonNegInt(v, tlvNOutInterests, s.NOutInterests)
+	v = appendNonNegInt(v, tlvNOutData, s.NOutData)
+	v = appendNonNegInt(v, tlvNOutNacks, s.NOutNacks)
+	v = appendNonNegInt(v, tlvNInBytes, s.NInBytes)
+	v = appendNonNegInt(v, tlvNOutBytes, s.NOutBytes)
+	v = appendNonNegInt(v, tlvFlags, s.Flags)
+	return appendTLV(nil, tlvFaceStatus, v)
+}
+
+// DecodeFaceStatuses decodes the content of the faces dataset.
+func DecodeFaceStatuses(content []byte) ([]FaceStatus, error) {
+	elems, err := readElements(content)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]FaceStatus, 0, len(elems))
+	for _, e := range elems {
+		if e.typ != tlvFaceStatus {
+			continue
+		}
+		fields, err := readElements(e.value)
+		if err != nil {
+			return nil, err
+		}
+		var s FaceStatus
+		for _, f := range fields {
+			var dst *uint64
+			switch f.typ {
+			case tlvURI:
+				s.URI = string(f.value)
+				continue
+			case tlvLocalURI:
+				s.LocalURI = string(f.value)
+				continue
+			case tlvFaceID:
+				dst = &s.FaceID
+			case tlvFaceScope:
+				dst = &s.Scope
+			case tlvFacePersistency:
+				dst = &s.Persistency
+			case tlvLinkType:
+				dst = &s.LinkType
+			case tlvMtu:
+				dst = &s.MTU
+			case tlvNInInterests:
+				dst = &s.NInInterests
+			case tlvNInData:
+				dst = &s.NInData
+			case tlvNInNacks:
+				dst = &s.NInNacks
+			case tlvNOutInterests:
+				dst = &s.NOutInterests
+			case tlvNOutData:
+				dst = &s.NOutData
+			case tlvNOutNacks:
+				dst = &s.NOutNacks
+			case tlvNInBytes:
+				dst = &s.NInBytes
+			case tlvNOutBytes:
+				dst = &s.NOutBytes
+			case tlvFlags:
+				dst = &s.Flags
+			default:
+				// Fields this package does not use are skipped.
+				continue
+			}
+			if *dst, err = decodeNonNegInt(f.value); err != nil {
+				return nil, err
+			}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// FetchDataset retrieves the latest version of a status dataset published
+// by the forwarder under name, as <name>/<version>/<segment>.
+func (c *Consumer) FetchDataset(ctx context.Context, name Name) ([]byte, error) {
+	first, err := c.Express(ctx, &Interest{Name: name, CanBePrefix: true, MustBeFresh: true})
+	if err != nil {
+		return nil, err
+	}
+	base, seg, ok := SplitSegmentName(first.Name)
+	if !ok || seg != 0 || len(base) != len(name)+1 || !name.IsPrefixOf(base) {
+		return nil, fmt.Errorf("ndn: %s is not the first segment of dataset %s", first.Name, name)
+	}
+	return c.fetchRemainingSegments(ctx, base, first)
+}
+
+// ListFaces returns the faces of the forwarder.
+func (c *Consumer) ListFaces(ctx context.Context) ([]FaceStatus, error) {
+	content, err := c.FetchDataset(ctx, FacesListPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeFaceStatuses(content)
+}
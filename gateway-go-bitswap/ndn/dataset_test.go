@@ -0,0 +1,70 @@
+package ndn_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/ndntest"
+)
+
+func TestFaceStatusRoundTrip(t *testing.T) {
+	in := ndn.FaceStatus{
+		FaceID:        260,
+		URI:           "udp4://192.0.2.1:6363",
+		LocalURI:      "udp4://192.0.2.2:6363",
+		Scope:         ndn.FaceScopeNonLocal,
+		Persistency:   ndn.FacePersistencyPermanent,
+		MTU:           8800,
+		NInInterests:  1,
+		NInData:       2,
+		NInNacks:      3,
+		NOutInterests: 4,
+		NOutData:      5,
+		NOutNacks:     6,
+		NInBytes:      1 << 40,
+		NOutBytes:     7,
+		Flags:         1,
+	}
+	out, err := ndn.DecodeFaceStatuses(append(in.Encode(), in.Encode()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0] != in || out[1] != in {
+		t.Fatalf("expected two copies of %+v, got %+v", in, out)
+	}
+}
+
+func TestListFaces(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	// Enough faces for the dataset to need several segments.
+	const others = 200
+	for i := 0; i < others; i++ {
+		f, err := fwd.Dial(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+	}
+
+	c := ndn.NewConsumer(fwd.Dial)
+	defer c.Close()
+	faces, err := c.ListFaces(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faces) != others+1 {
+		t.Fatalf("expected %d faces, got %d", others+1, len(faces))
+	}
+	for i, f := range faces {
+		if f.FaceID != uint64(i+1) || f.Scope != ndn.FaceScopeLocal {
+			t.Fatalf("unexpected face %+v", f)
+		}
+	}
+}
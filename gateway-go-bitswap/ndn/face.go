@@ -0,0 +1,347 @@
+package ndn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("ndn")
+
+var (
+	// ErrFaceClosed is returned by operations on a face whose connection
+	// has gone away.
+	ErrFaceClosed = errors.New("ndn: face closed")
+
+	// ErrTimeout is returned when an Interest is neither satisfied nor
+	// Nacked within its lifetime.
+	ErrTimeout = errors.New("ndn: interest timed out")
+)
+
+// NackError is returned when the forwarder answers an Interest with a Nack.
+type NackError struct {
+	Name   Name
+	Reason NackReason
+}
+
+func (e *NackError) Error() string {
+	return fmt.Sprintf("ndn: interest %s nacked: %s", e.Name, e.Reason)
+}
+
+// InterestHandler answers an Interest received on a face. It returns the
+// Data to send back, or nil to leave the Interest unanswered.
+type InterestHandler func(i *Interest) *Data
+
+type handlerEntry struct {
+	prefix  Name
+	handler InterestHandler
+}
+
+// pendingInterest is an Interest expressed on a face that is waiting for a
+// Data or a Nack.
+type pendingInterest struct {
+	interest *Interest
+	data     chan *Data
+	nack     chan NackReason
+}
+
+// Face is a connection to an NDN forwarder. It is safe for concurrent use.
+type Face struct {
+	conn io.ReadWriteCloser
+
+	wlk sync.Mutex
+
+	lk       sync.Mutex
+	pending  map[string][]*pendingInterest
+	handlers []handlerEntry
+	err      error
+
+	closed chan struct{}
+}
+
+// NewFace starts exchanging packets with a forwarder over conn.
+func NewFace(conn io.ReadWriteCloser) *Face {
+	f := &Face{
+		conn:    conn,
+		pending: make(map[string][]*pendingInterest),
+		closed:  make(chan struct{}),
+	}
+	go f.readLoop()
+	return f
+}
+
+// DialUnix connects to a forwarder listening on the Unix socket at path.
+func DialUnix(ctx context.Context, path string) (*Face, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFace(conn), nil
+}
+
+// Done returns a channel that is closed once the face is closed.
+func (f *Face) Done() <-chan struct{} {
+	return f.closed
+}
+
+// Close closes the connection to the forwarder. Pending Interests fail with
+// ErrFaceClosed.
+func (f *Face) Close() error {
+	return f.shutdown(ErrFaceClosed)
+}
+
+func (f *Face) shutdown(cause error) error {
+	f.lk.Lock()
+	if f.err != nil {
+		f.lk.Unlock()
+		return nil
+	}
+	f.err = cause
+	close(f.closed)
+	f.lk.Unlock()
+	return f.conn.Close()
+}
+
+// Send writes a raw packet to the forwarder. A failed write closes the face
+// and returns ErrFaceClosed.
+func (f *Face) Send(wire []byte) error {
+	if len(wire) > MaxPacketSize {
+		return errTooLarge
+	}
+	f.wlk.Lock()
+	defer f.wlk.Unlock()
+	select {
+	case <-f.closed:
+		return ErrFaceClosed
+	default:
+	}
+	if _, err := f.conn.Write(wire); err != nil {
+		log.Debugf("ndn face write error: %s", err)
+		f.shutdown(ErrFaceClosed)
+		return ErrFaceClosed
+	}
+	return nil
+}
+
+// Express sends an Interest and waits for the matching Data. It fails with a
+// *NackError if the forwarder Nacks the Interest, and with ErrTimeout if
+// nothing arrives within the Interest lifetime.
+func (f *Face) Express(ctx context.Context, i *Interest) (*Data, error) {
+	return f.express(ctx, i, i.lifetime())
+}
+
+// express is Express with a timeout that may be shorter than the Interest
+// lifetime, used for retransmissions.
+func (f *Face) express(ctx context.Context, i *Interest, timeout time.Duration) (*Data, error) {
+	if i.Nonce == 0 {
+		i.Nonce = NewNonce()
+	}
+	pi := &pendingInterest{
+		interest: i,
+		data:     make(chan *Data, 1),
+		nack:     make(chan NackReason, 1),
+	}
+	key := i.Name.key()
+
+	f.lk.Lock()
+	if f.err != nil {
+		f.lk.Unlock()
+		return nil, ErrFaceClosed
+	}
+	f.pending[key] = append(f.pending[key], pi)
+	f.lk.Unlock()
+	defer f.removePending(key, pi)
+
+	if err := f.Send(i.Encode()); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case d := <-pi.data:
+		return d, nil
+	case r := <-pi.nack:
+		return nil, &NackError{Name: i.Name, Reason: r}
+	case <-timer.C:
+		return nil, ErrTimeout
+	case <-f.closed:
+		return nil, ErrFaceClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (f *Face) removePending(key string, pi *pendingInterest) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	entries := f.pending[key]
+	for idx, e := range entries {
+		if e == pi {
+			entries = append(entries[:idx], entries[idx+1:]...)
+			break
+		}
+	}
+	if len(entries) == 0 {
+		delete(f.pending, key)
+	} else {
+		f.pending[key] = entries
+	}
+}
+
+func (f *Face) readLoop() {
+	r := bufio.NewReader(f.conn)
+	for {
+		pkt, err := ReadPacket(r)
+		if err != nil {
+			if err != io.EOF {
+				log.Debugf("ndn face read error: %s", err)
+			}
+			f.shutdown(ErrFaceClosed)
+			return
+		}
+		f.dispatch(pkt)
+	}
+}
+
+// dispatch handles one packet received from the forwarder.
+func (f *Face) dispatch(pkt []byte) {
+	e, _, err := readElement(pkt)
+	if err != nil {
+		return
+	}
+
+	var nack *NackReason
+	if e.typ == tlvLpPacket {
+		lp, err := decodeLpPacket(e.value)
+		if err != nil || lp.fragment == nil {
+			log.Debugf("dropping malformed LpPacket: %v", err)
+			return
+		}
+		pkt, nack = lp.fragment, lp.nack
+		if e, _, err = readElement(pkt); err != nil {
+			return
+		}
+	}
+
+	switch {
+	case e.typ == tlvData && nack == nil:
+		d, err := DecodeData(pkt)
+		if err != nil {
+			log.Debugf("dropping malformed Data: %s", err)
+			return
+		}
+		f.satisfy(d)
+	case e.typ == tlvInterest && nack != nil:
+		i, err := DecodeInterest(pkt)
+		if err != nil {
+			log.Debugf("dropping malformed Nack: %s", err)
+			return
+		}
+		f.nacked(i, *nack)
+	case e.typ == tlvInterest:
+		i, err := DecodeInterest(pkt)
+		if err != nil {
+			log.Debugf("dropping malformed Interest: %s", err)
+			return
+		}
+		f.handleInterest(i)
+	}
+}
+
+// satisfy delivers d to every pending Interest it matches. Interests are
+// looked up under every prefix of the full name of d, so that Interests
+// ending in its implicit digest are satisfied too.
+func (f *Face) satisfy(d *Data) {
+	full := d.FullName()
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for l := len(full); l >= 0; l-- {
+		key := full[:l].key()
+		for _, pi := range f.pending[key] {
+			if pi.interest.Matches(d) {
+				select {
+				case pi.data <- d:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (f *Face) nacked(i *Interest, reason NackReason) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for _, pi := range f.pending[i.Name.key()] {
+		if pi.interest.Nonce == i.Nonce {
+			select {
+			case pi.nack <- reason:
+			default:
+			}
+		}
+	}
+}
+
+// SetInterestHandler answers Interests under prefix with h. Interests are
+// dispatched to the handler with the longest matching prefix. The forwarder
+// only sends Interests for prefixes registered with Register.
+func (f *Face) SetInterestHandler(prefix Name, h InterestHandler) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for idx := range f.handlers {
+		if f.handlers[idx].prefix.Equal(prefix) {
+			f.handlers[idx].handler = h
+			return
+		}
+	}
+	f.handlers = append(f.handlers, handlerEntry{prefix: prefix, handler: h})
+}
+
+// RemoveInterestHandler stops answering Interests under prefix.
+func (f *Face) RemoveInterestHandler(prefix Name) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for idx := range f.handlers {
+		if f.handlers[idx].prefix.Equal(prefix) {
+			f.handlers = append(f.handlers[:idx], f.handlers[idx+1:]...)
+			return
+		}
+	}
+}
+
+// handleInterest is called for Interests the forwarder sends to this face.
+// Interests nobody handles are dropped.
+func (f *Face) handleInterest(i *Interest) {
+	f.lk.Lock()
+	var h InterestHandler
+	best := -1
+	for _, e := range f.handlers {
+		if len(e.prefix) > best && e.prefix.IsPrefixOf(i.Name) {
+			h, best = e.handler, len(e.prefix)
+		}
+	}
+	f.lk.Unlock()
+
+	if h == nil {
+		log.Debugf("dropping unexpected Interest %s", i.Name)
+		return
+	}
+	go func() {
+		d := h(i)
+		if d == nil {
+			return
+		}
+		if err := f.Send(d.Encode()); err != nil {
+			log.Debugf("sending Data %s: %s", d.Name, err)
+		}
+	}()
+}
@@ -0,0 +1,38 @@
+package ndn_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/ndntest"
+)
+
+func TestFaceExpressFullName(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+	name := ndn.MustParseName("/ipfs/block")
+	d := &ndn.Data{Name: name, Content: []byte("some content")}
+	fwd.Serve(name, func(i *ndn.Interest) *ndn.Data {
+		return d
+	})
+
+	face, err := fwd.Dial(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer face.Close()
+
+	got, err := face.Express(ctx, &ndn.Interest{Name: d.FullName(), Lifetime: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Content, d.Content) {
+		t.Fatalf("unexpected content %q", got.Content)
+	}
+}
@@ -0,0 +1,260 @@
+package ndn
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// TLV-TYPE numbers of the NFD management protocol.
+const (
+	tlvControlParameters = 0x68
+	tlvFaceID            = 0x69
+	tlvCost              = 0x6a
+	tlvFlags             = 0x6c
+	tlvExpirationPeriod  = 0x6d
+	tlvOrigin            = 0x6f
+
+	tlvControlResponse = 0x65
+	tlvStatusCode      = 0x66
+	tlvStatusText      = 0x67
+)
+
+// Route flags of a rib/register command.
+const (
+	RouteChildInherit = 1
+	RouteCapture      = 2
+)
+
+// RouteOriginApp is the origin of routes registered by applications.
+const RouteOriginApp = 0
+
+// LocalhostCommandPrefix is the prefix of management commands accepted from
+// applications running on the forwarder's host.
+var LocalhostCommandPrefix = MustParseName("/localhost/nfd")
+
+// ControlParameters are the arguments of an NFD management command. Zero
+// fields are omitted, in which case NFD applies its defaults; a zero FaceID
+// means the face the command arrived on.
+type ControlParameters struct {
+	Name             Name
+	FaceID           uint64
+	Origin           uint64
+	Cost             uint64
+	Flags            uint64
+	ExpirationPeriod time.Duration
+}
+
+// Encode returns the wire encoding of the parameters.
+func (p *ControlParameters) Encode() []byte {
+	var v []byte
+	if p.Name != nil {
+		v = p.Name.appendTo(v)
+	}
+	if p.FaceID != 0 {
+		v = appendNonNegInt(v, tlvFaceID, p.FaceID)
+	}
+	if p.Origin != 0 {
+		v = appendNonNegInt(v, tlvOrigin, p.Origin)
+	}
+	if p.Cost != 0 {
+		v = appendNonNegInt(v, tlvCost, p.Cost)
+	}
+	if p.Flags != 0 {
+		v = appendNonNegInt(v, tlvFlags, p.Flags)
+	}
+	if p.ExpirationPeriod > 0 {
+		v = appendNonNegInt(v, tlvExpirationPeriod, uint64(p.ExpirationPeriod/time.Millisecond))
+	}
+	return appendTLV(nil, tlvControlParameters, v)
+}
+
+// DecodeControlParameters decodes the wire encoding of ControlParameters.
+func DecodeControlParameters(wire []byte) (*ControlParameters, error) {
+	e, n, err := readElement(wire)
+	if err != nil {
+		return nil, err
+	}
+	if e.typ != tlvControlParameters || n != len(wire) {
+		return nil, fmt.Errorf("ndn: not a ControlParameters element")
+	}
+	elems, err := readElements(e.value)
+	if err != nil {
+		return nil, err
+	}
+	p := new(ControlParameters)
+	for _, el := range elems {
+		if el.typ == tlvName {
+			if p.Name, err = decodeName(el.value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var dst *uint64
+		switch el.typ {
+		case tlvFaceID:
+			dst = &p.FaceID
+		case tlvOrigin:
+			dst = &p.Origin
+		case tlvCost:
+			dst = &p.Cost
+		case tlvFlags:
+			dst = &p.Flags
+		case tlvExpirationPeriod:
+			ms, err := decodeNonNegInt(el.value)
+			if err != nil {
+				return nil, err
+			}
+			p.ExpirationPeriod = time.Duration(ms) * time.Millisecond
+			continue
+		default:
+			// Parameters this package does not use are skipped.
+			continue
+		}
+		if *dst, err = decodeNonNegInt(el.value); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ControlResponse is the status returned by NFD for a management command.
+type ControlResponse struct {
+	StatusCode uint64
+	StatusText string
+	// Body holds the elements following the status, usually the
+	// ControlParameters the command was executed with.
+	Body []byte
+}
+
+// Encode returns the wire encoding of the response.
+func (r *ControlResponse) Encode() []byte {
+	v := appendNonNegInt(nil, tlvStatusCode, r.StatusCode)
+	v = appendTLV(v, tlvStatusText, []byte(r.StatusText))
+	v = append(v, r.Body...)
+	return appendTLV(nil, tlvControlResponse, v)
+}
+
+// DecodeControlResponse decodes the wire encoding of a ControlResponse.
+func DecodeControlResponse(wire []byte) (*ControlResponse, error) {
+	e, _, err := readElement(wire)
+	if err != nil {
+		return nil, err
+	}
+	if e.typ != tlvControlResponse {
+		return nil, fmt.Errorf("ndn: not a ControlResponse element")
+	}
+	elems, err := readElements(e.value)
+	if err != nil {
+		return nil, err
+	}
+	r := new(ControlResponse)
+	for _, el := range elems {
+		switch el.typ {
+		case tlvStatusCode:
+			if r.StatusCode, err = decodeNonNegInt(el.value); err != nil {
+				return nil, err
+			}
+		case tlvStatusText:
+			r.StatusText = string(el.value)
+		default:
+			r.Body = append(r.Body, el.wire...)
+		}
+	}
+	return r, nil
+}
+
+// CommandError is returned when NFD rejects a management command.
+type CommandError struct {
+	Command  string
+	Response ControlResponse
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("ndn: %s failed: %d %s", e.Command, e.Response.StatusCode, e.Response.StatusText)
+}
+
+// CommandInterest builds a management command Interest for module and verb,
+// such as rib and register. The command is signed with DigestSha256, which
+// NFD accepts for commands sent to LocalhostCommandPrefix.
+func CommandInterest(module, verb string, p *ControlParameters) *Interest {
+	name := LocalhostCommandPrefix.Append(
+		GenericComponent([]byte(module)),
+		GenericComponent([]byte(verb)),
+		GenericComponent(p.Encode()),
+	)
+
+	// Signed Interest: timestamp, random value, SignatureInfo and
+	// SignatureValue components.
+	random := make([]byte, 8)
+	rand.Read(random)
+	name = name.Append(
+		GenericComponent(nonNegIntBytes(uint64(time.Now().UnixNano()/int64(time.Millisecond)))),
+		GenericComponent(random),
+		GenericComponent(appendTLV(nil, tlvSignatureInfo, appendNonNegInt(nil, tlvSignatureType, SignatureDigestSha256))),
+	)
+	var signed []byte
+	for _, c := range name {
+		signed = c.appendTo(signed)
+	}
+	sum := sha256.Sum256(signed)
+	name = name.Append(GenericComponent(appendTLV(nil, tlvSignatureValue, sum[:])))
+
+	return &Interest{Name: name, MustBeFresh: true}
+}
+
+// ParseCommand extracts the module, verb and parameters of a management
+// command Interest built by CommandInterest.
+func ParseCommand(i *Interest) (module, verb string, p *ControlParameters, err error) {
+	n := len(LocalhostCommandPrefix)
+	if !LocalhostCommandPrefix.IsPrefixOf(i.Name) || len(i.Name) < n+3 {
+		return "", "", nil, fmt.Errorf("ndn: %s is not a management command", i.Name)
+	}
+	p, err = DecodeControlParameters(i.Name[n+2].Value)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return string(i.Name[n].Value), string(i.Name[n+1].Value), p, nil
+}
+
+// Command sends a management command to the forwarder and waits for its
+// response. Responses with a status code other than 200 fail with a
+// *CommandError.
+func (f *Face) Command(ctx context.Context, module, verb string, p *ControlParameters) (*ControlParameters, error) {
+	d, err := f.Express(ctx, CommandInterest(module, verb, p))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := DecodeControlResponse(d.Content)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, &CommandError{Command: module + "/" + verb, Response: *resp}
+	}
+	if len(resp.Body) == 0 {
+		return p, nil
+	}
+	return DecodeControlParameters(resp.Body)
+}
+
+// Register asks the forwarder to route Interests under prefix to this face.
+func (f *Face) Register(ctx context.Context, prefix Name) error {
+	_, err := f.Command(ctx, "rib", "register", &ControlParameters{
+		Name:   prefix,
+		Origin: RouteOriginApp,
+		Flags:  RouteChildInherit,
+	})
+	return err
+}
+
+// Unregister removes the route to prefix added by Register.
+func (f *Face) Unregister(ctx context.Context, prefix Name) error {
+	_, err := f.Command(ctx, "rib", "unregister", &ControlParameters{
+		Name:   prefix,
+		Origin: RouteOriginApp,
+	})
+	return err
+}
@@ -0,0 +1,320 @@
+package ndn
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Name component types, see the NDN naming conventions (rev3).
+const (
+	ComponentImplicitSha256Digest   = 0x01
+	ComponentParametersSha256Digest = 0x02
+	ComponentGeneric                = 0x08
+	ComponentKeyword                = 0x20
+	ComponentSegment                = 0x32
+	ComponentByteOffset             = 0x34
+	ComponentVersion                = 0x36
+	ComponentTimestamp              = 0x38
+	ComponentSequenceNum            = 0x3a
+)
+
+// uriTypes maps the component types that have an alternate URI
+// representation to their prefix.
+var uriTypes = map[uint64]string{
+	ComponentSegment:     "seg",
+	ComponentByteOffset:  "off",
+	ComponentVersion:     "v",
+	ComponentTimestamp:   "t",
+	ComponentSequenceNum: "seq",
+}
+
+// Component is a single typed NDN name component.
+type Component struct {
+	Type  uint64
+	Value []byte
+}
+
+// GenericComponent returns a GenericNameComponent holding v.
+func GenericComponent(v []byte) Component {
+	return Component{Type: ComponentGeneric, Value: v}
+}
+
+// KeywordComponent returns a KeywordNameComponent holding v.
+func KeywordComponent(v []byte) Component {
+	return Component{Type: ComponentKeyword, Value: v}
+}
+
+// SegmentComponent returns a segment number component.
+func SegmentComponent(seg uint64) Component {
+	return Component{Type: ComponentSegment, Value: nonNegIntBytes(seg)}
+}
+
+// VersionComponent returns a version number component.
+func VersionComponent(v uint64) Component {
+	return Component{Type: ComponentVersion, Value: nonNegIntBytes(v)}
+}
+
+// ImplicitDigestComponent returns an implicit SHA-256 digest component
+// holding digest.
+func ImplicitDigestComponent(digest []byte) Component {
+	return Component{Type: ComponentImplicitSha256Digest, Value: digest}
+}
+
+// IsSegment reports whether the component is a segment number.
+func (c Component) IsSegment() bool {
+	return c.Type == ComponentSegment
+}
+
+// Segment returns the segment number held by a segment component.
+func (c Component) Segment() (uint64, error) {
+	if !c.IsSegment() {
+		return 0, fmt.Errorf("ndn: component type %d is not a segment", c.Type)
+	}
+	return decodeNonNegInt(c.Value)
+}
+
+// Equal reports whether both components have the same type and value.
+func (c Component) Equal(o Component) bool {
+	return c.Type == o.Type && bytes.Equal(c.Value, o.Value)
+}
+
+// String returns the URI representation of the component.
+func (c Component) String() string {
+	switch c.Type {
+	case ComponentGeneric:
+		return escapeComponent(c.Value)
+	case ComponentImplicitSha256Digest:
+		return "sha256digest=" + hex.EncodeToString(c.Value)
+	case ComponentParametersSha256Digest:
+		return "params-sha256=" + hex.EncodeToString(c.Value)
+	}
+	if p, ok := uriTypes[c.Type]; ok {
+		if v, err := decodeNonNegInt(c.Value); err == nil {
+			return p + "=" + strconv.FormatUint(v, 10)
+		}
+	}
+	return strconv.FormatUint(c.Type, 10) + "=" + escapeComponent(c.Value)
+}
+
+func (c Component) appendTo(b []byte) []byte {
+	return appendTLV(b, c.Type, c.Value)
+}
+
+// ParseComponent parses the URI representation of a name component.
+func ParseComponent(s string) (Component, error) {
+	eq := strings.IndexByte(s, '=')
+	if eq < 0 {
+		v, err := unescapeComponent(s)
+		if err != nil {
+			return Component{}, err
+		}
+		return GenericComponent(v), nil
+	}
+
+	prefix, rest := s[:eq], s[eq+1:]
+	switch prefix {
+	case "sha256digest", "params-sha256":
+		v, err := hex.DecodeString(rest)
+		if err != nil || len(v) != 32 {
+			return Component{}, fmt.Errorf("ndn: invalid digest component %q", s)
+		}
+		typ := uint64(ComponentImplicitSha256Digest)
+		if prefix == "params-sha256" {
+			typ = ComponentParametersSha256Digest
+		}
+		return Component{Type: typ, Value: v}, nil
+	}
+	for typ, p := range uriTypes {
+		if p == prefix {
+			n, err := strconv.ParseUint(rest, 10, 64)
+			if err != nil {
+				return Component{}, fmt.Errorf("ndn: invalid component %q: %s", s, err)
+			}
+			return Component{Type: typ, Value: nonNegIntBytes(n)}, nil
+		}
+	}
+
+	typ, err := strconv.ParseUint(prefix, 10, 16)
+	if err != nil || typ == 0 {
+		return Component{}, fmt.Errorf("ndn: unknown component type in %q", s)
+	}
+	v, err := unescapeComponent(rest)
+	if err != nil {
+		return Component{}, err
+	}
+	return Component{Type: typ, Value: v}, nil
+}
+
+func isUnreserved(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func escapeComponent(v []byte) string {
+	var sb strings.Builder
+	periods := true
+	for _, c := range v {
+		if c != '.' {
+			periods = false
+		}
+	}
+	if periods {
+		// A value made only of periods (including the empty value) gets
+		// three extra periods so that it is not mistaken for "." or "..".
+		sb.WriteString("...")
+	}
+	for _, c := range v {
+		if isUnreserved(c) {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func unescapeComponent(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			out = append(out, s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return nil, fmt.Errorf("ndn: truncated escape in %q", s)
+		}
+		v, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return nil, fmt.Errorf("ndn: invalid escape in %q", s)
+		}
+		out = append(out, v[0])
+		i += 2
+	}
+	if len(bytes.Trim(out, ".")) == 0 {
+		if len(out) < 3 {
+			return nil, fmt.Errorf("ndn: invalid component %q", s)
+		}
+		out = out[3:]
+	}
+	return out, nil
+}
+
+// Name is a hierarchical NDN name.
+type Name []Component
+
+// ParseName parses an NDN URI such as "/ipfs/seg=3". The "ndn:" scheme is
+// optional.
+func ParseName(uri string) (Name, error) {
+	uri = strings.TrimPrefix(uri, "ndn:")
+	uri = strings.Trim(uri, "/")
+	if uri == "" {
+		return Name{}, nil
+	}
+	parts := strings.Split(uri, "/")
+	n := make(Name, 0, len(parts))
+	for _, p := range parts {
+		c, err := ParseComponent(p)
+		if err != nil {
+			return nil, err
+		}
+		n = append(n, c)
+	}
+	return n, nil
+}
+
+// MustParseName is like ParseName but panics on error. It is intended for
+// package level variables and tests.
+func MustParseName(uri string) Name {
+	n, err := ParseName(uri)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// String returns the URI representation of the name.
+func (n Name) String() string {
+	if len(n) == 0 {
+		return "/"
+	}
+	var sb strings.Builder
+	for _, c := range n {
+		sb.WriteByte('/')
+		sb.WriteString(c.String())
+	}
+	return sb.String()
+}
+
+// Append returns a new name made of n followed by cs. The receiver is left
+// unmodified.
+func (n Name) Append(cs ...Component) Name {
+	out := make(Name, 0, len(n)+len(cs))
+	out = append(out, n...)
+	return append(out, cs...)
+}
+
+// IsPrefixOf reports whether n is a prefix of (or equal to) o.
+func (n Name) IsPrefixOf(o Name) bool {
+	if len(n) > len(o) {
+		return false
+	}
+	for i := range n {
+		if !n[i].Equal(o[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether both names have the same components.
+func (n Name) Equal(o Name) bool {
+	return len(n) == len(o) && n.IsPrefixOf(o)
+}
+
+// Encode returns the wire encoding of the Name element.
+func (n Name) Encode() []byte {
+	return n.appendTo(nil)
+}
+
+func (n Name) appendTo(b []byte) []byte {
+	var v []byte
+	for _, c := range n {
+		v = c.appendTo(v)
+	}
+	return appendTLV(b, tlvName, v)
+}
+
+// key returns a string that can be used to index maps by name.
+func (n Name) key() string {
+	return string(n.Encode())
+}
+
+func decodeName(value []byte) (Name, error) {
+	els, err := readElements(value)
+	if err != nil {
+		return nil, err
+	}
+	n := make(Name, 0, len(els))
+	for _, e := range els {
+		if e.typ == 0 || e.typ > 0xffff {
+			return nil, fmt.Errorf("ndn: invalid name component type %d", e.typ)
+		}
+		n = append(n, Component{Type: e.typ, Value: e.value})
+	}
+	return n, nil
+}
+
+// DecodeName decodes the wire encoding of a Name element.
+func DecodeName(wire []byte) (Name, error) {
+	e, n, err := readElement(wire)
+	if err != nil {
+		return nil, err
+	}
+	if e.typ != tlvName || n != len(wire) {
+		return nil, fmt.Errorf("ndn: not a Name element")
+	}
+	return decodeName(e.value)
+}
@@ -0,0 +1,403 @@
+// Package ndnexchange implements the IPFS exchange interface on top of NDN.
+//
+// Blocks are fetched by their cidname name through an ndn.Consumer, checked
+// against their CID and written to the blockstore, so that a blockservice can
+// use NDN instead of, or next to, bitswap. Coded blocks are found through the
+// coded block list of their parent, see producer.CodedIndex, and recorded in
+// a coding.Index once fetched. With CodedVerification, "nc" coded blocks are
+// checked against the checksums of their generation parent first.
+package ndnexchange
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("ndn/exchange")
+
+var _ exchange.SessionExchange = (*Exchange)(nil)
+var _ exchange.FetcherC = (*Exchange)(nil)
+
+var (
+	// ErrClosed is returned when fetching from a closed exchange.
+	ErrClosed = errors.New("ndn exchange closed")
+
+	// ErrHashMismatch is returned when the content fetched for a block does
+	// not hash to its CID.
+	ErrHashMismatch = errors.New("ndn exchange: block content does not match its CID")
+)
+
+// Exchange fetches blocks from NDN.
+type Exchange struct {
+	consumer *ndn.Consumer
+	names    *cidname.Mapper
+	bs       bstore.Blockstore
+	coded    coding.Index
+	notify   exchange.Interface
+	verifier *codedleaf.Verifier
+
+	// verify and checksumKey set up the verifier in New.
+	verify      bool
+	checksumKey []byte
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Option configures an Exchange.
+type Option func(*Exchange)
+
+// CodedIndex sets the index the fetched coded blocks are recorded in,
+// usually the one bitswap and the blockservice share. It defaults to an
+// index kept in memory.
+func CodedIndex(idx coding.Index) Option {
+	return func(e *Exchange) {
+		e.coded = idx
+	}
+}
+
+// Notify sets an exchange that is told about every block fetched over NDN
+// with HasBlock once it is stored, usually bitswap, so that its sessions and
+// the peers wanting the block learn about it.
+func Notify(x exchange.Interface) Option {
+	return func(e *Exchange) {
+		e.notify = x
+	}
+}
+
+// CodedVerification makes the exchange check the "nc" coded blocks it
+// fetches against the checksums of their generation parent before storing
+// them, like bitswap.WithCodedVerification. The parent is fetched first if
+// needed.
+func CodedVerification(enabled bool) Option {
+	return func(e *Exchange) {
+		e.verify = enabled
+	}
+}
+
+// ChecksumKey sets the key unmasking the keyed checksums of the
+// generations, like bitswap.WithChecksumKey.
+func ChecksumKey(key []byte) Option {
+	return func(e *Exchange) {
+		e.checksumKey = key
+	}
+}
+
+// New creates an exchange fetching blocks named by names through consumer,
+// and storing them in bs.
+func New(consumer *ndn.Consumer, names *cidname.Mapper, bs bstore.Blockstore, opts ...Option) *Exchange {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Exchange{
+		consumer: consumer,
+		names:    names,
+		bs:       bs,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	if e.coded == nil {
+		e.coded = coding.NewIndex(dssync.MutexWrap(ds.NewMapDatastore()))
+	}
+	if e.verify {
+		e.verifier = codedleaf.NewVerifier(bs, e.checksumKey)
+	}
+	return e
+}
+
+// GetBlock fetches the block c from NDN. Blocks nobody answers for fail with
+// blockstore.ErrNotFound.
+func (e *Exchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	ctx, cancel, err := e.context(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	return e.getBlock(ctx, c)
+}
+
+func (e *Exchange) getBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := e.fetch(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.bs.Put(blk); err != nil {
+		return nil, err
+	}
+	e.announce(blk)
+	return blk, nil
+}
+
+// GetBlocks fetches blocks concurrently and returns them as they arrive, in
+// no particular order. Blocks that cannot be fetched are left out.
+func (e *Exchange) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
+	ctx, cancel, err := e.context(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan blocks.Block)
+	var wg sync.WaitGroup
+	for _, c := range keys {
+		wg.Add(1)
+		go func(c cid.Cid) {
+			defer wg.Done()
+			blk, err := e.getBlock(ctx, c)
+			if err != nil {
+				log.Debugf("fetching %s: %s", c, err)
+				return
+			}
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+			}
+		}(c)
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+	return out, nil
+}
+
+// GetBlocksC fetches up to count blocks coded from parent with coding. The
+// coded blocks are listed by the producers of the parent; blocks already in
+// the blockstore are skipped, the others are written to the blockstore and to
+// the local coded block list. Blocks failing verification are dropped.
+func (e *Exchange) GetBlocksC(ctx context.Context, parent cid.Cid, coding string, count int) (<-chan blocks.Block, error) {
+	ctx, cancel, err := e.context(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer cancel()
+
+		cids, err := e.codedList(ctx, parent, coding)
+		if err != nil {
+			log.Debugf("fetching the %s blocks of %s: %s", coding, parent, err)
+			return
+		}
+		if e.verifier != nil {
+			// The coded blocks are checked against their parent.
+			if has, err := e.bs.Has(parent); err == nil && !has {
+				if _, err := e.getBlock(ctx, parent); err != nil {
+					log.Debugf("fetching the parent of the %s blocks %s: %s", coding, parent, err)
+					return
+				}
+			}
+		}
+
+		// Keep at most count fetches running, and start the next listed
+		// block whenever one fails.
+		results := make(chan *blocks.CodedBlock)
+		next, pending := 0, 0
+		start := func() {
+			go func(c cid.Cid) {
+				blk, err := e.fetchCoded(ctx, c, parent, coding)
+				if err != nil {
+					log.Debugf("fetching %s block %s: %s", coding, c, err)
+				}
+				select {
+				case results <- blk:
+				case <-ctx.Done():
+				}
+			}(cids[next])
+			next++
+			pending++
+		}
+		for pending < count && next < len(cids) {
+			start()
+		}
+
+		for pending > 0 {
+			var blk *blocks.CodedBlock
+			select {
+			case blk = <-results:
+			case <-ctx.Done():
+				return
+			}
+			pending--
+			if blk == nil {
+				if next < len(cids) {
+					start()
+				}
+				continue
+			}
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// codedList fetches the list of coded blocks of parent and returns those that
+// are not in the blockstore yet.
+func (e *Exchange) codedList(ctx context.Context, parent cid.Cid, coding string) ([]cid.Cid, error) {
+	list, err := e.consumer.FetchSegmented(ctx, e.names.CodedName(parent, coding))
+	if err != nil {
+		return nil, e.notFound(err)
+	}
+	var cids []cid.Cid
+	for _, line := range bytes.Split(list, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		c, err := cid.Parse(string(line))
+		if err != nil {
+			return nil, fmt.Errorf("ndn exchange: invalid %s list of %s: %s", coding, parent, err)
+		}
+		if has, err := e.bs.Has(c); err == nil && has {
+			continue
+		}
+		cids = append(cids, c)
+	}
+	return cids, nil
+}
+
+// fetchCoded fetches the coded block c of parent, verifies it and stores
+// it.
+func (e *Exchange) fetchCoded(ctx context.Context, c, parent cid.Cid, coding string) (*blocks.CodedBlock, error) {
+	b, err := e.fetch(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	blk, err := blocks.NewCodedBlockWithCid(b.RawData(), c, parent)
+	if err != nil {
+		return nil, err
+	}
+	if e.verifier != nil && coding == "nc" {
+		if err := e.verifier.Verify(blk); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.putCoded(blk, coding); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// putCoded writes a coded block to the blockstore and records it in the
+// coded index under its parent.
+func (e *Exchange) putCoded(blk *blocks.CodedBlock, coding string) error {
+	if err := e.bs.Put(blk); err != nil {
+		return err
+	}
+	if err := e.coded.Add(blk.Parent(), coding, blk.Cid()); err != nil {
+		return err
+	}
+	e.announce(blk)
+	return nil
+}
+
+// announce tells the exchange set with Notify about a block that was stored.
+func (e *Exchange) announce(blk blocks.Block) {
+	if e.notify == nil {
+		return
+	}
+	if err := e.notify.HasBlock(blk); err != nil {
+		log.Debugf("announcing %s: %s", blk.Cid(), err)
+	}
+}
+
+// fetch retrieves and verifies the content of block c.
+func (e *Exchange) fetch(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	content, err := e.consumer.FetchSegmented(ctx, e.names.Name(c))
+	if err != nil {
+		return nil, e.notFound(err)
+	}
+	chk, err := c.Prefix().Sum(content)
+	if err != nil {
+		return nil, err
+	}
+	if !chk.Equals(c) {
+		return nil, ErrHashMismatch
+	}
+	return blocks.NewBlockWithCid(content, c)
+}
+
+// notFound translates the NDN errors meaning that nobody answered into
+// blockstore.ErrNotFound, which the blockservice expects.
+func (e *Exchange) notFound(err error) error {
+	if err == ndn.ErrTimeout {
+		return bstore.ErrNotFound
+	}
+	if _, ok := err.(*ndn.NackError); ok {
+		return bstore.ErrNotFound
+	}
+	if err == context.Canceled && e.ctx.Err() != nil {
+		return ErrClosed
+	}
+	return err
+}
+
+// context returns a context canceled when either ctx is done or the exchange
+// is closed.
+func (e *Exchange) context(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if e.ctx.Err() != nil {
+		return nil, nil, ErrClosed
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-e.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel, nil
+}
+
+// HasBlock does nothing: the NDN producer answers from the blockstore, new
+// blocks need not be announced.
+func (e *Exchange) HasBlock(blocks.Block) error {
+	return nil
+}
+
+// IsOnline returns true.
+func (e *Exchange) IsOnline() bool {
+	return true
+}
+
+// NewSession returns a fetcher whose fetches end once ctx is done or the
+// exchange is closed. Interests of all sessions share the consumer's
+// congestion window, so sessions keep no other state of their own.
+func (e *Exchange) NewSession(ctx context.Context) exchange.Fetcher {
+	ctx, cancel, err := e.context(ctx)
+	if err != nil {
+		// A closed exchange fails every fetch.
+		return e
+	}
+	s := *e
+	s.ctx, s.cancel = ctx, cancel
+	return &s
+}
+
+// Close cancels the pending fetches. The consumer is owned by the caller and
+// left open.
+func (e *Exchange) Close() error {
+	e.cancel()
+	return nil
+}
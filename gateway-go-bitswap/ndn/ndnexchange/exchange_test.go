@@ -0,0 +1,360 @@
+package ndnexchange
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-bitswap/internal/codedleaf"
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	"github.com/ipfs/go-bitswap/ndn/ndntest"
+	"github.com/ipfs/go-bitswap/ndn/producer"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-block-format/coding/rlnc"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+)
+
+func newBlockstore() bstore.Blockstore {
+	return bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+}
+
+// testNetwork is a producer serving a blockstore and an exchange fetching
+// into another one through a test forwarder.
+type testNetwork struct {
+	fwd      *ndntest.Forwarder
+	producer *producer.Producer
+	remote   bstore.Blockstore
+	local    bstore.Blockstore
+	consumer *ndn.Consumer
+	ex       *Exchange
+
+	remoteCoded coding.Index
+	localCoded  coding.Index
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	n := &testNetwork{
+		fwd:         ndntest.New(),
+		remote:      newBlockstore(),
+		local:       newBlockstore(),
+		remoteCoded: coding.NewIndex(dssync.MutexWrap(ds.NewMapDatastore())),
+		localCoded:  coding.NewIndex(dssync.MutexWrap(ds.NewMapDatastore())),
+	}
+	n.producer = producer.New(n.remote, cidname.Default, n.fwd.Dial, producer.Coded(n.remoteCoded))
+	if err := n.producer.Start(); err != nil {
+		t.Fatal(err)
+	}
+	n.consumer = ndn.NewConsumer(n.fwd.Dial, ndn.InterestLifetime(100*time.Millisecond), ndn.Retries(0))
+	n.ex = New(n.consumer, cidname.Default, n.local, CodedIndex(n.localCoded))
+	return n
+}
+
+func (n *testNetwork) Close() {
+	n.ex.Close()
+	n.consumer.Close()
+	n.producer.Close()
+	n.fwd.Close()
+}
+
+// addCoded stores coded blocks of parent on the producer side.
+func (n *testNetwork) addCoded(t *testing.T, parent cid.Cid, coded []blocks.Block) {
+	for _, b := range coded {
+		if err := n.remote.Put(b); err != nil {
+			t.Fatal(err)
+		}
+		if err := n.remoteCoded.Add(parent, "nc", b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetBlock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNetwork(t)
+	defer n.Close()
+
+	blk := blocks.NewBlock([]byte("fetched over ndn"))
+	if err := n.remote.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := n.ex.GetBlock(ctx, blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Cid().Equals(blk.Cid()) {
+		t.Fatalf("expected %s, got %s", blk.Cid(), got.Cid())
+	}
+	if has, _ := n.local.Has(blk.Cid()); !has {
+		t.Fatal("expected the block to be stored")
+	}
+
+	missing := blocks.NewBlock([]byte("nobody has this"))
+	if _, err := n.ex.GetBlock(ctx, missing.Cid()); err != bstore.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// notifier records the blocks announced with HasBlock.
+type notifier struct {
+	exchange.Interface
+	lk  sync.Mutex
+	has []cid.Cid
+}
+
+func (n *notifier) HasBlock(b blocks.Block) error {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+	n.has = append(n.has, b.Cid())
+	return nil
+}
+
+func TestNotify(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNetwork(t)
+	defer n.Close()
+	var nt notifier
+	ex := New(n.consumer, cidname.Default, n.local, Notify(&nt))
+	defer ex.Close()
+
+	blk := blocks.NewBlock([]byte("fetched over ndn"))
+	if err := n.remote.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ex.GetBlock(ctx, blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	missing := blocks.NewBlock([]byte("nobody has this"))
+	if _, err := ex.GetBlock(ctx, missing.Cid()); err != bstore.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	nt.lk.Lock()
+	defer nt.lk.Unlock()
+	if len(nt.has) != 1 || !nt.has[0].Equals(blk.Cid()) {
+		t.Fatalf("expected %s to be announced, got %v", blk.Cid(), nt.has)
+	}
+}
+
+func TestGetBlockHashMismatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNetwork(t)
+	defer n.Close()
+
+	blk := blocks.NewBlock([]byte("expected"))
+	n.fwd.ServeSegmented(cidname.Default.Name(blk.Cid()), []byte("something else"))
+
+	if _, err := n.ex.GetBlock(ctx, blk.Cid()); err != ErrHashMismatch {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+	if has, _ := n.local.Has(blk.Cid()); has {
+		t.Fatal("expected the block not to be stored")
+	}
+}
+
+func TestGetBlocks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNetwork(t)
+	defer n.Close()
+
+	var keys []cid.Cid
+	for i := 0; i < 10; i++ {
+		blk := blocks.NewBlock([]byte(fmt.Sprintf("block %d", i)))
+		if err := n.remote.Put(blk); err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, blk.Cid())
+	}
+	keys = append(keys, blocks.NewBlock([]byte("missing")).Cid())
+
+	out, err := n.ex.GetBlocks(ctx, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(map[cid.Cid]bool)
+	for blk := range out {
+		received[blk.Cid()] = true
+	}
+	if len(received) != 10 {
+		t.Fatalf("expected 10 blocks, got %d", len(received))
+	}
+	for _, c := range keys[:10] {
+		if !received[c] {
+			t.Fatalf("missing block %s", c)
+		}
+	}
+}
+
+func TestGetBlocksC(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNetwork(t)
+	defer n.Close()
+
+	parent := blocks.NewBlock([]byte("parent"))
+	var coded []blocks.Block
+	for i := 0; i < 5; i++ {
+		coded = append(coded, blocks.NewBlock([]byte(fmt.Sprintf("coded %d", i))))
+	}
+	n.addCoded(t, parent.Cid(), coded)
+	// Blocks we already have are not fetched again.
+	if err := n.local.Put(coded[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := n.ex.GetBlocksC(ctx, parent.Cid(), "nc", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*blocks.CodedBlock
+	for blk := range out {
+		cb, ok := blk.(*blocks.CodedBlock)
+		if !ok {
+			t.Fatalf("expected a coded block, got %T", blk)
+		}
+		if !cb.Parent().Equals(parent.Cid()) {
+			t.Fatalf("expected parent %s, got %s", parent.Cid(), cb.Parent())
+		}
+		if cb.Cid().Equals(coded[0].Cid()) {
+			t.Fatal("fetched a block we already had")
+		}
+		got = append(got, cb)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 coded blocks, got %d", len(got))
+	}
+
+	// The fetched blocks are indexed for the blockservice.
+	list, err := n.localCoded.Coded(parent.Cid(), "nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("expected 3 listed blocks, got %d", len(list))
+	}
+	for _, cb := range got {
+		if has, _ := n.local.Has(cb.Cid()); !has {
+			t.Fatalf("expected %s to be stored", cb.Cid())
+		}
+	}
+
+	// Parents without coded blocks yield nothing.
+	out, err = n.ex.GetBlocksC(ctx, blocks.NewBlock([]byte("other")).Cid(), "nc", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range out {
+		t.Fatal("expected no coded blocks")
+	}
+}
+
+func TestGetBlocksCVerification(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNetwork(t)
+	defer n.Close()
+	ex := New(n.consumer, cidname.Default, n.local, CodedIndex(n.localCoded), CodedVerification(true))
+	defer ex.Close()
+
+	data := make([]byte, 2*100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	enc, err := rlnc.NewEncoder(data, 2, 100, rlnc.Systematic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &coding.Header{
+		Scheme:         "nc",
+		Field:          "gf256",
+		GenerationSize: 2,
+		SymbolSize:     100,
+		Length:         200,
+		Checksums:      enc.Checksums().Marshal(),
+	}
+	parent := blocks.NewBlock(codedleaf.FromPacket(header.Marshal()))
+	if err := n.remote.Put(parent); err != nil {
+		t.Fatal(err)
+	}
+	polluted, err := enc.EncodeWith([]byte{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	polluted.Symbol[0] ^= 0xff
+	good := []blocks.Block{
+		blocks.NewBlock(codedleaf.FromPacket(enc.Encode().Marshal())),
+		blocks.NewBlock(codedleaf.FromPacket(enc.Encode().Marshal())),
+	}
+	bad := blocks.NewBlock(codedleaf.FromPacket(polluted.Marshal()))
+	n.addCoded(t, parent.Cid(), append([]blocks.Block{bad}, good...))
+
+	out, err := ex.GetBlocksC(ctx, parent.Cid(), "nc", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := cid.NewSet()
+	for blk := range out {
+		received.Add(blk.Cid())
+	}
+	if received.Len() != len(good) || !received.Has(good[0].Cid()) || !received.Has(good[1].Cid()) {
+		t.Fatalf("expected the %d valid blocks, got %d blocks", len(good), received.Len())
+	}
+	if has, _ := n.local.Has(bad.Cid()); has {
+		t.Fatal("polluted block added to the blockstore")
+	}
+	if has, _ := n.local.Has(parent.Cid()); !has {
+		t.Fatal("expected the generation parent to be fetched")
+	}
+}
+
+func TestSessionContext(t *testing.T) {
+	n := newTestNetwork(t)
+	defer n.Close()
+
+	blk := blocks.NewBlock([]byte("session"))
+	if err := n.remote.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ses := n.ex.NewSession(ctx)
+	cancel()
+	if _, err := ses.GetBlock(context.Background(), blk.Cid()); err != ErrClosed {
+		t.Fatalf("expected ErrClosed once the session is over, got %v", err)
+	}
+	// Other sessions and the exchange go on.
+	if _, err := n.ex.GetBlock(context.Background(), blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClosedExchange(t *testing.T) {
+	n := newTestNetwork(t)
+	defer n.Close()
+
+	n.ex.Close()
+	blk := blocks.NewBlock([]byte("closed"))
+	if _, err := n.ex.GetBlock(context.Background(), blk.Cid()); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	if _, err := n.ex.GetBlocks(context.Background(), []cid.Cid{blk.Cid()}); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
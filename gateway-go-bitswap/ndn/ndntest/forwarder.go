@@ -0,0 +1,333 @@
+// Package ndntest provides an in-memory stand-in for a local NDN forwarder,
+// for use in tests.
+package ndntest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-bitswap/ndn"
+)
+
+// Handler answers an Interest that reached a prefix served with Serve. It
+// returns the Data to send back, or nil to let the Interest time out.
+type Handler func(i *ndn.Interest) *ndn.Data
+
+// conn is the forwarder side of a face.
+type conn struct {
+	net.Conn
+	id  uint64
+	wlk sync.Mutex
+}
+
+func (c *conn) send(wire []byte) {
+	c.wlk.Lock()
+	defer c.wlk.Unlock()
+	c.Write(wire)
+}
+
+type route struct {
+	prefix  ndn.Name
+	handler Handler
+	nack    ndn.NackReason
+	// face is set for routes registered by a face with rib/register.
+	face *conn
+}
+
+// pitEntry is an Interest forwarded to a face and waiting for Data.
+type pitEntry struct {
+	interest   *ndn.Interest
+	downstream *conn
+}
+
+// Forwarder is an in-memory NDN forwarder. Faces obtained from Dial are
+// connected to it through in-memory pipes. Interests are answered by the
+// longest matching route; Interests that match no route are Nacked with
+// NoRoute, like NFD does. Faces can add routes to themselves with
+// rib/register commands.
+type Forwarder struct {
+	lk        sync.Mutex
+	routes    []route
+	conns     []*conn
+	pit       []pitEntry
+	interests map[string]int
+	lastFace  uint64
+}
+
+// New creates an empty forwarder.
+func New() *Forwarder {
+	return &Forwarder{interests: make(map[string]int)}
+}
+
+// Serve answers Interests under prefix with h.
+func (f *Forwarder) Serve(prefix ndn.Name, h Handler) {
+	f.addRoute(route{prefix: prefix, handler: h})
+}
+
+// ServeContent answers Interests for exactly name with a Data packet
+// carrying content.
+func (f *Forwarder) ServeContent(name ndn.Name, content []byte) {
+	f.Serve(name, func(i *ndn.Interest) *ndn.Data {
+		if !i.Name.Equal(name) {
+			return nil
+		}
+		return &ndn.Data{Name: name, Content: content}
+	})
+}
+
+// ServeSegmented answers Interests for the segments of content published
+// under name.
+func (f *Forwarder) ServeSegmented(name ndn.Name, content []byte) {
+	obj := &ndn.Segmented{Name: name, Content: content}
+	f.Serve(name, func(i *ndn.Interest) *ndn.Data {
+		base, seg, ok := ndn.SplitSegmentName(i.Name)
+		if !ok || !base.Equal(name) {
+			return nil
+		}
+		return obj.Segment(seg)
+	})
+}
+
+// Nack answers Interests under prefix with a Nack carrying reason.
+func (f *Forwarder) Nack(prefix ndn.Name, reason ndn.NackReason) {
+	f.addRoute(route{prefix: prefix, nack: reason})
+}
+
+func (f *Forwarder) addRoute(r route) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for i := range f.routes {
+		if f.routes[i].prefix.Equal(r.prefix) {
+			f.routes[i] = r
+			return
+		}
+	}
+	f.routes = append(f.routes, r)
+}
+
+// HasRoute reports whether a face registered prefix.
+func (f *Forwarder) HasRoute(prefix ndn.Name) bool {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for _, r := range f.routes {
+		if r.face != nil && r.prefix.Equal(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interests returns how many Interests for name the forwarder has received.
+func (f *Forwarder) Interests(name ndn.Name) int {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.interests[name.String()]
+}
+
+// Dial returns a new face connected to the forwarder. It has the signature
+// of an ndn.DialFunc.
+func (f *Forwarder) Dial(ctx context.Context) (*ndn.Face, error) {
+	local, remote := net.Pipe()
+	f.lk.Lock()
+	f.lastFace++
+	c := &conn{Conn: remote, id: f.lastFace}
+	f.conns = append(f.conns, c)
+	f.lk.Unlock()
+	go f.serveConn(c)
+	return ndn.NewFace(local), nil
+}
+
+// Close disconnects every face and drops the routes they registered, like a
+// forwarder restart would.
+func (f *Forwarder) Close() error {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	for _, c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+	f.pit = nil
+	routes := f.routes[:0]
+	for _, r := range f.routes {
+		if r.face == nil {
+			routes = append(routes, r)
+		}
+	}
+	f.routes = routes
+	return nil
+}
+
+func (f *Forwarder) serveConn(c *conn) {
+	defer f.removeFace(c)
+
+	r := bufio.NewReader(c)
+	for {
+		pkt, err := ndn.ReadPacket(r)
+		if err != nil {
+			return
+		}
+		if d, err := ndn.DecodeData(pkt); err == nil {
+			f.satisfy(d)
+			continue
+		}
+		i, err := ndn.DecodeInterest(pkt)
+		if err != nil {
+			continue
+		}
+		if ndn.FacesListPrefix.IsPrefixOf(i.Name) {
+			go f.faces(i, c)
+			continue
+		}
+		if ndn.LocalhostCommandPrefix.IsPrefixOf(i.Name) {
+			go f.command(i, c)
+			continue
+		}
+		go f.forward(i, c)
+	}
+}
+
+// removeFace drops the routes and pending Interests of a closed face.
+func (f *Forwarder) removeFace(c *conn) {
+	c.Close()
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	routes := f.routes[:0]
+	for _, r := range f.routes {
+		if r.face != c {
+			routes = append(routes, r)
+		}
+	}
+	f.routes = routes
+	pit := f.pit[:0]
+	for _, e := range f.pit {
+		if e.downstream != c {
+			pit = append(pit, e)
+		}
+	}
+	f.pit = pit
+}
+
+// command handles the rib/register and rib/unregister management commands.
+func (f *Forwarder) command(i *ndn.Interest, c *conn) {
+	resp := ndn.ControlResponse{StatusCode: 200, StatusText: "OK"}
+	module, verb, p, err := ndn.ParseCommand(i)
+	switch {
+	case err != nil:
+		resp = ndn.ControlResponse{StatusCode: 400, StatusText: err.Error()}
+	case module == "rib" && verb == "register":
+		f.addRoute(route{prefix: p.Name, face: c})
+	case module == "rib" && verb == "unregister":
+		f.lk.Lock()
+		for idx, r := range f.routes {
+			if r.face == c && r.prefix.Equal(p.Name) {
+				f.routes = append(f.routes[:idx], f.routes[idx+1:]...)
+				break
+			}
+		}
+		f.lk.Unlock()
+	default:
+		resp = ndn.ControlResponse{StatusCode: 501, StatusText: "unsupported command"}
+	}
+
+	if resp.StatusCode == 200 {
+		// NFD echoes the parameters in the response body.
+		resp.Body = p.Encode()
+	}
+	c.send((&ndn.Data{Name: i.Name, Content: resp.Encode()}).Encode())
+}
+
+// faces answers Interests for the segments of the faces dataset, which lists
+// the faces currently connected.
+func (f *Forwarder) faces(i *ndn.Interest, c *conn) {
+	version := ndn.VersionComponent(uint64(time.Now().UnixNano() / int64(time.Millisecond)))
+	seg := uint64(0)
+	if n := len(ndn.FacesListPrefix); len(i.Name) > n {
+		if len(i.Name) != n+2 || i.Name[n].Type != ndn.ComponentVersion {
+			return
+		}
+		version = i.Name[n]
+		var ok bool
+		if _, seg, ok = ndn.SplitSegmentName(i.Name); !ok {
+			return
+		}
+	}
+
+	var content []byte
+	f.lk.Lock()
+	for _, fc := range f.conns {
+		s := ndn.FaceStatus{
+			FaceID:      fc.id,
+			URI:         fmt.Sprintf("fd://%d", fc.id),
+			LocalURI:    "unix://" + ndn.DefaultSocketPath,
+			Scope:       ndn.FaceScopeLocal,
+			Persistency: ndn.FacePersistencyOnDemand,
+		}
+		content = append(content, s.Encode()...)
+	}
+	f.lk.Unlock()
+
+	obj := &ndn.Segmented{Name: ndn.FacesListPrefix.Append(version), Content: content, FreshnessPeriod: time.Second}
+	if d := obj.Segment(seg); d != nil {
+		c.send(d.Encode())
+	}
+}
+
+func (f *Forwarder) forward(i *ndn.Interest, downstream *conn) {
+	f.lk.Lock()
+	f.interests[i.Name.String()]++
+	var best *route
+	for idx := range f.routes {
+		r := &f.routes[idx]
+		if r.prefix.IsPrefixOf(i.Name) && (best == nil || len(r.prefix) > len(best.prefix)) {
+			best = r
+		}
+	}
+	var rt route
+	if best != nil {
+		rt = *best
+	}
+	if rt.face != nil {
+		f.pit = append(f.pit, pitEntry{interest: i, downstream: downstream})
+	}
+	f.lk.Unlock()
+
+	switch {
+	case best == nil:
+		downstream.send((&ndn.Nack{Reason: ndn.NackNoRoute, Interest: i}).Encode())
+	case rt.face != nil:
+		rt.face.send(i.Encode())
+	case rt.handler == nil:
+		downstream.send((&ndn.Nack{Reason: rt.nack, Interest: i}).Encode())
+	default:
+		d := rt.handler(i)
+		if d != nil && i.Matches(d) {
+			downstream.send(d.Encode())
+		}
+	}
+}
+
+// satisfy returns Data received from a face to the faces whose Interests it
+// matches.
+func (f *Forwarder) satisfy(d *ndn.Data) {
+	f.lk.Lock()
+	var matched []*conn
+	pit := f.pit[:0]
+	for _, e := range f.pit {
+		if e.interest.Matches(d) {
+			matched = append(matched, e.downstream)
+		} else {
+			pit = append(pit, e)
+		}
+	}
+	f.pit = pit
+	f.lk.Unlock()
+
+	wire := d.Encode()
+	for _, c := range matched {
+		c.send(wire)
+	}
+}
@@ -0,0 +1,411 @@
+package ndn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultInterestLifetime is the lifetime assumed by forwarders when an
+// Interest does not carry one.
+const DefaultInterestLifetime = 4 * time.Second
+
+// Content types carried in the MetaInfo of a Data packet.
+const (
+	ContentTypeBlob = 0
+	ContentTypeLink = 1
+	ContentTypeKey  = 2
+	ContentTypeNack = 3
+)
+
+// Signature types.
+const (
+	SignatureDigestSha256 = 0
+)
+
+// ErrBadSignature is returned when the DigestSha256 signature of a Data
+// packet does not match its signed portion.
+var ErrBadSignature = errors.New("ndn: data signature does not match")
+
+// Interest is an NDN Interest packet.
+type Interest struct {
+	Name        Name
+	CanBePrefix bool
+	MustBeFresh bool
+	Nonce       uint32
+	// Lifetime is omitted from the wire encoding when zero, in which case
+	// DefaultInterestLifetime applies.
+	Lifetime time.Duration
+	// HopLimit is omitted from the wire encoding when zero.
+	HopLimit uint8
+	// AppParameters are carried verbatim; no parameters digest component
+	// is added to the name.
+	AppParameters []byte
+}
+
+// NewNonce returns a random Interest nonce.
+func NewNonce() uint32 {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// Encode returns the wire encoding of the Interest.
+func (i *Interest) Encode() []byte {
+	v := i.Name.appendTo(nil)
+	if i.CanBePrefix {
+		v = appendTLV(v, tlvCanBePrefix, nil)
+	}
+	if i.MustBeFresh {
+		v = appendTLV(v, tlvMustBeFresh, nil)
+	}
+	var nonce [4]byte
+	binary.BigEndian.PutUint32(nonce[:], i.Nonce)
+	v = appendTLV(v, tlvNonce, nonce[:])
+	if i.Lifetime > 0 {
+		v = appendNonNegInt(v, tlvInterestLifetime, uint64(i.Lifetime/time.Millisecond))
+	}
+	if i.HopLimit > 0 {
+		v = appendTLV(v, tlvHopLimit, []byte{i.HopLimit})
+	}
+	if i.AppParameters != nil {
+		v = appendTLV(v, tlvApplicationParameter, i.AppParameters)
+	}
+	return appendTLV(nil, tlvInterest, v)
+}
+
+// lifetime returns the effective lifetime of the Interest.
+func (i *Interest) lifetime() time.Duration {
+	if i.Lifetime > 0 {
+		return i.Lifetime
+	}
+	return DefaultInterestLifetime
+}
+
+// Matches reports whether d satisfies the Interest. An Interest whose name
+// ends with an implicit digest only matches the Data with that digest.
+func (i *Interest) Matches(d *Data) bool {
+	if l := len(i.Name); l > 0 && i.Name[l-1].Type == ComponentImplicitSha256Digest {
+		return i.Name.Equal(d.FullName())
+	}
+	if i.CanBePrefix {
+		return i.Name.IsPrefixOf(d.Name)
+	}
+	return i.Name.Equal(d.Name)
+}
+
+// DecodeInterest decodes the wire encoding of an Interest packet.
+func DecodeInterest(wire []byte) (*Interest, error) {
+	e, n, err := readElement(wire)
+	if err != nil {
+		return nil, err
+	}
+	if e.typ != tlvInterest || n != len(wire) {
+		return nil, fmt.Errorf("ndn: not an Interest packet")
+	}
+	els, err := readElements(e.value)
+	if err != nil {
+		return nil, err
+	}
+	if len(els) == 0 || els[0].typ != tlvName {
+		return nil, fmt.Errorf("ndn: Interest does not start with a Name")
+	}
+
+	i := new(Interest)
+	if i.Name, err = decodeName(els[0].value); err != nil {
+		return nil, err
+	}
+	for _, el := range els[1:] {
+		switch el.typ {
+		case tlvCanBePrefix:
+			i.CanBePrefix = true
+		case tlvMustBeFresh:
+			i.MustBeFresh = true
+		case tlvForwardingHint:
+		case tlvNonce:
+			if len(el.value) != 4 {
+				return nil, fmt.Errorf("ndn: invalid Nonce length %d", len(el.value))
+			}
+			i.Nonce = binary.BigEndian.Uint32(el.value)
+		case tlvInterestLifetime:
+			ms, err := decodeNonNegInt(el.value)
+			if err != nil {
+				return nil, err
+			}
+			i.Lifetime = time.Duration(ms) * time.Millisecond
+		case tlvHopLimit:
+			if len(el.value) != 1 {
+				return nil, fmt.Errorf("ndn: invalid HopLimit length %d", len(el.value))
+			}
+			i.HopLimit = el.value[0]
+		case tlvApplicationParameter:
+			i.AppParameters = el.value
+		default:
+			if isCritical(el.typ) {
+				return nil, fmt.Errorf("ndn: unrecognised critical element %d in Interest", el.typ)
+			}
+		}
+	}
+	return i, nil
+}
+
+// Data is an NDN Data packet. Packets produced by this package are signed
+// with DigestSha256.
+type Data struct {
+	Name            Name
+	ContentType     uint64
+	FreshnessPeriod time.Duration
+	// FinalBlockID is the last segment component of a segmented object,
+	// or nil.
+	FinalBlockID *Component
+	Content      []byte
+
+	SignatureType  uint64
+	SignatureValue []byte
+
+	// signed is the portion of the wire encoding covered by the
+	// signature, set when the packet was decoded.
+	signed []byte
+	wire   []byte
+}
+
+// Encode returns the wire encoding of the Data packet, signing it with
+// DigestSha256.
+func (d *Data) Encode() []byte {
+	v := d.Name.appendTo(nil)
+
+	var meta []byte
+	if d.ContentType != ContentTypeBlob {
+		meta = appendNonNegInt(meta, tlvContentType, d.ContentType)
+	}
+	if d.FreshnessPeriod > 0 {
+		meta = appendNonNegInt(meta, tlvFreshnessPeriod, uint64(d.FreshnessPeriod/time.Millisecond))
+	}
+	if d.FinalBlockID != nil {
+		meta = appendTLV(meta, tlvFinalBlockID, d.FinalBlockID.appendTo(nil))
+	}
+	if meta != nil {
+		v = appendTLV(v, tlvMetaInfo, meta)
+	}
+	v = appendTLV(v, tlvContent, d.Content)
+	v = appendTLV(v, tlvSignatureInfo, appendNonNegInt(nil, tlvSignatureType, SignatureDigestSha256))
+
+	sum := sha256.Sum256(v)
+	d.SignatureType = SignatureDigestSha256
+	d.SignatureValue = sum[:]
+	d.signed = v
+	v = appendTLV(v, tlvSignatureValue, d.SignatureValue)
+
+	d.wire = appendTLV(nil, tlvData, v)
+	return d.wire
+}
+
+// FullName returns the name of the Data packet followed by the implicit
+// SHA-256 digest of its wire encoding.
+func (d *Data) FullName() Name {
+	wire := d.wire
+	if wire == nil {
+		wire = d.Encode()
+	}
+	sum := sha256.Sum256(wire)
+	return d.Name.Append(ImplicitDigestComponent(sum[:]))
+}
+
+// Verify checks a DigestSha256 signature. Packets signed with any other
+// signature type are accepted unchecked.
+func (d *Data) Verify() error {
+	if d.SignatureType != SignatureDigestSha256 {
+		return nil
+	}
+	if d.signed == nil {
+		d.Encode()
+		return nil
+	}
+	sum := sha256.Sum256(d.signed)
+	if !bytes.Equal(sum[:], d.SignatureValue) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// DecodeData decodes the wire encoding of a Data packet.
+func DecodeData(wire []byte) (*Data, error) {
+	e, n, err := readElement(wire)
+	if err != nil {
+		return nil, err
+	}
+	if e.typ != tlvData || n != len(wire) {
+		return nil, fmt.Errorf("ndn: not a Data packet")
+	}
+	els, err := readElements(e.value)
+	if err != nil {
+		return nil, err
+	}
+	if len(els) == 0 || els[0].typ != tlvName {
+		return nil, fmt.Errorf("ndn: Data does not start with a Name")
+	}
+
+	d := &Data{wire: wire}
+	if d.Name, err = decodeName(els[0].value); err != nil {
+		return nil, err
+	}
+	sigInfo := false
+	for _, el := range els[1:] {
+		switch el.typ {
+		case tlvMetaInfo:
+			if err := d.decodeMetaInfo(el.value); err != nil {
+				return nil, err
+			}
+		case tlvContent:
+			d.Content = el.value
+		case tlvSignatureInfo:
+			sig, err := readElements(el.value)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range sig {
+				if s.typ == tlvSignatureType {
+					if d.SignatureType, err = decodeNonNegInt(s.value); err != nil {
+						return nil, err
+					}
+					sigInfo = true
+				}
+			}
+		case tlvSignatureValue:
+			d.SignatureValue = el.value
+			// The signed portion runs from the Name up to, but not
+			// including, the SignatureValue.
+			start := len(e.wire) - len(e.value)
+			end := len(e.wire) - len(el.wire)
+			d.signed = wire[start:end]
+		default:
+			if isCritical(el.typ) {
+				return nil, fmt.Errorf("ndn: unrecognised critical element %d in Data", el.typ)
+			}
+		}
+	}
+	if !sigInfo || d.SignatureValue == nil {
+		return nil, fmt.Errorf("ndn: Data %s is not signed", d.Name)
+	}
+	return d, nil
+}
+
+func (d *Data) decodeMetaInfo(value []byte) error {
+	els, err := readElements(value)
+	if err != nil {
+		return err
+	}
+	for _, el := range els {
+		switch el.typ {
+		case tlvContentType:
+			if d.ContentType, err = decodeNonNegInt(el.value); err != nil {
+				return err
+			}
+		case tlvFreshnessPeriod:
+			ms, err := decodeNonNegInt(el.value)
+			if err != nil {
+				return err
+			}
+			d.FreshnessPeriod = time.Duration(ms) * time.Millisecond
+		case tlvFinalBlockID:
+			c, _, err := readElement(el.value)
+			if err != nil {
+				return err
+			}
+			d.FinalBlockID = &Component{Type: c.typ, Value: c.value}
+		default:
+			if isCritical(el.typ) {
+				return fmt.Errorf("ndn: unrecognised critical element %d in MetaInfo", el.typ)
+			}
+		}
+	}
+	return nil
+}
+
+// NackReason is the reason code carried in an NDNLPv2 Nack.
+type NackReason uint64
+
+// Nack reasons defined by NDNLPv2.
+const (
+	NackNone       NackReason = 0
+	NackCongestion NackReason = 50
+	NackDuplicate  NackReason = 100
+	NackNoRoute    NackReason = 150
+)
+
+func (r NackReason) String() string {
+	switch r {
+	case NackNone:
+		return "None"
+	case NackCongestion:
+		return "Congestion"
+	case NackDuplicate:
+		return "Duplicate"
+	case NackNoRoute:
+		return "NoRoute"
+	default:
+		return fmt.Sprintf("NackReason(%d)", uint64(r))
+	}
+}
+
+// Nack is a network Nack for an Interest.
+type Nack struct {
+	Reason   NackReason
+	Interest *Interest
+}
+
+// Encode returns the LpPacket that carries the Nack.
+func (n *Nack) Encode() []byte {
+	var reason []byte
+	if n.Reason != NackNone {
+		reason = appendNonNegInt(nil, tlvNackReason, uint64(n.Reason))
+	}
+	v := appendTLV(nil, tlvNack, reason)
+	v = appendTLV(v, tlvLpFragment, n.Interest.Encode())
+	return appendTLV(nil, tlvLpPacket, v)
+}
+
+// lpPacket is a decoded NDNLPv2 packet.
+type lpPacket struct {
+	fragment []byte
+	nack     *NackReason
+}
+
+func decodeLpPacket(value []byte) (*lpPacket, error) {
+	els, err := readElements(value)
+	if err != nil {
+		return nil, err
+	}
+	lp := new(lpPacket)
+	for _, el := range els {
+		switch el.typ {
+		case tlvLpFragment:
+			lp.fragment = el.value
+		case tlvNack:
+			reason := NackNone
+			fields, err := readElements(el.value)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range fields {
+				if f.typ == tlvNackReason {
+					r, err := decodeNonNegInt(f.value)
+					if err != nil {
+						return nil, err
+					}
+					reason = NackReason(r)
+				}
+			}
+			lp.nack = &reason
+		}
+		// Other header fields (sequence numbers, PIT tokens, congestion
+		// marks...) are not needed on a local face and are ignored.
+	}
+	return lp, nil
+}
@@ -0,0 +1,199 @@
+package ndn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+	"time"
+)
+
+func TestNameURIRoundTrip(t *testing.T) {
+	uris := []string{
+		"/",
+		"/ipfs",
+		"/ipfs/QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG",
+		"/a%20b/...",
+		"/a/..../seg=12",
+		"/a/v=3/sha256digest=0000000000000000000000000000000000000000000000000000000000000000",
+		"/a/300=x%2Fy",
+	}
+	for _, uri := range uris {
+		n, err := ParseName(uri)
+		if err != nil {
+			t.Fatalf("parsing %q: %s", uri, err)
+		}
+		if n.String() != uri {
+			t.Fatalf("expected %q, got %q", uri, n.String())
+		}
+		back, err := DecodeName(n.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !back.Equal(n) {
+			t.Fatalf("wire round trip of %q changed the name to %q", uri, back)
+		}
+	}
+
+	if n := MustParseName("/a/..."); len(n[1].Value) != 0 {
+		t.Fatal("expected '...' to be the empty component")
+	}
+	if _, err := ParseName("/a/.."); err == nil {
+		t.Fatal("expected '..' to be rejected")
+	}
+}
+
+func TestSegmentComponent(t *testing.T) {
+	for _, seg := range []uint64{0, 1, 255, 256, 70000, 1 << 40} {
+		c := SegmentComponent(seg)
+		got, err := c.Segment()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != seg {
+			t.Fatalf("expected segment %d, got %d", seg, got)
+		}
+	}
+	if _, err := GenericComponent([]byte{1}).Segment(); err == nil {
+		t.Fatal("expected generic component not to be a segment")
+	}
+}
+
+func TestInterestEncoding(t *testing.T) {
+	i := &Interest{
+		Name:          MustParseName("/ipfs/abc"),
+		CanBePrefix:   true,
+		MustBeFresh:   true,
+		Nonce:         0xdeadbeef,
+		Lifetime:      1500 * time.Millisecond,
+		HopLimit:      16,
+		AppParameters: []byte("params"),
+	}
+	got, err := DecodeInterest(i.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Name.Equal(i.Name) || !got.CanBePrefix || !got.MustBeFresh ||
+		got.Nonce != i.Nonce || got.Lifetime != i.Lifetime || got.HopLimit != i.HopLimit ||
+		!bytes.Equal(got.AppParameters, i.AppParameters) {
+		t.Fatalf("interest changed in round trip: %+v", got)
+	}
+
+	// Unknown non-critical elements are ignored, critical ones are not.
+	wire := i.Encode()
+	withExtra := appendTLV(nil, tlvInterest, append(wire[2:], appendTLV(nil, 0xfe, []byte{1})...))
+	if _, err := DecodeInterest(withExtra); err != nil {
+		t.Fatalf("non-critical element was not ignored: %s", err)
+	}
+	withCritical := appendTLV(nil, tlvInterest, append(wire[2:], appendTLV(nil, 0x11, []byte{1})...))
+	if _, err := DecodeInterest(withCritical); err == nil {
+		t.Fatal("expected critical element to be rejected")
+	}
+}
+
+func TestDataEncoding(t *testing.T) {
+	final := SegmentComponent(7)
+	d := &Data{
+		Name:            MustParseName("/ipfs/abc/seg=3"),
+		FreshnessPeriod: time.Second,
+		FinalBlockID:    &final,
+		Content:         []byte("hello"),
+	}
+	wire := d.Encode()
+	got, err := DecodeData(wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Name.Equal(d.Name) || got.FreshnessPeriod != time.Second ||
+		got.FinalBlockID == nil || !got.FinalBlockID.Equal(final) ||
+		!bytes.Equal(got.Content, d.Content) {
+		t.Fatalf("data changed in round trip: %+v", got)
+	}
+	if err := got.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a content byte, the digest must no longer match.
+	corrupt := append([]byte(nil), wire...)
+	idx := bytes.Index(corrupt, []byte("hello"))
+	corrupt[idx] = 'j'
+	bad, err := DecodeData(corrupt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad.Verify() != ErrBadSignature {
+		t.Fatal("expected signature mismatch")
+	}
+}
+
+func TestNackEncoding(t *testing.T) {
+	i := &Interest{Name: MustParseName("/ipfs/abc"), Nonce: 42}
+	n := &Nack{Reason: NackNoRoute, Interest: i}
+	e, _, err := readElement(n.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.typ != tlvLpPacket {
+		t.Fatalf("expected an LpPacket, got type %d", e.typ)
+	}
+	lp, err := decodeLpPacket(e.value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lp.nack == nil || *lp.nack != NackNoRoute {
+		t.Fatal("expected NoRoute reason")
+	}
+	got, err := DecodeInterest(lp.fragment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Nonce != 42 || !got.Name.Equal(i.Name) {
+		t.Fatal("nacked interest changed")
+	}
+}
+
+func TestCommandInterest(t *testing.T) {
+	params := &ControlParameters{
+		Name:   MustParseName("/ipfs"),
+		Cost:   10,
+		Flags:  RouteChildInherit | RouteCapture,
+		FaceID: 300,
+	}
+	i := CommandInterest("rib", "register", params)
+	got, err := DecodeInterest(i.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	module, verb, p, err := ParseCommand(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if module != "rib" || verb != "register" {
+		t.Fatalf("unexpected command %s/%s", module, verb)
+	}
+	if !p.Name.Equal(params.Name) || p.Cost != 10 || p.Flags != 3 || p.FaceID != 300 {
+		t.Fatalf("parameters changed in round trip: %+v", p)
+	}
+
+	// The last component is a DigestSha256 over the preceding ones.
+	var signed []byte
+	for _, c := range got.Name[:len(got.Name)-1] {
+		signed = c.appendTo(signed)
+	}
+	sum := sha256.Sum256(signed)
+	sig, _, err := readElement(got.Name[len(got.Name)-1].Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.typ != tlvSignatureValue || !bytes.Equal(sig.value, sum[:]) {
+		t.Fatal("command is not signed")
+	}
+
+	resp := &ControlResponse{StatusCode: 200, StatusText: "OK", Body: params.Encode()}
+	back, err := DecodeControlResponse(resp.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.StatusCode != 200 || back.StatusText != "OK" || !bytes.Equal(back.Body, resp.Body) {
+		t.Fatalf("response changed in round trip: %+v", back)
+	}
+}
@@ -0,0 +1,312 @@
+package ndn
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// CongestionAlgorithm selects how the consumer's congestion window grows.
+type CongestionAlgorithm int
+
+const (
+	// AIMD grows the window by one Interest per round trip and halves it
+	// on loss.
+	AIMD CongestionAlgorithm = iota
+	// Cubic grows the window along the TCP CUBIC curve (RFC 8312), which
+	// recovers faster on paths with a large bandwidth-delay product.
+	Cubic
+)
+
+func (a CongestionAlgorithm) String() string {
+	switch a {
+	case AIMD:
+		return "aimd"
+	case Cubic:
+		return "cubic"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// DefaultInitialWindow is the congestion window a consumer starts with.
+	DefaultInitialWindow = 2
+
+	minWindow = 1
+
+	aimdBeta  = 0.5
+	cubicBeta = 0.7
+	cubicC    = 0.4
+
+	// RTO bounds and initial value, see RFC 6298.
+	initialRTO = time.Second
+	minRTO     = 200 * time.Millisecond
+	maxRTO     = 60 * time.Second
+)
+
+// window is a congestion window. It is guarded by the pipeline's lock.
+type window struct {
+	alg      CongestionAlgorithm
+	cwnd     float64
+	ssthresh float64
+
+	// wmax is the window before the last decrease, used by CUBIC.
+	wmax         float64
+	lastDecrease time.Time
+}
+
+func newWindow(alg CongestionAlgorithm, initial float64) window {
+	return window{
+		alg:      alg,
+		cwnd:     initial,
+		ssthresh: math.Inf(1),
+	}
+}
+
+// increase grows the window after a Data arrived.
+func (w *window) increase(now time.Time, srtt time.Duration) {
+	if w.cwnd < w.ssthresh {
+		// Slow start.
+		w.cwnd++
+		return
+	}
+	// Congestion avoidance. CUBIC never grows slower than AIMD, which is
+	// its TCP-friendly region.
+	inc := 1 / w.cwnd
+	if w.alg == Cubic && !w.lastDecrease.IsZero() {
+		t := now.Sub(w.lastDecrease).Seconds() + srtt.Seconds()
+		k := math.Cbrt(w.wmax * (1 - cubicBeta) / cubicC)
+		target := cubicC*math.Pow(t-k, 3) + w.wmax
+		if target > w.cwnd {
+			// Never grow faster than doubling per round trip.
+			inc = math.Max(inc, math.Min(target-w.cwnd, w.cwnd)/w.cwnd)
+		}
+	}
+	w.cwnd += inc
+}
+
+// decrease shrinks the window after a loss. Losses within one round trip of
+// the previous decrease belong to the same congestion event and are ignored.
+func (w *window) decrease(now time.Time, srtt time.Duration) bool {
+	if !w.lastDecrease.IsZero() && now.Sub(w.lastDecrease) < srtt {
+		return false
+	}
+	beta := aimdBeta
+	if w.alg == Cubic {
+		beta = cubicBeta
+	}
+	w.wmax = w.cwnd
+	w.ssthresh = math.Max(w.cwnd*beta, minWindow)
+	w.cwnd = w.ssthresh
+	w.lastDecrease = now
+	return true
+}
+
+// rttEstimator computes the retransmission timeout as in RFC 6298.
+type rttEstimator struct {
+	srtt   time.Duration
+	rttvar time.Duration
+	rto    time.Duration
+	min    time.Duration
+}
+
+func newRTTEstimator() rttEstimator {
+	return rttEstimator{rto: initialRTO}
+}
+
+func (r *rttEstimator) addSample(rtt time.Duration) {
+	if r.srtt == 0 {
+		r.srtt = rtt
+		r.rttvar = rtt / 2
+	} else {
+		diff := r.srtt - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		r.rttvar = (3*r.rttvar + diff) / 4
+		r.srtt = (7*r.srtt + rtt) / 8
+	}
+	if r.min == 0 || rtt < r.min {
+		r.min = rtt
+	}
+	r.rto = r.srtt + 4*r.rttvar
+	r.clamp()
+}
+
+// backoff doubles the RTO after a timeout.
+func (r *rttEstimator) backoff() {
+	r.rto *= 2
+	r.clamp()
+}
+
+func (r *rttEstimator) clamp() {
+	if r.rto < minRTO {
+		r.rto = minRTO
+	}
+	if r.rto > maxRTO {
+		r.rto = maxRTO
+	}
+}
+
+// ConsumerStats are counters and estimates of a consumer's pipeline.
+type ConsumerStats struct {
+	InterestsSent   uint64
+	Retransmissions uint64
+	Timeouts        uint64
+	Nacks           uint64
+	DataReceived    uint64
+	BytesReceived   uint64
+
+	// InFlight is the number of Interests waiting for Data.
+	InFlight int
+	// Window and SlowStartThreshold are in Interests.
+	Window             float64
+	SlowStartThreshold float64
+
+	SRTT   time.Duration
+	RTTVar time.Duration
+	MinRTT time.Duration
+	RTO    time.Duration
+
+	// Elapsed is the time since the first Interest was sent.
+	Elapsed time.Duration
+}
+
+// Throughput returns the average goodput in bytes per second.
+func (s ConsumerStats) Throughput() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.BytesReceived) / s.Elapsed.Seconds()
+}
+
+// pipeline limits the Interests a consumer has in flight to its congestion
+// window, and keeps the RTT estimates and statistics.
+type pipeline struct {
+	lk       sync.Mutex
+	inflight int
+	win      window
+	rtt      rttEstimator
+	stats    ConsumerStats
+	start    time.Time
+
+	// wake is closed, and replaced, whenever a slot may have opened up.
+	wake chan struct{}
+}
+
+func newPipeline(alg CongestionAlgorithm, initialWindow int) *pipeline {
+	return &pipeline{
+		win:  newWindow(alg, float64(initialWindow)),
+		rtt:  newRTTEstimator(),
+		wake: make(chan struct{}),
+	}
+}
+
+// acquire waits until the window has room for another Interest.
+func (p *pipeline) acquire(ctx context.Context) error {
+	for {
+		p.lk.Lock()
+		if float64(p.inflight) < math.Floor(p.win.cwnd) || p.inflight == 0 {
+			p.inflight++
+			p.lk.Unlock()
+			return nil
+		}
+		wake := p.wake
+		p.lk.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release frees the slot taken by acquire.
+func (p *pipeline) release() {
+	p.lk.Lock()
+	p.inflight--
+	p.signal()
+	p.lk.Unlock()
+}
+
+// signal wakes up the goroutines waiting in acquire. The lock must be held.
+func (p *pipeline) signal() {
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+// timeout returns how long to wait for the Data of an Interest before
+// retransmitting it.
+func (p *pipeline) timeout(lifetime time.Duration) time.Duration {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.rtt.rto < lifetime {
+		return p.rtt.rto
+	}
+	return lifetime
+}
+
+func (p *pipeline) sent(retransmission bool) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	p.stats.InterestsSent++
+	if retransmission {
+		p.stats.Retransmissions++
+	}
+}
+
+// received records a Data. The RTT is only sampled for Interests that were
+// not retransmitted (Karn's algorithm).
+func (p *pipeline) received(rtt time.Duration, sample bool, size int) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.stats.DataReceived++
+	p.stats.BytesReceived += uint64(size)
+	if sample {
+		p.rtt.addSample(rtt)
+	}
+	p.win.increase(time.Now(), p.rtt.srtt)
+	p.signal()
+}
+
+// timedOut records an Interest that got no answer within its timeout.
+func (p *pipeline) timedOut() {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.stats.Timeouts++
+	p.rtt.backoff()
+	p.win.decrease(time.Now(), p.rtt.srtt)
+}
+
+// nacked records a Nack. Congestion Nacks shrink the window like a loss.
+func (p *pipeline) nacked(reason NackReason) {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	p.stats.Nacks++
+	if reason == NackCongestion {
+		p.win.decrease(time.Now(), p.rtt.srtt)
+	}
+}
+
+func (p *pipeline) snapshot() ConsumerStats {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	s := p.stats
+	s.InFlight = p.inflight
+	s.Window = p.win.cwnd
+	s.SlowStartThreshold = p.win.ssthresh
+	s.SRTT = p.rtt.srtt
+	s.RTTVar = p.rtt.rttvar
+	s.MinRTT = p.rtt.min
+	s.RTO = p.rtt.rto
+	if !p.start.IsZero() {
+		s.Elapsed = time.Since(p.start)
+	}
+	return s
+}
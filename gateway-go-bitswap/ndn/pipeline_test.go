@@ -0,0 +1,145 @@
+package ndn
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRTTEstimator(t *testing.T) {
+	r := newRTTEstimator()
+	if r.rto != time.Second {
+		t.Fatalf("expected an initial RTO of 1s, got %s", r.rto)
+	}
+
+	// First sample: SRTT = R, RTTVAR = R/2, RTO = SRTT + 4*RTTVAR.
+	r.addSample(100 * time.Millisecond)
+	if r.srtt != 100*time.Millisecond || r.rttvar != 50*time.Millisecond || r.rto != 300*time.Millisecond {
+		t.Fatalf("unexpected estimates after the first sample: %+v", r)
+	}
+
+	// RTTVAR = 3/4*50 + 1/4*|100-200| = 62.5, SRTT = 7/8*100 + 1/8*200 = 112.5.
+	r.addSample(200 * time.Millisecond)
+	if r.srtt != 112500*time.Microsecond || r.rttvar != 62500*time.Microsecond {
+		t.Fatalf("unexpected estimates after the second sample: %+v", r)
+	}
+	if r.min != 100*time.Millisecond {
+		t.Fatalf("expected a min RTT of 100ms, got %s", r.min)
+	}
+
+	// Small RTTs are bounded by the minimum RTO, backoff by the maximum.
+	for i := 0; i < 50; i++ {
+		r.addSample(time.Millisecond)
+	}
+	if r.rto != minRTO {
+		t.Fatalf("expected RTO to be clamped to %s, got %s", minRTO, r.rto)
+	}
+	for i := 0; i < 20; i++ {
+		r.backoff()
+	}
+	if r.rto != maxRTO {
+		t.Fatalf("expected RTO to be clamped to %s, got %s", maxRTO, r.rto)
+	}
+}
+
+func TestAIMDWindow(t *testing.T) {
+	now := time.Now()
+	srtt := 10 * time.Millisecond
+	w := newWindow(AIMD, 2)
+
+	// Slow start adds one Interest per Data.
+	for i := 0; i < 6; i++ {
+		w.increase(now, srtt)
+	}
+	if w.cwnd != 8 {
+		t.Fatalf("expected a window of 8 after slow start, got %f", w.cwnd)
+	}
+
+	if !w.decrease(now, srtt) || w.cwnd != 4 || w.ssthresh != 4 {
+		t.Fatalf("expected the window to halve, got %f/%f", w.cwnd, w.ssthresh)
+	}
+	// Losses within the same round trip are one congestion event.
+	if w.decrease(now.Add(srtt/2), srtt) || w.cwnd != 4 {
+		t.Fatal("expected a single decrease per round trip")
+	}
+
+	// Congestion avoidance adds about one Interest per window of Data.
+	for i := 0; i < 4; i++ {
+		w.increase(now, srtt)
+	}
+	if w.cwnd < 4.8 || w.cwnd > 5 {
+		t.Fatalf("expected additive increase, got %f", w.cwnd)
+	}
+
+	for i := 0; i < 10; i++ {
+		w.decrease(now.Add(time.Duration(i+1)*time.Second), srtt)
+	}
+	if w.cwnd != minWindow {
+		t.Fatalf("expected the window not to drop below %d, got %f", minWindow, w.cwnd)
+	}
+}
+
+func TestCubicRecoversFaster(t *testing.T) {
+	now := time.Now()
+	// A long path, where AIMD takes long to fill the pipe again.
+	srtt := 500 * time.Millisecond
+	aimd := newWindow(AIMD, 100)
+	cubic := newWindow(Cubic, 100)
+	aimd.decrease(now, srtt)
+	cubic.decrease(now, srtt)
+	if cubic.cwnd != 70 || aimd.cwnd != 50 {
+		t.Fatalf("unexpected windows after a loss: aimd %f, cubic %f", aimd.cwnd, cubic.cwnd)
+	}
+
+	// Ten seconds of Data at one window per round trip.
+	for rtt := 1; rtt <= 20; rtt++ {
+		at := now.Add(time.Duration(rtt) * srtt)
+		for i, n := 0, int(aimd.cwnd); i < n; i++ {
+			aimd.increase(at, srtt)
+		}
+		for i, n := 0, int(cubic.cwnd); i < n; i++ {
+			cubic.increase(at, srtt)
+		}
+	}
+	if cubic.cwnd < 100 {
+		t.Fatalf("expected CUBIC to get back to its previous window, got %f", cubic.cwnd)
+	}
+	if aimd.cwnd >= 100 {
+		t.Fatalf("expected AIMD to still be recovering, got %f", aimd.cwnd)
+	}
+}
+
+func TestPipelineLimitsInFlight(t *testing.T) {
+	p := newPipeline(AIMD, 2)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := p.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := p.acquire(tctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the window to be full, got %v", err)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- p.acquire(ctx)
+	}()
+	p.received(10*time.Millisecond, true, 100)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a growing window to let another Interest through")
+	}
+
+	s := p.snapshot()
+	if s.InFlight != 3 || s.Window != 3 || s.DataReceived != 1 || s.BytesReceived != 100 || s.SRTT != 10*time.Millisecond {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
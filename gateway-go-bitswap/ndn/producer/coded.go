@@ -0,0 +1,13 @@
+package producer
+
+import (
+	cid "github.com/ipfs/go-cid"
+)
+
+// CodedIndex lists the coded blocks held for a parent block. A coding.Index
+// is one.
+type CodedIndex interface {
+	// Coded returns the CIDs of the blocks coded from parent with coding.
+	// It returns no CIDs, and no error, when there are none.
+	Coded(parent cid.Cid, coding string) ([]cid.Cid, error)
+}
@@ -0,0 +1,298 @@
+// Package producer serves the blocks of a blockstore to the NDN network.
+//
+// The producer registers the prefix of a cidname.Mapper on the local
+// forwarder and answers Interests for block names with Data packets carrying
+// the raw block. Blocks are split into segments named <block name>/seg=<n>,
+// blocks that fit in a single packet can also be fetched by their plain
+// name. Interests for blocks that are not in the blockstore are left
+// unanswered so that the forwarder can try other routes.
+//
+// With a CodedIndex the producer also answers for the list of coded blocks
+// of a parent, named by cidname.Mapper.CodedName. The list holds one CID per
+// line, and the coded blocks themselves are served like any other block.
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("ndn/producer")
+
+const (
+	// DefaultFreshnessPeriod is the freshness period of the Data packets
+	// produced. Blocks never change, so it only bounds how long caches keep
+	// answering for blocks we may have deleted.
+	DefaultFreshnessPeriod = time.Hour
+
+	// CodedFreshnessPeriod is the freshness period of coded block lists,
+	// which grow as coded blocks are received.
+	CodedFreshnessPeriod = 10 * time.Second
+
+	// registerTimeout bounds each rib/register attempt.
+	registerTimeout = 4 * time.Second
+
+	// maxRedialDelay caps the backoff between reconnection attempts.
+	maxRedialDelay = time.Minute
+)
+
+// ErrClosed is returned when starting a closed producer.
+var ErrClosed = errors.New("ndn producer closed")
+
+// Producer answers Interests for block names from a blockstore.
+type Producer struct {
+	bs    bstore.Blockstore
+	names *cidname.Mapper
+	dial  ndn.DialFunc
+
+	freshness time.Duration
+	coded     CodedIndex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	lk      sync.Mutex
+	started bool
+	face    *ndn.Face
+}
+
+// Option configures a Producer.
+type Option func(*Producer)
+
+// FreshnessPeriod sets the freshness period of produced Data packets.
+func FreshnessPeriod(d time.Duration) Option {
+	return func(p *Producer) {
+		p.freshness = d
+	}
+}
+
+// Coded serves the lists of coded blocks held in idx.
+func Coded(idx CodedIndex) Option {
+	return func(p *Producer) {
+		p.coded = idx
+	}
+}
+
+// New creates a producer serving bs under the prefix of names. Faces to the
+// forwarder are obtained from dial.
+func New(bs bstore.Blockstore, names *cidname.Mapper, dial ndn.DialFunc, opts ...Option) *Producer {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Producer{
+		bs:        bs,
+		names:     names,
+		dial:      dial,
+		freshness: DefaultFreshnessPeriod,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(p)
+	}
+	return p
+}
+
+// Start connects to the forwarder and registers the prefix. If the
+// forwarder is not reachable Start returns the error but keeps retrying in
+// the background, so that the producer comes up once the forwarder does.
+// The producer also reconnects and registers again whenever its face closes.
+// Start must be called at most once.
+func (p *Producer) Start() error {
+	select {
+	case <-p.ctx.Done():
+		return ErrClosed
+	default:
+	}
+	p.lk.Lock()
+	p.started = true
+	p.lk.Unlock()
+
+	face, err := p.connect()
+	go p.run(face)
+	return err
+}
+
+// Close unregisters the prefix and disconnects from the forwarder.
+func (p *Producer) Close() error {
+	p.cancel()
+	p.lk.Lock()
+	started := p.started
+	p.lk.Unlock()
+	if started {
+		<-p.done
+	}
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.face == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), registerTimeout)
+	defer cancel()
+	if err := p.face.Unregister(ctx, p.names.Prefix()); err != nil {
+		log.Debugf("unregistering %s: %s", p.names.Prefix(), err)
+	}
+	err := p.face.Close()
+	p.face = nil
+	return err
+}
+
+// Registered reports whether the producer is connected to the forwarder
+// and has registered its prefix.
+func (p *Producer) Registered() bool {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.face == nil {
+		return false
+	}
+	select {
+	case <-p.face.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// connect dials the forwarder, installs the Interest handler and registers
+// the prefix.
+func (p *Producer) connect() (*ndn.Face, error) {
+	ctx, cancel := context.WithTimeout(p.ctx, registerTimeout)
+	defer cancel()
+
+	face, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefix := p.names.Prefix()
+	face.SetInterestHandler(prefix, p.handle)
+	if err := face.Register(ctx, prefix); err != nil {
+		face.Close()
+		return nil, err
+	}
+	log.Infof("serving blocks to NDN under %s", prefix)
+
+	p.lk.Lock()
+	p.face = face
+	p.lk.Unlock()
+	return face, nil
+}
+
+// run keeps the producer connected until it is closed.
+func (p *Producer) run(face *ndn.Face) {
+	defer close(p.done)
+
+	delay := time.Second
+	for {
+		if face != nil {
+			delay = time.Second
+			select {
+			case <-face.Done():
+				log.Warnf("lost connection to the NDN forwarder, reconnecting")
+			case <-p.ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-p.ctx.Done():
+			return
+		}
+
+		var err error
+		if face, err = p.connect(); err != nil {
+			log.Debugf("connecting to the NDN forwarder: %s", err)
+			if delay *= 2; delay > maxRedialDelay {
+				delay = maxRedialDelay
+			}
+		}
+	}
+}
+
+// handle answers an Interest for a block name or a coded block list.
+func (p *Producer) handle(i *ndn.Interest) *ndn.Data {
+	c, err := p.names.CID(i.Name)
+	if err != nil {
+		log.Debugf("ignoring Interest %s: %s", i.Name, err)
+		return nil
+	}
+
+	var content []byte
+	freshness := p.freshness
+	if coding, ok := p.names.Coding(i.Name); ok {
+		if content = p.codedList(c, coding); content == nil {
+			return nil
+		}
+		freshness = CodedFreshnessPeriod
+	} else {
+		blk, err := p.bs.Get(c)
+		if err != nil {
+			if err != bstore.ErrNotFound {
+				log.Errorf("reading block %s: %s", c, err)
+			}
+			return nil
+		}
+		content = blk.RawData()
+	}
+
+	// Answer under the name that was asked for, which may use another
+	// base than our canonical names, without its implicit digest.
+	name := i.Name
+	if l := len(name); l > 0 && name[l-1].Type == ndn.ComponentImplicitSha256Digest {
+		name = name[:l-1]
+	}
+	base, seg, isSegment := ndn.SplitSegmentName(name)
+	obj := &ndn.Segmented{
+		Name:            base,
+		Content:         content,
+		FreshnessPeriod: freshness,
+	}
+
+	var d *ndn.Data
+	switch {
+	case isSegment:
+		d = obj.Segment(seg)
+	case i.CanBePrefix:
+		// Segment discovery, answer with the first segment.
+		d = obj.Segment(0)
+	case obj.Count() == 1:
+		// Blocks that fit in one packet can be fetched without a segment
+		// number.
+		d = &ndn.Data{Name: name, FreshnessPeriod: freshness, Content: obj.Content}
+	}
+	if d == nil {
+		return nil
+	}
+	if !i.Matches(d) {
+		return nil
+	}
+	return d
+}
+
+// codedList returns the list of coded blocks of parent, or nil if there are
+// none.
+func (p *Producer) codedList(parent cid.Cid, coding string) []byte {
+	if p.coded == nil {
+		return nil
+	}
+	cids, err := p.coded.Coded(parent, coding)
+	if err != nil {
+		log.Errorf("listing %s blocks of %s: %s", coding, parent, err)
+		return nil
+	}
+	var list []byte
+	for _, c := range cids {
+		list = append(list, c.String()...)
+		list = append(list, '\n')
+	}
+	return list
+}
@@ -0,0 +1,218 @@
+package producer
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	"github.com/ipfs/go-bitswap/ndn/ndntest"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+)
+
+func newBlockstore() blockstore.Blockstore {
+	return blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+}
+
+func waitForRoute(t *testing.T, fwd *ndntest.Forwarder, prefix ndn.Name) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !fwd.HasRoute(prefix) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was never registered", prefix)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProducerServesBlocks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	bs := newBlockstore()
+	blk := blocks.NewBlock([]byte("served over ndn"))
+	if err := bs.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(bs, cidname.Default, fwd.Dial)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if !fwd.HasRoute(cidname.Default.Prefix()) || !p.Registered() {
+		t.Fatal("expected the prefix to be registered")
+	}
+
+	c := ndn.NewConsumer(fwd.Dial, ndn.InterestLifetime(100*time.Millisecond), ndn.Retries(0))
+	defer c.Close()
+
+	content, err := c.Fetch(ctx, cidname.Default.Name(blk.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, blk.RawData()) {
+		t.Fatal("got the wrong content")
+	}
+
+	// Blocks we do not have are not answered.
+	missing := blocks.NewBlock([]byte("not in the blockstore"))
+	if _, err := c.Fetch(ctx, cidname.Default.Name(missing.Cid())); err != ndn.ErrTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	// Neither are names that do not map to a CID.
+	if _, err := c.Fetch(ctx, cidname.Default.Prefix().Append(ndn.GenericComponent([]byte("junk")))); err != ndn.ErrTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestProducerReconnects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	bs := newBlockstore()
+	blk := blocks.NewBlock([]byte("still served"))
+	if err := bs.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(bs, cidname.Default, fwd.Dial)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// Simulate a forwarder restart.
+	fwd.Close()
+	waitForRoute(t, fwd, cidname.Default.Prefix())
+
+	c := ndn.NewConsumer(fwd.Dial)
+	defer c.Close()
+	if _, err := c.Fetch(ctx, cidname.Default.Name(blk.Cid())); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProducerClose(t *testing.T) {
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	p := New(newBlockstore(), cidname.Default, fwd.Dial)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fwd.HasRoute(cidname.Default.Prefix()) || p.Registered() {
+		t.Fatal("expected the prefix to be unregistered")
+	}
+	if err := p.Start(); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+
+	// Closing a producer that never started must not block.
+	if err := New(newBlockstore(), cidname.Default, fwd.Dial).Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProducerSegmentsLargeBlocks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	bs := newBlockstore()
+	data := make([]byte, 256*1024)
+	rand.Read(data)
+	blk := blocks.NewBlock(data)
+	if err := bs.Put(blk); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(bs, cidname.Default, fwd.Dial)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	c := ndn.NewConsumer(fwd.Dial, ndn.InterestLifetime(time.Second))
+	defer c.Close()
+
+	content, err := c.FetchSegmented(ctx, cidname.Default.Name(blk.Cid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(content, data) {
+		t.Fatal("got the wrong content")
+	}
+
+	// The plain name only works for blocks that fit in one packet.
+	plain := ndn.NewConsumer(fwd.Dial, ndn.InterestLifetime(100*time.Millisecond), ndn.Retries(0))
+	defer plain.Close()
+	if _, err := plain.Fetch(ctx, cidname.Default.Name(blk.Cid())); err != ndn.ErrTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestProducerServesCodedLists(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	idx := coding.NewIndex(dssync.MutexWrap(ds.NewMapDatastore()))
+	parent := blocks.NewBlock([]byte("parent"))
+	if err := idx.Add(parent.Cid(), "nc", blocks.NewBlock([]byte("coded 0")).Cid(), blocks.NewBlock([]byte("coded 1")).Cid()); err != nil {
+		t.Fatal(err)
+	}
+	coded, err := idx.Coded(parent.Cid(), "nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list string
+	for _, c := range coded {
+		list += c.String() + "\n"
+	}
+
+	p := New(newBlockstore(), cidname.Default, fwd.Dial, Coded(idx))
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	c := ndn.NewConsumer(fwd.Dial, ndn.InterestLifetime(100*time.Millisecond), ndn.Retries(0))
+	defer c.Close()
+
+	content, err := c.FetchSegmented(ctx, cidname.Default.CodedName(parent.Cid(), "nc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != list {
+		t.Fatalf("expected the list %q, got %q", list, content)
+	}
+
+	// Parents without coded blocks, and unknown codings, are not answered.
+	other := blocks.NewBlock([]byte("other parent"))
+	if _, err := c.FetchSegmented(ctx, cidname.Default.CodedName(other.Cid(), "nc")); err != ndn.ErrTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if _, err := c.FetchSegmented(ctx, cidname.Default.CodedName(parent.Cid(), "rs")); err != ndn.ErrTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
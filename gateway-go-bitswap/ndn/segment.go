@@ -0,0 +1,165 @@
+package ndn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultSegmentSize is the payload size of each segment. It leaves
+	// room for the name, MetaInfo and signature of the Data packet within
+	// MaxPacketSize.
+	DefaultSegmentSize = 8000
+
+	// MaxSegments bounds the number of segments the consumer accepts for
+	// one object, about 8 MB with the default segment size.
+	MaxSegments = 1024
+)
+
+// ErrTooManySegments is returned when a segmented object announces more than
+// MaxSegments segments.
+var ErrTooManySegments = errors.New("ndn: object has too many segments")
+
+// Segmented describes content split into segments named
+// <Name>/seg=<number>.
+type Segmented struct {
+	Name            Name
+	Content         []byte
+	SegmentSize     int
+	FreshnessPeriod time.Duration
+}
+
+// Count returns the number of segments; empty content has one empty segment.
+func (s *Segmented) Count() uint64 {
+	size := s.segmentSize()
+	if len(s.Content) == 0 {
+		return 1
+	}
+	return uint64((len(s.Content) + size - 1) / size)
+}
+
+func (s *Segmented) segmentSize() int {
+	if s.SegmentSize <= 0 {
+		return DefaultSegmentSize
+	}
+	return s.SegmentSize
+}
+
+// Segment returns the Data packet of segment seg, or nil if there is no such
+// segment. Every segment carries the FinalBlockId of the last one.
+func (s *Segmented) Segment(seg uint64) *Data {
+	count := s.Count()
+	if seg >= count {
+		return nil
+	}
+	size := uint64(s.segmentSize())
+	start := seg * size
+	end := start + size
+	if end > uint64(len(s.Content)) {
+		end = uint64(len(s.Content))
+	}
+	final := SegmentComponent(count - 1)
+	return &Data{
+		Name:            s.Name.Append(SegmentComponent(seg)),
+		FreshnessPeriod: s.FreshnessPeriod,
+		FinalBlockID:    &final,
+		Content:         s.Content[start:end],
+	}
+}
+
+// SplitSegmentName splits a name ending with a segment component into the
+// name of the object and the segment number.
+func SplitSegmentName(n Name) (Name, uint64, bool) {
+	if len(n) == 0 || !n[len(n)-1].IsSegment() {
+		return n, 0, false
+	}
+	seg, err := n[len(n)-1].Segment()
+	if err != nil {
+		return n, 0, false
+	}
+	return n[:len(n)-1], seg, true
+}
+
+// lastSegment returns the last segment number announced by d. Data without a
+// FinalBlockId is taken to be the only segment.
+func lastSegment(d *Data) (uint64, error) {
+	if d.FinalBlockID == nil {
+		return 0, nil
+	}
+	return d.FinalBlockID.Segment()
+}
+
+// FetchSegmented retrieves an object published as segments under name and
+// returns its reassembled content. The first segment is fetched on its own to
+// learn the last segment number from its FinalBlockId, the others are
+// pipelined within the consumer's congestion window.
+func (c *Consumer) FetchSegmented(ctx context.Context, name Name) ([]byte, error) {
+	first, err := c.Express(ctx, &Interest{Name: name.Append(SegmentComponent(0))})
+	if err != nil {
+		return nil, err
+	}
+	return c.fetchRemainingSegments(ctx, name, first)
+}
+
+// fetchRemainingSegments retrieves the segments of the object published
+// under name that follow first, and returns the reassembled content.
+func (c *Consumer) fetchRemainingSegments(ctx context.Context, name Name, first *Data) ([]byte, error) {
+	last, err := lastSegment(first)
+	if err != nil {
+		return nil, err
+	}
+	if last >= MaxSegments {
+		return nil, ErrTooManySegments
+	}
+	if last == 0 {
+		return first.Content, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	segments := make([][]byte, last+1)
+	segments[0] = first.Content
+
+	type result struct {
+		seg uint64
+		d   *Data
+		err error
+	}
+	results := make(chan result)
+	for seg := uint64(1); seg <= last; seg++ {
+		go func(seg uint64) {
+			d, err := c.Express(ctx, &Interest{Name: name.Append(SegmentComponent(seg))})
+			select {
+			case results <- result{seg, d, err}:
+			case <-ctx.Done():
+			}
+		}(seg)
+	}
+
+	size := len(first.Content)
+	for received := uint64(0); received < last; received++ {
+		var r result
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		if l, err := lastSegment(r.d); err != nil || l != last {
+			return nil, fmt.Errorf("ndn: segment %d of %s announces a different last segment", r.seg, name)
+		}
+		segments[r.seg] = r.d.Content
+		size += len(r.d.Content)
+	}
+
+	content := make([]byte, 0, size)
+	for _, s := range segments {
+		content = append(content, s...)
+	}
+	return content, nil
+}
@@ -0,0 +1,119 @@
+package ndn_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/ndntest"
+)
+
+func TestSegmented(t *testing.T) {
+	content := make([]byte, 2*ndn.DefaultSegmentSize+10)
+	rand.Read(content)
+	obj := &ndn.Segmented{Name: ndn.MustParseName("/ipfs/obj"), Content: content}
+	if obj.Count() != 3 {
+		t.Fatalf("expected 3 segments, got %d", obj.Count())
+	}
+
+	var joined []byte
+	for seg := uint64(0); seg < obj.Count(); seg++ {
+		d := obj.Segment(seg)
+		if len(d.Encode()) > ndn.MaxPacketSize {
+			t.Fatalf("segment %d does not fit in a packet", seg)
+		}
+		if last, err := d.FinalBlockID.Segment(); err != nil || last != 2 {
+			t.Fatalf("unexpected FinalBlockId %v", d.FinalBlockID)
+		}
+		base, n, ok := ndn.SplitSegmentName(d.Name)
+		if !ok || n != seg || !base.Equal(obj.Name) {
+			t.Fatalf("unexpected segment name %s", d.Name)
+		}
+		joined = append(joined, d.Content...)
+	}
+	if !bytes.Equal(joined, content) {
+		t.Fatal("segments do not add up to the content")
+	}
+	if obj.Segment(3) != nil {
+		t.Fatal("expected no segment past the last one")
+	}
+
+	empty := &ndn.Segmented{Name: obj.Name}
+	if empty.Count() != 1 || len(empty.Segment(0).Content) != 0 {
+		t.Fatal("expected empty content to have a single empty segment")
+	}
+}
+
+func TestFetchSegmented(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	c := ndn.NewConsumer(fwd.Dial)
+	defer c.Close()
+
+	// From a single segment up to a 1 MiB nc-1024 chunk.
+	for _, size := range []int{0, 100, ndn.DefaultSegmentSize, 256 * 1024, 1024*1024 + 14} {
+		content := make([]byte, size)
+		rand.Read(content)
+		name := ndn.MustParseName("/ipfs/obj").Append(ndn.GenericComponent([]byte{byte(size), byte(size >> 8), byte(size >> 16)}))
+		fwd.ServeSegmented(name, content)
+
+		got, err := c.FetchSegmented(ctx, name)
+		if err != nil {
+			t.Fatalf("fetching %d bytes: %s", size, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("fetching %d bytes: reassembled content differs", size)
+		}
+	}
+}
+
+func TestFetchSegmentedMissingSegment(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	name := ndn.MustParseName("/ipfs/holey")
+	obj := &ndn.Segmented{Name: name, Content: make([]byte, 5*ndn.DefaultSegmentSize)}
+	fwd.Serve(name, func(i *ndn.Interest) *ndn.Data {
+		_, seg, _ := ndn.SplitSegmentName(i.Name)
+		if seg == 3 {
+			return nil
+		}
+		return obj.Segment(seg)
+	})
+
+	c := ndn.NewConsumer(fwd.Dial, ndn.InterestLifetime(50*time.Millisecond), ndn.Retries(1))
+	defer c.Close()
+	if _, err := c.FetchSegmented(ctx, name); err != ndn.ErrTimeout {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
+
+func TestFetchSegmentedTooLarge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fwd := ndntest.New()
+	defer fwd.Close()
+
+	name := ndn.MustParseName("/ipfs/huge")
+	final := ndn.SegmentComponent(ndn.MaxSegments)
+	fwd.Serve(name, func(i *ndn.Interest) *ndn.Data {
+		return &ndn.Data{Name: i.Name, FinalBlockID: &final}
+	})
+
+	c := ndn.NewConsumer(fwd.Dial)
+	defer c.Close()
+	if _, err := c.FetchSegmented(ctx, name); err != ndn.ErrTooManySegments {
+		t.Fatalf("expected ErrTooManySegments, got %v", err)
+	}
+}
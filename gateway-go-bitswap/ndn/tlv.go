@@ -0,0 +1,237 @@
+// Package ndn implements the parts of the Named Data Networking protocol
+// that the gateway needs to talk to a local NDN forwarder: NDN-TLV encoding
+// of Interest and Data packets, NDNLPv2 Nacks, and a face that exchanges
+// packets with the forwarder over a stream socket.
+package ndn
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TLV-TYPE numbers, see the NDN packet format specification v0.3 and
+// NDNLPv2.
+const (
+	tlvInterest = 0x05
+	tlvData     = 0x06
+
+	tlvName                 = 0x07
+	tlvCanBePrefix          = 0x21
+	tlvMustBeFresh          = 0x12
+	tlvForwardingHint       = 0x1e
+	tlvNonce                = 0x0a
+	tlvInterestLifetime     = 0x0c
+	tlvHopLimit             = 0x22
+	tlvApplicationParameter = 0x24
+
+	tlvMetaInfo        = 0x14
+	tlvContent         = 0x15
+	tlvSignatureInfo   = 0x16
+	tlvSignatureValue  = 0x17
+	tlvContentType     = 0x18
+	tlvFreshnessPeriod = 0x19
+	tlvFinalBlockID    = 0x1a
+	tlvSignatureType   = 0x1b
+
+	tlvLpPacket   = 0x64
+	tlvLpFragment = 0x50
+	tlvNack       = 0x0320
+	tlvNackReason = 0x0321
+)
+
+// MaxPacketSize is the largest packet a forwarder is required to accept.
+const MaxPacketSize = 8800
+
+var (
+	// ErrTruncated is returned when a TLV element is longer than the buffer
+	// that holds it.
+	ErrTruncated = errors.New("ndn: truncated TLV element")
+
+	errTooLarge = errors.New("ndn: packet exceeds MaxPacketSize")
+)
+
+// element is one decoded TLV element.
+type element struct {
+	typ   uint64
+	value []byte
+	// wire is the full encoding of the element, including type and length.
+	wire []byte
+}
+
+// isCritical reports whether an unrecognised element of the given type must
+// cause decoding to fail.
+func isCritical(typ uint64) bool {
+	return typ <= 31 || typ&1 == 1
+}
+
+func appendVarNum(b []byte, v uint64) []byte {
+	switch {
+	case v < 253:
+		return append(b, byte(v))
+	case v <= 0xffff:
+		b = append(b, 253, 0, 0)
+		binary.BigEndian.PutUint16(b[len(b)-2:], uint16(v))
+	case v <= 0xffffffff:
+		b = append(b, 254, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(b[len(b)-4:], uint32(v))
+	default:
+		b = append(b, 255, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(b[len(b)-8:], v)
+	}
+	return b
+}
+
+func readVarNum(b []byte) (uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, ErrTruncated
+	}
+	switch b[0] {
+	case 253:
+		if len(b) < 3 {
+			return 0, 0, ErrTruncated
+		}
+		return uint64(binary.BigEndian.Uint16(b[1:])), 3, nil
+	case 254:
+		if len(b) < 5 {
+			return 0, 0, ErrTruncated
+		}
+		return uint64(binary.BigEndian.Uint32(b[1:])), 5, nil
+	case 255:
+		if len(b) < 9 {
+			return 0, 0, ErrTruncated
+		}
+		return binary.BigEndian.Uint64(b[1:]), 9, nil
+	default:
+		return uint64(b[0]), 1, nil
+	}
+}
+
+func appendTLV(b []byte, typ uint64, value []byte) []byte {
+	b = appendVarNum(b, typ)
+	b = appendVarNum(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// nonNegIntBytes encodes v as a NonNegativeInteger using the shortest of the
+// 1, 2, 4 or 8 byte forms.
+func nonNegIntBytes(v uint64) []byte {
+	switch {
+	case v <= 0xff:
+		return []byte{byte(v)}
+	case v <= 0xffff:
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, uint16(v))
+		return b
+	case v <= 0xffffffff:
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(v))
+		return b
+	default:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, v)
+		return b
+	}
+}
+
+func appendNonNegInt(b []byte, typ uint64, v uint64) []byte {
+	return appendTLV(b, typ, nonNegIntBytes(v))
+}
+
+func decodeNonNegInt(b []byte) (uint64, error) {
+	switch len(b) {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	case 8:
+		return binary.BigEndian.Uint64(b), nil
+	default:
+		return 0, fmt.Errorf("ndn: invalid NonNegativeInteger length %d", len(b))
+	}
+}
+
+// readElement decodes the TLV element at the start of b and returns it
+// together with the number of bytes it occupies.
+func readElement(b []byte) (element, int, error) {
+	typ, n1, err := readVarNum(b)
+	if err != nil {
+		return element{}, 0, err
+	}
+	length, n2, err := readVarNum(b[n1:])
+	if err != nil {
+		return element{}, 0, err
+	}
+	start := n1 + n2
+	if uint64(len(b)-start) < length {
+		return element{}, 0, ErrTruncated
+	}
+	end := start + int(length)
+	return element{typ: typ, value: b[start:end], wire: b[:end]}, end, nil
+}
+
+// readElements decodes a sequence of TLV elements that fills b exactly.
+func readElements(b []byte) ([]element, error) {
+	var out []element
+	for len(b) > 0 {
+		e, n, err := readElement(b)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+		b = b[n:]
+	}
+	return out, nil
+}
+
+// ReadPacket reads the next top-level TLV element, such as an Interest, a
+// Data or an LpPacket, from a stream.
+func ReadPacket(r *bufio.Reader) ([]byte, error) {
+	var hdr []byte
+	readNum := func() (uint64, error) {
+		first, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		hdr = append(hdr, first)
+		extra := 0
+		switch first {
+		case 253:
+			extra = 2
+		case 254:
+			extra = 4
+		case 255:
+			extra = 8
+		}
+		for i := 0; i < extra; i++ {
+			c, err := r.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			hdr = append(hdr, c)
+		}
+		v, _, err := readVarNum(hdr[len(hdr)-1-extra:])
+		return v, err
+	}
+
+	if _, err := readNum(); err != nil {
+		return nil, err
+	}
+	length, err := readNum()
+	if err != nil {
+		return nil, err
+	}
+	if length > MaxPacketSize {
+		return nil, errTooLarge
+	}
+	pkt := make([]byte, len(hdr)+int(length))
+	copy(pkt, hdr)
+	if _, err := io.ReadFull(r, pkt[len(hdr):]); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
@@ -0,0 +1,158 @@
+package bitswap
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// LossGenerator decides which of the messages sent on a link are lost.
+type LossGenerator interface {
+	// NextLost returns whether the next message sent on the link is lost.
+	NextLost() bool
+}
+
+// LossModel makes the loss generator of each link. Links are directed: the
+// messages from a to b are lost independently of the ones from b to a.
+type LossModel interface {
+	NextLossGenerator(from peer.ID, to peer.ID) LossGenerator
+}
+
+type bernoulliLoss struct {
+	lossRate float64
+	rng      *rand.Rand
+}
+
+// BernoulliLoss returns a loss model where every message is lost
+// independently of the others, with probability lossRate.
+func BernoulliLoss(lossRate float64, rng *rand.Rand) LossModel {
+	if rng == nil {
+		rng = sharedRNG
+	}
+
+	return &bernoulliLoss{
+		lossRate: lossRate,
+		rng:      rng,
+	}
+}
+
+func (m *bernoulliLoss) NextLossGenerator(from peer.ID, to peer.ID) LossGenerator {
+	return m
+}
+
+func (m *bernoulliLoss) NextLost() bool {
+	return m.rng.Float64() < m.lossRate
+}
+
+// GilbertElliottLoss returns a loss model of bursty losses, such as the ones
+// of a wireless link. Each link is a two state Markov chain: in the good state
+// messages are lost with probability lossGood, in the bad state with
+// probability lossBad. After each message, a link in the good state goes bad
+// with probability goodToBad, and a link in the bad state recovers with
+// probability badToGood, so that bursts last 1/badToGood messages on average.
+// Links start in the good state.
+func GilbertElliottLoss(
+	goodToBad float64,
+	badToGood float64,
+	lossGood float64,
+	lossBad float64,
+	rng *rand.Rand) LossModel {
+	if rng == nil {
+		rng = sharedRNG
+	}
+
+	return &gilbertElliottLoss{
+		goodToBad: goodToBad,
+		badToGood: badToGood,
+		lossGood:  lossGood,
+		lossBad:   lossBad,
+		rng:       rng,
+	}
+}
+
+type gilbertElliottLoss struct {
+	goodToBad float64
+	badToGood float64
+	lossGood  float64
+	lossBad   float64
+	rng       *rand.Rand
+}
+
+func (m *gilbertElliottLoss) NextLossGenerator(from peer.ID, to peer.ID) LossGenerator {
+	return &gilbertElliottLossGenerator{model: m}
+}
+
+type gilbertElliottLossGenerator struct {
+	model *gilbertElliottLoss
+	bad   bool
+}
+
+func (g *gilbertElliottLossGenerator) NextLost() bool {
+	m := g.model
+	lossRate := m.lossGood
+	if g.bad {
+		lossRate = m.lossBad
+	}
+	lost := m.rng.Float64() < lossRate
+
+	if g.bad {
+		g.bad = m.rng.Float64() >= m.badToGood
+	} else {
+		g.bad = m.rng.Float64() < m.goodToBad
+	}
+	return lost
+}
+
+type link struct {
+	from peer.ID
+	to   peer.ID
+}
+
+// PerLinkLoss is a loss model with a model of its own for some links, to
+// make links asymmetric, like a lossy upload from a seed with a clean path
+// back to it. The other links use the default model, or are lossless without
+// one.
+type PerLinkLoss struct {
+	lk     sync.Mutex
+	def    LossModel
+	models map[link]LossModel
+}
+
+// NewPerLinkLoss returns a PerLinkLoss using def for the links without a
+// model of their own.
+func NewPerLinkLoss(def LossModel) *PerLinkLoss {
+	return &PerLinkLoss{
+		def:    def,
+		models: make(map[link]LossModel),
+	}
+}
+
+// SetLink sets the loss model of the link from one peer to another. It must
+// be set before the first message is sent on the link.
+func (m *PerLinkLoss) SetLink(from peer.ID, to peer.ID, model LossModel) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+
+	m.models[link{from, to}] = model
+}
+
+func (m *PerLinkLoss) NextLossGenerator(from peer.ID, to peer.ID) LossGenerator {
+	m.lk.Lock()
+	model, ok := m.models[link{from, to}]
+	m.lk.Unlock()
+
+	if !ok {
+		model = m.def
+	}
+	if model == nil {
+		return noLoss{}
+	}
+	return model.NextLossGenerator(from, to)
+}
+
+type noLoss struct{}
+
+func (noLoss) NextLost() bool {
+	return false
+}
@@ -0,0 +1,65 @@
+package bitswap
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const lossSamples = 100000
+
+func TestBernoulliLossRate(t *testing.T) {
+	g := BernoulliLoss(0.1, rand.New(rand.NewSource(testSeed))).NextLossGenerator("a", "b")
+
+	lost := 0
+	for i := 0; i < lossSamples; i++ {
+		if g.NextLost() {
+			lost++
+		}
+	}
+	if rate := float64(lost) / lossSamples; math.Abs(rate-0.1) >= 0.01 {
+		t.Fatalf("expected a loss rate of 0.1, got %f", rate)
+	}
+}
+
+func TestGilbertElliottLossIsBursty(t *testing.T) {
+	goodToBad, badToGood := 0.02, 0.2
+	g := GilbertElliottLoss(goodToBad, badToGood, 0, 1, rand.New(rand.NewSource(testSeed))).NextLossGenerator("a", "b")
+
+	lost, bursts := 0, 0
+	previous := false
+	for i := 0; i < lossSamples; i++ {
+		l := g.NextLost()
+		if l {
+			lost++
+			if !previous {
+				bursts++
+			}
+		}
+		previous = l
+	}
+
+	// the link is bad for goodToBad / (goodToBad + badToGood) of the time
+	expectedRate := goodToBad / (goodToBad + badToGood)
+	if rate := float64(lost) / lossSamples; math.Abs(rate-expectedRate) >= 0.02 {
+		t.Fatalf("expected a loss rate of %f, got %f", expectedRate, rate)
+	}
+	if burst := float64(lost) / float64(bursts); math.Abs(burst-1/badToGood) >= 1 {
+		t.Fatalf("expected bursts of %f losses, got %f", 1/badToGood, burst)
+	}
+}
+
+func TestPerLinkLossIsAsymmetric(t *testing.T) {
+	var a, b peer.ID = "a", "b"
+	m := NewPerLinkLoss(nil)
+	m.SetLink(a, b, BernoulliLoss(1, nil))
+
+	if !m.NextLossGenerator(a, b).NextLost() {
+		t.Fatal("expected the link from a to b to lose messages")
+	}
+	if m.NextLossGenerator(b, a).NextLost() {
+		t.Fatal("expected the link from b to a to be lossless")
+	}
+}
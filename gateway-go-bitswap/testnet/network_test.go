@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 	"testing"
+	"time"
 
 	bsmsg "github.com/ipfs/go-bitswap/message"
 	bsnet "github.com/ipfs/go-bitswap/network"
@@ -71,6 +72,52 @@ func TestSendMessageAsyncButWaitForResponse(t *testing.T) {
 	wg.Wait() // until waiter delegate function is executed
 }
 
+func TestLossyNetworkImpairments(t *testing.T) {
+	send := func(imp Impairments) int {
+		net := LossyVirtualNetwork(mockrouting.NewServer(), delay.Fixed(0), imp)
+		senderPeer := tnet.RandIdentityOrFatal(t)
+		receiverPeer := tnet.RandIdentityOrFatal(t)
+		sender := net.Adapter(senderPeer)
+		receiver := net.Adapter(receiverPeer)
+
+		received := make(chan struct{}, 10)
+		receiver.SetDelegate(lambda(func(
+			ctx context.Context,
+			from peer.ID,
+			incoming bsmsg.BitSwapMessage) {
+			received <- struct{}{}
+		}))
+		sender.SetDelegate(lambda(func(context.Context, peer.ID, bsmsg.BitSwapMessage) {}))
+
+		msg := bsmsg.New(true)
+		msg.AddBlock(blocks.NewBlock([]byte("data")))
+		if err := sender.SendMessage(context.Background(), receiverPeer.ID(), msg); err != nil {
+			t.Fatal(err)
+		}
+
+		count := 0
+		timeout := time.After(100 * time.Millisecond)
+		for {
+			select {
+			case <-received:
+				count++
+			case <-timeout:
+				return count
+			}
+		}
+	}
+
+	if n := send(Impairments{}); n != 1 {
+		t.Fatalf("expected the message to be delivered once, got %d", n)
+	}
+	if n := send(Impairments{Loss: BernoulliLoss(1, nil)}); n != 0 {
+		t.Fatalf("expected the message to be lost, got %d", n)
+	}
+	if n := send(Impairments{Duplicate: 1}); n != 2 {
+		t.Fatalf("expected the message to be delivered twice, got %d", n)
+	}
+}
+
 type receiverFunc func(ctx context.Context, p peer.ID,
 	incoming bsmsg.BitSwapMessage)
 
@@ -3,6 +3,7 @@ package bitswap
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -58,6 +59,41 @@ func RateLimitedVirtualNetwork(rs mockrouting.Server, d delay.D, rateLimitGenera
 	}
 }
 
+// Impairments are the faults injected into the messages of a lossy network.
+type Impairments struct {
+	// Loss decides which messages are lost on each link; nil for none.
+	Loss LossModel
+	// Duplicate is the probability that a message is delivered twice.
+	Duplicate float64
+	// Reorder is the probability that a message is held back by up to
+	// ReorderDelay, letting the messages sent after it overtake it.
+	Reorder      float64
+	ReorderDelay time.Duration
+	// Rng drives duplication and reordering; a shared source when nil.
+	Rng *rand.Rand
+}
+
+// LossyVirtualNetwork generates a testnet instance where messages are lost,
+// duplicated and reordered as described by imp.
+func LossyVirtualNetwork(rs mockrouting.Server, d delay.D, imp Impairments) Network {
+	if imp.Rng == nil {
+		imp.Rng = sharedRNG
+	}
+	if imp.Loss == nil {
+		imp.Loss = NewPerLinkLoss(nil)
+	}
+
+	return &network{
+		latencies:      make(map[peer.ID]map[peer.ID]time.Duration),
+		clients:        make(map[peer.ID]*receiverQueue),
+		delay:          d,
+		routingserver:  rs,
+		impairments:    &imp,
+		lossGenerators: make(map[peer.ID]map[peer.ID]LossGenerator),
+		conns:          make(map[string]struct{}),
+	}
+}
+
 type network struct {
 	mu                 sync.Mutex
 	latencies          map[peer.ID]map[peer.ID]time.Duration
@@ -67,6 +103,8 @@ type network struct {
 	delay              delay.D
 	isRateLimited      bool
 	rateLimitGenerator RateLimitGenerator
+	impairments        *Impairments
+	lossGenerators     map[peer.ID]map[peer.ID]LossGenerator
 	conns              map[string]struct{}
 }
 
@@ -173,16 +211,51 @@ func (n *network) SendMessage(
 	// nb: terminate the context since the context wouldn't actually be passed
 	// over the network in a real scenario
 
+	shouldSend := time.Now().Add(latency).Add(bandwidthDelay)
+	if imp := n.impairments; imp != nil {
+		// A lost message is still sent as far as the sender can tell.
+		if n.nextLossGenerator(from, to).NextLost() {
+			return nil
+		}
+		if imp.Rng.Float64() < imp.Reorder && imp.ReorderDelay > 0 {
+			shouldSend = shouldSend.Add(time.Duration(imp.Rng.Int63n(int64(imp.ReorderDelay))))
+		}
+		if imp.Rng.Float64() < imp.Duplicate {
+			receiver.enqueue(&message{
+				from:       from,
+				msg:        mes.Clone(),
+				shouldSend: shouldSend,
+			})
+		}
+	}
+
 	msg := &message{
 		from:       from,
 		msg:        mes,
-		shouldSend: time.Now().Add(latency).Add(bandwidthDelay),
+		shouldSend: shouldSend,
 	}
 	receiver.enqueue(msg)
 
 	return nil
 }
 
+// nextLossGenerator returns the loss generator of the link from one peer to
+// another, made on the first message sent on the link. n.mu must be held.
+func (n *network) nextLossGenerator(from peer.ID, to peer.ID) LossGenerator {
+	generators, ok := n.lossGenerators[from]
+	if !ok {
+		generators = make(map[peer.ID]LossGenerator)
+		n.lossGenerators[from] = generators
+	}
+
+	generator, ok := generators[to]
+	if !ok {
+		generator = n.impairments.Loss.NextLossGenerator(from, to)
+		generators[to] = generator
+	}
+	return generator
+}
+
 type networkClient struct {
 	// These need to be at the top of the struct (allocated on the heap) for alignment on 32bit platforms.
 	stats bsnet.Stats
@@ -22,6 +22,8 @@ type Entry struct {
 	WantType pb.Message_Wantlist_WantType
 	Coding   string
 	Count    int
+	// Rank is the number of innovative coded blocks the requester holds.
+	Rank int
 }
 
 // NewRefEntry creates a new reference tracked wantlist entry.
@@ -79,12 +81,13 @@ func (w *Wantlist) Add(c cid.Cid, priority int32, wantType pb.Message_Wantlist_W
 	return true
 }
 
-// Add adds an entry in a wantlist from CID & Priority, if not already present.
-func (w *Wantlist) AddC(c cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, coding string, count int) bool {
+// AddC adds a coded entry in a wantlist. An existing coded entry is replaced,
+// as count and rank change while the requester collects coded blocks.
+func (w *Wantlist) AddC(c cid.Cid, priority int32, wantType pb.Message_Wantlist_WantType, coding string, count int, rank int) bool {
 	e, ok := w.set[c]
 
 	// Adding want-have should not override want-block
-	if ok && (e.WantType == pb.Message_Wantlist_Block || wantType == pb.Message_Wantlist_Have) {
+	if ok && e.Count == 0 && (e.WantType == pb.Message_Wantlist_Block || wantType == pb.Message_Wantlist_Have) {
 		return false
 	}
 
@@ -92,8 +95,9 @@ func (w *Wantlist) AddC(c cid.Cid, priority int32, wantType pb.Message_Wantlist_
 		Cid:      c,
 		Priority: priority,
 		WantType: wantType,
-		Coding: coding,
-		Count: count,
+		Coding:   coding,
+		Count:    count,
+		Rank:     rank,
 	}
 
 	return true
@@ -119,6 +123,7 @@ func (w *Wantlist) RemoveC(c cid.Cid, co int) bool {
 
 	if wi.Count > co{
 		wi.Count-=co
+		w.set[c] = wi
 	} else {delete(w.set, c)}
 	return true
 }
@@ -157,6 +157,28 @@ func TestAddBlockThenRemoveAny(t *testing.T) {
 	}
 }
 
+func TestAddCodedReplaces(t *testing.T) {
+	wl := New()
+	if !wl.AddC(testcids[0], 5, pb.Message_Wantlist_Block, "nc", 8, 0) {
+		t.Fatal("expected true")
+	}
+	if !wl.AddC(testcids[0], 5, pb.Message_Wantlist_Block, "nc", 3, 5) {
+		t.Fatal("expected a coded want to replace the previous one")
+	}
+	e, ok := wl.Contains(testcids[0])
+	if !ok {
+		t.Fatal("expected to have ", testcids[0])
+	}
+	if e.Count != 3 || e.Rank != 5 {
+		t.Fatalf("expected count 3 and rank 5, got %d and %d", e.Count, e.Rank)
+	}
+
+	wl.Add(testcids[1], 4, pb.Message_Wantlist_Block)
+	if wl.AddC(testcids[1], 4, pb.Message_Wantlist_Block, "nc", 8, 0) {
+		t.Fatal("a coded want should not replace a want-block")
+	}
+}
+
 func TestAbsort(t *testing.T) {
 	wl := New()
 	wl.Add(testcids[0], 5, pb.Message_Wantlist_Block)
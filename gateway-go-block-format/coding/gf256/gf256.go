@@ -0,0 +1,80 @@
+// Package gf256 implements arithmetic over GF(2^8) with the primitive
+// polynomial x^8+x^4+x^3+x^2+1 (0x11d), shared by the coding schemes.
+// Addition is XOR; multiplication goes through a full product table, which
+// keeps the row operations of the codecs to one lookup per byte.
+package gf256
+
+const gfPoly = 0x11d
+
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+	gfMul [256][256]byte
+	gfInv [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= gfPoly
+		}
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			gfMul[a][b] = gfExp[int(gfLog[a])+int(gfLog[b])]
+		}
+		gfInv[a] = gfExp[255-int(gfLog[a])]
+	}
+}
+
+// Mul returns a*b.
+func Mul(a, b byte) byte {
+	return gfMul[a][b]
+}
+
+// Inv returns the multiplicative inverse of a, which must not be zero.
+func Inv(a byte) byte {
+	return gfInv[a]
+}
+
+// MulAdd adds c*src to dst, element wise. src must not be shorter than dst.
+func MulAdd(dst, src []byte, c byte) {
+	switch c {
+	case 0:
+		return
+	case 1:
+		for i := range dst {
+			dst[i] ^= src[i]
+		}
+		return
+	}
+	t := &gfMul[c]
+	for i := range dst {
+		dst[i] ^= t[src[i]]
+	}
+}
+
+// Scale multiplies every element of v by c.
+func Scale(v []byte, c byte) {
+	if c == 1 {
+		return
+	}
+	t := &gfMul[c]
+	for i := range v {
+		v[i] = t[v[i]]
+	}
+}
+
+// Dot returns the inner product of a and b. b must not be shorter than a.
+func Dot(a, b []byte) byte {
+	var s byte
+	for i, v := range a {
+		s ^= gfMul[v][b[i]]
+	}
+	return s
+}
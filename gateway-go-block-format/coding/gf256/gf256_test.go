@@ -0,0 +1,22 @@
+package gf256
+
+import (
+	"testing"
+)
+
+func TestGF256(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if Mul(byte(a), Inv(byte(a))) != 1 {
+			t.Fatalf("%d * inv(%d) != 1", a, a)
+		}
+		for b := 0; b < 256; b++ {
+			if Mul(byte(a), byte(b)) != Mul(byte(b), byte(a)) {
+				t.Fatalf("multiplication of %d and %d does not commute", a, b)
+			}
+		}
+	}
+	// x^8 = x^4+x^3+x^2+1
+	if Mul(0x80, 2) != 0x1d {
+		t.Fatal("unexpected reduction polynomial")
+	}
+}
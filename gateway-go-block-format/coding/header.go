@@ -0,0 +1,205 @@
+package coding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// headerMagic starts every encoded Header. Its zero byte is not a valid
+// protobuf field key, so a header is never mistaken for a unixfs Data
+// message.
+var headerMagic = []byte("\x00coded")
+
+// headerVersion is the layout version following the magic.
+const headerVersion = 1
+
+var (
+	// ErrNotHeader is returned when decoding bytes that are not a coded
+	// file header.
+	ErrNotHeader = errors.New("coding: not a coded file header")
+
+	// ErrHeaderVersion is returned for headers of an unknown layout.
+	ErrHeaderVersion = errors.New("coding: unknown coded file header version")
+)
+
+// Header describes how a file was coded, so that readers, pinners and
+// nodes recoding its packets never have to infer the layout from block
+// sizes.
+//
+// A coded file is a root node whose links are the parents of its
+// generations, in order, and each generation parent links to the coded
+// blocks of its generation. Both carry a Header in their data. The header
+// of the root describes the whole file and has Generation -1; the header of
+// a generation parent describes that generation only, with its index, its
+// own number of source symbols and the length of its data.
+type Header struct {
+	// Scheme is the coding of the packets, like "nc" or "rs".
+	Scheme string
+	// Field is the finite field the code works over, like "gf256".
+	Field string
+
+	// GenerationSize is the number of source symbols of a generation, the
+	// number of innovative packets needed to decode it. Every generation
+	// but the last one of a file has GenerationSize symbols.
+	GenerationSize int
+	// SymbolSize is the size of a source symbol in bytes.
+	SymbolSize int
+	// Redundancy is the number of packets per generation written beyond
+	// GenerationSize, like the parity shards of "rs".
+	Redundancy int
+
+	// Length is the size of the original data, of the whole file in the
+	// root and of the generation in a generation parent.
+	Length uint64
+	// Generation is the index of the generation of a generation parent,
+	// or -1 in the root.
+	Generation int
+
+	// Checksums lets receivers verify the packets of a generation before
+	// storing them, in the generation parents of schemes that publish
+	// them, like the checksums of "nc" generations. It is opaque here.
+	Checksums []byte
+}
+
+// Marshal returns the encoding of the header:
+//
+//	| magic (6) | version (1) | scheme | field | generation size |
+//	| symbol size | redundancy | length | generation+1 | [checksums] |
+//
+// with strings and checksums prefixed by their length, and every number an
+// unsigned varint. Headers without checksums end after the generation.
+func (h *Header) Marshal() []byte {
+	b := append([]byte{}, headerMagic...)
+	b = append(b, headerVersion)
+	b = appendString(b, h.Scheme)
+	b = appendString(b, h.Field)
+	for _, v := range []uint64{
+		uint64(h.GenerationSize),
+		uint64(h.SymbolSize),
+		uint64(h.Redundancy),
+		h.Length,
+		uint64(h.Generation + 1),
+	} {
+		b = appendUvarint(b, v)
+	}
+	if len(h.Checksums) > 0 {
+		b = appendString(b, string(h.Checksums))
+	}
+	return b
+}
+
+// UnmarshalHeader decodes a header encoded by Marshal.
+func UnmarshalHeader(b []byte) (*Header, error) {
+	if !bytes.HasPrefix(b, headerMagic) {
+		return nil, ErrNotHeader
+	}
+	b = b[len(headerMagic):]
+	if len(b) == 0 || b[0] != headerVersion {
+		return nil, ErrHeaderVersion
+	}
+	r := &headerReader{b: b[1:]}
+
+	h := &Header{
+		Scheme:         r.string(),
+		Field:          r.string(),
+		GenerationSize: int(r.uvarint()),
+		SymbolSize:     int(r.uvarint()),
+		Redundancy:     int(r.uvarint()),
+		Length:         r.uvarint(),
+		Generation:     int(r.uvarint()) - 1,
+	}
+	if len(r.b) > 0 {
+		h.Checksums = []byte(r.string())
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if h.Scheme == "" || h.GenerationSize < 1 || h.SymbolSize < 1 || h.Redundancy < 0 {
+		return nil, fmt.Errorf("coding: invalid header of a %q file of %d symbols of %d bytes", h.Scheme, h.GenerationSize, h.SymbolSize)
+	}
+	return h, nil
+}
+
+// GenerationCapacity returns how many bytes of data a generation holds.
+func (h *Header) GenerationCapacity() uint64 {
+	return uint64(h.GenerationSize) * uint64(h.SymbolSize)
+}
+
+// Generations returns the number of generations of the file described by
+// the header of a root. Even an empty file has one.
+func (h *Header) Generations() int {
+	c := h.GenerationCapacity()
+	if c == 0 || h.Length == 0 {
+		return 1
+	}
+	return int((h.Length + c - 1) / c)
+}
+
+// GenerationLength returns the size of the data of generation g: the
+// capacity of a generation for all but the last one.
+func (h *Header) GenerationLength(g int) uint64 {
+	start := uint64(g) * h.GenerationCapacity()
+	if start >= h.Length {
+		return 0
+	}
+	if left := h.Length - start; left < h.GenerationCapacity() {
+		return left
+	}
+	return h.GenerationCapacity()
+}
+
+// ForGeneration returns the header of the parent of generation g of the
+// file described by the header of a root. It keeps GenerationSize, which
+// coding schemes whose last generation is smaller adjust.
+func (h *Header) ForGeneration(g int) *Header {
+	gh := *h
+	gh.Generation = g
+	gh.Length = h.GenerationLength(g)
+	gh.Checksums = nil
+	return &gh
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// headerReader reads the fields of a header, keeping the first error.
+type headerReader struct {
+	b   []byte
+	err error
+}
+
+func (r *headerReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = ErrNotHeader
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *headerReader) string() string {
+	l := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+	if l > uint64(len(r.b)) {
+		r.err = ErrNotHeader
+		return ""
+	}
+	s := string(r.b[:l])
+	r.b = r.b[l:]
+	return s
+}
@@ -0,0 +1,56 @@
+package coding
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	h := &Header{
+		Scheme:         "nc",
+		Field:          "gf256",
+		GenerationSize: 40,
+		SymbolSize:     1000,
+		Length:         100001,
+		Generation:     -1,
+	}
+	b := h.Marshal()
+	out, err := UnmarshalHeader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, h) {
+		t.Fatalf("expected %v, got %v", h, out)
+	}
+
+	if h.Generations() != 3 {
+		t.Fatalf("expected 3 generations, got %d", h.Generations())
+	}
+	for g, l := range []uint64{40000, 40000, 20001, 0} {
+		if h.GenerationLength(g) != l {
+			t.Fatalf("expected generation %d to hold %d bytes, got %d", g, l, h.GenerationLength(g))
+		}
+	}
+
+	gh := h.ForGeneration(2)
+	if out, err := UnmarshalHeader(gh.Marshal()); err != nil || out.Generation != 2 || out.Length != 20001 {
+		t.Fatalf("expected the header of generation 2, got %v, %v", out, err)
+	}
+
+	gh.Checksums = []byte("checksums")
+	if out, err := UnmarshalHeader(gh.Marshal()); err != nil || !reflect.DeepEqual(out, gh) {
+		t.Fatalf("expected %v, got %v, %v", gh, out, err)
+	}
+
+	if _, err := UnmarshalHeader(b[:len(b)-1]); err != ErrNotHeader {
+		t.Fatalf("expected ErrNotHeader, got %v", err)
+	}
+	// A unixfs Data message of type File.
+	if _, err := UnmarshalHeader([]byte{0x08, 0x02, 0x12, 0x00}); err != ErrNotHeader {
+		t.Fatalf("expected ErrNotHeader, got %v", err)
+	}
+	b[len(headerMagic)] = 0
+	if _, err := UnmarshalHeader(b); err != ErrHeaderVersion {
+		t.Fatalf("expected ErrHeaderVersion, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package coding keeps track of the coded blocks held for parent blocks, and
+// of the coding schemes they are made with.
+//
+// Coded blocks, like the "nc" packets of a network coded file, are not
+// linked from any DAG node: the only record of which blocks were coded from
+// a parent is an Index. Bitswap, the blockservice, the adder and garbage
+// collection share one, usually kept in the repo datastore.
+//
+// Schemes are looked up by name in a registry: the rlnc and rs packages
+// register "nc" and "rs", and go-ipfs plugins can register more.
+package coding
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+// ErrInvalidCoding is returned for coding names that are empty or contain a
+// slash.
+var ErrInvalidCoding = errors.New("coding: invalid coding name")
+
+// Index records the coded blocks held for parent blocks, per coding.
+type Index interface {
+	// Add records children as coded blocks of parent with coding.
+	// Adding a child twice records it once.
+	Add(parent cid.Cid, coding string, children ...cid.Cid) error
+
+	// Coded returns the coded blocks of parent with coding, always in the
+	// same order. It returns no CIDs, and no error, when there are none.
+	Coded(parent cid.Cid, coding string) ([]cid.Cid, error)
+
+	// Count returns how many coded blocks of parent with coding are
+	// recorded.
+	Count(parent cid.Cid, coding string) (int, error)
+
+	// Remove forgets the given coded blocks of parent, or all of them
+	// when no child is given.
+	Remove(parent cid.Cid, coding string, children ...cid.Cid) error
+
+	// Parents returns the parents with coded blocks of coding.
+	Parents(coding string) ([]cid.Cid, error)
+}
+
+// IndexPrefix is where a datastore Index keeps its entries.
+var IndexPrefix = ds.NewKey("/local/coded")
+
+// dsIndex is an Index with one datastore entry per coded block, under
+// IndexPrefix/<coding>/<parent>/<child>. CIDs are encoded as base32 of
+// their binary form, so any CID version fits in a key.
+type dsIndex struct {
+	d ds.Datastore
+}
+
+// NewIndex returns an Index kept in d. The entries of several children are
+// written in one batch when d supports batching. d must be safe for
+// concurrent use, as blocks are indexed from concurrent fetches.
+func NewIndex(d ds.Datastore) Index {
+	return &dsIndex{d: d}
+}
+
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func cidKeyString(c cid.Cid) string {
+	return keyEncoding.EncodeToString(c.Bytes())
+}
+
+func cidFromKeyString(s string) (cid.Cid, error) {
+	b, err := keyEncoding.DecodeString(s)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.Cast(b)
+}
+
+func parentKey(parent cid.Cid, coding string) (ds.Key, error) {
+	if coding == "" || strings.Contains(coding, "/") {
+		return ds.Key{}, ErrInvalidCoding
+	}
+	return IndexPrefix.ChildString(coding).ChildString(cidKeyString(parent)), nil
+}
+
+func (idx *dsIndex) Add(parent cid.Cid, coding string, children ...cid.Cid) error {
+	pk, err := parentKey(parent, coding)
+	if err != nil {
+		return err
+	}
+	return idx.write(func(w ds.Write) error {
+		for _, c := range children {
+			if err := w.Put(pk.ChildString(cidKeyString(c)), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (idx *dsIndex) Coded(parent cid.Cid, coding string) ([]cid.Cid, error) {
+	pk, err := parentKey(parent, coding)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := idx.keys(pk)
+	if err != nil {
+		return nil, err
+	}
+
+	cids := make([]cid.Cid, 0, len(keys))
+	for _, k := range keys {
+		c, err := cidFromKeyString(k.BaseNamespace())
+		if err != nil {
+			// Not written by us, skip it.
+			continue
+		}
+		cids = append(cids, c)
+	}
+	return cids, nil
+}
+
+func (idx *dsIndex) Count(parent cid.Cid, coding string) (int, error) {
+	pk, err := parentKey(parent, coding)
+	if err != nil {
+		return 0, err
+	}
+	keys, err := idx.keys(pk)
+	return len(keys), err
+}
+
+func (idx *dsIndex) Remove(parent cid.Cid, coding string, children ...cid.Cid) error {
+	pk, err := parentKey(parent, coding)
+	if err != nil {
+		return err
+	}
+
+	var keys []ds.Key
+	if len(children) == 0 {
+		if keys, err = idx.keys(pk); err != nil {
+			return err
+		}
+	}
+	for _, c := range children {
+		keys = append(keys, pk.ChildString(cidKeyString(c)))
+	}
+
+	return idx.write(func(w ds.Write) error {
+		for _, k := range keys {
+			if err := w.Delete(k); err != nil && err != ds.ErrNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (idx *dsIndex) Parents(coding string) ([]cid.Cid, error) {
+	if coding == "" || strings.Contains(coding, "/") {
+		return nil, ErrInvalidCoding
+	}
+	keys, err := idx.keys(IndexPrefix.ChildString(coding))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := cid.NewSet()
+	var parents []cid.Cid
+	for _, k := range keys {
+		p, err := cidFromKeyString(k.Parent().BaseNamespace())
+		if err != nil {
+			// Not written by us, skip it.
+			continue
+		}
+		if seen.Visit(p) {
+			parents = append(parents, p)
+		}
+	}
+	return parents, nil
+}
+
+// keys returns the keys under prefix, sorted.
+func (idx *dsIndex) keys(prefix ds.Key) ([]ds.Key, error) {
+	res, err := idx.d.Query(dsq.Query{
+		Prefix:   prefix.String(),
+		KeysOnly: true,
+		Orders:   []dsq.Order{dsq.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]ds.Key, 0, len(entries))
+	for _, e := range entries {
+		k := ds.RawKey(e.Key)
+		// Datastores may match prefixes that do not end on a namespace.
+		if !prefix.IsAncestorOf(k) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// write applies f in a batch if the datastore supports it, so that all the
+// entries of one call are written or none.
+func (idx *dsIndex) write(f func(ds.Write) error) error {
+	b, ok := idx.d.(ds.Batching)
+	if !ok {
+		return f(idx.d)
+	}
+	batch, err := b.Batch()
+	if err != nil {
+		return err
+	}
+	if err := f(batch); err != nil {
+		return err
+	}
+	return batch.Commit()
+}
@@ -0,0 +1,115 @@
+package coding
+
+import (
+	"fmt"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	u "github.com/ipfs/go-ipfs-util"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func testCid(s string, v1 bool) cid.Cid {
+	h := mh.Multihash(u.Hash([]byte(s)))
+	if v1 {
+		return cid.NewCidV1(cid.DagProtobuf, h)
+	}
+	return cid.NewCidV0(h)
+}
+
+func testChildren(n int) []cid.Cid {
+	children := make([]cid.Cid, n)
+	for i := range children {
+		children[i] = testCid(fmt.Sprint("child", i), i%2 == 0)
+	}
+	return children
+}
+
+func TestIndex(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	idx := NewIndex(d)
+	p1 := testCid("parent1", false)
+	p2 := testCid("parent2", true)
+	children := testChildren(10)
+
+	if err := idx.Add(p1, "nc", children[:6]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Add(p1, "nc", children[4:8]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Add(p2, "nc", children[8:]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Add(p2, "rs", children[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	coded, err := idx.Coded(p1, "nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coded) != 8 {
+		t.Fatalf("expected 8 coded blocks, got %d", len(coded))
+	}
+	set := cid.NewSet()
+	for _, c := range coded {
+		set.Add(c)
+	}
+	for _, c := range children[:8] {
+		if !set.Has(c) {
+			t.Fatalf("missing coded block %s", c)
+		}
+	}
+
+	if n, err := idx.Count(p2, "nc"); err != nil || n != 2 {
+		t.Fatalf("expected 2 coded blocks, got %d, %v", n, err)
+	}
+	if n, err := idx.Count(p2, "rs"); err != nil || n != 1 {
+		t.Fatalf("expected 1 coded block, got %d, %v", n, err)
+	}
+
+	parents, err := idx.Parents("nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parents) != 2 {
+		t.Fatalf("expected 2 parents, got %d", len(parents))
+	}
+
+	if err := idx.Remove(p1, "nc", children[0], children[9]); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := idx.Count(p1, "nc"); n != 7 {
+		t.Fatalf("expected 7 coded blocks, got %d", n)
+	}
+	if err := idx.Remove(p1, "nc"); err != nil {
+		t.Fatal(err)
+	}
+	if coded, err := idx.Coded(p1, "nc"); err != nil || len(coded) != 0 {
+		t.Fatalf("expected no coded blocks, got %d, %v", len(coded), err)
+	}
+	if parents, _ := idx.Parents("nc"); len(parents) != 1 || parents[0] != p2 {
+		t.Fatalf("expected %s to be the only parent, got %v", p2, parents)
+	}
+
+	// The index survives its handle.
+	if n, _ := NewIndex(d).Count(p2, "nc"); n != 2 {
+		t.Fatalf("expected 2 coded blocks in a new index, got %d", n)
+	}
+}
+
+func TestIndexInvalidCoding(t *testing.T) {
+	idx := NewIndex(ds.NewMapDatastore())
+	p := testCid("parent", false)
+	for _, coding := range []string{"", "n/c"} {
+		if err := idx.Add(p, coding, p); err != ErrInvalidCoding {
+			t.Fatalf("%q: expected ErrInvalidCoding, got %v", coding, err)
+		}
+		if _, err := idx.Parents(coding); err != ErrInvalidCoding {
+			t.Fatalf("%q: expected ErrInvalidCoding, got %v", coding, err)
+		}
+	}
+}
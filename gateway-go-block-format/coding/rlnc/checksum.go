@@ -0,0 +1,201 @@
+package rlnc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ipfs/go-block-format/coding/gf256"
+)
+
+// checksumCount is the number of checksums of every source symbol. A packet
+// that is not the combination its coefficients claim passes all of them
+// with a chance of 2^-64.
+const checksumCount = 8
+
+// checksumSeedSize is the size of the seed of the checksum vectors.
+const checksumSeedSize = 16
+
+// keyedChecksums flags the count of keyed checksums in their encoding.
+const keyedChecksums = 0x80
+
+var (
+	// ErrBadChecksums is returned when decoding bytes that are not the
+	// checksums of a generation.
+	ErrBadChecksums = errors.New("rlnc: invalid generation checksums")
+
+	// ErrChecksumKey is returned when decoding keyed checksums without
+	// their key.
+	ErrChecksumKey = errors.New("rlnc: the generation checksums are keyed")
+
+	// ErrPolluted is returned for a packet whose symbol is not the
+	// combination of the source symbols its coefficients claim.
+	ErrPolluted = errors.New("rlnc: packet does not match the checksums of its generation")
+)
+
+// Checksums are linear checksums of the source symbols of a generation:
+// the inner products of every source symbol with checksumCount vectors
+// derived from a random seed. As a packet is a linear combination of the
+// source symbols, the checksums of its symbol are the same combination of
+// the checksums of the source symbols, so any packet can be checked on its
+// own, before decoding and whoever recoded it.
+//
+// Published in the header of a generation, the checksums are authenticated
+// by the CID of the generation parent. They catch corrupted packets and
+// pollution by peers sending garbage. With public vectors and checksums, a
+// peer can craft packets passing them though: keyed checksums derive both
+// the vectors and a mask of the published checksums from a key shared by
+// the publisher and the receivers only, so that the peers sending packets
+// learn nothing about them.
+type Checksums struct {
+	symbols    int
+	symbolSize int
+	seed       []byte
+	key        []byte
+	// values holds the checksums of every source symbol in turn.
+	values  []byte
+	vectors [][]byte
+}
+
+// Checksums returns the checksums of the source symbols of the encoder,
+// with a random seed.
+func (e *Encoder) Checksums() *Checksums {
+	return e.KeyedChecksums(nil)
+}
+
+// KeyedChecksums returns the checksums of the source symbols of the
+// encoder, with a random seed, which only the holders of key can verify
+// packets against. A nil key returns public checksums.
+func (e *Encoder) KeyedChecksums(key []byte) *Checksums {
+	seed := make([]byte, checksumSeedSize)
+	e.lk.Lock()
+	e.rand.Read(seed)
+	e.lk.Unlock()
+
+	c := &Checksums{
+		symbols:    e.symbols,
+		symbolSize: e.symbolSize,
+		seed:       seed,
+		key:        key,
+		values:     make([]byte, e.symbols*checksumCount),
+	}
+	c.vectors = c.checksumVectors()
+	for i, s := range e.source {
+		for j, v := range c.vectors {
+			c.values[i*checksumCount+j] = gf256.Dot(v, s)
+		}
+	}
+	return c
+}
+
+// Marshal returns the encoding of the checksums:
+//
+//	| count (1) | seed (16) | checksums (symbols*count) |
+//
+// The high bit of the count is set for keyed checksums, whose checksums are
+// masked.
+func (c *Checksums) Marshal() []byte {
+	b := make([]byte, 0, 1+len(c.seed)+len(c.values))
+	if c.key != nil {
+		b = append(b, checksumCount|keyedChecksums)
+	} else {
+		b = append(b, checksumCount)
+	}
+	b = append(b, c.seed...)
+	b = append(b, c.values...)
+	c.mask(b[1+checksumSeedSize:])
+	return b
+}
+
+// UnmarshalChecksums decodes the checksums of a generation of symbols of
+// symbolSize bytes encoded by Marshal. Keyed checksums need their key, and
+// fail with ErrChecksumKey without one; public checksums ignore it.
+func UnmarshalChecksums(b []byte, symbolSize int, key []byte) (*Checksums, error) {
+	if len(b) < 1+checksumSeedSize || b[0]&^keyedChecksums != checksumCount {
+		return nil, ErrBadChecksums
+	}
+	if b[0]&keyedChecksums == 0 {
+		key = nil
+	} else if key == nil {
+		return nil, ErrChecksumKey
+	}
+	values := b[1+checksumSeedSize:]
+	if len(values) == 0 || len(values)%checksumCount != 0 {
+		return nil, ErrBadChecksums
+	}
+	symbols := len(values) / checksumCount
+	if err := checkGeneration(symbols, symbolSize); err != nil {
+		return nil, err
+	}
+	c := &Checksums{
+		symbols:    symbols,
+		symbolSize: symbolSize,
+		seed:       append([]byte{}, b[1:1+checksumSeedSize]...),
+		key:        key,
+		values:     append([]byte{}, values...),
+	}
+	c.mask(c.values)
+	c.vectors = c.checksumVectors()
+	return c, nil
+}
+
+// Verify returns ErrPolluted if p is not the combination of the source
+// symbols of the generation its coefficients claim, or an error if it is
+// not a packet of the generation.
+func (c *Checksums) Verify(p *Packet) error {
+	if err := checkPacket(p, c.symbols, c.symbolSize); err != nil {
+		return err
+	}
+	for j, v := range c.vectors {
+		var want byte
+		for i, coefficient := range p.Coefficients {
+			want ^= gf256.Mul(coefficient, c.values[i*checksumCount+j])
+		}
+		if gf256.Dot(v, p.Symbol) != want {
+			return ErrPolluted
+		}
+	}
+	return nil
+}
+
+// checksumVectors derives the checksum vectors from the seed.
+func (c *Checksums) checksumVectors() [][]byte {
+	vectors := make([][]byte, checksumCount)
+	for j := range vectors {
+		vectors[j] = c.stream(j, c.symbolSize)
+	}
+	return vectors
+}
+
+// mask masks or unmasks the checksums in values with the stream following
+// the vectors, for keyed checksums only.
+func (c *Checksums) mask(values []byte) {
+	if c.key == nil {
+		return
+	}
+	for i, m := range c.stream(checksumCount, len(values)) {
+		values[i] ^= m
+	}
+}
+
+// stream returns size bytes derived from the seed, hashing it with the index
+// of the stream and a counter, keyed for keyed checksums.
+func (c *Checksums) stream(index, size int) []byte {
+	var in [checksumSeedSize + 8]byte
+	copy(in[:], c.seed)
+	binary.BigEndian.PutUint32(in[checksumSeedSize:], uint32(index))
+	s := make([]byte, 0, size+sha256.Size)
+	for n := uint32(0); len(s) < size; n++ {
+		binary.BigEndian.PutUint32(in[checksumSeedSize+4:], n)
+		if c.key != nil {
+			h := hmac.New(sha256.New, c.key)
+			h.Write(in[:])
+			s = h.Sum(s)
+		} else {
+			sum := sha256.Sum256(in[:])
+			s = append(s, sum[:]...)
+		}
+	}
+	return s[:size]
+}
@@ -0,0 +1,161 @@
+package rlnc
+
+import (
+	"math/rand"
+
+	"github.com/ipfs/go-block-format/coding/gf256"
+)
+
+// Decoder recovers the source symbols of a generation from coded packets.
+// Every packet is reduced against the ones received before as it arrives,
+// keeping the coefficient matrix in reduced row echelon form, so the rank is
+// always known and source symbols become available as soon as they are
+// determined. A Decoder is not safe for concurrent use.
+type Decoder struct {
+	symbols    int
+	symbolSize int
+
+	// coefficients[i] and data[i] hold the row whose pivot is column i,
+	// or nil.
+	coefficients [][]byte
+	data         [][]byte
+	rank         int
+
+	rand *rand.Rand
+}
+
+// NewDecoder returns a decoder for a generation of symbols symbols of
+// symbolSize bytes.
+func NewDecoder(symbols, symbolSize int) (*Decoder, error) {
+	if err := checkGeneration(symbols, symbolSize); err != nil {
+		return nil, err
+	}
+	return &Decoder{
+		symbols:      symbols,
+		symbolSize:   symbolSize,
+		coefficients: make([][]byte, symbols),
+		data:         make([][]byte, symbols),
+	}, nil
+}
+
+// Symbols returns the number of source symbols.
+func (d *Decoder) Symbols() int {
+	return d.symbols
+}
+
+// SymbolSize returns the size of the symbols.
+func (d *Decoder) SymbolSize() int {
+	return d.symbolSize
+}
+
+// Rank returns the number of linearly independent packets received.
+func (d *Decoder) Rank() int {
+	return d.rank
+}
+
+// IsComplete reports whether every source symbol is decoded.
+func (d *Decoder) IsComplete() bool {
+	return d.rank == d.symbols
+}
+
+// Add reduces p against the packets received so far. It reports whether p
+// was innovative, that is whether it increased the rank; packets that are
+// not are dropped. p is not modified.
+func (d *Decoder) Add(p *Packet) (bool, error) {
+	if err := checkPacket(p, d.symbols, d.symbolSize); err != nil {
+		return false, err
+	}
+	if d.IsComplete() {
+		return false, nil
+	}
+	c := append([]byte(nil), p.Coefficients...)
+	s := append([]byte(nil), p.Symbol...)
+
+	// Eliminate the pivots we have. Every stored row is zero in the
+	// other pivot columns, so one pass is enough.
+	for i, row := range d.coefficients {
+		if row != nil && c[i] != 0 {
+			f := c[i]
+			gf256.MulAdd(c, row, f)
+			gf256.MulAdd(s, d.data[i], f)
+		}
+	}
+	pivot := -1
+	for i, v := range c {
+		if v != 0 {
+			pivot = i
+			break
+		}
+	}
+	if pivot < 0 {
+		return false, nil
+	}
+	f := gf256.Inv(c[pivot])
+	gf256.Scale(c, f)
+	gf256.Scale(s, f)
+
+	// Clear the new pivot column in the other rows.
+	for i, row := range d.coefficients {
+		if row != nil && row[pivot] != 0 {
+			f := row[pivot]
+			gf256.MulAdd(row, c, f)
+			gf256.MulAdd(d.data[i], s, f)
+		}
+	}
+	d.coefficients[pivot] = c
+	d.data[pivot] = s
+	d.rank++
+	return true, nil
+}
+
+// IsDecoded reports whether source symbol i is known, which can happen
+// before the decoder is complete.
+func (d *Decoder) IsDecoded(i int) bool {
+	row := d.coefficients[i]
+	if row == nil {
+		return false
+	}
+	for j, v := range row {
+		if j != i && v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Symbol returns source symbol i, or nil if it is not decoded yet.
+func (d *Decoder) Symbol(i int) []byte {
+	if !d.IsDecoded(i) {
+		return nil
+	}
+	return d.data[i]
+}
+
+// Data returns the source symbols concatenated, including any padding the
+// encoder added.
+func (d *Decoder) Data() ([]byte, error) {
+	if !d.IsComplete() {
+		return nil, ErrNotDecoded
+	}
+	out := make([]byte, 0, d.symbols*d.symbolSize)
+	for _, s := range d.data {
+		out = append(out, s...)
+	}
+	return out, nil
+}
+
+// Recode returns a random combination of the packets received so far, for
+// forwarding to another decoder. It fails with ErrNoPackets if nothing was
+// received.
+func (d *Decoder) Recode() (*Packet, error) {
+	var packets []*Packet
+	for i, row := range d.coefficients {
+		if row != nil {
+			packets = append(packets, &Packet{Coefficients: row, Symbol: d.data[i]})
+		}
+	}
+	if d.rand == nil {
+		d.rand = newRand()
+	}
+	return Recode(d.rand, packets...)
+}
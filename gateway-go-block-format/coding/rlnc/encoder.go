@@ -0,0 +1,114 @@
+package rlnc
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/ipfs/go-block-format/coding/gf256"
+)
+
+// Option configures an Encoder.
+type Option func(*Encoder)
+
+// Systematic makes the encoder send every source symbol unchanged before it
+// sends random combinations. A receiver on a lossless link then decodes
+// without any arithmetic.
+func Systematic() Option {
+	return func(e *Encoder) {
+		e.systematic = true
+	}
+}
+
+// Rand sets the source of the random coefficients, which is seeded from
+// crypto/rand by default.
+func Rand(r *rand.Rand) Option {
+	return func(e *Encoder) {
+		e.rand = r
+	}
+}
+
+// Encoder produces coded packets of one generation.
+type Encoder struct {
+	symbols    int
+	symbolSize int
+	source     [][]byte
+	systematic bool
+
+	lk   sync.Mutex
+	rand *rand.Rand
+	// next is the next source symbol to send in systematic mode.
+	next int
+}
+
+// NewEncoder returns an encoder for data split into symbols symbols of
+// symbolSize bytes. Data shorter than symbols*symbolSize is padded with
+// zeros.
+func NewEncoder(data []byte, symbols, symbolSize int, opts ...Option) (*Encoder, error) {
+	if err := checkGeneration(symbols, symbolSize); err != nil {
+		return nil, err
+	}
+	if len(data) > symbols*symbolSize {
+		return nil, ErrDataTooLarge
+	}
+	e := &Encoder{
+		symbols:    symbols,
+		symbolSize: symbolSize,
+		source:     make([][]byte, symbols),
+	}
+	padded := make([]byte, symbols*symbolSize)
+	copy(padded, data)
+	for i := range e.source {
+		e.source[i] = padded[i*symbolSize : (i+1)*symbolSize]
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	if e.rand == nil {
+		e.rand = newRand()
+	}
+	return e, nil
+}
+
+// Symbols returns the number of source symbols.
+func (e *Encoder) Symbols() int {
+	return e.symbols
+}
+
+// SymbolSize returns the size of the symbols.
+func (e *Encoder) SymbolSize() int {
+	return e.symbolSize
+}
+
+// Encode returns the next packet: a source symbol while a systematic encoder
+// has not sent them all, a random combination of the source symbols
+// otherwise.
+func (e *Encoder) Encode() *Packet {
+	e.lk.Lock()
+	coefficients := make([]byte, e.symbols)
+	if e.systematic && e.next < e.symbols {
+		coefficients[e.next] = 1
+		e.next++
+	} else {
+		randomCoefficients(e.rand, coefficients)
+	}
+	e.lk.Unlock()
+
+	p, _ := e.EncodeWith(coefficients)
+	return p
+}
+
+// EncodeWith returns the combination of the source symbols with the given
+// coefficients.
+func (e *Encoder) EncodeWith(coefficients []byte) (*Packet, error) {
+	if len(coefficients) != e.symbols {
+		return nil, ErrSymbolCount
+	}
+	p := &Packet{
+		Coefficients: coefficients,
+		Symbol:       make([]byte, e.symbolSize),
+	}
+	for i, c := range coefficients {
+		gf256.MulAdd(p.Symbol, e.source[i], c)
+	}
+	return p, nil
+}
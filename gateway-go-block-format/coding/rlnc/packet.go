@@ -0,0 +1,143 @@
+package rlnc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// HeaderSize is the size of the header in front of every coded packet.
+const HeaderSize = 3
+
+// MaxSymbols is the largest number of symbols a generation can have, bounded
+// by the 16 bit symbol count of the header.
+const MaxSymbols = math.MaxUint16
+
+// packetVersion is the layout version carried in the first header byte.
+const packetVersion = 1
+
+var (
+	// ErrPacketTooShort is returned when a packet is shorter than its
+	// header and coefficient vector.
+	ErrPacketTooShort = errors.New("rlnc: packet too short")
+
+	// ErrPacketVersion is returned for packets of an unknown layout.
+	ErrPacketVersion = errors.New("rlnc: unknown packet layout version")
+
+	// ErrDataTooLarge is returned when data does not fit in the packets
+	// of the requested size.
+	ErrDataTooLarge = errors.New("rlnc: data too large for the packet size")
+)
+
+// Packet is a coded packet: a linear combination of the source symbols of a
+// generation, and the coefficients of that combination.
+//
+// On the wire a packet is
+//
+//	| version (1) | symbols (2, big endian) | coefficients (symbols) | symbol (symbolSize) |
+//
+// so a packet of a generation of n symbols of s bytes takes
+// HeaderSize+n+s bytes. This is the layout the "nc" coding of unixfs files
+// assumes: the payload of every coded leaf is one packet.
+type Packet struct {
+	// Coefficients has one entry per source symbol.
+	Coefficients []byte
+	Symbol       []byte
+}
+
+// Size returns the size of the wire encoding of the packet.
+func (p *Packet) Size() int {
+	return PacketSize(len(p.Coefficients), len(p.Symbol))
+}
+
+// Marshal returns the wire encoding of the packet.
+func (p *Packet) Marshal() []byte {
+	b := make([]byte, HeaderSize, p.Size())
+	b[0] = packetVersion
+	binary.BigEndian.PutUint16(b[1:], uint16(len(p.Coefficients)))
+	b = append(b, p.Coefficients...)
+	return append(b, p.Symbol...)
+}
+
+// Unmarshal decodes the wire encoding of a packet. The packet shares b.
+func Unmarshal(b []byte) (*Packet, error) {
+	if len(b) < HeaderSize {
+		return nil, ErrPacketTooShort
+	}
+	if b[0] != packetVersion {
+		return nil, ErrPacketVersion
+	}
+	n := int(binary.BigEndian.Uint16(b[1:]))
+	if len(b) < HeaderSize+n {
+		return nil, ErrPacketTooShort
+	}
+	return &Packet{
+		Coefficients: b[HeaderSize : HeaderSize+n],
+		Symbol:       b[HeaderSize+n:],
+	}, nil
+}
+
+// IsSystematic reports whether the packet carries source symbol i
+// unchanged.
+func (p *Packet) IsSystematic() (i int, ok bool) {
+	i = -1
+	for j, c := range p.Coefficients {
+		switch {
+		case c == 0:
+		case c == 1 && i < 0:
+			i = j
+		default:
+			return -1, false
+		}
+	}
+	return i, i >= 0
+}
+
+// PacketSize returns the size of a packet of a generation of symbols
+// symbols of symbolSize bytes.
+func PacketSize(symbols, symbolSize int) int {
+	return HeaderSize + symbols + symbolSize
+}
+
+// Capacity returns how many bytes of data a generation of symbols packets of
+// packetSize bytes holds.
+func Capacity(packetSize, symbols int) int {
+	symbolSize := packetSize - HeaderSize - symbols
+	if symbolSize <= 0 {
+		return 0
+	}
+	return symbols * symbolSize
+}
+
+// Layout returns the smallest number of symbols, and the symbol size, with
+// which dataSize bytes fit in packets of packetSize bytes: the smallest n
+// with n*(packetSize-HeaderSize-n) >= dataSize.
+func Layout(packetSize, dataSize int) (symbols, symbolSize int, err error) {
+	if dataSize <= 0 {
+		return 0, 0, fmt.Errorf("rlnc: invalid data size %d", dataSize)
+	}
+	// n*(m-n) is largest for n = m/2.
+	m := packetSize - HeaderSize
+	half := m / 2
+	if m <= 0 || Capacity(packetSize, half) < dataSize {
+		return 0, 0, ErrDataTooLarge
+	}
+	// Smaller root of n^2 - m*n + dataSize = 0, then correct for
+	// rounding.
+	h := float64(m) / 2
+	n := int(math.Ceil(h - math.Sqrt(h*h-float64(dataSize))))
+	if n < 1 {
+		n = 1
+	}
+	for n > 1 && Capacity(packetSize, n-1) >= dataSize {
+		n--
+	}
+	for Capacity(packetSize, n) < dataSize {
+		n++
+	}
+	if n > MaxSymbols {
+		return 0, 0, ErrDataTooLarge
+	}
+	return n, m - n, nil
+}
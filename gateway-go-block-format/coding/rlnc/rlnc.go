@@ -0,0 +1,105 @@
+// Package rlnc implements random linear network coding over GF(2^8).
+//
+// Data is split into a generation of equally sized source symbols. An
+// Encoder emits packets that are random linear combinations of the source
+// symbols, optionally preceded by the source symbols themselves (systematic
+// coding). A Decoder collects packets and solves for the source symbols by
+// Gaussian elimination as they arrive; any set of packets whose coefficient
+// vectors have full rank decodes the data. Nodes that hold coded packets can
+// recode them into new combinations without decoding first.
+package rlnc
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/ipfs/go-block-format/coding/gf256"
+)
+
+var (
+	// ErrSymbolCount is returned for a packet of a generation with a
+	// different number of symbols.
+	ErrSymbolCount = errors.New("rlnc: packet has the wrong number of symbols")
+
+	// ErrSymbolSize is returned for a packet with a symbol of the wrong
+	// size.
+	ErrSymbolSize = errors.New("rlnc: packet has the wrong symbol size")
+
+	// ErrNotDecoded is returned when the data is asked for before the
+	// decoder reached full rank.
+	ErrNotDecoded = errors.New("rlnc: not enough innovative packets to decode")
+
+	// ErrNoPackets is returned when recoding without any packet.
+	ErrNoPackets = errors.New("rlnc: no packets to recode")
+)
+
+// newRand returns a coefficient generator seeded from crypto/rand.
+func newRand() *rand.Rand {
+	var seed [8]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		panic(err)
+	}
+	return rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(seed[:]))))
+}
+
+// randomCoefficients fills c with random coefficients, not all zero.
+func randomCoefficients(r *rand.Rand, c []byte) {
+	for {
+		r.Read(c)
+		for _, v := range c {
+			if v != 0 {
+				return
+			}
+		}
+	}
+}
+
+func checkGeneration(symbols, symbolSize int) error {
+	if symbols < 1 || symbols > MaxSymbols {
+		return fmt.Errorf("rlnc: invalid number of symbols %d", symbols)
+	}
+	if symbolSize < 1 {
+		return fmt.Errorf("rlnc: invalid symbol size %d", symbolSize)
+	}
+	return nil
+}
+
+func checkPacket(p *Packet, symbols, symbolSize int) error {
+	if len(p.Coefficients) != symbols {
+		return ErrSymbolCount
+	}
+	if len(p.Symbol) != symbolSize {
+		return ErrSymbolSize
+	}
+	return nil
+}
+
+// Recode returns a random linear combination of packets of one generation.
+// The result is as useful to a decoder as a packet from the encoder, as long
+// as the packets it was made from were.
+func Recode(r *rand.Rand, packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, ErrNoPackets
+	}
+	if r == nil {
+		r = newRand()
+	}
+	symbols, symbolSize := len(packets[0].Coefficients), len(packets[0].Symbol)
+	out := &Packet{
+		Coefficients: make([]byte, symbols),
+		Symbol:       make([]byte, symbolSize),
+	}
+	weights := make([]byte, len(packets))
+	randomCoefficients(r, weights)
+	for i, p := range packets {
+		if err := checkPacket(p, symbols, symbolSize); err != nil {
+			return nil, err
+		}
+		gf256.MulAdd(out.Coefficients, p.Coefficients, weights[i])
+		gf256.MulAdd(out.Symbol, p.Symbol, weights[i])
+	}
+	return out, nil
+}
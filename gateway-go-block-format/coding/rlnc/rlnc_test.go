@@ -0,0 +1,374 @@
+package rlnc
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-block-format/coding"
+)
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestPacketMarshal(t *testing.T) {
+	p := &Packet{Coefficients: []byte{1, 2, 3}, Symbol: []byte("symbol")}
+	b := p.Marshal()
+	if len(b) != PacketSize(3, 6) || len(b) != p.Size() {
+		t.Fatalf("unexpected packet size %d", len(b))
+	}
+	q, err := Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(q.Coefficients, p.Coefficients) || !bytes.Equal(q.Symbol, p.Symbol) {
+		t.Fatalf("expected %v, got %v", p, q)
+	}
+
+	if _, err := Unmarshal(b[:4]); err != ErrPacketTooShort {
+		t.Fatalf("expected ErrPacketTooShort, got %v", err)
+	}
+	b[0] = 0
+	if _, err := Unmarshal(b); err != ErrPacketVersion {
+		t.Fatalf("expected ErrPacketVersion, got %v", err)
+	}
+}
+
+// The layout must agree with the arithmetic of the unixfs coded reader.
+func TestLayout(t *testing.T) {
+	packetSize := 256*1024 + 3
+	for _, size := range []int{1, 1000, 256 * 1024, 1 << 20, 10 << 20, 1 << 30} {
+		symbols, symbolSize, err := Layout(packetSize, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := float64(packetSize - HeaderSize)
+		want := int(math.Ceil(b/2 - math.Sqrt(b/2*b/2-float64(size))))
+		if symbols != want {
+			t.Fatalf("size %d: expected %d symbols, got %d", size, want, symbols)
+		}
+		if PacketSize(symbols, symbolSize) != packetSize {
+			t.Fatalf("size %d: packets of %d bytes", size, PacketSize(symbols, symbolSize))
+		}
+		if Capacity(packetSize, symbols) < size || Capacity(packetSize, symbols-1) >= size {
+			t.Fatalf("size %d: %d symbols is not the smallest layout", size, symbols)
+		}
+	}
+	if _, _, err := Layout(100, 100*100); err != ErrDataTooLarge {
+		t.Fatalf("expected ErrDataTooLarge, got %v", err)
+	}
+}
+
+func TestSystematic(t *testing.T) {
+	data := testData(1000)
+	enc, err := NewEncoder(data, 10, 100, Systematic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, _ := NewDecoder(10, 100)
+	for i := 0; i < 10; i++ {
+		p := enc.Encode()
+		if j, ok := p.IsSystematic(); !ok || j != i {
+			t.Fatalf("expected source symbol %d, got coefficients %v", i, p.Coefficients)
+		}
+		if innovative, err := dec.Add(p); err != nil || !innovative {
+			t.Fatalf("expected an innovative packet, got %t, %v", innovative, err)
+		}
+		if !dec.IsDecoded(i) {
+			t.Fatalf("expected symbol %d to be decoded", i)
+		}
+	}
+	if _, ok := enc.Encode().IsSystematic(); ok {
+		t.Fatal("expected coded packets once the source symbols are sent")
+	}
+	out, err := dec.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("decoded data differs")
+	}
+}
+
+func TestProgressiveDecoding(t *testing.T) {
+	data := testData(5000)
+	symbols, symbolSize, err := Layout(600, len(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewEncoder(data, symbols, symbolSize, Rand(rand.New(rand.NewSource(2))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, _ := NewDecoder(symbols, symbolSize)
+
+	var received []*Packet
+	for !dec.IsComplete() {
+		// Go through the wire encoding.
+		p, err := Unmarshal(enc.Encode().Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(received) > 2*symbols {
+			t.Fatal("too many packets without decoding")
+		}
+		rank := dec.Rank()
+		innovative, err := dec.Add(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if innovative != (dec.Rank() == rank+1) {
+			t.Fatal("rank does not match the innovative packets")
+		}
+		if _, err := dec.Data(); !dec.IsComplete() && err != ErrNotDecoded {
+			t.Fatalf("expected ErrNotDecoded, got %v", err)
+		}
+		received = append(received, p)
+	}
+
+	out, err := dec.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[:len(data)], data) {
+		t.Fatal("decoded data differs")
+	}
+	for _, b := range out[len(data):] {
+		if b != 0 {
+			t.Fatal("expected zero padding")
+		}
+	}
+
+	// A combination of received packets brings nothing new.
+	p, err := Recode(nil, received...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if innovative, _ := dec.Add(p); innovative {
+		t.Fatal("expected a dependent packet not to be innovative")
+	}
+}
+
+func TestRecode(t *testing.T) {
+	data := testData(2000)
+	enc, _ := NewEncoder(data, 20, 100)
+
+	// A relay that got half of the packets cannot decode, but what it
+	// forwards combines with the other half.
+	relay, _ := NewDecoder(20, 100)
+	for relay.Rank() < 10 {
+		relay.Add(enc.Encode())
+	}
+	if relay.IsComplete() {
+		t.Fatal("the relay should not be able to decode")
+	}
+	dec, _ := NewDecoder(20, 100)
+	for i := 0; i < 10; i++ {
+		p, err := relay.Recode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec.Add(p)
+	}
+	if dec.Rank() != 10 {
+		t.Fatalf("expected rank 10 from the relay, got %d", dec.Rank())
+	}
+	for !dec.IsComplete() {
+		dec.Add(enc.Encode())
+	}
+	out, _ := dec.Data()
+	if !bytes.Equal(out, data) {
+		t.Fatal("decoded data differs")
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	enc, _ := NewEncoder(testData(2000), 20, 100, Systematic())
+	c, err := UnmarshalChecksums(enc.Checksums().Marshal(), 100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Source symbols, coded and recoded packets all pass.
+	var packets []*Packet
+	for i := 0; i < 25; i++ {
+		packets = append(packets, enc.Encode())
+	}
+	recoded, _ := Recode(nil, packets...)
+	for _, p := range append(packets, recoded) {
+		if err := c.Verify(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Tampering with the symbol or the coefficients does not.
+	p := *recoded
+	p.Symbol = append([]byte{}, recoded.Symbol...)
+	p.Symbol[42] ^= 1
+	if err := c.Verify(&p); err != ErrPolluted {
+		t.Fatalf("expected ErrPolluted, got %v", err)
+	}
+	p = *packets[0]
+	p.Coefficients = []byte{0, 1}
+	p.Coefficients = append(p.Coefficients, make([]byte, 18)...)
+	if err := c.Verify(&p); err != ErrPolluted {
+		t.Fatalf("expected ErrPolluted, got %v", err)
+	}
+	if err := c.Verify(&Packet{Coefficients: make([]byte, 10), Symbol: make([]byte, 100)}); err != ErrSymbolCount {
+		t.Fatalf("expected ErrSymbolCount, got %v", err)
+	}
+
+	if _, err := UnmarshalChecksums(enc.Checksums().Marshal()[:20], 100, nil); err != ErrBadChecksums {
+		t.Fatalf("expected ErrBadChecksums, got %v", err)
+	}
+}
+
+func TestKeyedChecksums(t *testing.T) {
+	key := []byte("checksum key")
+	enc, _ := NewEncoder(testData(2000), 20, 100, Systematic())
+	keyed := enc.KeyedChecksums(key).Marshal()
+
+	c, err := UnmarshalChecksums(keyed, 100, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := enc.Encode()
+	if err := c.Verify(p); err != nil {
+		t.Fatal(err)
+	}
+	p.Symbol[0] ^= 1
+	if err := c.Verify(p); err != ErrPolluted {
+		t.Fatalf("expected ErrPolluted, got %v", err)
+	}
+
+	// The published checksums are masked: they differ from the checksums
+	// they decode to, and only the key unmasks them.
+	if bytes.Equal(keyed[1+checksumSeedSize:], c.values) {
+		t.Fatal("expected masked checksums")
+	}
+	if _, err := UnmarshalChecksums(keyed, 100, nil); err != ErrChecksumKey {
+		t.Fatalf("expected ErrChecksumKey, got %v", err)
+	}
+	other, err := UnmarshalChecksums(keyed, 100, []byte("other key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Verify(enc.Encode()); err != ErrPolluted {
+		t.Fatalf("expected ErrPolluted with the wrong key, got %v", err)
+	}
+
+	// A key does not get in the way of public checksums.
+	c, err = UnmarshalChecksums(enc.Checksums().Marshal(), 100, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Verify(enc.Encode()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := NewEncoder(make([]byte, 101), 10, 10); err != ErrDataTooLarge {
+		t.Fatalf("expected ErrDataTooLarge, got %v", err)
+	}
+	if _, err := NewDecoder(0, 10); err == nil {
+		t.Fatal("expected an error for an empty generation")
+	}
+	dec, _ := NewDecoder(4, 10)
+	if _, err := dec.Add(&Packet{Coefficients: make([]byte, 3), Symbol: make([]byte, 10)}); err != ErrSymbolCount {
+		t.Fatalf("expected ErrSymbolCount, got %v", err)
+	}
+	if _, err := dec.Add(&Packet{Coefficients: make([]byte, 4), Symbol: make([]byte, 9)}); err != ErrSymbolSize {
+		t.Fatalf("expected ErrSymbolSize, got %v", err)
+	}
+	if _, err := dec.Recode(); err != ErrNoPackets {
+		t.Fatalf("expected ErrNoPackets, got %v", err)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data := testData(1 << 20)
+	symbols, symbolSize, _ := Layout(64*1024, len(data))
+	enc, _ := NewEncoder(data, symbols, symbolSize)
+	packets := make([]*Packet, symbols+4)
+	for i := range packets {
+		packets[i] = enc.Encode()
+	}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec, _ := NewDecoder(symbols, symbolSize)
+		for _, p := range packets {
+			if dec.IsComplete() {
+				break
+			}
+			dec.Add(p)
+		}
+	}
+}
+
+// namedScheme is the nc scheme under another name.
+type namedScheme struct {
+	Scheme
+	name string
+}
+
+func (s namedScheme) Name() string {
+	return s.name
+}
+
+func TestScheme(t *testing.T) {
+	s, err := coding.Lookup("nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := coding.Register(Scheme{}); err == nil {
+		t.Fatal("expected nc to be registered once")
+	}
+	if err := coding.Register(namedScheme{name: "nc-2"}); err != coding.ErrInvalidCoding {
+		t.Fatalf("expected ErrInvalidCoding, got %v", err)
+	}
+
+	data := testData(1000)
+	enc, _ := NewEncoder(data, 10, 100, Systematic())
+	var packets [][]byte
+	for i := 0; i < 10; i++ {
+		packets = append(packets, enc.Encode().Marshal())
+	}
+	h := &coding.Header{Scheme: "nc", GenerationSize: 10, SymbolSize: 100, Length: 1000}
+	if n := s.GenerationSymbols(&coding.Header{SymbolSize: 100, GenerationSize: 10, Length: 1550}, 1); n != 6 {
+		t.Fatalf("expected a last generation of 6 symbols, got %d", n)
+	}
+
+	// Recoding skips packets of other generations.
+	other, _ := NewEncoder(data[:500], 5, 100)
+	recoded, err := s.(coding.Recoder).Recode(h, append(packets[:5:5], other.Encode().Marshal()), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recoded) != 5 {
+		t.Fatalf("expected 5 recoded packets, got %d", len(recoded))
+	}
+
+	dec, err := s.DecoderFor(recoded[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range append(recoded, packets[5:]...) {
+		if _, err := dec.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !dec.IsComplete() || dec.Rank() != 10 {
+		t.Fatalf("expected the generation to be decoded, got rank %d", dec.Rank())
+	}
+	for i, p := range packets {
+		if q, err := dec.Packet(i); err != nil || !bytes.Equal(p, q) {
+			t.Fatalf("expected systematic packet %d to be re-derived, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package rlnc
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-block-format/coding"
+)
+
+func init() {
+	coding.MustRegister(Scheme{})
+}
+
+// Scheme is the "nc" coding scheme: generations of source symbols coded
+// with random linear network coding, and written as systematic packets.
+// Nodes holding packets recode them, so it is a coding.Recoder.
+type Scheme struct{}
+
+// Name implements coding.Scheme.
+func (Scheme) Name() string {
+	return "nc"
+}
+
+// NewDecoder implements coding.Scheme.
+func (Scheme) NewDecoder(h *coding.Header) (coding.Decoder, error) {
+	dec, err := NewDecoder(h.GenerationSize, h.SymbolSize)
+	if err != nil {
+		return nil, err
+	}
+	return schemeDecoder{dec}, nil
+}
+
+// DecoderFor implements coding.Scheme. Packets carry their coefficients, so
+// any packet describes its generation.
+func (Scheme) DecoderFor(packet []byte) (coding.Decoder, error) {
+	p, err := Unmarshal(packet)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := NewDecoder(len(p.Coefficients), len(p.Symbol))
+	if err != nil {
+		return nil, err
+	}
+	return schemeDecoder{dec}, nil
+}
+
+// GenerationSymbols implements coding.Scheme. Generations have as many
+// symbols as their data needs, so the last one of a file is usually smaller.
+func (Scheme) GenerationSymbols(h *coding.Header, g int) int {
+	length := h.GenerationLength(g)
+	return int((length + uint64(h.SymbolSize) - 1) / uint64(h.SymbolSize))
+}
+
+// Recode implements coding.Recoder. Every packet it returns is a fresh
+// random combination.
+func (Scheme) Recode(h *coding.Header, packets [][]byte, n int) ([][]byte, error) {
+	var symbols, symbolSize int
+	if h != nil {
+		symbols, symbolSize = h.GenerationSize, h.SymbolSize
+	}
+
+	var ps []*Packet
+	for _, b := range packets {
+		p, err := Unmarshal(b)
+		if err != nil {
+			continue
+		}
+		if symbols == 0 {
+			symbols, symbolSize = len(p.Coefficients), len(p.Symbol)
+		}
+		if len(p.Coefficients) != symbols || len(p.Symbol) != symbolSize {
+			continue
+		}
+		ps = append(ps, p)
+	}
+
+	if n > len(ps) {
+		n = len(ps)
+	}
+	out := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		p, err := Recode(nil, ps...)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p.Marshal())
+	}
+	return out, nil
+}
+
+// schemeDecoder is a Decoder fed with packets in their wire encoding.
+type schemeDecoder struct {
+	*Decoder
+}
+
+func (d schemeDecoder) Add(packet []byte) (bool, error) {
+	p, err := Unmarshal(packet)
+	if err != nil {
+		return false, err
+	}
+	return d.Decoder.Add(p)
+}
+
+// Packet returns the systematic packet of symbol i.
+func (d schemeDecoder) Packet(i int) ([]byte, error) {
+	if i >= d.Symbols() || !d.IsDecoded(i) {
+		return nil, fmt.Errorf("rlnc: cannot re-derive packet %d of a generation of %d symbols", i, d.Symbols())
+	}
+	p := &Packet{
+		Coefficients: make([]byte, d.Symbols()),
+		Symbol:       d.Symbol(i),
+	}
+	p.Coefficients[i] = 1
+	return p.Marshal(), nil
+}
+
+func (d schemeDecoder) NotDecoded() error {
+	return ErrNotDecoded
+}
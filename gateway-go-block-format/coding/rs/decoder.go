@@ -0,0 +1,99 @@
+package rs
+
+import (
+	"github.com/ipfs/go-block-format/coding/rlnc"
+)
+
+// Decoder recovers the data of an encoding from any k of its shards. A
+// Decoder is not safe for concurrent use.
+type Decoder struct {
+	k, m int
+	size uint64
+	dec  *rlnc.Decoder
+}
+
+// NewDecoder returns a decoder for size bytes of data split into k data
+// shards of shardSize bytes, with m parity shards.
+func NewDecoder(k, m, shardSize int, size uint64) (*Decoder, error) {
+	if err := checkLayout(k, m); err != nil {
+		return nil, err
+	}
+	if size > uint64(k)*uint64(shardSize) {
+		return nil, ErrShardLayout
+	}
+	dec, err := rlnc.NewDecoder(k, shardSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Decoder{
+		k:    k,
+		m:    m,
+		size: size,
+		dec:  dec,
+	}, nil
+}
+
+// NewDecoderFor returns a decoder for the encoding s is a shard of. s is not
+// added to it.
+func NewDecoderFor(s *Shard) (*Decoder, error) {
+	return NewDecoder(s.K, s.M, len(s.Data), s.Size)
+}
+
+// DataShards returns k, the number of data shards.
+func (d *Decoder) DataShards() int {
+	return d.k
+}
+
+// ShardSize returns the size of the data of every shard.
+func (d *Decoder) ShardSize() int {
+	return d.dec.SymbolSize()
+}
+
+// Size returns the size of the encoded data.
+func (d *Decoder) Size() uint64 {
+	return d.size
+}
+
+// Received returns the number of distinct shards received.
+func (d *Decoder) Received() int {
+	return d.dec.Rank()
+}
+
+// IsComplete reports whether the data is decoded.
+func (d *Decoder) IsComplete() bool {
+	return d.dec.IsComplete()
+}
+
+// Add adds a shard. It reports whether the shard was new; shards received
+// before, and any shard once the data is decoded, are dropped.
+func (d *Decoder) Add(s *Shard) (bool, error) {
+	if s.K != d.k || s.M != d.m || s.Size != d.size || len(s.Data) != d.ShardSize() {
+		return false, ErrShardLayout
+	}
+	if s.Index < 0 || s.Index >= d.k+d.m {
+		return false, ErrShardIndex
+	}
+	// Any k rows of the generator are independent, so a shard is only
+	// ever not innovative when it was received before.
+	return d.dec.Add(&rlnc.Packet{Coefficients: row(d.k, s.Index), Symbol: s.Data})
+}
+
+// IsDecoded reports whether data shard i is known, which is the case as
+// soon as it was received.
+func (d *Decoder) IsDecoded(i int) bool {
+	return d.dec.IsDecoded(i)
+}
+
+// DataShard returns data shard i, or nil if it is not decoded yet.
+func (d *Decoder) DataShard(i int) []byte {
+	return d.dec.Symbol(i)
+}
+
+// Data returns the decoded data, without padding.
+func (d *Decoder) Data() ([]byte, error) {
+	data, err := d.dec.Data()
+	if err != nil {
+		return nil, ErrNotDecoded
+	}
+	return data[:d.size], nil
+}
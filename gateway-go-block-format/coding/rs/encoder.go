@@ -0,0 +1,62 @@
+package rs
+
+import (
+	"github.com/ipfs/go-block-format/coding/rlnc"
+)
+
+// Encoder produces the shards of one encoding.
+type Encoder struct {
+	k, m int
+	size uint64
+	enc  *rlnc.Encoder
+}
+
+// NewEncoder returns an encoder for data split into k data shards, with m
+// parity shards. The last data shard is padded with zeros.
+func NewEncoder(data []byte, k, m int) (*Encoder, error) {
+	if err := checkLayout(k, m); err != nil {
+		return nil, err
+	}
+	enc, err := rlnc.NewEncoder(data, k, ShardSize(k, len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &Encoder{
+		k:    k,
+		m:    m,
+		size: uint64(len(data)),
+		enc:  enc,
+	}, nil
+}
+
+// ShardSize returns the size of the data of every shard.
+func (e *Encoder) ShardSize() int {
+	return e.enc.SymbolSize()
+}
+
+// Shard returns shard i, the data shards coming first.
+func (e *Encoder) Shard(i int) (*Shard, error) {
+	if i < 0 || i >= e.k+e.m {
+		return nil, ErrShardIndex
+	}
+	p, err := e.enc.EncodeWith(row(e.k, i))
+	if err != nil {
+		return nil, err
+	}
+	return &Shard{
+		K:     e.k,
+		M:     e.m,
+		Index: i,
+		Size:  e.size,
+		Data:  p.Symbol,
+	}, nil
+}
+
+// Shards returns all the k+m shards.
+func (e *Encoder) Shards() []*Shard {
+	shards := make([]*Shard, e.k+e.m)
+	for i := range shards {
+		shards[i], _ = e.Shard(i)
+	}
+	return shards
+}
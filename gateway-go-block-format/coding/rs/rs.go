@@ -0,0 +1,145 @@
+// Package rs implements systematic Reed-Solomon erasure coding over GF(2^8).
+//
+// Data is split into k data shards of equal size, and m parity shards are
+// computed from them; any k of the k+m shards recover the data. Unlike
+// random linear network coding the code is maximum distance separable, so a
+// receiver never needs more than k shards, but nodes forwarding shards
+// cannot recode them. The generator matrix is the identity stacked on a
+// Cauchy matrix, and decoding goes through the Gaussian elimination of the
+// rlnc package: data shards are usable as soon as they arrive, and parity
+// shards as soon as enough of them did.
+package rs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-block-format/coding/gf256"
+)
+
+// HeaderSize is the size of the header in front of every shard.
+const HeaderSize = 12
+
+// MaxShards is the largest number of shards, data and parity, of one
+// encoding: the rows of the generator matrix are indexed by field elements.
+const MaxShards = 256
+
+// shardVersion is the layout version carried in the first header byte.
+const shardVersion = 1
+
+var (
+	// ErrShardTooShort is returned when a shard is shorter than its
+	// header.
+	ErrShardTooShort = errors.New("rs: shard too short")
+
+	// ErrShardVersion is returned for shards of an unknown layout.
+	ErrShardVersion = errors.New("rs: unknown shard layout version")
+
+	// ErrShardLayout is returned for a shard of another encoding than the
+	// one a decoder was made for.
+	ErrShardLayout = errors.New("rs: shard of a different encoding")
+
+	// ErrShardIndex is returned for shard indexes out of range.
+	ErrShardIndex = errors.New("rs: invalid shard index")
+
+	// ErrNotDecoded is returned when the data is asked for before k
+	// shards were received.
+	ErrNotDecoded = errors.New("rs: not enough shards to decode")
+)
+
+// Shard is one of the k+m shards of an encoding: data shards have an index
+// below K and carry the data unchanged, parity shards carry combinations of
+// the data shards.
+//
+// On the wire a shard is
+//
+//	| version (1) | k (1) | m (1) | index (1) | size (8, big endian) | data |
+//
+// so every shard describes its encoding, and a decoder can be made from
+// whichever shard comes first. This is the layout the "rs" coding of unixfs
+// files assumes: the payload of every coded leaf is one shard.
+type Shard struct {
+	// K and M are the number of data and parity shards.
+	K, M  int
+	Index int
+	// Size is the size of the encoded data, before padding.
+	Size uint64
+	Data []byte
+}
+
+// Marshal returns the wire encoding of the shard.
+func (s *Shard) Marshal() []byte {
+	b := make([]byte, HeaderSize, HeaderSize+len(s.Data))
+	b[0] = shardVersion
+	b[1] = byte(s.K)
+	b[2] = byte(s.M)
+	b[3] = byte(s.Index)
+	binary.BigEndian.PutUint64(b[4:], s.Size)
+	return append(b, s.Data...)
+}
+
+// Unmarshal decodes the wire encoding of a shard. The shard shares b.
+func Unmarshal(b []byte) (*Shard, error) {
+	if len(b) < HeaderSize {
+		return nil, ErrShardTooShort
+	}
+	if b[0] != shardVersion {
+		return nil, ErrShardVersion
+	}
+	s := &Shard{
+		K:     int(b[1]),
+		M:     int(b[2]),
+		Index: int(b[3]),
+		Size:  binary.BigEndian.Uint64(b[4:]),
+		Data:  b[HeaderSize:],
+	}
+	if err := checkLayout(s.K, s.M); err != nil {
+		return nil, err
+	}
+	if s.Index >= s.K+s.M {
+		return nil, ErrShardIndex
+	}
+	return s, nil
+}
+
+// IsParity reports whether the shard is a parity shard.
+func (s *Shard) IsParity() bool {
+	return s.Index >= s.K
+}
+
+// ShardSize returns the size of the shards of dataSize bytes split into k
+// data shards.
+func ShardSize(k, dataSize int) int {
+	if k < 1 {
+		return 0
+	}
+	size := (dataSize + k - 1) / k
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+func checkLayout(k, m int) error {
+	if k < 1 || k >= MaxShards || m < 0 || k+m > MaxShards {
+		return fmt.Errorf("rs: invalid encoding of %d data and %d parity shards", k, m)
+	}
+	return nil
+}
+
+// row returns the row of the generator matrix for shard index: a unit
+// vector for data shards, and for parity shards the row of the Cauchy
+// matrix 1/(x_i+y_j) with x_i = index and y_j = j. The x and y are distinct
+// since index >= k > j, so any k rows of the generator are independent.
+func row(k, index int) []byte {
+	r := make([]byte, k)
+	if index < k {
+		r[index] = 1
+		return r
+	}
+	for j := range r {
+		r[j] = gf256.Inv(byte(index) ^ byte(j))
+	}
+	return r
+}
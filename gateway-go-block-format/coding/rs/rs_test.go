@@ -0,0 +1,178 @@
+package rs
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-block-format/coding"
+)
+
+func testData(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestShardMarshal(t *testing.T) {
+	s := &Shard{K: 4, M: 2, Index: 5, Size: 1000, Data: []byte("shard")}
+	b := s.Marshal()
+	if len(b) != HeaderSize+len(s.Data) {
+		t.Fatalf("unexpected shard size %d", len(b))
+	}
+	q, err := Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.K != 4 || q.M != 2 || q.Index != 5 || q.Size != 1000 || !bytes.Equal(q.Data, s.Data) {
+		t.Fatalf("expected %v, got %v", s, q)
+	}
+	if !q.IsParity() {
+		t.Fatal("expected a parity shard")
+	}
+
+	if _, err := Unmarshal(b[:HeaderSize-1]); err != ErrShardTooShort {
+		t.Fatalf("expected ErrShardTooShort, got %v", err)
+	}
+	b[3] = 6
+	if _, err := Unmarshal(b); err != ErrShardIndex {
+		t.Fatalf("expected ErrShardIndex, got %v", err)
+	}
+	b[0] = 0
+	if _, err := Unmarshal(b); err != ErrShardVersion {
+		t.Fatalf("expected ErrShardVersion, got %v", err)
+	}
+}
+
+// Any k of the k+m shards decode the data.
+func TestAnyKShards(t *testing.T) {
+	data := testData(1001)
+	k, m := 5, 3
+	enc, err := NewEncoder(data, k, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc.ShardSize() != 201 {
+		t.Fatalf("expected shards of 201 bytes, got %d", enc.ShardSize())
+	}
+	shards := enc.Shards()
+	// The last data shard is padded.
+	for i, s := range shards[:k] {
+		chunk := data[i*201 : min(len(data), (i+1)*201)]
+		if !bytes.HasPrefix(s.Data, chunk) {
+			t.Fatalf("data shard %d does not carry the data", i)
+		}
+	}
+
+	// Every subset of k shards.
+	for set := 0; set < 1<<uint(k+m); set++ {
+		var picked []*Shard
+		for i := 0; i < k+m; i++ {
+			if set&(1<<uint(i)) != 0 {
+				picked = append(picked, shards[i])
+			}
+		}
+		if len(picked) != k {
+			continue
+		}
+		// Go through the wire encoding.
+		first, err := Unmarshal(picked[0].Marshal())
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := NewDecoderFor(first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range picked {
+			if ok, err := dec.Add(s); err != nil || !ok {
+				t.Fatalf("shards %b: expected a new shard, got %t, %v", set, ok, err)
+			}
+			if !s.IsParity() && !dec.IsDecoded(s.Index) {
+				t.Fatalf("shards %b: expected data shard %d to be decoded", set, s.Index)
+			}
+		}
+		out, err := dec.Data()
+		if err != nil {
+			t.Fatalf("shards %b: %s", set, err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("shards %b: decoded data differs", set)
+		}
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	data := testData(1000)
+	enc, _ := NewEncoder(data, 4, 4)
+	dec, err := NewDecoder(4, 4, enc.ShardSize(), uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, _ := enc.Shard(6)
+	if ok, _ := dec.Add(p); !ok {
+		t.Fatal("expected a new shard")
+	}
+	if ok, _ := dec.Add(p); ok {
+		t.Fatal("expected a shard received twice not to be new")
+	}
+	if _, err := dec.Data(); err != ErrNotDecoded {
+		t.Fatalf("expected ErrNotDecoded, got %v", err)
+	}
+
+	other, _ := NewEncoder(data, 4, 2)
+	s, _ := other.Shard(0)
+	if _, err := dec.Add(s); err != ErrShardLayout {
+		t.Fatalf("expected ErrShardLayout, got %v", err)
+	}
+	if _, err := enc.Shard(8); err != ErrShardIndex {
+		t.Fatalf("expected ErrShardIndex, got %v", err)
+	}
+	for _, layout := range [][2]int{{0, 1}, {256, 0}, {200, 57}, {1, -1}} {
+		if _, err := NewEncoder(data, layout[0], layout[1]); err == nil {
+			t.Fatalf("expected an error for %d data and %d parity shards", layout[0], layout[1])
+		}
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestScheme(t *testing.T) {
+	s, err := coding.Lookup("rs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := testData(1000)
+	enc, _ := NewEncoder(data, 4, 2)
+	shards := enc.Shards()
+	h := &coding.Header{Scheme: "rs", GenerationSize: 4, Redundancy: 2, SymbolSize: enc.ShardSize(), Length: 1000}
+	if n := s.GenerationSymbols(h, 0); n != 4 {
+		t.Fatalf("expected 4 symbols, got %d", n)
+	}
+
+	dec, err := s.NewDecoder(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Any 4 shards decode the data, and every shard is re-derived.
+	for _, sh := range shards[2:] {
+		if _, err := dec.Add(sh.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !dec.IsComplete() || dec.Rank() != 4 {
+		t.Fatalf("expected the data to be decoded, got rank %d", dec.Rank())
+	}
+	for i, sh := range shards {
+		if p, err := dec.Packet(i); err != nil || !bytes.Equal(p, sh.Marshal()) {
+			t.Fatalf("expected shard %d to be re-derived, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package rs
+
+import (
+	"github.com/ipfs/go-block-format/coding"
+)
+
+func init() {
+	coding.MustRegister(Scheme{})
+}
+
+// Scheme is the "rs" coding scheme: generations of k data shards followed
+// by m parity shards.
+type Scheme struct{}
+
+// Name implements coding.Scheme.
+func (Scheme) Name() string {
+	return "rs"
+}
+
+// NewDecoder implements coding.Scheme.
+func (Scheme) NewDecoder(h *coding.Header) (coding.Decoder, error) {
+	dec, err := NewDecoder(h.GenerationSize, h.Redundancy, h.SymbolSize, h.Length)
+	if err != nil {
+		return nil, err
+	}
+	return &schemeDecoder{Decoder: dec}, nil
+}
+
+// DecoderFor implements coding.Scheme. Shards describe their encoding, so
+// any shard describes its generation.
+func (Scheme) DecoderFor(packet []byte) (coding.Decoder, error) {
+	s, err := Unmarshal(packet)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := NewDecoderFor(s)
+	if err != nil {
+		return nil, err
+	}
+	return &schemeDecoder{Decoder: dec}, nil
+}
+
+// GenerationSymbols implements coding.Scheme: every generation has k data
+// shards.
+func (Scheme) GenerationSymbols(h *coding.Header, g int) int {
+	return h.GenerationSize
+}
+
+// schemeDecoder is a Decoder fed with shards in their wire encoding. It
+// re-encodes the shards of a decoded generation.
+type schemeDecoder struct {
+	*Decoder
+	enc *Encoder
+}
+
+func (d *schemeDecoder) Add(packet []byte) (bool, error) {
+	s, err := Unmarshal(packet)
+	if err != nil {
+		return false, err
+	}
+	return d.Decoder.Add(s)
+}
+
+func (d *schemeDecoder) Rank() int {
+	return d.Received()
+}
+
+func (d *schemeDecoder) SymbolSize() int {
+	return d.ShardSize()
+}
+
+func (d *schemeDecoder) Symbol(i int) []byte {
+	return d.DataShard(i)
+}
+
+// Packet returns shard i, data shards coming before parity shards.
+func (d *schemeDecoder) Packet(i int) ([]byte, error) {
+	if d.enc == nil {
+		data, err := d.Data()
+		if err != nil {
+			return nil, err
+		}
+		if d.enc, err = NewEncoder(data, d.k, d.m); err != nil {
+			return nil, err
+		}
+	}
+	s, err := d.enc.Shard(i)
+	if err != nil {
+		return nil, err
+	}
+	return s.Marshal(), nil
+}
+
+func (d *schemeDecoder) NotDecoded() error {
+	return ErrNotDecoded
+}
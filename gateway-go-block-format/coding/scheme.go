@@ -0,0 +1,142 @@
+package coding
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownScheme is returned when looking up a coding scheme that was
+// never registered.
+var ErrUnknownScheme = errors.New("coding: unknown coding scheme")
+
+// Scheme is a coding scheme: the layout of the packets of a generation, and
+// how they are decoded. Coded files, coded wants and the Index name their
+// scheme, and every node handling them looks it up with Lookup.
+//
+// The "nc" and "rs" schemes are registered by the rlnc and rs packages.
+type Scheme interface {
+	// Name is the name of the scheme in headers, coded wants and the
+	// Index, like "nc".
+	Name() string
+
+	// NewDecoder returns a decoder of the generation h describes.
+	NewDecoder(h *Header) (Decoder, error)
+
+	// DecoderFor returns a decoder of the generation packet belongs to,
+	// for nodes that do not hold the header of that generation. packet
+	// is not added to it.
+	DecoderFor(packet []byte) (Decoder, error)
+
+	// GenerationSymbols returns the number of source symbols of generation
+	// g of the file whose root has the header h, which is the number of
+	// innovative packets decoding it takes.
+	GenerationSymbols(h *Header, g int) int
+}
+
+// Decoder solves the source symbols of a generation from its packets, in
+// whatever order they arrive. A Decoder is not safe for concurrent use.
+type Decoder interface {
+	// Add feeds a packet and reports whether it was innovative, that is
+	// whether it raised the rank.
+	Add(packet []byte) (bool, error)
+
+	// Rank returns the number of innovative packets added.
+	Rank() int
+
+	// IsComplete reports whether every source symbol is solved.
+	IsComplete() bool
+
+	// SymbolSize returns the size of the source symbols.
+	SymbolSize() int
+
+	// IsDecoded reports whether source symbol i is solved.
+	IsDecoded(i int) bool
+
+	// Symbol returns source symbol i, or nil if it is not solved yet.
+	Symbol(i int) []byte
+
+	// Packet returns the i-th packet the generation was written with, as
+	// linked from its parent. It fails unless the decoder is complete,
+	// or at least holds what the packet is made of.
+	Packet(i int) ([]byte, error)
+
+	// NotDecoded is the error of a generation without enough packets.
+	NotDecoded() error
+}
+
+// Recoder is implemented by the schemes whose packets can be combined into
+// new packets of their generation without decoding it, like network coding.
+type Recoder interface {
+	// Recode returns up to n new packets combining packets. Packets of
+	// another generation than the one h describes, or than the first
+	// packet's one without h, are skipped. It returns fewer packets when
+	// more would not be innovative.
+	Recode(h *Header, packets [][]byte, n int) ([][]byte, error)
+}
+
+var schemes = struct {
+	sync.RWMutex
+	m map[string]Scheme
+}{m: make(map[string]Scheme)}
+
+// Register makes a coding scheme available under its name. Names cannot
+// contain a dash, which separates them from the parameters of chunker
+// strings. It fails for invalid names and names already registered.
+func Register(s Scheme) error {
+	name := s.Name()
+	if name == "" || strings.ContainsAny(name, "/-") {
+		return ErrInvalidCoding
+	}
+
+	schemes.Lock()
+	defer schemes.Unlock()
+	if _, ok := schemes.m[name]; ok {
+		return fmt.Errorf("coding: scheme %q already registered", name)
+	}
+	schemes.m[name] = s
+	return nil
+}
+
+// MustRegister is Register panicking on errors, for registering schemes
+// from init.
+func MustRegister(s Scheme) {
+	if err := Register(s); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the scheme registered under name, or ErrUnknownScheme.
+func Lookup(name string) (Scheme, error) {
+	schemes.RLock()
+	defer schemes.RUnlock()
+	s, ok := schemes.m[name]
+	if !ok {
+		return nil, ErrUnknownScheme
+	}
+	return s, nil
+}
+
+// NewDecoder returns a decoder of the generation h describes, from the
+// scheme registered under the name of h, or ErrUnknownScheme.
+func NewDecoder(h *Header) (Decoder, error) {
+	s, err := Lookup(h.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	return s.NewDecoder(h)
+}
+
+// Names returns the names of the registered schemes, sorted.
+func Names() []string {
+	schemes.RLock()
+	defer schemes.RUnlock()
+	names := make([]string, 0, len(schemes.m))
+	for name := range schemes.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -4,6 +4,7 @@ go 1.15
 
 require (
 	github.com/ipfs/go-cid v0.0.7
+	github.com/ipfs/go-datastore v0.4.5
 	github.com/ipfs/go-ipfs-util v0.0.2
 	github.com/multiformats/go-multihash v0.0.14
 )
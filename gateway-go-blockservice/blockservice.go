@@ -4,15 +4,13 @@
 package blockservice
 
 import (
-	"bufio"
 	"context"
 	"errors"
-	"fmt"
 	"io"
-	"os"
 	"sync"
 
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
 	cid "github.com/ipfs/go-cid"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	exchange "github.com/ipfs/go-ipfs-exchange-interface"
@@ -78,6 +76,14 @@ type blockService struct {
 	// If checkFirst is true then first check that a block doesn't
 	// already exist to avoid republishing the block on the exchange.
 	checkFirst bool
+	// coded lists the coded blocks held in the blockstore.
+	coded coding.Index
+}
+
+// codedIndexer is implemented by blockservices that know the coded blocks
+// their blockstore holds.
+type codedIndexer interface {
+	CodedIndex() coding.Index
 }
 
 // NewBlockService creates a BlockService with given datastore instance.
@@ -107,6 +113,21 @@ func NewWriteThrough(bs blockstore.Blockstore, rem exchange.Interface) BlockServ
 	}
 }
 
+// NewCoded creates a BlockService that looks up the coded blocks of a parent
+// in idx before fetching them from the exchange.
+func NewCoded(bs blockstore.Blockstore, rem exchange.Interface, idx coding.Index) BlockService {
+	if rem == nil {
+		log.Debug("blockservice running in local (offline) mode.")
+	}
+
+	return &blockService{
+		blockstore: bs,
+		exchange:   rem,
+		checkFirst: true,
+		coded:      idx,
+	}
+}
+
 // Blockstore returns the blockstore behind this blockservice.
 func (s *blockService) Blockstore() blockstore.Blockstore {
 	return s.blockstore
@@ -117,12 +138,23 @@ func (s *blockService) Exchange() exchange.Interface {
 	return s.exchange
 }
 
+// CodedIndex returns the index of the coded blocks held in the blockstore,
+// or nil if there is none.
+func (s *blockService) CodedIndex() coding.Index {
+	return s.coded
+}
+
 // NewSession creates a new session that allows for
 // controlled exchange of wantlists to decrease the bandwidth overhead.
 // If the current exchange is a SessionExchange, a new exchange
 // session will be created. Otherwise, the current exchange will be used
 // directly.
 func NewSession(ctx context.Context, bs BlockService) *Session {
+	var coded coding.Index
+	if ci, ok := bs.(codedIndexer); ok {
+		coded = ci.CodedIndex()
+	}
+
 	exch := bs.Exchange()
 	if sessEx, ok := exch.(exchange.SessionExchange); ok {
 		return &Session{
@@ -130,12 +162,14 @@ func NewSession(ctx context.Context, bs BlockService) *Session {
 			ses:     nil,
 			sessEx:  sessEx,
 			bs:      bs.Blockstore(),
+			coded:   coded,
 		}
 	}
 	return &Session{
 		ses:     exch,
 		sessCtx: ctx,
 		bs:      bs.Blockstore(),
+		coded:   coded,
 	}
 }
 
@@ -345,39 +379,28 @@ func getBlocks(ctx context.Context, ks []cid.Cid, bs blockstore.Blockstore, fget
 	return out
 }
 
-func getBlocksC(ctx context.Context, parent cid.Cid, coding string, count int, bs blockstore.Blockstore, fget func() exchange.Fetcher) <-chan blocks.Block {
+// getBlocksC sends the coded blocks of parent listed in idx, then fetches
+// the ones missing to make count from the exchange.
+func getBlocksC(ctx context.Context, parent cid.Cid, coding string, count int, bs blockstore.Blockstore, idx coding.Index, fget func() exchange.Fetcher) <-chan blocks.Block {
 	out := make(chan blocks.Block)
-	//fmt.Println("Debug: bserv-getBlocksC")
 
 	go func() {
 		defer close(out)
 
 		if err := verifcid.ValidateCid(parent); err != nil {
-			log.Errorf("unsafe CID (%s) passed to blockService.GetBlocks: %s", parent, err)
+			log.Errorf("unsafe CID (%s) passed to blockService.GetBlocksC: %s", parent, err)
 			return
 		}
 
-		// MARS
 		remaining := count
-		switch coding {
-		case "nc":
-			f, err := os.Open(os.Getenv("IPFS_PATH") + "/nc/" + parent.String())
-			defer f.Close()
-
+		if idx != nil {
+			cids, err := idx.Coded(parent, coding)
 			if err != nil {
-				fmt.Println(err)
-				break
+				log.Errorf("listing the %s blocks of %s: %s", coding, parent, err)
 			}
-			s := bufio.NewScanner(f)
-			for s.Scan() {
-				id, err := cid.Parse(s.Text())
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				block, err := bs.Get(id)
+			for _, c := range cids {
+				block, err := bs.Get(c)
 				if err != nil {
-					fmt.Println(err)
 					continue
 				}
 
@@ -388,24 +411,22 @@ func getBlocksC(ctx context.Context, parent cid.Cid, coding string, count int, b
 					return
 				}
 			}
-		default:
-			fmt.Println("unsupported Coding format")
-			return
 		}
 
-		if remaining <= 0 {
+		if remaining <= 0 || fget == nil {
 			return
 		}
 
 		f := fget() // don't load exchange unless we have to
 		fc, ok := f.(exchange.FetcherC)
 		if !ok {
-			fmt.Println("Error: wrong Fetcher impl")
+			log.Debugf("exchange cannot fetch %s blocks", coding)
+			return
 		}
 
 		rblocks, err := fc.GetBlocksC(ctx, parent, coding, remaining)
 		if err != nil {
-			log.Debugf("Error with GetBlocks: %s", err)
+			log.Debugf("Error with GetBlocksC: %s", err)
 			return
 		}
 
@@ -421,10 +442,6 @@ func getBlocksC(ctx context.Context, parent cid.Cid, coding string, count int, b
 	return out
 }
 
-
-
-
-
 // DeleteBlock deletes a block in the blockservice from the datastore
 func (s *blockService) DeleteBlock(c cid.Cid) error {
 	err := s.blockstore.DeleteBlock(c)
@@ -445,6 +462,7 @@ type Session struct {
 	ses     exchange.Fetcher
 	sessEx  exchange.SessionExchange
 	sessCtx context.Context
+	coded   coding.Index
 	lk      sync.Mutex
 }
 
@@ -471,7 +489,7 @@ func (s *Session) GetBlocks(ctx context.Context, ks []cid.Cid) <-chan blocks.Blo
 
 func (s *Session) GetBlocksC(ctx context.Context, parent cid.Cid, coding string, count int) <-chan blocks.Block {
 	//fmt.Println("Debug: sess_bs-GetBlocksC")
-	return getBlocksC(ctx, parent, coding, count, s.bs, s.getSession) // hash security
+	return getBlocksC(ctx, parent, coding, count, s.bs, s.coded, s.getSession) // hash security
 }
 
 
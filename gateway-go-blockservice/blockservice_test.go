@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-block-format/coding"
+	cid "github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
 	dssync "github.com/ipfs/go-datastore/sync"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
@@ -94,6 +96,41 @@ func TestLazySessionInitialization(t *testing.T) {
 	}
 }
 
+func TestSessionGetBlocksCFromIndex(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	idx := coding.NewIndex(dssync.MutexWrap(ds.NewMapDatastore()))
+	bserv := NewCoded(bstore, offline.Exchange(bstore), idx)
+	bgen := butil.NewBlockGenerator()
+
+	parent := bgen.Next()
+	held := []blocks.Block{bgen.Next(), bgen.Next()}
+	for _, b := range held {
+		if err := bserv.AddBlock(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Indexed, but no longer in the blockstore.
+	gone := bgen.Next()
+	if err := idx.Add(parent.Cid(), "nc", held[0].Cid(), held[1].Cid(), gone.Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := cid.NewSet()
+	for b := range NewSession(ctx, bserv).GetBlocksC(ctx, parent.Cid(), "nc", 5) {
+		seen.Add(b.Cid())
+	}
+	if seen.Len() != 2 || !seen.Has(held[0].Cid()) || !seen.Has(held[1].Cid()) {
+		t.Fatalf("expected the 2 held coded blocks, got %d blocks", seen.Len())
+	}
+
+	for range NewSession(ctx, bserv).GetBlocksC(ctx, parent.Cid(), "rs", 5) {
+		t.Fatal("expected no blocks of another coding")
+	}
+}
+
 var _ blockstore.Blockstore = (*PutCountingBlockstore)(nil)
 
 type PutCountingBlockstore struct {
@@ -0,0 +1,152 @@
+package chunk
+
+import (
+	"errors"
+	"io"
+
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-block-format/coding/rlnc"
+)
+
+// DefaultGenerationSize is the number of source symbols of the generations
+// the nc splitter codes files into, unless told otherwise: with the default
+// packet size a generation holds about 32MiB, small enough to decode in
+// memory.
+const DefaultGenerationSize = 128
+
+var ErrNCGeneration = errors.New("nc chunker: the generation size must be at least 1 and leave room for the symbols in a packet")
+
+// A CodedSplitter is a Splitter whose chunks are the coded packets of its
+// input rather than pieces of it, one generation after the other. Importers
+// store the packets of every generation as coded blocks under a generation
+// parent, and the generation parents under a root carrying the header of
+// the file.
+type CodedSplitter interface {
+	Splitter
+
+	// Generation describes the generation of the last packet returned.
+	Generation() *coding.Header
+
+	// Header describes the coding of the whole input. It is only valid
+	// once NextBytes returned io.EOF.
+	Header() *coding.Header
+}
+
+// A KeyedSplitter is a CodedSplitter publishing checksums of its
+// generations, which can be keyed so that only the holders of the key can
+// verify packets against them, see rlnc.Encoder.KeyedChecksums.
+type KeyedSplitter interface {
+	CodedSplitter
+
+	// SetChecksumKey keys the checksums of the generations coded next.
+	SetChecksumKey(key []byte)
+}
+
+// ncSplitter codes its input with random linear network coding, one
+// generation of a fixed number of symbols at a time, and returns the source
+// symbols of every generation as systematic packets in their wire encoding.
+// Nodes holding them recode new packets on demand. The header of every
+// generation carries the checksums of its source symbols, which receivers
+// verify packets against. Only one generation is held in memory.
+type ncSplitter struct {
+	r              io.Reader
+	generationSize int
+	symbolSize     int
+	checksumKey    []byte
+
+	header     *coding.Header
+	generation *coding.Header
+	encoder    *rlnc.Encoder
+	left       int
+	err        error
+}
+
+// NewNCSplitter returns a Splitter reading r and producing one chunk per
+// packet of its network coding into packets of at most packetSize bytes,
+// in generations of generationSize symbols. The last generation only has
+// as many symbols as its data needs.
+func NewNCSplitter(r io.Reader, packetSize, generationSize int) KeyedSplitter {
+	return &ncSplitter{
+		r:              r,
+		generationSize: generationSize,
+		symbolSize:     packetSize - rlnc.HeaderSize - generationSize,
+	}
+}
+
+// NextBytes produces the next packet.
+func (ns *ncSplitter) NextBytes() ([]byte, error) {
+	if ns.err != nil {
+		return nil, ns.err
+	}
+	if ns.left == 0 {
+		if ns.err = ns.nextGeneration(); ns.err != nil {
+			return nil, ns.err
+		}
+	}
+	ns.left--
+	return ns.encoder.Encode().Marshal(), nil
+}
+
+// nextGeneration reads and encodes the data of the next generation.
+func (ns *ncSplitter) nextGeneration() error {
+	if ns.generationSize < 1 || ns.generationSize > rlnc.MaxSymbols || ns.symbolSize < 1 {
+		return ErrNCGeneration
+	}
+	if ns.header != nil && ns.header.Length%ns.header.GenerationCapacity() != 0 {
+		// The last generation was not full.
+		return io.EOF
+	}
+
+	data := make([]byte, ns.generationSize*ns.symbolSize)
+	n, err := io.ReadFull(ns.r, data)
+	switch err {
+	case nil, io.ErrUnexpectedEOF:
+	case io.EOF:
+		return io.EOF
+	default:
+		return err
+	}
+	data = data[:n]
+
+	symbols := (n + ns.symbolSize - 1) / ns.symbolSize
+	ns.encoder, err = rlnc.NewEncoder(data, symbols, ns.symbolSize, rlnc.Systematic())
+	if err != nil {
+		return err
+	}
+	ns.left = symbols
+
+	if ns.header == nil {
+		ns.header = &coding.Header{
+			Scheme:         "nc",
+			Field:          "gf256",
+			GenerationSize: ns.generationSize,
+			SymbolSize:     ns.symbolSize,
+			Generation:     -1,
+		}
+	}
+	ns.header.Length += uint64(n)
+	ns.generation = ns.header.ForGeneration(ns.header.Generations() - 1)
+	ns.generation.GenerationSize = symbols
+	ns.generation.Checksums = ns.encoder.KeyedChecksums(ns.checksumKey).Marshal()
+	return nil
+}
+
+// SetChecksumKey keys the checksums of the generations coded next.
+func (ns *ncSplitter) SetChecksumKey(key []byte) {
+	ns.checksumKey = key
+}
+
+// Generation describes the generation of the last packet returned.
+func (ns *ncSplitter) Generation() *coding.Header {
+	return ns.generation
+}
+
+// Header describes the generations the packets belong to.
+func (ns *ncSplitter) Header() *coding.Header {
+	return ns.header
+}
+
+// Reader returns the io.Reader associated to this Splitter.
+func (ns *ncSplitter) Reader() io.Reader {
+	return ns.r
+}
@@ -0,0 +1,126 @@
+package chunk
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ipfs/go-block-format/coding/rlnc"
+)
+
+func TestNCSplitter(t *testing.T) {
+	data := randBuf(t, 100000)
+	// Generations of 10 symbols of 4096-3-10 bytes, the third one partial.
+	splitter := NewNCSplitter(bytes.NewReader(data), 4096, 10)
+
+	var out []byte
+	var dec *rlnc.Decoder
+	var checksums *rlnc.Checksums
+	generation := -1
+	for {
+		chunk, err := splitter.NextBytes()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk) > 4096 {
+			t.Fatalf("packet of %d bytes larger than 4096", len(chunk))
+		}
+		p, err := rlnc.Unmarshal(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gh := splitter.Generation()
+		if gh.Generation != generation {
+			if dec != nil {
+				decoded, err := dec.Data()
+				if err != nil {
+					t.Fatal(err)
+				}
+				out = append(out, decoded...)
+			}
+			generation = gh.Generation
+			if dec, err = rlnc.NewDecoder(gh.GenerationSize, gh.SymbolSize); err != nil {
+				t.Fatal(err)
+			}
+			if checksums, err = rlnc.UnmarshalChecksums(gh.Checksums, gh.SymbolSize, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := checksums.Verify(p); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dec.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decoded, err := dec.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = append(out, decoded...)
+
+	h := splitter.Header()
+	if h.Scheme != "nc" || h.Length != uint64(len(data)) || h.GenerationSize != 10 || h.Generations() != 3 {
+		t.Fatalf("unexpected header %v", h)
+	}
+	if generation != 2 || splitter.Generation().GenerationSize != 5 {
+		t.Fatalf("expected a last generation of 5 symbols, got %v", splitter.Generation())
+	}
+	if !bytes.Equal(out[:h.Length], data) {
+		t.Fatal("decoded data differs")
+	}
+}
+
+func TestNCSplitterEdges(t *testing.T) {
+	// Data filling its generations exactly.
+	splitter := NewNCSplitter(bytes.NewReader(make([]byte, 2*4*1000)), rlnc.HeaderSize+4+1000, 4)
+	packets := 0
+	for {
+		if _, err := splitter.NextBytes(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		packets++
+	}
+	if packets != 8 || splitter.Header().Generations() != 2 {
+		t.Fatalf("expected 8 packets in 2 generations, got %d in %d", packets, splitter.Header().Generations())
+	}
+
+	if _, err := NewNCSplitter(bytes.NewReader(nil), 1024, 10).NextBytes(); err != io.EOF {
+		t.Fatalf("expected io.EOF for no data, got %v", err)
+	}
+	if _, err := NewNCSplitter(bytes.NewReader([]byte("data")), 1024, 1021).NextBytes(); err != ErrNCGeneration {
+		t.Fatalf("expected ErrNCGeneration, got %v", err)
+	}
+}
+
+func TestNCSplitterChecksumKey(t *testing.T) {
+	key := []byte("checksum key")
+	splitter := NewNCSplitter(bytes.NewReader(make([]byte, 4*1000)), rlnc.HeaderSize+4+1000, 4)
+	splitter.SetChecksumKey(key)
+	chunk, err := splitter.NextBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := rlnc.Unmarshal(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gh := splitter.Generation()
+	if _, err := rlnc.UnmarshalChecksums(gh.Checksums, gh.SymbolSize, nil); err != rlnc.ErrChecksumKey {
+		t.Fatalf("expected ErrChecksumKey, got %v", err)
+	}
+	checksums, err := rlnc.UnmarshalChecksums(gh.Checksums, gh.SymbolSize, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checksums.Verify(p); err != nil {
+		t.Fatal(err)
+	}
+}
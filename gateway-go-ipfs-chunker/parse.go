@@ -6,6 +6,9 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/ipfs/go-block-format/coding/rlnc"
 )
 
 const (
@@ -24,13 +27,76 @@ var (
 	ErrSizeMax  = fmt.Errorf("chunker parameters may not exceed the maximum chunk size of %d", ChunkSizeLimit)
 )
 
+// CodedParser returns the CodedSplitter of r given the parameters following
+// the name of its coding scheme in a chunker string: "4-2" for "rs-4-2", and
+// "" for a bare "nc".
+type CodedParser func(r io.Reader, params string) (CodedSplitter, error)
+
+var codedParsers = struct {
+	sync.RWMutex
+	m map[string]CodedParser
+}{m: map[string]CodedParser{
+	"nc": parseNCString,
+	"rs": parseRSString,
+}}
+
+// RegisterCoded makes the chunker strings "{name}" and "{name}-{params}"
+// return the CodedSplitters of parse, name being the coding scheme they code
+// with. It fails for names that are taken, by another coding scheme or by a
+// splitter that is not coded.
+func RegisterCoded(name string, parse CodedParser) error {
+	switch name {
+	case "", "default", "size", "rabin", "buzhash":
+		return fmt.Errorf("chunker name %q cannot be used by a coded chunker", name)
+	}
+	if strings.Contains(name, "-") {
+		return fmt.Errorf("chunker name %q cannot contain a dash", name)
+	}
+
+	codedParsers.Lock()
+	defer codedParsers.Unlock()
+	if _, ok := codedParsers.m[name]; ok {
+		return fmt.Errorf("coded chunker %q already registered", name)
+	}
+	codedParsers.m[name] = parse
+	return nil
+}
+
+// IsCoded reports whether chunker names a CodedSplitter.
+func IsCoded(chunker string) bool {
+	_, _, ok := codedParser(chunker)
+	return ok
+}
+
+// codedParser returns the parser of the coded chunker string chunker, and
+// the parameters to give it.
+func codedParser(chunker string) (CodedParser, string, bool) {
+	name, params := chunker, ""
+	if i := strings.IndexByte(chunker, '-'); i >= 0 {
+		name, params = chunker[:i], chunker[i+1:]
+	}
+	codedParsers.RLock()
+	defer codedParsers.RUnlock()
+	parse, ok := codedParsers.m[name]
+	return parse, params, ok
+}
+
 // FromString returns a Splitter depending on the given string:
 // it supports "default" (""), "size-{size}", "rabin", "rabin-{blocksize}",
-// "rabin-{min}-{avg}-{max}" and "buzhash".
+// "rabin-{min}-{avg}-{max}", "buzhash", "nc", "nc-{KiB}" or
+// "nc-{KiB}-{generation size}" to network code files into packets of 256KiB
+// or the given size, in generations of DefaultGenerationSize or the given
+// number of symbols, and "rs-{k}-{m}" to Reed-Solomon encode files into
+// generations of k data and m parity shards. The "nc" and "rs" splitters, and those of the
+// coding schemes given to RegisterCoded, are CodedSplitters.
 func FromString(r io.Reader, chunker string) (Splitter, error) {
+	if parse, params, ok := codedParser(chunker); ok {
+		return parse(r, params)
+	}
+
 	switch {
 	// MARS todo support different chunk sizes?
-	case chunker == "" || chunker == "default" || chunker == "nc":
+	case chunker == "" || chunker == "default":
 		return DefaultSplitter(r), nil
 
 	case strings.HasPrefix(chunker, "size-"):
@@ -45,19 +111,6 @@ func FromString(r io.Reader, chunker string) (Splitter, error) {
 		}
 		return NewSizeSplitter(r, int64(size)), nil
 
-	case strings.HasPrefix(chunker, "nc-"):
-		sizeStr := strings.Split(chunker, "-")[1]
-		size, err := strconv.Atoi(sizeStr)
-		size = size*1024
-		if err != nil {
-			return nil, err
-		} else if size <= 0 {
-			return nil, ErrSize
-		} else if size > ChunkSizeLimit {
-			return nil, ErrSizeMax
-		}
-		return NewSizeSplitter(r, int64(size)), nil
-
 	case strings.HasPrefix(chunker, "rabin"):
 		return parseRabinString(r, chunker)
 
@@ -69,6 +122,55 @@ func FromString(r io.Reader, chunker string) (Splitter, error) {
 	}
 }
 
+func parseNCString(r io.Reader, params string) (CodedSplitter, error) {
+	if params == "" {
+		return NewNCSplitter(r, int(DefaultBlockSize), DefaultGenerationSize), nil
+	}
+	parts := strings.Split(params, "-")
+	if len(parts) > 2 {
+		return nil, errors.New("incorrect format (expected 'nc-[KiB]' or 'nc-[KiB]-[generation size]')")
+	}
+	size, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	size *= 1024
+	if size <= 0 {
+		return nil, ErrSize
+	} else if size > ChunkSizeLimit {
+		return nil, ErrSizeMax
+	}
+	generationSize := DefaultGenerationSize
+	if len(parts) == 2 {
+		if generationSize, err = strconv.Atoi(parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	if generationSize < 1 || generationSize > size-rlnc.HeaderSize-1 {
+		return nil, ErrNCGeneration
+	}
+	return NewNCSplitter(r, size, generationSize), nil
+}
+
+func parseRSString(r io.Reader, params string) (CodedSplitter, error) {
+	parts := strings.Split(params, "-")
+	if len(parts) != 2 {
+		return nil, errors.New("incorrect format (expected 'rs-[k]-[m]')")
+	}
+	k, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if k < 1 || m < 0 || k+m > MaxRSShards {
+		return nil, ErrRSShards
+	}
+	return NewRSSplitter(r, k, m), nil
+}
+
 func parseRabinString(r io.Reader, chunker string) (Splitter, error) {
 	parts := strings.Split(chunker, "-")
 	switch len(parts) {
@@ -3,6 +3,7 @@ package chunk
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"testing"
 )
 
@@ -78,3 +79,73 @@ func TestParseSize(t *testing.T) {
 		t.Fatalf("Expected 'ErrSizeMax', got: %#v", err)
 	}
 }
+
+func TestParseNC(t *testing.T) {
+	r := bytes.NewReader(randBuf(t, 1000))
+
+	for _, s := range []string{"nc", "nc-64", "nc-64-16"} {
+		if _, err := FromString(r, s); err != nil {
+			t.Fatalf("%s: expected success, got: %#v", s, err)
+		}
+	}
+	if _, err := FromString(r, "nc-1-2000"); err != ErrNCGeneration {
+		t.Fatalf("Expected 'ErrNCGeneration', got: %#v", err)
+	}
+	if _, err := FromString(r, "nc-2048"); err != ErrSizeMax {
+		t.Fatalf("Expected 'ErrSizeMax', got: %#v", err)
+	}
+	if _, err := FromString(r, "nc-1-2-3"); err == nil {
+		t.Fatal("Expected an error for too many parameters")
+	}
+}
+
+func TestParseRS(t *testing.T) {
+	r := bytes.NewReader(randBuf(t, 1000))
+
+	for _, s := range []string{"rs-0-2", "rs-100-75"} {
+		if _, err := FromString(r, s); err != ErrRSShards {
+			t.Fatalf("%s: expected 'ErrRSShards', got: %#v", s, err)
+		}
+	}
+	if _, err := FromString(r, "rs-4"); err == nil {
+		t.Fatal("Expected an error for a missing parity shard count")
+	}
+	if _, err := FromString(r, "rs-10-4"); err != nil {
+		t.Fatalf("Expected success, got: %#v", err)
+	}
+}
+
+func TestRegisterCoded(t *testing.T) {
+	var gotParams string
+	parse := func(r io.Reader, params string) (CodedSplitter, error) {
+		gotParams = params
+		return NewRSSplitter(r, 4, 2), nil
+	}
+	if err := RegisterCoded("test", parse); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"test", "nc", "size", "default", "a-b"} {
+		if err := RegisterCoded(name, parse); err == nil {
+			t.Fatalf("%s: expected the name to be refused", name)
+		}
+	}
+
+	for _, s := range []string{"test", "test-1-2", "nc-64", "rs-4-2"} {
+		if !IsCoded(s) {
+			t.Fatalf("%s: expected a coded chunker", s)
+		}
+	}
+	for _, s := range []string{"", "size-1024", "rabin", "buzhash", "tests"} {
+		if IsCoded(s) {
+			t.Fatalf("%s: expected a chunker that is not coded", s)
+		}
+	}
+
+	spl, err := FromString(bytes.NewReader(randBuf(t, 1000)), "test-1-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := spl.(CodedSplitter); !ok || gotParams != "1-2" {
+		t.Fatalf("expected a coded splitter parsed from '1-2', got %q", gotParams)
+	}
+}
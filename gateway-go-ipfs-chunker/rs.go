@@ -0,0 +1,123 @@
+package chunk
+
+import (
+	"errors"
+	"io"
+
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-block-format/coding/rs"
+)
+
+// MaxRSShards is the largest number of shards, data and parity, the rs
+// splitter encodes a generation into. The shards of a generation are the
+// leaves of its parent node, which holds at most 174 links in the default
+// importer layout.
+const MaxRSShards = 174
+
+var ErrRSShards = errors.New("rs chunker needs at least one data shard and at most 174 shards")
+
+// rsSplitter encodes its input with a systematic Reed-Solomon code, one
+// generation of k data shards of a fixed size at a time, and returns the k
+// data shards, then the m parity shards of every generation, in their wire
+// encoding. The last generation has shards only as large as its data needs.
+// Only one generation is held in memory.
+type rsSplitter struct {
+	r         io.Reader
+	k, m      int
+	shardSize int
+
+	header     *coding.Header
+	generation *coding.Header
+	shards     []*rs.Shard
+	err        error
+}
+
+// NewRSSplitter returns a Splitter reading r and producing one chunk per
+// shard of its Reed-Solomon encoding, in generations of k data and m parity
+// shards of DefaultBlockSize bytes at most.
+func NewRSSplitter(r io.Reader, k, m int) CodedSplitter {
+	return &rsSplitter{
+		r:         r,
+		k:         k,
+		m:         m,
+		shardSize: int(DefaultBlockSize) - rs.HeaderSize,
+	}
+}
+
+// NextBytes produces the next shard.
+func (ss *rsSplitter) NextBytes() ([]byte, error) {
+	if ss.err != nil {
+		return nil, ss.err
+	}
+	if len(ss.shards) == 0 {
+		if ss.err = ss.nextGeneration(); ss.err != nil {
+			return nil, ss.err
+		}
+	}
+	b := ss.shards[0].Marshal()
+	ss.shards = ss.shards[1:]
+	return b, nil
+}
+
+// nextGeneration reads and encodes the data of the next generation.
+func (ss *rsSplitter) nextGeneration() error {
+	if ss.k < 1 || ss.m < 0 || ss.k+ss.m > MaxRSShards {
+		return ErrRSShards
+	}
+	if ss.header != nil && ss.header.Length%ss.header.GenerationCapacity() != 0 {
+		// The last generation was not full.
+		return io.EOF
+	}
+
+	data := make([]byte, ss.k*ss.shardSize)
+	n, err := io.ReadFull(ss.r, data)
+	switch err {
+	case nil, io.ErrUnexpectedEOF:
+	case io.EOF:
+		return io.EOF
+	default:
+		return err
+	}
+	data = data[:n]
+
+	enc, err := rs.NewEncoder(data, ss.k, ss.m)
+	if err != nil {
+		return err
+	}
+	ss.shards = enc.Shards()
+
+	if ss.header == nil {
+		ss.header = &coding.Header{
+			Scheme:         "rs",
+			Field:          "gf256",
+			GenerationSize: ss.k,
+			SymbolSize:     ss.shardSize,
+			Redundancy:     ss.m,
+			Generation:     -1,
+		}
+		if n < ss.k*ss.shardSize {
+			// The file is a single generation, with shards as small
+			// as its data allows.
+			ss.header.SymbolSize = enc.ShardSize()
+		}
+	}
+	ss.header.Length += uint64(n)
+	ss.generation = ss.header.ForGeneration(ss.header.Generations() - 1)
+	ss.generation.SymbolSize = enc.ShardSize()
+	return nil
+}
+
+// Generation describes the generation of the last shard returned.
+func (ss *rsSplitter) Generation() *coding.Header {
+	return ss.generation
+}
+
+// Header describes the generations the shards belong to.
+func (ss *rsSplitter) Header() *coding.Header {
+	return ss.header
+}
+
+// Reader returns the io.Reader associated to this Splitter.
+func (ss *rsSplitter) Reader() io.Reader {
+	return ss.r
+}
@@ -0,0 +1,122 @@
+package chunk
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ipfs/go-block-format/coding/rs"
+)
+
+func TestRSSplitter(t *testing.T) {
+	data := randBuf(t, 10000)
+	splitter := NewRSSplitter(bytes.NewReader(data), 4, 2)
+
+	var shards []*rs.Shard
+	for {
+		chunk, err := splitter.NextBytes()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := rs.Unmarshal(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Index != len(shards) {
+			t.Fatalf("expected shard %d, got %d", len(shards), s.Index)
+		}
+		shards = append(shards, s)
+	}
+	if len(shards) != 6 {
+		t.Fatalf("expected 6 shards, got %d", len(shards))
+	}
+	h := splitter.Header()
+	if h.Scheme != "rs" || h.GenerationSize != 4 || h.Redundancy != 2 || h.SymbolSize != len(shards[0].Data) || h.Length != uint64(len(data)) {
+		t.Fatalf("unexpected header %v", h)
+	}
+
+	// The parity shards and two data shards decode the data.
+	dec, err := rs.NewDecoderFor(shards[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range shards[2:] {
+		dec.Add(s)
+	}
+	out, err := dec.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("decoded data differs")
+	}
+}
+
+func TestRSSplitterGenerations(t *testing.T) {
+	// Two full generations of 2 data shards, and a smaller last one.
+	shardSize := int(DefaultBlockSize) - rs.HeaderSize
+	data := randBuf(t, 4*shardSize+1000)
+	splitter := NewRSSplitter(bytes.NewReader(data), 2, 1)
+
+	var out []byte
+	var dec *rs.Decoder
+	generation := -1
+	for {
+		chunk, err := splitter.NextBytes()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := rs.Unmarshal(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := splitter.Generation()
+		if g.Generation != generation {
+			if g.Generation != generation+1 || s.Index != 0 {
+				t.Fatalf("expected generation %d to start, got shard %d of generation %d", generation+1, s.Index, g.Generation)
+			}
+			generation = g.Generation
+			if dec, err = rs.NewDecoder(g.GenerationSize, g.Redundancy, g.SymbolSize, g.Length); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Decode every generation from the parity shard and the
+		// second data shard.
+		if s.Index == 0 {
+			continue
+		}
+		if _, err := dec.Add(s); err != nil {
+			t.Fatal(err)
+		}
+		if dec.IsComplete() {
+			d, err := dec.Data()
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, d...)
+		}
+	}
+	if generation != 2 {
+		t.Fatalf("expected 3 generations, got %d", generation+1)
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("decoded data differs")
+	}
+	h := splitter.Header()
+	if h.SymbolSize != shardSize || h.Generations() != 3 || h.Length != uint64(len(data)) {
+		t.Fatalf("unexpected header %v", h)
+	}
+	if g := splitter.Generation(); g.SymbolSize != 500 || g.Length != 1000 {
+		t.Fatalf("unexpected last generation %v", g)
+	}
+
+	if _, err := NewRSSplitter(bytes.NewReader(nil), 2, 1).NextBytes(); err != io.EOF {
+		t.Fatalf("expected io.EOF for no data, got %v", err)
+	}
+}
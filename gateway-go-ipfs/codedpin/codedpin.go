@@ -0,0 +1,189 @@
+// Package codedpin keeps the coded pins of go-ipfs.
+//
+// A coded pin keeps a number of coded blocks of a parent, like the "nc"
+// packets of a network coded file, instead of its DAG: the parent and the
+// coded blocks the coded index lists for it are kept by garbage collection.
+package codedpin
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+var (
+	// ErrNotPinned is returned when removing a coded pin that does not
+	// exist.
+	ErrNotPinned = errors.New("not pinned with a coding")
+
+	// ErrInvalidPin is returned for coded pins without a coding, without
+	// coded blocks to keep, or with a negative redundancy.
+	ErrInvalidPin = errors.New("coded pins need a coding, a positive count and a non-negative redundancy")
+)
+
+// Prefix is where coded pins are kept in the datastore.
+var Prefix = ds.NewKey("/local/pins/coded")
+
+// Pin is a coded pin: Count coded blocks of Parent with Coding are kept, and
+// Redundancy times more on top of them.
+type Pin struct {
+	Parent     cid.Cid
+	Coding     string
+	Count      int
+	Redundancy float64
+}
+
+// Keep returns the number of coded blocks the pin keeps: garbage collection
+// reclaims the ones beyond.
+func (p Pin) Keep() int {
+	return int(math.Ceil(float64(p.Count) * (1 + p.Redundancy)))
+}
+
+// Pinner keeps coded pins in a datastore, one entry per parent and coding.
+// Callers serialize changes with the blockstore pin lock, like for the
+// regular pinner.
+type Pinner struct {
+	d ds.Datastore
+}
+
+// New returns a Pinner keeping its pins in d.
+func New(d ds.Datastore) *Pinner {
+	return &Pinner{d: d}
+}
+
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func pinKey(parent cid.Cid, coding string) ds.Key {
+	return Prefix.ChildString(coding).ChildString(keyEncoding.EncodeToString(parent.Bytes()))
+}
+
+// Pin pins count coded blocks of parent with coding, plus redundancy times
+// more, replacing an existing coded pin.
+func (p *Pinner) Pin(parent cid.Cid, coding string, count int, redundancy float64) error {
+	if coding == "" || strings.Contains(coding, "/") || count <= 0 || !(redundancy >= 0) {
+		return ErrInvalidPin
+	}
+	return p.d.Put(pinKey(parent, coding), encodePin(count, redundancy))
+}
+
+// encodePin returns the value of a coded pin: the count as a uvarint, then
+// the redundancy as a big endian float64 when there is one. Pins written
+// before redundancies were kept have none.
+func encodePin(count int, redundancy float64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64+8)
+	n := binary.PutUvarint(buf, uint64(count))
+	if redundancy == 0 {
+		return buf[:n]
+	}
+	binary.BigEndian.PutUint64(buf[n:], math.Float64bits(redundancy))
+	return buf[:n+8]
+}
+
+// decodePin decodes the value of a coded pin written by encodePin.
+func decodePin(v []byte) (count int, redundancy float64, err error) {
+	c, n := binary.Uvarint(v)
+	if n <= 0 {
+		return 0, 0, ErrInvalidPin
+	}
+	switch len(v) - n {
+	case 0:
+	case 8:
+		redundancy = math.Float64frombits(binary.BigEndian.Uint64(v[n:]))
+	default:
+		return 0, 0, ErrInvalidPin
+	}
+	return int(c), redundancy, nil
+}
+
+// Unpin removes the coded pin of parent with coding.
+func (p *Pinner) Unpin(parent cid.Cid, coding string) error {
+	k := pinKey(parent, coding)
+	has, err := p.d.Has(k)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return ErrNotPinned
+	}
+	return p.d.Delete(k)
+}
+
+// UnpinAll removes the coded pins of parent, whatever their coding. It
+// returns whether there were any.
+func (p *Pinner) UnpinAll(parent cid.Cid) (bool, error) {
+	pins, err := p.Pins()
+	if err != nil {
+		return false, err
+	}
+	removed := false
+	for _, pin := range pins {
+		if !pin.Parent.Equals(parent) {
+			continue
+		}
+		if err := p.d.Delete(pinKey(pin.Parent, pin.Coding)); err != nil {
+			return removed, err
+		}
+		removed = true
+	}
+	return removed, nil
+}
+
+// Get returns the coded pin of parent with coding, and whether there is one.
+func (p *Pinner) Get(parent cid.Cid, coding string) (Pin, bool, error) {
+	v, err := p.d.Get(pinKey(parent, coding))
+	if err == ds.ErrNotFound {
+		return Pin{}, false, nil
+	} else if err != nil {
+		return Pin{}, false, err
+	}
+	count, redundancy, err := decodePin(v)
+	if err != nil {
+		return Pin{}, false, err
+	}
+	return Pin{Parent: parent, Coding: coding, Count: count, Redundancy: redundancy}, true, nil
+}
+
+// Pins returns all the coded pins.
+func (p *Pinner) Pins() ([]Pin, error) {
+	res, err := p.d.Query(dsq.Query{Prefix: Prefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make([]Pin, 0, len(entries))
+	for _, e := range entries {
+		k := ds.RawKey(e.Key)
+		if !k.Parent().Parent().Equal(Prefix) {
+			continue
+		}
+		b, err := keyEncoding.DecodeString(k.BaseNamespace())
+		if err != nil {
+			continue
+		}
+		parent, err := cid.Cast(b)
+		if err != nil {
+			continue
+		}
+		count, redundancy, err := decodePin(e.Value)
+		if err != nil {
+			continue
+		}
+		pins = append(pins, Pin{
+			Parent:     parent,
+			Coding:     k.Parent().BaseNamespace(),
+			Count:      count,
+			Redundancy: redundancy,
+		})
+	}
+	return pins, nil
+}
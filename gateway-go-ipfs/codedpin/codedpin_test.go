@@ -0,0 +1,74 @@
+package codedpin
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func TestPinner(t *testing.T) {
+	p := New(dssync.MutexWrap(ds.NewMapDatastore()))
+	a := blocks.NewBlock([]byte("a")).Cid()
+	b := blocks.NewBlock([]byte("b")).Cid()
+
+	if err := p.Pin(a, "nc", 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(a, "rs", 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Pin(b, "nc", 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	// Pinning again replaces the count and the redundancy.
+	if err := p.Pin(b, "nc", 5, 0.2); err != nil {
+		t.Fatal(err)
+	}
+
+	pin, ok, err := p.Get(b, "nc")
+	if err != nil || !ok || pin.Count != 5 || pin.Redundancy != 0.2 {
+		t.Fatalf("expected a coded pin of 5 blocks and 0.2 redundancy, got %v, %t, %v", pin, ok, err)
+	}
+	if pin.Keep() != 6 {
+		t.Fatalf("expected the pin to keep 6 blocks, got %d", pin.Keep())
+	}
+	if _, ok, _ := p.Get(b, "rs"); ok {
+		t.Fatal("expected no rs pin")
+	}
+
+	pins, err := p.Pins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 3 {
+		t.Fatalf("expected 3 coded pins, got %d", len(pins))
+	}
+	for _, pin := range pins {
+		got, ok, _ := p.Get(pin.Parent, pin.Coding)
+		if !ok || got != pin {
+			t.Fatalf("listed %v, got %v", pin, got)
+		}
+	}
+
+	if err := p.Unpin(b, "rs"); err != ErrNotPinned {
+		t.Fatalf("expected ErrNotPinned, got %v", err)
+	}
+	if removed, err := p.UnpinAll(a); err != nil || !removed {
+		t.Fatalf("expected the pins of a to be removed, got %t, %v", removed, err)
+	}
+	if pins, _ := p.Pins(); len(pins) != 1 || pins[0].Parent != b {
+		t.Fatalf("expected only the pin of b, got %v", pins)
+	}
+	if err := p.Unpin(b, "nc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Pin(a, "nc", 0, 0); err != ErrInvalidPin {
+		t.Fatalf("expected ErrInvalidPin, got %v", err)
+	}
+	if err := p.Pin(a, "nc", 1, -1); err != ErrInvalidPin {
+		t.Fatalf("expected ErrInvalidPin, got %v", err)
+	}
+}
@@ -6,12 +6,14 @@ import (
 
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	e "github.com/ipfs/go-ipfs/core/commands/e"
+	"github.com/ipfs/go-ipfs/exchange/composite"
 
 	humanize "github.com/dustin/go-humanize"
 	bitswap "github.com/ipfs/go-bitswap"
 	decision "github.com/ipfs/go-bitswap/decision"
 	cidutil "github.com/ipfs/go-cidutil"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 )
 
@@ -33,6 +35,17 @@ const (
 	peerOptionName = "peer"
 )
 
+// bitswapOf returns the bitswap instance behind exch, which may wrap it in a
+// composite exchange.
+func bitswapOf(exch exchange.Interface) (*bitswap.Bitswap, bool) {
+	if c, ok := exch.(*composite.Exchange); ok {
+		bs, ok := c.Source("bitswap").(*bitswap.Bitswap)
+		return bs, ok
+	}
+	bs, ok := exch.(*bitswap.Bitswap)
+	return bs, ok
+}
+
 var showWantlistCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Show blocks currently on the wantlist.",
@@ -53,7 +66,7 @@ Print out all blocks currently on the bitswap wantlist for the local peer.`,
 			return ErrNotOnline
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		bs, ok := bitswapOf(nd.Exchange)
 		if !ok {
 			return e.TypeErr(bs, nd.Exchange)
 		}
@@ -112,7 +125,7 @@ var bitswapStatCmd = &cmds.Command{
 			return cmds.Errorf(cmds.ErrClient, ErrNotOnline.Error())
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		bs, ok := bitswapOf(nd.Exchange)
 		if !ok {
 			return e.TypeErr(bs, nd.Exchange)
 		}
@@ -190,7 +203,7 @@ prints the ledger associated with a given peer.
 			return ErrNotOnline
 		}
 
-		bs, ok := nd.Exchange.(*bitswap.Bitswap)
+		bs, ok := bitswapOf(nd.Exchange)
 		if !ok {
 			return e.TypeErr(bs, nd.Exchange)
 		}
@@ -155,6 +155,18 @@ func TestCommands(t *testing.T) {
 		"/name/pubsub/subs",
 		"/name/pubsub/cancel",
 		"/name/resolve",
+		"/nc",
+		"/nc/encode",
+		"/nc/ls",
+		"/nc/repair",
+		"/nc/stat",
+		"/ndn",
+		"/ndn/face",
+		"/ndn/face/list",
+		"/ndn/fetch",
+		"/ndn/name",
+		"/ndn/stats",
+		"/ndn/status",
 		"/object",
 		"/object/data",
 		"/object/diff",
@@ -0,0 +1,370 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/core/coreunix"
+
+	"github.com/ipfs/go-block-format/coding"
+	// Register the built-in coding schemes.
+	_ "github.com/ipfs/go-block-format/coding/rlnc"
+	_ "github.com/ipfs/go-block-format/coding/rs"
+	cid "github.com/ipfs/go-cid"
+	chunk "github.com/ipfs/go-ipfs-chunker"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	files "github.com/ipfs/go-ipfs-files"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-unixfs"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+)
+
+const (
+	ncCodingOptionName  = "coding"
+	ncChunkerOptionName = "chunker"
+	ncPinOptionName     = "pin"
+)
+
+var NCCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect and repair coded content.",
+		ShortDescription: `
+'ipfs nc' is a set of commands to inspect the coded blocks held for network
+coded ("nc") and Reed-Solomon ("rs") files, or with the coding schemes of
+plugins, to code files already added, and to repair coded files missing some
+of their blocks.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"ls":     ncLsCmd,
+		"stat":   ncStatCmd,
+		"encode": ncEncodeCmd,
+		"repair": ncRepairCmd,
+	},
+}
+
+// NCGeneration describes the coded blocks held for a parent, usually the
+// parent of a generation of a coded file.
+type NCGeneration struct {
+	Parent string
+	Coding string
+	// Generation is -1 when the parent is not held.
+	Generation int
+	Symbols    int
+	Held       int
+	// Rank is -1 when it cannot be computed without the parent.
+	Rank      int
+	Needed    int
+	Decodable bool
+	Repaired  int `json:",omitempty"`
+}
+
+func ncGenerations(gens []coreunix.CodedGeneration) []NCGeneration {
+	out := make([]NCGeneration, len(gens))
+	for i := range gens {
+		g := &gens[i]
+		out[i] = NCGeneration{
+			Parent:     g.Parent.String(),
+			Coding:     g.Coding,
+			Generation: g.Generation,
+			Symbols:    g.Symbols,
+			Held:       g.Held,
+			Rank:       g.Rank,
+			Needed:     g.Needed(),
+			Decodable:  g.Decodable(),
+			Repaired:   g.Repaired,
+		}
+	}
+	return out
+}
+
+// ncRank prints a rank, which is unknown without the parent.
+func ncRank(rank int) string {
+	if rank < 0 {
+		return "?"
+	}
+	return fmt.Sprint(rank)
+}
+
+type NCParents struct {
+	Parents []NCGeneration
+}
+
+var ncLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the parents with coded blocks, and their ranks.",
+		ShortDescription: `
+'ipfs nc ls' lists the parents the coded index holds coded blocks of, with
+how many of them are in the blockstore and how many are innovative. Only
+local blocks are read.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(ncCodingOptionName, "Only list the parents with blocks of this coding."),
+	},
+	Type: NCParents{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		codings := coding.Names()
+		if cd, ok := req.Options[ncCodingOptionName].(string); ok && cd != "" {
+			codings = []string{cd}
+		}
+
+		gens, err := coreunix.ListCoded(req.Context, nd.DAG, nd.Blockstore, nd.CodedIndex, codings...)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &NCParents{Parents: ncGenerations(gens)})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *NCParents) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "PARENT\tCODING\tGENERATION\tHELD\tRANK\tSYMBOLS")
+			for _, g := range out.Parents {
+				generation := "-"
+				if g.Generation >= 0 {
+					generation = fmt.Sprint(g.Generation)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%d\n", g.Parent, g.Coding, generation, g.Held, ncRank(g.Rank), g.Symbols)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+type NCStat struct {
+	Cid            string
+	Scheme         string
+	Field          string
+	GenerationSize int
+	SymbolSize     int
+	Redundancy     int
+	Length         uint64
+	Generations    []NCGeneration
+	Decodable      bool
+}
+
+var ncStatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the coding of a coded file and what is held of it.",
+		ShortDescription: `
+'ipfs nc stat' prints the coding scheme and the generation sizes of a coded
+file, or of a single generation given its parent, and for every generation
+how many packets are held, how many more are needed and whether it can be
+decoded from the local blocks.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path of the coded file or generation parent."),
+	},
+	Type: NCStat{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		root, err := ncResolve(req.Context, env, req)
+		if err != nil {
+			return err
+		}
+
+		h, gens, err := coreunix.StatCoded(req.Context, nd.DAG, nd.Blockstore, nd.CodedIndex, root)
+		if err != nil {
+			return err
+		}
+		st := &NCStat{
+			Cid:            root.Cid().String(),
+			Scheme:         h.Scheme,
+			Field:          h.Field,
+			GenerationSize: h.GenerationSize,
+			SymbolSize:     h.SymbolSize,
+			Redundancy:     h.Redundancy,
+			Length:         h.Length,
+			Generations:    ncGenerations(gens),
+			Decodable:      true,
+		}
+		for _, g := range st.Generations {
+			st.Decodable = st.Decodable && g.Decodable
+		}
+		return cmds.EmitOnce(res, st)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, st *NCStat) error {
+			fmt.Fprintf(w, "%s\n", st.Cid)
+			fmt.Fprintf(w, "\tscheme: %s (%s)\n", st.Scheme, st.Field)
+			fmt.Fprintf(w, "\tlength: %d\n", st.Length)
+			fmt.Fprintf(w, "\tgeneration size: %d symbols of %d bytes\n", st.GenerationSize, st.SymbolSize)
+			if st.Redundancy > 0 {
+				fmt.Fprintf(w, "\tredundancy: %d\n", st.Redundancy)
+			}
+			fmt.Fprintf(w, "\tdecodable: %t\n", st.Decodable)
+
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "GENERATION\tPARENT\tSYMBOLS\tHELD\tRANK\tNEEDED")
+			for _, g := range st.Generations {
+				fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\t%d\n", g.Generation, g.Parent, g.Symbols, g.Held, ncRank(g.Rank), g.Needed)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+type NCEncodeOutput struct {
+	Cid string
+}
+
+var ncEncodeCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Code a file already added.",
+		ShortDescription: `
+'ipfs nc encode' reads a file from IPFS and adds it again as a coded file,
+with the coded chunker given by --chunker: "nc", "nc-[KiB]" or
+"nc-[KiB]-[generation size]" for network coding, "rs-[k]-[m]" for
+Reed-Solomon, or the chunker of a coding plugin. It prints the CID of the coded file, which is pinned unless
+--pin=false is given.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path of the file to code."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(ncChunkerOptionName, "s", "Coded chunker to use.").WithDefault("nc"),
+		cmds.BoolOption(ncPinOptionName, "Pin the coded file.").WithDefault(true),
+	},
+	Type: NCEncodeOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		chunker, _ := req.Options[ncChunkerOptionName].(string)
+		if !chunk.IsCoded(chunker) {
+			return fmt.Errorf("%q is not a coded chunker", chunker)
+		}
+		pin, _ := req.Options[ncPinOptionName].(bool)
+
+		p := path.New(req.Arguments[0])
+		root, err := api.ResolveNode(req.Context, p)
+		if err != nil {
+			return err
+		}
+		if _, err := unixfs.CodedHeader(root); err == nil {
+			return fmt.Errorf("%s is already coded", root.Cid())
+		}
+		f, err := api.Unixfs().Get(req.Context, p)
+		if err != nil {
+			return err
+		}
+		file, ok := f.(files.File)
+		if !ok {
+			return errors.New("only files can be coded")
+		}
+		defer file.Close()
+
+		coded, err := api.Unixfs().Add(req.Context, file,
+			options.Unixfs.Chunker(chunker),
+			options.Unixfs.Pin(pin),
+		)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &NCEncodeOutput{Cid: coded.Cid().String()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *NCEncodeOutput) error {
+			_, err := fmt.Fprintln(w, out.Cid)
+			return err
+		}),
+	},
+}
+
+type NCRepair struct {
+	Cid         string
+	Generations []NCGeneration
+	Repaired    int
+}
+
+var ncRepairCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Repair a coded file.",
+		ShortDescription: `
+'ipfs nc repair' fetches the packets of a coded file, or of a single
+generation given its parent, until every generation can be decoded, then
+re-derives the blocks the generation parents link to that are missing from
+the blockstore.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path of the coded file or generation parent."),
+	},
+	Type: NCRepair{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		root, err := ncResolve(req.Context, env, req)
+		if err != nil {
+			return err
+		}
+		fetch := func(ctx context.Context, parent cid.Cid, cd string, count int) <-chan *ipld.NodeOption {
+			return api.ResolveNodeC(ctx, path.IpfsPath(parent), cd, count)
+		}
+
+		// Keep garbage collection away from the blocks being repaired.
+		defer nd.Blockstore.PinLock().Unlock()
+
+		gens, err := coreunix.RepairCoded(req.Context, nd.DAG, nd.Blockstore, nd.CodedIndex, fetch, root)
+		out := &NCRepair{Cid: root.Cid().String(), Generations: ncGenerations(gens)}
+		for _, g := range gens {
+			out.Repaired += g.Repaired
+		}
+		if err != nil {
+			return fmt.Errorf("repaired %d blocks of %s: %s", out.Repaired, out.Cid, err)
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *NCRepair) error {
+			for _, g := range out.Generations {
+				if g.Repaired > 0 {
+					fmt.Fprintf(w, "generation %d: re-derived %d blocks\n", g.Generation, g.Repaired)
+				}
+			}
+			_, err := fmt.Fprintf(w, "repaired %d blocks of %s\n", out.Repaired, out.Cid)
+			return err
+		}),
+	},
+}
+
+// ncResolve resolves the path argument of req to a coded file root or a
+// generation parent.
+func ncResolve(ctx context.Context, env cmds.Environment, req *cmds.Request) (ipld.Node, error) {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := api.ResolveNode(ctx, path.New(req.Arguments[0]))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := unixfs.CodedHeader(nd); err == coding.ErrNotHeader {
+		return nil, fmt.Errorf("%s is not coded", nd.Cid())
+	} else if err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
@@ -0,0 +1,371 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ipfs/go-ipfs/core"
+	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
+	"github.com/ipfs/go-ipfs/core/node"
+	"github.com/ipfs/go-ipfs/exchange/composite"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/ipfs/go-bitswap/ndn"
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// ErrNDNDisabled is returned by the commands that need the NDN forwarder when
+// the node does not connect to it.
+var ErrNDNDisabled = errors.New("NDN is disabled, set Ndn.Enabled to true in the config")
+
+// ndnProbeTimeout bounds how long 'ipfs ndn status' waits for the forwarder.
+const ndnProbeTimeout = 2 * time.Second
+
+var NDNCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Interact with the NDN forwarder.",
+		ShortDescription: `
+'ipfs ndn' is a set of commands to inspect and debug the bridge between IPFS
+and the local NDN forwarder configured in the Ndn section of the config.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"status": ndnStatusCmd,
+		"fetch":  ndnFetchCmd,
+		"name":   ndnNameCmd,
+		"stats":  ndnStatsCmd,
+		"face":   ndnFaceCmd,
+	},
+}
+
+// ndnNode returns the node if it is online and connects to the forwarder.
+func ndnNode(env cmds.Environment) (*core.IpfsNode, error) {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+	if !nd.IsOnline {
+		return nil, ErrNotOnline
+	}
+	if nd.NDN == nil {
+		return nil, ErrNDNDisabled
+	}
+	return nd, nil
+}
+
+type NDNStatus struct {
+	Enabled  bool
+	Socket   string
+	Prefix   string
+	Producer bool
+	Exchange string
+	// Online is false when the node runs offline, in which case the
+	// fields below are not set.
+	Online bool
+	// Faces is the number of faces of the forwarder, or -1 if it could
+	// not be reached.
+	Faces          int
+	ForwarderError string `json:",omitempty"`
+	Registered     bool
+}
+
+var ndnStatusCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the NDN configuration and whether the forwarder is reachable.",
+		ShortDescription: `
+'ipfs ndn status' prints the NDN settings in use and, when the daemon is
+running, whether the forwarder answers and the producer registered its prefix.
+`,
+	},
+	Type: NDNStatus{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		cfg, err := node.ReadNDNConfig(nd.Repo)
+		if err != nil {
+			return err
+		}
+
+		st := &NDNStatus{
+			Enabled:  cfg.Enabled,
+			Socket:   cfg.Socket,
+			Prefix:   cfg.Prefix,
+			Producer: cfg.Producer,
+			Exchange: cfg.Exchange,
+			Online:   nd.IsOnline,
+		}
+		if nd.IsOnline && nd.NDN != nil {
+			ctx, cancel := context.WithTimeout(req.Context, ndnProbeTimeout)
+			defer cancel()
+			faces, err := nd.NDN.ListFaces(ctx)
+			if err != nil {
+				st.Faces = -1
+				st.ForwarderError = err.Error()
+			} else {
+				st.Faces = len(faces)
+			}
+			st.Registered = nd.NDNProducer != nil && nd.NDNProducer.Registered()
+		}
+		return cmds.EmitOnce(res, st)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, st *NDNStatus) error {
+			exchange := st.Exchange
+			if exchange == "" {
+				exchange = "bitswap only"
+			}
+			fmt.Fprintln(w, "ndn status")
+			fmt.Fprintf(w, "\tenabled: %t\n", st.Enabled)
+			fmt.Fprintf(w, "\tsocket: %s\n", st.Socket)
+			fmt.Fprintf(w, "\tprefix: %s\n", st.Prefix)
+			fmt.Fprintf(w, "\texchange: %s\n", exchange)
+			switch {
+			case !st.Enabled:
+			case !st.Online:
+				fmt.Fprintln(w, "\tforwarder: unknown, the node is offline")
+			case st.Faces < 0:
+				fmt.Fprintf(w, "\tforwarder: unreachable (%s)\n", st.ForwarderError)
+			default:
+				fmt.Fprintf(w, "\tforwarder: reachable, %d faces\n", st.Faces)
+			}
+			switch {
+			case !st.Producer:
+				fmt.Fprintln(w, "\tproducer: disabled")
+			case st.Online && st.Enabled:
+				fmt.Fprintf(w, "\tproducer: registered %t\n", st.Registered)
+			default:
+				fmt.Fprintln(w, "\tproducer: enabled")
+			}
+			return nil
+		}),
+	},
+}
+
+var ndnFetchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Fetch a block from NDN.",
+		ShortDescription: `
+'ipfs ndn fetch' retrieves a block from the NDN network, bypassing the
+blockstore and bitswap, checks it against its CID and writes it to stdout.
+The block is not stored.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", true, false, "The CID of the block to fetch.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := ndnNode(env)
+		if err != nil {
+			return err
+		}
+		c, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		data, err := nd.NDN.FetchSegmented(req.Context, nd.NDNNames.Name(c))
+		if err != nil {
+			return fmt.Errorf("fetching %s: %s", nd.NDNNames.Name(c), err)
+		}
+		chk, err := c.Prefix().Sum(data)
+		if err != nil {
+			return err
+		}
+		if !chk.Equals(c) {
+			return fmt.Errorf("content fetched from NDN does not match %s", c)
+		}
+		return res.Emit(bytes.NewReader(data))
+	},
+}
+
+type NDNName struct {
+	Cid  string
+	Name string
+}
+
+var ndnNameCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Map a CID to its NDN name, or an NDN name to its CID.",
+		ShortDescription: `
+'ipfs ndn name' prints the NDN name a block is published and fetched under,
+using the prefix configured in Ndn.Prefix. Given an NDN name instead of a
+CID, it prints the CID of the block.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid-or-name", true, false, "The CID or NDN name to map."),
+	},
+	Type: NDNName{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		names := nd.NDNNames
+		if names == nil {
+			// Offline nodes do not set up NDN.
+			cfg, err := node.ReadNDNConfig(nd.Repo)
+			if err != nil {
+				return err
+			}
+			if names, err = node.NDNNames(cfg); err != nil {
+				return err
+			}
+		}
+
+		arg := req.Arguments[0]
+		if c, err := cid.Decode(arg); err == nil {
+			return cmds.EmitOnce(res, &NDNName{Cid: c.String(), Name: names.Name(c).String()})
+		}
+		n, err := ndn.ParseName(arg)
+		if err != nil {
+			return fmt.Errorf("%q is neither a CID nor an NDN name", arg)
+		}
+		c, err := names.CID(n)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &NDNName{Cid: c.String(), Name: n.String()})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *NDNName) error {
+			// Print the other side of the mapping.
+			if _, err := cid.Decode(req.Arguments[0]); err == nil {
+				_, err := fmt.Fprintln(w, out.Name)
+				return err
+			}
+			_, err := fmt.Fprintln(w, out.Cid)
+			return err
+		}),
+	},
+}
+
+type NDNStats struct {
+	Consumer ndn.ConsumerStats
+	// Throughput is the average goodput in bytes per second.
+	Throughput float64
+	// Sources are the networks the exchange fetches from, when the
+	// blockservice uses NDN directly.
+	Sources []composite.SourceStats `json:",omitempty"`
+}
+
+var ndnStatsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show statistics of the NDN consumer.",
+		ShortDescription: `
+'ipfs ndn stats' prints the Interest counters and congestion control state of
+the consumer fetching blocks from NDN and, with Ndn.Exchange set, how each
+network performed for the blockservice.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(bitswapHumanOptionName, "Print sizes in human readable format (e.g., 1K 234M 2G)"),
+	},
+	Type: NDNStats{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := ndnNode(env)
+		if err != nil {
+			return err
+		}
+		st := nd.NDN.Stats()
+		out := &NDNStats{Consumer: st, Throughput: st.Throughput()}
+		if exch, ok := nd.Exchange.(*composite.Exchange); ok {
+			out.Sources = exch.Stats()
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *NDNStats) error {
+			human, _ := req.Options[bitswapHumanOptionName].(bool)
+			c := s.Consumer
+
+			fmt.Fprintln(w, "ndn consumer")
+			fmt.Fprintf(w, "\tinterests sent: %d\n", c.InterestsSent)
+			fmt.Fprintf(w, "\tretransmissions: %d\n", c.Retransmissions)
+			fmt.Fprintf(w, "\ttimeouts: %d\n", c.Timeouts)
+			fmt.Fprintf(w, "\tnacks: %d\n", c.Nacks)
+			fmt.Fprintf(w, "\tdata received: %d\n", c.DataReceived)
+			if human {
+				fmt.Fprintf(w, "\tbytes received: %s\n", humanize.Bytes(c.BytesReceived))
+				fmt.Fprintf(w, "\tthroughput: %s/s\n", humanize.Bytes(uint64(s.Throughput)))
+			} else {
+				fmt.Fprintf(w, "\tbytes received: %d\n", c.BytesReceived)
+				fmt.Fprintf(w, "\tthroughput: %.0f B/s\n", s.Throughput)
+			}
+			fmt.Fprintf(w, "\tin flight: %d\n", c.InFlight)
+			fmt.Fprintf(w, "\twindow: %.1f (ssthresh %.1f)\n", c.Window, c.SlowStartThreshold)
+			fmt.Fprintf(w, "\trtt: srtt %s, rttvar %s, min %s, rto %s\n", c.SRTT, c.RTTVar, c.MinRTT, c.RTO)
+
+			for _, src := range s.Sources {
+				fmt.Fprintf(w, "exchange source %s\n", src.Name)
+				fmt.Fprintf(w, "\trequests: %d\n", src.Requests)
+				fmt.Fprintf(w, "\tfailures: %d\n", src.Failures)
+				fmt.Fprintf(w, "\tcancels: %d\n", src.Cancels)
+				fmt.Fprintf(w, "\tblocks: %d\n", src.Blocks)
+				fmt.Fprintf(w, "\tduplicates: %d\n", src.Duplicates)
+				fmt.Fprintf(w, "\tmean latency: %s\n", src.MeanLatency())
+			}
+			return nil
+		}),
+	},
+}
+
+var ndnFaceCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect the faces of the NDN forwarder.",
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"list": ndnFaceListCmd,
+	},
+}
+
+type NDNFaces struct {
+	Faces []ndn.FaceStatus
+}
+
+var ndnFaceListCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the faces of the NDN forwarder.",
+		ShortDescription: `
+'ipfs ndn face list' retrieves the faces dataset of the local forwarder, like
+'nfdc face list' does.
+`,
+	},
+	Type: NDNFaces{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := ndnNode(env)
+		if err != nil {
+			return err
+		}
+		faces, err := nd.NDN.ListFaces(req.Context)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &NDNFaces{Faces: faces})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *NDNFaces) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "FACEID\tREMOTE\tLOCAL\tSCOPE\tIN INTERESTS\tIN DATA\tOUT INTERESTS\tOUT DATA")
+			for _, f := range out.Faces {
+				scope := "non-local"
+				if f.Scope == ndn.FaceScopeLocal {
+					scope = "local"
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\n", f.FaceID, f.URI, f.LocalURI, scope,
+					f.NInInterests, f.NInData, f.NOutInterests, f.NOutData)
+			}
+			return tw.Flush()
+		}),
+	},
+}
@@ -60,6 +60,15 @@ var addPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "Pin objects to local storage.",
 		ShortDescription: "Stores an IPFS object(s) from a given path locally to disk.",
+		LongDescription: `
+Stores an IPFS object(s) from a given path locally to disk.
+
+With --coding, a coded file is pinned with coded blocks of the given coding
+scheme instead of its DAG: count*(1+red) coded blocks of every generation of
+the file are fetched, and the file root, the generation parents and --count
+coded blocks of each are kept by garbage collection. --count defaults to the
+generation size recorded in the header of the file.
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -69,7 +78,7 @@ var addPinCmd = &cmds.Command{
 		cmds.BoolOption(pinRecursiveOptionName, "r", "Recursively pin the object linked to by the specified object(s).").WithDefault(true),
 		cmds.BoolOption(pinProgressOptionName, "Show progress"),
 		cmds.StringOption(pinCodingOptionName, "s", "associated coding scheme (nc, rs)"),
-		cmds.IntOption(pinCountOptionName, "n", "total desired amount of coded packets"),
+		cmds.IntOption(pinCountOptionName, "n", "coded packets to keep per generation, the generation size by default"),
 		cmds.FloatOption(pinRedundancyOptionName, "f", "redundancy factor for coded packets"),
 	},
 	Type: AddPinOutput{},
@@ -153,6 +162,12 @@ var addPinCmd = &cmds.Command{
 			} else {
 				pintype = "directly"
 			}
+			if coding, _ := req.Options[pinCodingOptionName].(string); coding != "" {
+				pintype = fmt.Sprintf("with %s blocks", coding)
+				if count, _ := req.Options[pinCountOptionName].(int); count > 0 {
+					pintype = fmt.Sprintf("with %d %s blocks per generation", count, coding)
+				}
+			}
 
 			for _, k := range out.Pins {
 				fmt.Fprintf(w, "pinned %s %s\n", k, pintype)
@@ -303,6 +318,7 @@ Valid values are:
     * "recursive": pin that specific object, and indirectly pin all its
     	descendants
     * "indirect": pinned indirectly by an ancestor (like a refcount)
+    * "coded": pin coded blocks of that specific object
     * "all"
 
 With arguments, the command fails if any of the arguments is not a pinned
@@ -330,7 +346,7 @@ Example:
 		cmds.StringArg("ipfs-path", false, true, "Path to object(s) to be listed."),
 	},
 	Options: []cmds.Option{
-		cmds.StringOption(pinTypeOptionName, "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\".").WithDefault("all"),
+		cmds.StringOption(pinTypeOptionName, "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", \"coded\", or \"all\".").WithDefault("all"),
 		cmds.BoolOption(pinQuietOptionName, "q", "Write just hashes of objects."),
 		cmds.BoolOption(pinStreamOptionName, "s", "Enable streaming of pins as they are discovered."),
 	},
@@ -344,9 +360,9 @@ Example:
 		stream, _ := req.Options[pinStreamOptionName].(bool)
 
 		switch typeStr {
-		case "all", "direct", "indirect", "recursive":
+		case "all", "direct", "indirect", "recursive", "coded":
 		default:
-			err = fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, all}", typeStr)
+			err = fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, coded, all}", typeStr)
 			return err
 		}
 
@@ -356,7 +372,11 @@ Example:
 		if !stream {
 			emit = func(v interface{}) error {
 				obj := v.(*PinLsOutputWrapper)
-				lgcList[obj.PinLsObject.Cid] = PinLsType{Type: obj.PinLsObject.Type}
+				lgcList[obj.PinLsObject.Cid] = PinLsType{
+					Type:   obj.PinLsObject.Type,
+					Coding: obj.PinLsObject.Coding,
+					Count:  obj.PinLsObject.Count,
+				}
 				return nil
 			}
 		}
@@ -399,7 +419,7 @@ Example:
 				if quiet {
 					fmt.Fprintf(w, "%s\n", out.PinLsObject.Cid)
 				} else {
-					fmt.Fprintf(w, "%s %s\n", out.PinLsObject.Cid, out.PinLsObject.Type)
+					fmt.Fprintf(w, "%s %s\n", out.PinLsObject.Cid, pinLsTypeString(out.PinLsObject.Type, out.PinLsObject.Coding, out.PinLsObject.Count))
 				}
 				return nil
 			}
@@ -408,7 +428,7 @@ Example:
 				if quiet {
 					fmt.Fprintf(w, "%s\n", k)
 				} else {
-					fmt.Fprintf(w, "%s %s\n", k, v.Type)
+					fmt.Fprintf(w, "%s %s\n", k, pinLsTypeString(v.Type, v.Coding, v.Count))
 				}
 			}
 
@@ -432,13 +452,26 @@ type PinLsList struct {
 
 // PinLsType contains the type of a pin
 type PinLsType struct {
-	Type string
+	Type   string
+	Coding string `json:",omitempty"`
+	Count  int    `json:",omitempty"`
 }
 
 // PinLsObject contains the description of a pin
 type PinLsObject struct {
-	Cid  string `json:",omitempty"`
-	Type string `json:",omitempty"`
+	Cid    string `json:",omitempty"`
+	Type   string `json:",omitempty"`
+	Coding string `json:",omitempty"`
+	Count  int    `json:",omitempty"`
+}
+
+// pinLsTypeString describes a pin type, with the coded blocks kept by coded
+// pins.
+func pinLsTypeString(pinType, coding string, count int) string {
+	if coding == "" {
+		return pinType
+	}
+	return fmt.Sprintf("%s (%d %s blocks)", pinType, count, coding)
 }
 
 func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit func(value interface{}) error) error {
@@ -448,14 +481,17 @@ func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fu
 	}
 
 	switch typeStr {
-	case "all", "direct", "indirect", "recursive":
+	case "all", "direct", "indirect", "recursive", "coded":
 	default:
-		return fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, all}", typeStr)
+		return fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, coded, all}", typeStr)
 	}
 
-	opt, err := options.Pin.IsPinned.Type(typeStr)
-	if err != nil {
-		panic("unhandled pin type")
+	coded := make(map[cid.Cid]coreiface.CodedPin)
+	if typeStr == "coded" || typeStr == "all" {
+		coded, err = codedPins(req.Context, api)
+		if err != nil {
+			return err
+		}
 	}
 
 	for _, p := range req.Arguments {
@@ -464,6 +500,28 @@ func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fu
 			return err
 		}
 
+		if cp, ok := coded[rp.Cid()]; ok {
+			err = emit(&PinLsOutputWrapper{
+				PinLsObject: PinLsObject{
+					Type:   cp.Type(),
+					Cid:    enc.Encode(rp.Cid()),
+					Coding: cp.Coding(),
+					Count:  cp.Count(),
+				},
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if typeStr == "coded" {
+			return fmt.Errorf("path '%s' is not pinned with a coding", p)
+		}
+
+		opt, err := options.Pin.IsPinned.Type(typeStr)
+		if err != nil {
+			panic("unhandled pin type")
+		}
 		pinType, pinned, err := api.Pin().IsPinned(req.Context, rp, opt)
 		if err != nil {
 			return err
@@ -493,6 +551,29 @@ func pinLsKeys(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fu
 	return nil
 }
 
+// codedPins returns the coded pins by pinned CID.
+func codedPins(ctx context.Context, api coreiface.CoreAPI) (map[cid.Cid]coreiface.CodedPin, error) {
+	opt, err := options.Pin.Ls.Type("coded")
+	if err != nil {
+		return nil, err
+	}
+	pins, err := api.Pin().Ls(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	coded := make(map[cid.Cid]coreiface.CodedPin)
+	for p := range pins {
+		if err := p.Err(); err != nil {
+			return nil, err
+		}
+		if cp, ok := p.(coreiface.CodedPin); ok {
+			coded[cp.Path().Cid()] = cp
+		}
+	}
+	return coded, nil
+}
+
 func pinLsAll(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit func(value interface{}) error) error {
 	enc, err := cmdenv.GetCidEncoder(req)
 	if err != nil {
@@ -500,9 +581,9 @@ func pinLsAll(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fun
 	}
 
 	switch typeStr {
-	case "all", "direct", "indirect", "recursive":
+	case "all", "direct", "indirect", "recursive", "coded":
 	default:
-		err = fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, all}", typeStr)
+		err = fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, coded, all}", typeStr)
 		return err
 	}
 
@@ -520,12 +601,15 @@ func pinLsAll(req *cmds.Request, typeStr string, api coreiface.CoreAPI, emit fun
 		if err := p.Err(); err != nil {
 			return err
 		}
-		err = emit(&PinLsOutputWrapper{
-			PinLsObject: PinLsObject{
-				Type: p.Type(),
-				Cid:  enc.Encode(p.Path().Cid()),
-			},
-		})
+		obj := PinLsObject{
+			Type: p.Type(),
+			Cid:  enc.Encode(p.Path().Cid()),
+		}
+		if cp, ok := p.(coreiface.CodedPin); ok {
+			obj.Coding = cp.Coding()
+			obj.Count = cp.Count()
+		}
+		err = emit(&PinLsOutputWrapper{PinLsObject: obj})
 		if err != nil {
 			return err
 		}
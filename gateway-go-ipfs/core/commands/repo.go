@@ -14,6 +14,7 @@ import (
 	humanize "github.com/dustin/go-humanize"
 	cmdenv "github.com/ipfs/go-ipfs/core/commands/cmdenv"
 	corerepo "github.com/ipfs/go-ipfs/core/corerepo"
+	gc "github.com/ipfs/go-ipfs/gc"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
 	cid "github.com/ipfs/go-cid"
@@ -44,8 +45,10 @@ var RepoCmd = &cmds.Command{
 
 // GcResult is the result returned by "repo gc" command.
 type GcResult struct {
-	Key   cid.Cid
-	Error string `json:",omitempty"`
+	Key cid.Cid
+	// CodedBytes is the size of Key when it was a coded block.
+	CodedBytes uint64 `json:",omitempty"`
+	Error      string `json:",omitempty"`
 }
 
 const (
@@ -60,6 +63,10 @@ var repoGcCmd = &cmds.Command{
 'ipfs repo gc' is a plumbing command that will sweep the local
 set of stored objects and remove ones that are not pinned in
 order to reclaim hard disk space.
+
+Coded blocks are kept for coded pins and for the pinned parents they
+are coded from, as many as the pin or the coded header asks for. The
+excess ones are removed, and listed with their size.
 `,
 	},
 	Options: []cmds.Option{
@@ -85,7 +92,7 @@ order to reclaim hard disk space.
 					}
 					errs = true
 				} else {
-					if err := re.Emit(&GcResult{Key: res.KeyRemoved}); err != nil {
+					if err := re.Emit(&GcResult{Key: res.KeyRemoved, CodedBytes: res.CodedBytes}); err != nil {
 						return err
 					}
 				}
@@ -94,11 +101,11 @@ order to reclaim hard disk space.
 				return errors.New("encountered errors during gc run")
 			}
 		} else {
-			err := corerepo.CollectResult(req.Context, gcOutChan, func(k cid.Cid) {
+			err := corerepo.CollectResult(req.Context, gcOutChan, func(res gc.Result) {
 				// Nothing to do with this error, really. This
 				// most likely means that the client is gone but
 				// we still need to let the GC finish.
-				_ = re.Emit(&GcResult{Key: k})
+				_ = re.Emit(&GcResult{Key: res.KeyRemoved, CodedBytes: res.CodedBytes})
 			})
 			if err != nil {
 				return err
@@ -122,6 +129,10 @@ order to reclaim hard disk space.
 				prefix = ""
 			}
 
+			if gcr.CodedBytes > 0 && !quiet {
+				_, err := fmt.Fprintf(w, "%s%s (coded, %s)\n", prefix, gcr.Key, humanize.Bytes(gcr.CodedBytes))
+				return err
+			}
 			_, err := fmt.Fprintf(w, "%s%s\n", prefix, gcr.Key)
 			return err
 		}),
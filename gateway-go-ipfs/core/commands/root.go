@@ -44,6 +44,7 @@ DATA STRUCTURE COMMANDS
   files         Interact with files as if they were a unix filesystem
   block         Interact with raw blocks in the datastore
   cid           Convert and discover properties of CIDs
+  nc            Inspect and repair coded content
 
 ADVANCED COMMANDS
   daemon        Start a long-running daemon process
@@ -67,6 +68,7 @@ NETWORK COMMANDS
   diag          Print diagnostics
   bitswap       Inspect bitswap state
   pubsub        Send and receive messages via pubsub
+  ndn           Interact with the NDN forwarder
 
 TOOL COMMANDS
   config        Manage configuration
@@ -137,6 +139,8 @@ var rootSubcommands = map[string]*cmds.Command{
 	"ls":        LsCmd,
 	"mount":     MountCmd,
 	"name":      name.NameCmd,
+	"nc":        NCCmd,
+	"ndn":       NDNCmd,
 	"object":    ocmd.ObjectCmd,
 	"pin":       pin.PinCmd,
 	"ping":      PingCmd,
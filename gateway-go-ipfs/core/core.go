@@ -16,6 +16,10 @@ import (
 	"github.com/ipfs/go-filestore"
 	"github.com/ipfs/go-ipfs-pinner"
 
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	"github.com/ipfs/go-bitswap/ndn/producer"
+	"github.com/ipfs/go-block-format/coding"
 	bserv "github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-graphsync"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
@@ -42,6 +46,7 @@ import (
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
 
+	"github.com/ipfs/go-ipfs/codedpin"
 	"github.com/ipfs/go-ipfs/core/bootstrap"
 	"github.com/ipfs/go-ipfs/core/node"
 	"github.com/ipfs/go-ipfs/core/node/libp2p"
@@ -65,6 +70,7 @@ type IpfsNode struct {
 
 	// Local node
 	Pinning         pin.Pinner             // the pinning manager
+	CodedPins       *codedpin.Pinner       // the coded pins
 	Mounts          Mounts                 `optional:"true"` // current mount state, if any.
 	PrivateKey      ic.PrivKey             `optional:"true"` // the local node's private Key
 	PNetFingerprint libp2p.PNetFingerprint `optional:"true"` // fingerprint of private network
@@ -74,6 +80,8 @@ type IpfsNode struct {
 	Blockstore      bstore.GCBlockstore       // the block store (lower level)
 	Filestore       *filestore.Filestore      `optional:"true"` // the filestore blockstore
 	BaseBlocks      node.BaseBlocks           // the raw blockstore, no filestore wrapping
+	CodedIndex      coding.Index              // the coded blocks held in the blockstore
+	ChecksumKey     node.ChecksumKey          // the key of the checksums of coded generations, if any
 	GCLocker        bstore.GCLocker           // the locker used to protect the blockstore during gc
 	Blocks          bserv.BlockService        // the block service, get/add blocks.
 	DAG             ipld.DAGService           // the merkle dag service, get/add objects.
@@ -104,6 +112,10 @@ type IpfsNode struct {
 
 	P2P *p2p.P2P `optional:"true"`
 
+	NDN         *ndn.Consumer      `optional:"true"` // fetches blocks from the local NDN forwarder, nil when disabled
+	NDNNames    *cidname.Mapper    `optional:"true"` // maps CIDs to NDN names
+	NDNProducer *producer.Producer `optional:"true"` // serves the blockstore to NDN consumers
+
 	Process goprocess.Process
 	ctx     context.Context
 
@@ -18,6 +18,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/ipfs/go-block-format/coding"
 	bserv "github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-exchange-interface"
@@ -38,6 +39,7 @@ import (
 	record "github.com/libp2p/go-libp2p-record"
 	madns "github.com/multiformats/go-multiaddr-dns"
 
+	"github.com/ipfs/go-ipfs/codedpin"
 	"github.com/ipfs/go-ipfs/core"
 	"github.com/ipfs/go-ipfs/core/node"
 	"github.com/ipfs/go-ipfs/repo"
@@ -54,6 +56,10 @@ type CoreAPI struct {
 	blockstore blockstore.GCBlockstore
 	baseBlocks blockstore.Blockstore
 	pinning    pin.Pinner
+	codedPins  *codedpin.Pinner
+	codedIndex coding.Index
+	// checksumKey keys the checksums of the coded files added
+	checksumKey []byte
 
 	blocks bserv.BlockService
 	dag    ipld.DAGService
@@ -162,10 +168,13 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 		identity:   n.Identity,
 		privateKey: n.PrivateKey,
 
-		repo:       n.Repo,
-		blockstore: n.Blockstore,
-		baseBlocks: n.BaseBlocks,
-		pinning:    n.Pinning,
+		repo:        n.Repo,
+		blockstore:  n.Blockstore,
+		baseBlocks:  n.BaseBlocks,
+		pinning:     n.Pinning,
+		codedPins:   n.CodedPins,
+		codedIndex:  n.CodedIndex,
+		checksumKey: n.ChecksumKey,
 
 		blocks: n.Blocks,
 		dag:    n.DAG,
@@ -233,7 +242,7 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 
 	if settings.Offline || !settings.FetchBlocks {
 		subApi.exchange = offlinexch.Exchange(subApi.blockstore)
-		subApi.blocks = bserv.New(subApi.blockstore, subApi.exchange)
+		subApi.blocks = bserv.NewCoded(subApi.blockstore, subApi.exchange, subApi.codedIndex)
 		subApi.dag = dag.NewDAGService(subApi.blocks)
 	}
 
@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	gopath "path"
-	"reflect"
 
 	"github.com/ipfs/go-namesys/resolve"
 
 	"github.com/ipfs/go-cid"
 	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
 	ipfspath "github.com/ipfs/go-path"
 	"github.com/ipfs/go-path/resolver"
 	uio "github.com/ipfs/go-unixfs/io"
@@ -36,19 +36,16 @@ func (api *CoreAPI) ResolveNodeC(ctx context.Context, p path.Path, coding string
 	rp, err := api.ResolvePath(ctx, p)
 	if err != nil {
 		ch := make(chan *ipld.NodeOption, 1)
-		ch <- &ipld.NodeOption{nil, err}
+		ch <- &ipld.NodeOption{Err: err}
+		close(ch)
 		return ch
 	}
 
-    fmt.Println(reflect.TypeOf(api.dag))
-
-	ds, ok := api.dag.(ipld.NodeGetterC)
-	if !ok {
-		ch := make(chan *ipld.NodeOption, 1)
-		ch <- &ipld.NodeOption{nil, fmt.Errorf("DAG assertion failed")}
-		return ch
+	if ds, ok := api.dag.(ipld.NodeGetterC); ok {
+		return ds.GetManyC(ctx, rp.Cid(), coding, count)
 	}
-	return ds.GetManyC(ctx, rp.Cid(), coding, count)
+	// Sessions fetch coded blocks.
+	return dag.FetchCodedCh(ctx, rp.Cid(), coding, count, api.dag)
 }
 
 
@@ -1,18 +1,17 @@
 package coreapi
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"os"
-	"reflect"
-	"time"
+	"math"
 
 	bserv "github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
 	offline "github.com/ipfs/go-ipfs-exchange-offline"
 	pin "github.com/ipfs/go-ipfs-pinner"
+	"github.com/ipfs/go-ipfs/codedpin"
 	"github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	caopts "github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/interface-go-ipfs-core/path"
@@ -26,7 +25,10 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 		return err
 	}
 
-	
+	if settings.Coding != "" {
+		return api.addCoded(ctx, p, settings)
+	}
+
 	dagNode, err := api.core().ResolveNode(ctx, p)
 	if err != nil {
 		return fmt.Errorf("pin: %s", err)
@@ -46,6 +48,211 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 	return api.pinning.Flush(ctx)
 }
 
+// addCoded pins the coded file at p with coded blocks instead of its DAG.
+// The header of the file tells its generations: count*(1+redundancy) coded
+// blocks of every generation parent are fetched, and count of them are
+// kept, count being the number of source symbols of the generation unless
+// given. The root is pinned directly, to keep the header.
+func (api *PinAPI) addCoded(ctx context.Context, p path.Path, settings *caopts.PinAddSettings) error {
+	if settings.Count < 0 {
+		return fmt.Errorf("pin: a coded pin needs a positive count, got %d", settings.Count)
+	}
+	if settings.Redundancy < 0 {
+		return fmt.Errorf("pin: negative redundancy %g", settings.Redundancy)
+	}
+
+	root, err := api.core().ResolveNode(ctx, p)
+	if err != nil {
+		return fmt.Errorf("pin: %s", err)
+	}
+	header, err := unixfs.CodedHeader(root)
+	if err != nil || header.Generation >= 0 {
+		return fmt.Errorf("pin: %s is not the root of a coded file", root.Cid())
+	}
+	if header.Scheme != settings.Coding {
+		return fmt.Errorf("pin: %s is coded with %s, not %s", root.Cid(), header.Scheme, settings.Coding)
+	}
+
+	defer api.blockstore.PinLock().Unlock()
+
+	// Every generation is fetched before anything is pinned, so that a
+	// generation failing leaves no coded pins without the root pin.
+	gens := make([]codedGeneration, 0, len(root.Links()))
+	for _, link := range root.Links() {
+		g, err := api.fetchGeneration(ctx, link.Cid, settings)
+		if err != nil {
+			return err
+		}
+		gens = append(gens, g)
+	}
+
+	if err := api.pinGenerations(gens, settings); err != nil {
+		return err
+	}
+	api.pinning.PinWithMode(root.Cid(), pin.Direct)
+	if err := api.pinning.Flush(ctx); err != nil {
+		api.pinning.RemovePinWithMode(root.Cid(), pin.Direct)
+		return api.unpinGenerations(gens, settings.Coding, err)
+	}
+
+	for _, g := range gens {
+		if err := api.provider.Provide(g.parent); err != nil {
+			return err
+		}
+	}
+	return api.provider.Provide(root.Cid())
+}
+
+// codedGeneration is a generation parent fetched for a coded pin, with the
+// coded blocks it is pinned with and the coded pin it replaces.
+type codedGeneration struct {
+	parent cid.Cid
+	coded  []cid.Cid
+	count  int
+
+	prev   codedpin.Pin
+	pinned bool
+}
+
+// fetchGeneration fetches the coded blocks of a generation parent to pin
+// it with. Without a count, a generation is pinned with as many blocks as it
+// has source symbols, the last generation of a file usually having fewer.
+func (api *PinAPI) fetchGeneration(ctx context.Context, parent cid.Cid, settings *caopts.PinAddSettings) (codedGeneration, error) {
+	g := codedGeneration{parent: parent}
+	nd, err := api.dag.Get(ctx, parent)
+	if err != nil {
+		return g, fmt.Errorf("pin: %s", err)
+	}
+	header, err := unixfs.CodedHeader(nd)
+	if err != nil || header.Generation < 0 {
+		return g, fmt.Errorf("pin: %s is not a generation parent", parent)
+	}
+	coding := settings.Coding
+	g.count = settings.Count
+	if g.count == 0 {
+		g.count = header.GenerationSize
+	}
+	want := int(math.Ceil(float64(g.count) * (1 + settings.Redundancy)))
+
+	// The coded blocks already held are sent first.
+	for opt := range api.core().ResolveNodeC(ctx, path.IpfsPath(parent), coding, want) {
+		if opt.Err != nil {
+			return g, fmt.Errorf("pin: %s", opt.Err)
+		}
+		g.coded = append(g.coded, opt.Node.Cid())
+	}
+	if len(g.coded) < g.count {
+		return g, fmt.Errorf("pin: got %d of the %d %s blocks of %s", len(g.coded), g.count, coding, parent)
+	}
+
+	g.prev, g.pinned, err = api.codedPins.Get(parent, coding)
+	return g, err
+}
+
+// pinGenerations indexes the coded blocks of the fetched generations and
+// pins them. The coded pins written are restored when one fails.
+func (api *PinAPI) pinGenerations(gens []codedGeneration, settings *caopts.PinAddSettings) error {
+	for i, g := range gens {
+		err := api.codedIndex.Add(g.parent, settings.Coding, g.coded...)
+		if err == nil {
+			err = api.codedPins.Pin(g.parent, settings.Coding, g.count, settings.Redundancy)
+		}
+		if err != nil {
+			return api.unpinGenerations(gens[:i], settings.Coding, fmt.Errorf("pin: %s", err))
+		}
+	}
+	return nil
+}
+
+// unpinGenerations puts back the coded pins of the generations as they were
+// before they were pinned, after pinning them failed with cause. It returns
+// cause, along with the first error restoring them.
+func (api *PinAPI) unpinGenerations(gens []codedGeneration, coding string, cause error) error {
+	for _, g := range gens {
+		var err error
+		if g.pinned {
+			err = api.codedPins.Pin(g.parent, coding, g.prev.Count, g.prev.Redundancy)
+		} else {
+			err = api.codedPins.Unpin(g.parent, coding)
+		}
+		if err != nil {
+			return fmt.Errorf("%s, and restoring the coded pin of %s: %s", cause, g.parent, err)
+		}
+	}
+	return cause
+}
+
+// codedParents returns the generation parents of the coded file c when we
+// hold its root, the parents its coded pins are kept under.
+func (api *PinAPI) codedParents(ctx context.Context, c cid.Cid) ([]cid.Cid, error) {
+	if has, err := api.blockstore.Has(c); err != nil || !has {
+		return nil, err
+	}
+	nd, err := api.dag.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if h, err := unixfs.CodedHeader(nd); err != nil || h.Generation >= 0 {
+		return nil, nil
+	}
+	var parents []cid.Cid
+	for _, l := range nd.Links() {
+		parents = append(parents, l.Cid)
+	}
+	return parents, nil
+}
+
+// codedPinsByRoot returns the coded pins of the coded files whose root is
+// pinned, under their root: the coded pins are kept under the generation
+// parents. Their count is the one of the first generation. Coded pins of
+// parents whose root is not pinned are returned as they are.
+func (api *PinAPI) codedPinsByRoot(ctx context.Context) ([]codedpin.Pin, error) {
+	pins, err := api.codedPins.Pins()
+	if err != nil || len(pins) == 0 {
+		return pins, err
+	}
+	roots, err := api.pinning.DirectKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byParent := make(map[string]map[cid.Cid]codedpin.Pin)
+	for _, p := range pins {
+		if byParent[p.Coding] == nil {
+			byParent[p.Coding] = make(map[cid.Cid]codedpin.Pin)
+		}
+		byParent[p.Coding][p.Parent] = p
+	}
+
+	var res []codedpin.Pin
+	for _, root := range roots {
+		parents, err := api.codedParents(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+		if len(parents) == 0 {
+			continue
+		}
+		for _, ps := range byParent {
+			first, ok := ps[parents[0]]
+			if !ok {
+				continue
+			}
+			for _, parent := range parents {
+				delete(ps, parent)
+			}
+			first.Parent = root
+			res = append(res, first)
+		}
+	}
+	for _, ps := range byParent {
+		for _, p := range ps {
+			res = append(res, p)
+		}
+	}
+	return res, nil
+}
+
 func (api *PinAPI) Ls(ctx context.Context, opts ...caopts.PinLsOption) (<-chan coreiface.Pin, error) {
 	settings, err := caopts.PinLsOptions(opts...)
 	if err != nil {
@@ -53,9 +260,9 @@ func (api *PinAPI) Ls(ctx context.Context, opts ...caopts.PinLsOption) (<-chan c
 	}
 
 	switch settings.Type {
-	case "all", "direct", "indirect", "recursive":
+	case "all", "direct", "indirect", "recursive", "coded":
 	default:
-		return nil, fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, all}", settings.Type)
+		return nil, fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, coded, all}", settings.Type)
 	}
 
 	return api.pinLsAll(ctx, settings.Type), nil
@@ -96,6 +303,29 @@ func (api *PinAPI) Rm(ctx context.Context, p path.Path, opts ...caopts.PinRmOpti
 	// to take a lock to prevent a concurrent garbage collection
 	defer api.blockstore.PinLock().Unlock()
 
+	parents, err := api.codedParents(ctx, rp.Cid())
+	if err != nil {
+		return err
+	}
+	var coded bool
+	for _, c := range append(parents, rp.Cid()) {
+		unpinned, err := api.codedPins.UnpinAll(c)
+		if err != nil {
+			return err
+		}
+		coded = coded || unpinned
+	}
+	if coded {
+		// Only coded pins may be left.
+		reason, pinned, err := api.pinning.IsPinned(ctx, rp.Cid())
+		if err != nil {
+			return err
+		}
+		if !pinned || (reason != "direct" && reason != "recursive") {
+			return nil
+		}
+	}
+
 	if err = api.pinning.Unpin(ctx, rp.Cid(), settings.Recursive); err != nil {
 		return err
 	}
@@ -211,6 +441,20 @@ type pinInfo struct {
 	err     error
 }
 
+type codedPinInfo struct {
+	pinInfo
+	coding string
+	count  int
+}
+
+func (p *codedPinInfo) Coding() string {
+	return p.coding
+}
+
+func (p *codedPinInfo) Count() int {
+	return p.count
+}
+
 func (p *pinInfo) Path() path.Resolved {
 	return p.path
 }
@@ -259,6 +503,26 @@ func (api *PinAPI) pinLsAll(ctx context.Context, typeStr string) <-chan coreifac
 
 		var dkeys, rkeys []cid.Cid
 		var err error
+		if typeStr == "coded" || typeStr == "all" {
+			pins, err := api.codedPinsByRoot(ctx)
+			if err != nil {
+				out <- &pinInfo{err: err}
+				return
+			}
+			for _, p := range pins {
+				// The root is reported as a coded pin, not a direct one.
+				keys.Visit(p.Parent)
+				select {
+				case out <- &codedPinInfo{
+					pinInfo: pinInfo{pinType: "coded", path: path.IpldPath(p.Parent)},
+					coding:  p.Coding,
+					count:   p.Count,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
 		if typeStr == "recursive" || typeStr == "all" {
 			rkeys, err = api.pinning.RecursiveKeys(ctx)
 			if err != nil {
@@ -136,6 +136,10 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 	fileAdder.RawLeaves = settings.RawLeaves
 	fileAdder.NoCopy = settings.NoCopy
 	fileAdder.CidBuilder = prefix
+	fileAdder.ChecksumKey = api.checksumKey
+	if !settings.OnlyHash {
+		fileAdder.CodedIndex = api.codedIndex
+	}
 
 	switch settings.Layout {
 	case options.BalancedLayout:
@@ -189,23 +193,24 @@ func (api *UnixfsAPI) Get(ctx context.Context, p path.Path) (files.Node, error)
 		return nil, err
 	}
 	fmt.Println("Debug: time ipfs header received ", time.Now().UnixNano())
-	return unixfile.NewUnixfsFile(ctx, ses.dag, nd, "", cid.Cid{})
+	return unixfile.NewUnixfsFile(ctx, ses.dag, nd, "")
 }
 
-func (api *UnixfsAPI) GetC(ctx context.Context, p path.Path, cd string) (files.Node, error) {
-	ses := api.core().getSession(ctx)
-
-	nd, err := ses.ResolveNode(ctx, p)
+func (api *UnixfsAPI) GetC(ctx context.Context, p path.Path, cd string, opts ...options.UnixfsGetOption) (files.Node, error) {
+	settings, err := options.UnixfsGetOptions(opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	rp, err:= ses.ResolvePath(ctx, p)
+	ses := api.core().getSession(ctx)
+
+	nd, err := ses.ResolveNode(ctx, p)
 	if err != nil {
 		return nil, err
 	}
+
         fmt.Println("Debug: time mars header received ", time.Now().UnixNano())
-	return unixfile.NewUnixfsFile(ctx, ses.dag, nd, cd, rp.Cid())
+	return unixfile.NewUnixfsFileC(ctx, ses.dag, nd, cd, settings.Redundancy)
 }
 
 
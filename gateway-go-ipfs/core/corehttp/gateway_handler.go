@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"math"
 	"mime"
 	"net/http"
 	"net/url"
@@ -25,7 +26,10 @@ import (
 	mfs "github.com/ipfs/go-mfs"
 	path "github.com/ipfs/go-path"
 	"github.com/ipfs/go-path/resolver"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	uio "github.com/ipfs/go-unixfs/io"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
 	ipath "github.com/ipfs/interface-go-ipfs-core/path"
 	routing "github.com/libp2p/go-libp2p-core/routing"
 )
@@ -265,8 +269,24 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	dr, err := i.api.Unixfs().Get(r.Context(), resolvedPath)
+	coding, getOpts, err := codingFromRequest(r)
 	if err != nil {
+		webError(w, "invalid coding", err, http.StatusBadRequest)
+		return
+	}
+
+	var dr files.Node
+	if coding != "" {
+		dr, err = i.api.Unixfs().GetC(r.Context(), resolvedPath, coding, getOpts...)
+	} else {
+		dr, err = i.api.Unixfs().Get(r.Context(), resolvedPath)
+	}
+	switch err {
+	case nil:
+	case uio.ErrNotCoded, uio.ErrUnknownCoding:
+		webError(w, "ipfs get --coding "+coding+" "+escapedURLPath, err, http.StatusBadRequest)
+		return
+	default:
 		webError(w, "ipfs cat "+escapedURLPath, err, http.StatusNotFound)
 		return
 	}
@@ -309,6 +329,9 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 			// set modtime to a really long time ago, since files are immutable and should stay cached
 			modtime = time.Unix(1, 0)
 		}
+		if coding != "" {
+			w.Header().Set("X-Ipfs-Coding", coding)
+		}
 
 		urlFilename := r.URL.Query().Get("filename")
 		var name string
@@ -482,6 +505,13 @@ func (i *gatewayHandler) serveFile(w http.ResponseWriter, req *http.Request, nam
 		return
 	}
 
+	if size < 0 {
+		// The size of coded files comes from the header of their root,
+		// which whoever published them wrote.
+		http.Error(w, fmt.Sprintf("cannot serve a file of invalid size %d", size), http.StatusBadGateway)
+		return
+	}
+
 	content := &lazySeeker{
 		size:   size,
 		reader: file,
@@ -520,10 +550,56 @@ func (i *gatewayHandler) serveFile(w http.ResponseWriter, req *http.Request, nam
 	}
 	w.Header().Set("Content-Type", ctype)
 
+	if cf, ok := file.(unixfile.CodedFile); ok {
+		// Headers go out before the body is decoded, the same for GET,
+		// HEAD and Range requests: they report the packets decoding the
+		// whole file takes, along with the duplicates received and the
+		// time spent decoding so far. The totals are logged once sent.
+		st := cf.Stats()
+		w.Header().Set("X-Ipfs-Coded-Packets", strconv.Itoa(cf.Symbols()))
+		w.Header().Set("X-Ipfs-Coded-Duplicates", strconv.Itoa(st.Duplicates))
+		w.Header().Set("X-Ipfs-Coded-Decode-Time", st.DecodeTime.String())
+		defer func() {
+			st := cf.Stats()
+			log.Debugf("served %s from %d coded packets, %d duplicates, decoded in %s", name, st.Packets, st.Duplicates, st.DecodeTime)
+		}()
+	}
+
 	w = &statusResponseWriter{w}
 	http.ServeContent(w, req, name, modtime, content)
 }
 
+// codingFromRequest returns the coding to fetch files with, and the options
+// to fetch them with. They are taken from the "coding" and "redundancy"
+// query parameters, or else from the X-Ipfs-Coding and
+// X-Ipfs-Coding-Redundancy headers. No coding fetches files as they are.
+func codingFromRequest(r *http.Request) (string, []options.UnixfsGetOption, error) {
+	query := r.URL.Query()
+	coding := query.Get("coding")
+	if coding == "" {
+		coding = r.Header.Get("X-Ipfs-Coding")
+	}
+	redundancy := query.Get("redundancy")
+	if redundancy == "" {
+		redundancy = r.Header.Get("X-Ipfs-Coding-Redundancy")
+	}
+
+	if coding == "" {
+		if redundancy != "" {
+			return "", nil, fmt.Errorf("redundancy %q given without a coding", redundancy)
+		}
+		return "", nil, nil
+	}
+	if redundancy == "" {
+		return coding, nil, nil
+	}
+	red, err := strconv.ParseFloat(redundancy, 64)
+	if err != nil || !(red >= 0) || math.IsInf(red, 0) {
+		return "", nil, fmt.Errorf("invalid redundancy %q", redundancy)
+	}
+	return coding, []options.UnixfsGetOption{options.Unixfs.Redundancy(red)}, nil
+}
+
 func (i *gatewayHandler) servePretty404IfPresent(w http.ResponseWriter, r *http.Request, parsedPath ipath.Path) bool {
 	resolved404Path, ctype, err := i.searchUpTreeFor404(r, parsedPath)
 	if err != nil {
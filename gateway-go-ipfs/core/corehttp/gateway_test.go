@@ -23,6 +23,7 @@ import (
 	files "github.com/ipfs/go-ipfs-files"
 	path "github.com/ipfs/go-path"
 	iface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
 	nsopts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
 	ipath "github.com/ipfs/interface-go-ipfs-core/path"
 	ci "github.com/libp2p/go-libp2p-core/crypto"
@@ -661,6 +662,113 @@ func TestIPNSHostnameBacklinks(t *testing.T) {
 	}
 }
 
+func TestGatewayGetCoded(t *testing.T) {
+	ts, api, ctx := newTestServerAndNode(t, nil)
+
+	data := []byte(strings.Repeat("fnord", 1000))
+	coded, err := api.Unixfs().Add(ctx, files.NewBytesFile(data), options.Unixfs.Chunker("nc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := api.Unixfs().Add(ctx, files.NewBytesFile(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path   string
+		header string
+		status int
+	}{
+		{"/ipfs/" + coded.Cid().String() + "?coding=nc", "", http.StatusOK},
+		{"/ipfs/" + coded.Cid().String() + "?coding=nc&redundancy=0.2", "", http.StatusOK},
+		{"/ipfs/" + coded.Cid().String(), "nc", http.StatusOK},
+		{"/ipfs/" + coded.Cid().String() + "?coding=nc&redundancy=-1", "", http.StatusBadRequest},
+		{"/ipfs/" + coded.Cid().String() + "?coding=xx", "", http.StatusBadRequest},
+		{"/ipfs/" + plain.Cid().String() + "?coding=nc", "", http.StatusBadRequest},
+		{"/ipfs/" + plain.Cid().String() + "?redundancy=0.2", "", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.header != "" {
+			req.Header.Set("X-Ipfs-Coding", test.header)
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != test.status {
+			t.Fatalf("got status %d for %s, expected %d: %s", res.StatusCode, test.path, test.status, body)
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+
+		if string(body) != string(data) {
+			t.Fatalf("got the wrong data for %s", test.path)
+		}
+		if res.ContentLength != int64(len(data)) {
+			t.Fatalf("got a content length of %d for %s, expected %d", res.ContentLength, test.path, len(data))
+		}
+		if coding := res.Header.Get("X-Ipfs-Coding"); coding != "nc" {
+			t.Fatalf("expected the response to be coded with nc, got %q", coding)
+		}
+		checkCodedStats(t, res)
+	}
+
+	// HEAD and Range requests get the same headers.
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, ts.URL+"/ipfs/"+coded.Cid().String()+"?coding=nc", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		length := len(data)
+		if method == http.MethodGet {
+			req.Header.Set("Range", "bytes=10-19")
+			length = 10
+		}
+		res, err := doWithoutRedirect(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ContentLength != int64(length) {
+			t.Fatalf("got a content length of %d for a %s request, expected %d", res.ContentLength, method, length)
+		}
+		if method == http.MethodGet && string(body) != string(data[10:20]) {
+			t.Fatalf("got the wrong range of data: %q", body)
+		}
+		checkCodedStats(t, res)
+	}
+}
+
+// checkCodedStats checks the stats of a response of the coded file of
+// TestGatewayGetCoded, which fits in a single generation whose source
+// symbols are all local.
+func checkCodedStats(t *testing.T, res *http.Response) {
+	t.Helper()
+	if packets := res.Header.Get("X-Ipfs-Coded-Packets"); packets != "1" {
+		t.Fatalf("expected the file to be decoded from 1 packet, got %q", packets)
+	}
+	if dups := res.Header.Get("X-Ipfs-Coded-Duplicates"); dups != "0" {
+		t.Fatalf("expected no duplicate packets, got %q", dups)
+	}
+	if _, err := time.ParseDuration(res.Header.Get("X-Ipfs-Coded-Decode-Time")); err != nil {
+		t.Fatalf("expected a decode time: %s", err)
+	}
+}
+
 func TestCacheControlImmutable(t *testing.T) {
 	ts, _, _ := newTestServerAndNode(t, nil)
 	t.Logf("test server url: %s", ts.URL)
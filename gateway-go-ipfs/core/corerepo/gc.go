@@ -96,7 +96,7 @@ func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 // CollectResult collects the output of a garbage collection run and calls the
 // given callback for each object removed.  It also collects all errors into a
 // MultiError which is returned after the gc is completed.
-func CollectResult(ctx context.Context, gcOut <-chan gc.Result, cb func(cid.Cid)) error {
+func CollectResult(ctx context.Context, gcOut <-chan gc.Result, cb func(gc.Result)) error {
 	var errors []error
 loop:
 	for {
@@ -108,7 +108,7 @@ loop:
 			if res.Error != nil {
 				errors = append(errors, res.Error)
 			} else if res.KeyRemoved.Defined() && cb != nil {
-				cb(res.KeyRemoved)
+				cb(res)
 			}
 		case <-ctx.Done():
 			errors = append(errors, ctx.Err())
@@ -1,18 +1,14 @@
 package coreunix
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"math"
-	"os"
 	gopath "path"
 	"strconv"
-	"strings"
 
+	"github.com/ipfs/go-block-format/coding"
 	"github.com/ipfs/go-cid"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	chunker "github.com/ipfs/go-ipfs-chunker"
@@ -85,7 +81,11 @@ type Adder struct {
 	unlocker   bstore.Unlocker
 	tempRoot   cid.Cid
 	CidBuilder cid.Builder
-	liveNodes  uint64
+	CodedIndex coding.Index
+	// ChecksumKey keys the checksums of the generations of coded
+	// splitters publishing them, see chunker.KeyedSplitter.
+	ChecksumKey []byte
+	liveNodes   uint64
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -114,6 +114,17 @@ func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 		return nil, err
 	}
 
+	if ks, ok := chnk.(chunker.KeyedSplitter); ok && adder.ChecksumKey != nil {
+		ks.SetChecksumKey(adder.ChecksumKey)
+	}
+	if cs, ok := chnk.(chunker.CodedSplitter); ok {
+		nd, err := adder.addCoded(cs)
+		if err != nil {
+			return nil, err
+		}
+		return nd, adder.bufferedDS.Commit()
+	}
+
 	params := ihelper.DagBuilderParams{
 		Dagserv:    adder.bufferedDS,
 		RawLeaves:  adder.RawLeaves,
@@ -189,33 +200,83 @@ func (adder *Adder) PinRoot(root ipld.Node) error {
 	return adder.pinning.Flush(adder.ctx)
 }
 
-func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) error {
-	switch fsn := fsn.(type) {
-	case *mfs.File:
-		// MARS
-		if strings.HasPrefix(adder.Chunker, "nc") {
-			node, err := fsn.GetNode()
-			if err != nil {
-				return err
-			}
-			stat, err := node.Stat()
-			if err != nil {
-				return err
-			}
-			f, err := os.Create(os.Getenv("IPFS_PATH") + "/nc/" + stat.Hash)
+// addCoded stores the packets of a coded splitter as coded leaves, under
+// one parent per generation, and the generation parents under a root
+// carrying the header of the file. Coded leaves are always unixfs nodes,
+// whatever RawLeaves says.
+func (adder *Adder) addCoded(cs chunker.CodedSplitter) (ipld.Node, error) {
+	var parents, leaves []ipld.Node
+	var generation *coding.Header
+	for {
+		packet, err := cs.NextBytes()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		// A generation is complete once the packets of the next one start.
+		if generation != nil && (err == io.EOF || cs.Generation().Generation != generation.Generation) {
+			parent, err := adder.addGeneration(generation, leaves)
 			if err != nil {
-				return err
-			}
-			defer f.Close()
-			w := bufio.NewWriter(f)
-			//fmt.Println(stat.Hash)
-			//fmt.Println(node.Links())
-			for _, link := range node.Links() {
-				//fmt.Println(link.Cid)
-				fmt.Fprintln(w, link.Cid)
+				return nil, err
 			}
-			return w.Flush()
+			parents = append(parents, parent)
+			leaves = nil
+		}
+		if err == io.EOF {
+			break
+		}
+		generation = cs.Generation()
+
+		leaf := dag.NodeWithData(unixfs.FilePBData(packet, uint64(len(packet))))
+		leaf.SetCidBuilder(adder.CidBuilder)
+		if err := adder.bufferedDS.Add(adder.ctx, leaf); err != nil {
+			return nil, err
 		}
+		leaves = append(leaves, leaf)
+	}
+
+	// Nothing to code, add an empty file.
+	if len(parents) == 0 {
+		nd := dag.NodeWithData(unixfs.FilePBData(nil, 0))
+		nd.SetCidBuilder(adder.CidBuilder)
+		return nd, adder.bufferedDS.Add(adder.ctx, nd)
+	}
+
+	root, err := unixfs.CodedNode(cs.Header(), parents...)
+	if err != nil {
+		return nil, err
+	}
+	root.SetCidBuilder(adder.CidBuilder)
+	return root, adder.bufferedDS.Add(adder.ctx, root)
+}
+
+// addGeneration adds the parent of the coded leaves of a generation, and
+// indexes them for the blockservice and bitswap.
+func (adder *Adder) addGeneration(h *coding.Header, leaves []ipld.Node) (ipld.Node, error) {
+	parent, err := unixfs.CodedNode(h, leaves...)
+	if err != nil {
+		return nil, err
+	}
+	parent.SetCidBuilder(adder.CidBuilder)
+	if err := adder.bufferedDS.Add(adder.ctx, parent); err != nil {
+		return nil, err
+	}
+
+	if adder.CodedIndex != nil {
+		children := make([]cid.Cid, len(leaves))
+		for i, leaf := range leaves {
+			children[i] = leaf.Cid()
+		}
+		if err := adder.CodedIndex.Add(parent.Cid(), h.Scheme, children...); err != nil {
+			return nil, err
+		}
+	}
+	return parent, nil
+}
+
+func (adder *Adder) outputDirs(path string, fsn mfs.FSNode) error {
+	switch fsn := fsn.(type) {
+	case *mfs.File:
 		return nil
 	case *mfs.Directory:
 		names, err := fsn.ListNames(adder.ctx)
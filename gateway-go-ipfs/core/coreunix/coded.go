@@ -0,0 +1,348 @@
+package coreunix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-block-format/coding"
+	// Register the built-in coding schemes.
+	_ "github.com/ipfs/go-block-format/coding/rlnc"
+	_ "github.com/ipfs/go-block-format/coding/rs"
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
+)
+
+// ErrUnknownCoding is returned for coded content with a coding scheme that
+// cannot be decoded here.
+var ErrUnknownCoding = errors.New("unknown coding scheme")
+
+// CodedGeneration describes what is held of a generation of a coded file,
+// or of the coded blocks of any parent.
+type CodedGeneration struct {
+	Parent cid.Cid
+	Coding string
+	// Generation is the index of the generation in its file, or -1 when the
+	// parent is not held.
+	Generation int
+	// Symbols is the number of innovative packets needed to decode the
+	// generation, or 0 when the parent is not held.
+	Symbols int
+	// Held is the number of coded blocks of the generation in the
+	// blockstore, linked from the parent or listed in the coded index.
+	Held int
+	// Rank is the number of innovative packets among them, or -1 when
+	// the parent is not held.
+	Rank int
+	// Repaired is the number of blocks linked from the parent that were
+	// missing and re-derived by RepairCoded.
+	Repaired int
+}
+
+// Needed returns the number of packets missing to decode the generation.
+func (g *CodedGeneration) Needed() int {
+	if g.Rank >= g.Symbols {
+		return 0
+	}
+	return g.Symbols - g.Rank
+}
+
+// Decodable reports whether the packets held decode the generation.
+func (g *CodedGeneration) Decodable() bool {
+	return g.Symbols > 0 && g.Rank >= g.Symbols
+}
+
+// CodedFetcher fetches count coded blocks of parent with coding, like
+// CoreAPI.ResolveNodeC, the ones held locally first.
+type CodedFetcher func(ctx context.Context, parent cid.Cid, coding string, count int) <-chan *ipld.NodeOption
+
+// ListCoded describes the parents idx lists coded blocks of, with the
+// codings in codings. Only the blocks in bs are read.
+func ListCoded(ctx context.Context, ds ipld.DAGService, bs bstore.Blockstore, idx coding.Index, codings ...string) ([]CodedGeneration, error) {
+	var out []CodedGeneration
+	for _, cd := range codings {
+		parents, err := idx.Parents(cd)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parents {
+			g, err := statParent(ctx, ds, bs, idx, p, cd)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, g)
+		}
+	}
+	return out, nil
+}
+
+// statParent describes the coded blocks of parent with coding, whether or
+// not parent is a generation parent we hold.
+func statParent(ctx context.Context, ds ipld.DAGService, bs bstore.Blockstore, idx coding.Index, parent cid.Cid, cd string) (CodedGeneration, error) {
+	nd, err := getHeld(ctx, ds, bs, parent)
+	if err != nil {
+		return CodedGeneration{}, err
+	}
+	if nd != nil {
+		if h, err := unixfs.CodedHeader(nd); err == nil && h.Generation >= 0 && h.Scheme == cd {
+			g, _, err := statGeneration(ctx, ds, bs, idx, nd, h)
+			return g, err
+		}
+	}
+
+	// Without a header, the packets cannot be decoded.
+	g := CodedGeneration{Parent: parent, Coding: cd, Generation: -1, Rank: -1}
+	children, err := idx.Coded(parent, cd)
+	if err != nil {
+		return g, err
+	}
+	for _, c := range children {
+		has, err := bs.Has(c)
+		if err != nil {
+			return g, err
+		}
+		if has {
+			g.Held++
+		}
+	}
+	return g, nil
+}
+
+// StatCoded describes the generations of the coded file whose root is nd,
+// or the generation whose parent is nd, from the blocks in bs. Generations
+// whose parent is not held are described from the header of the root.
+func StatCoded(ctx context.Context, ds ipld.DAGService, bs bstore.Blockstore, idx coding.Index, nd ipld.Node) (*coding.Header, []CodedGeneration, error) {
+	h, err := unixfs.CodedHeader(nd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Generation >= 0 {
+		g, _, err := statGeneration(ctx, ds, bs, idx, nd, h)
+		if err != nil {
+			return nil, nil, err
+		}
+		return h, []CodedGeneration{g}, nil
+	}
+
+	gens := make([]CodedGeneration, 0, len(nd.Links()))
+	for i, l := range nd.Links() {
+		parent, err := getHeld(ctx, ds, bs, l.Cid)
+		if err != nil {
+			return nil, nil, err
+		}
+		if parent == nil {
+			gens = append(gens, CodedGeneration{
+				Parent:     l.Cid,
+				Coding:     h.Scheme,
+				Generation: i,
+				Symbols:    generationSymbols(h, i),
+			})
+			continue
+		}
+		gh, err := generationHeader(parent, h, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		g, _, err := statGeneration(ctx, ds, bs, idx, parent, gh)
+		if err != nil {
+			return nil, nil, err
+		}
+		gens = append(gens, g)
+	}
+	return h, gens, nil
+}
+
+// RepairCoded fetches packets with fetch for the generations of the coded
+// file whose root is nd, or the generation whose parent is nd, until they
+// have full rank. It then re-derives the blocks their parents link to that
+// are missing from bs, and adds them to ds. Generation parents missing
+// from bs are fetched through ds.
+func RepairCoded(ctx context.Context, ds ipld.DAGService, bs bstore.Blockstore, idx coding.Index, fetch CodedFetcher, nd ipld.Node) ([]CodedGeneration, error) {
+	h, err := unixfs.CodedHeader(nd)
+	if err != nil {
+		return nil, err
+	}
+	if h.Generation >= 0 {
+		g, err := repairGeneration(ctx, ds, bs, idx, fetch, nd, h)
+		return []CodedGeneration{g}, err
+	}
+
+	gens := make([]CodedGeneration, 0, len(nd.Links()))
+	for i, l := range nd.Links() {
+		parent, err := l.GetNode(ctx, ds)
+		if err != nil {
+			return gens, err
+		}
+		gh, err := generationHeader(parent, h, i)
+		if err != nil {
+			return gens, err
+		}
+		g, err := repairGeneration(ctx, ds, bs, idx, fetch, parent, gh)
+		gens = append(gens, g)
+		if err != nil {
+			return gens, err
+		}
+	}
+	return gens, nil
+}
+
+func repairGeneration(ctx context.Context, ds ipld.DAGService, bs bstore.Blockstore, idx coding.Index, fetch CodedFetcher, parent ipld.Node, h *coding.Header) (CodedGeneration, error) {
+	g, dec, err := statGeneration(ctx, ds, bs, idx, parent, h)
+	if err != nil {
+		return g, err
+	}
+
+	if !dec.IsComplete() {
+		fetchCtx, cancel := context.WithCancel(ctx)
+		var fetched []cid.Cid
+		for opt := range fetch(fetchCtx, g.Parent, h.Scheme, g.Held+g.Needed()) {
+			if opt.Err != nil {
+				cancel()
+				return g, opt.Err
+			}
+			fetched = append(fetched, opt.Node.Cid())
+			if payload, err := unixfs.ReadUnixFSNodeData(opt.Node); err == nil {
+				// The packets held come again first: they are not
+				// innovative.
+				dec.Add(payload)
+			}
+			if dec.IsComplete() {
+				break
+			}
+		}
+		cancel()
+		if err := idx.Add(g.Parent, h.Scheme, fetched...); err != nil {
+			return g, err
+		}
+		// Count what the exchange stored.
+		if g, dec, err = statGeneration(ctx, ds, bs, idx, parent, h); err != nil {
+			return g, err
+		}
+		if !dec.IsComplete() {
+			return g, fmt.Errorf("generation %d of the coded file only reached rank %d of %d", h.Generation, dec.Rank(), g.Symbols)
+		}
+	}
+
+	for i, l := range parent.Links() {
+		has, err := bs.Has(l.Cid)
+		if err != nil {
+			return g, err
+		}
+		if has {
+			continue
+		}
+		payload, err := dec.Packet(i)
+		if err != nil {
+			return g, err
+		}
+		leaf := dag.NodeWithData(unixfs.FilePBData(payload, uint64(len(payload))))
+		leaf.SetCidBuilder(l.Cid.Prefix())
+		if !leaf.Cid().Equals(l.Cid) {
+			return g, fmt.Errorf("re-derived block %d of generation %d does not match %s", i, h.Generation, l.Cid)
+		}
+		if err := ds.Add(ctx, leaf); err != nil {
+			return g, err
+		}
+		if err := idx.Add(g.Parent, h.Scheme, l.Cid); err != nil {
+			return g, err
+		}
+		g.Held++
+		g.Repaired++
+	}
+	return g, nil
+}
+
+// statGeneration describes the coded blocks held of the generation whose
+// parent is nd, and returns the decoder they were added to.
+func statGeneration(ctx context.Context, ds ipld.DAGService, bs bstore.Blockstore, idx coding.Index, nd ipld.Node, h *coding.Header) (CodedGeneration, coding.Decoder, error) {
+	g := CodedGeneration{
+		Parent:     nd.Cid(),
+		Coding:     h.Scheme,
+		Generation: h.Generation,
+		Symbols:    h.GenerationSize,
+	}
+	dec, err := coding.NewDecoder(h)
+	if err == coding.ErrUnknownScheme {
+		return g, nil, ErrUnknownCoding
+	} else if err != nil {
+		return g, nil, err
+	}
+
+	children, err := codedChildren(nd, idx, h.Scheme)
+	if err != nil {
+		return g, nil, err
+	}
+	for _, c := range children {
+		child, err := getHeld(ctx, ds, bs, c)
+		if err != nil {
+			return g, nil, err
+		}
+		if child == nil {
+			continue
+		}
+		g.Held++
+		payload, err := unixfs.ReadUnixFSNodeData(child)
+		if err != nil {
+			continue
+		}
+		// Packets of another generation, or corrupted ones, are not
+		// counted in the rank.
+		dec.Add(payload)
+	}
+	g.Rank = dec.Rank()
+	return g, dec, nil
+}
+
+// codedChildren returns the coded blocks of the generation whose parent is
+// nd: the ones it links to, then the other ones idx lists for it.
+func codedChildren(nd ipld.Node, idx coding.Index, cd string) ([]cid.Cid, error) {
+	seen := cid.NewSet()
+	var children []cid.Cid
+	for _, l := range nd.Links() {
+		if seen.Visit(l.Cid) {
+			children = append(children, l.Cid)
+		}
+	}
+	indexed, err := idx.Coded(nd.Cid(), cd)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range indexed {
+		if seen.Visit(c) {
+			children = append(children, c)
+		}
+	}
+	return children, nil
+}
+
+// getHeld returns the node c when bs holds it, or nil.
+func getHeld(ctx context.Context, ds ipld.DAGService, bs bstore.Blockstore, c cid.Cid) (ipld.Node, error) {
+	has, err := bs.Has(c)
+	if err != nil || !has {
+		return nil, err
+	}
+	return ds.Get(ctx, c)
+}
+
+// generationHeader returns the header of parent, the parent of generation g
+// of the coded file whose root has the header h.
+func generationHeader(parent ipld.Node, h *coding.Header, g int) (*coding.Header, error) {
+	gh, err := unixfs.CodedHeader(parent)
+	if err != nil || gh.Generation != g || gh.Scheme != h.Scheme {
+		return nil, fmt.Errorf("parent of generation %d of the coded file is invalid", g)
+	}
+	return gh, nil
+}
+
+// generationSymbols returns the number of source symbols of generation g of
+// the file whose root has the header h.
+func generationSymbols(h *coding.Header, g int) int {
+	scheme, err := coding.Lookup(h.Scheme)
+	if err != nil {
+		return h.GenerationSize
+	}
+	return scheme.GenerationSymbols(h, g)
+}
@@ -0,0 +1,117 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-block-format/coding/rlnc"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
+)
+
+func TestStatAndRepairCoded(t *testing.T) {
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(syncds.MutexWrap(datastore.NewMapDatastore()))
+	dserv := dag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+	idx := coding.NewIndex(syncds.MutexWrap(datastore.NewMapDatastore()))
+
+	// Packets of 1KiB in generations of 4 symbols.
+	symbolSize := 1024 - rlnc.HeaderSize - 4
+	data := make([]byte, 10*symbolSize)
+	rand.Read(data)
+	spl, err := chunker.FromString(bytes.NewReader(data), "nc-1-4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder := &Adder{ctx: ctx, bufferedDS: ipld.NewBufferedDAG(ctx, dserv), CodedIndex: idx}
+	root, err := adder.addCoded(spl.(chunker.CodedSplitter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adder.bufferedDS.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, gens, err := StatCoded(ctx, dserv, bs, idx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Scheme != "nc" || len(gens) != 3 {
+		t.Fatalf("expected 3 nc generations, got %d %s ones", len(gens), h.Scheme)
+	}
+	for i, g := range gens {
+		symbols := 4
+		if i == 2 {
+			symbols = 2
+		}
+		if g.Symbols != symbols || g.Held != symbols || g.Rank != symbols || !g.Decodable() {
+			t.Fatalf("expected generation %d to be decodable from its %d packets, got %+v", i, symbols, g)
+		}
+	}
+
+	// Lose two source packets of the first generation.
+	parent, err := dserv.Get(ctx, gens[0].Parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost := []cid.Cid{parent.Links()[1].Cid, parent.Links()[3].Cid}
+	for _, c := range lost {
+		if err := bs.DeleteBlock(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, gens, err = StatCoded(ctx, dserv, bs, idx, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := gens[0]; g.Held != 2 || g.Rank != 2 || g.Needed() != 2 || g.Decodable() {
+		t.Fatalf("expected 2 packets to be needed, got %+v", g)
+	}
+
+	// Peers send random combinations of the generation.
+	enc, err := rlnc.NewEncoder(data[:4*symbolSize], 4, symbolSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetch := func(ctx context.Context, p cid.Cid, cd string, count int) <-chan *ipld.NodeOption {
+		out := make(chan *ipld.NodeOption, count)
+		defer close(out)
+		for i := 0; i < count; i++ {
+			packet := enc.Encode().Marshal()
+			nd := dag.NodeWithData(unixfs.FilePBData(packet, uint64(len(packet))))
+			if err := dserv.Add(ctx, nd); err != nil {
+				out <- &ipld.NodeOption{Err: err}
+				return out
+			}
+			out <- &ipld.NodeOption{Node: nd}
+		}
+		return out
+	}
+
+	gens, err = RepairCoded(ctx, dserv, bs, idx, fetch, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := gens[0]; g.Repaired != 2 || !g.Decodable() {
+		t.Fatalf("expected 2 packets to be re-derived, got %+v", g)
+	}
+	if gens[1].Repaired != 0 || gens[2].Repaired != 0 {
+		t.Fatal("expected the other generations to be left alone")
+	}
+	for _, c := range lost {
+		if has, _ := bs.Has(c); !has {
+			t.Fatalf("expected %s to be re-derived", c)
+		}
+	}
+}
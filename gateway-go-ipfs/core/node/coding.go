@@ -0,0 +1,59 @@
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/common"
+)
+
+// CodingConfigKey is the key of the coding section in the repo config. Like
+// the NDN section, it is read as a raw config key.
+const CodingConfigKey = "Coding"
+
+// CodingConfig configures the coded files the node adds and fetches.
+type CodingConfig struct {
+	// ChecksumKey is the hex encoded key of the checksums of the "nc"
+	// generations, shared by the nodes publishing and fetching them and
+	// kept secret from the other peers. Empty publishes public checksums.
+	ChecksumKey string
+}
+
+// ChecksumKey is the key of the checksums of the "nc" generations, nil for
+// public checksums.
+type ChecksumKey []byte
+
+// ReadCodingConfig reads the coding section of the repo config.
+func ReadCodingConfig(r repo.Repo) (CodingConfig, error) {
+	var cfg CodingConfig
+	raw, err := r.GetConfigKey(CodingConfigKey)
+	if errors.Is(err, common.ErrKeyNotFound) {
+		// The section is optional.
+		return cfg, nil
+	} else if err != nil {
+		return cfg, fmt.Errorf("failure to read config setting %s: %s", CodingConfigKey, err)
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("failure to parse config setting %s: %s", CodingConfigKey, err)
+	}
+	return cfg, nil
+}
+
+// CodingChecksumKey returns the checksum key set up by cfg.
+func CodingChecksumKey(cfg CodingConfig) (ChecksumKey, error) {
+	if cfg.ChecksumKey == "" {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(cfg.ChecksumKey)
+	if err != nil {
+		return nil, fmt.Errorf("failure to parse config setting %s.ChecksumKey: %s", CodingConfigKey, err)
+	}
+	return key, nil
+}
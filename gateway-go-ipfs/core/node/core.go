@@ -6,7 +6,11 @@ import (
 	"time"
 
 	"github.com/ipfs/go-bitswap"
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	"github.com/ipfs/go-bitswap/ndn/ndnexchange"
 	"github.com/ipfs/go-bitswap/network"
+	"github.com/ipfs/go-block-format/coding"
 	"github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
@@ -23,13 +27,15 @@ import (
 	"github.com/libp2p/go-libp2p-core/routing"
 	"go.uber.org/fx"
 
+	"github.com/ipfs/go-ipfs/codedpin"
 	"github.com/ipfs/go-ipfs/core/node/helpers"
+	"github.com/ipfs/go-ipfs/exchange/composite"
 	"github.com/ipfs/go-ipfs/repo"
 )
 
 // BlockService creates new blockservice which provides an interface to fetch content-addressable blocks
-func BlockService(lc fx.Lifecycle, bs blockstore.Blockstore, rem exchange.Interface) blockservice.BlockService {
-	bsvc := blockservice.New(bs, rem)
+func BlockService(lc fx.Lifecycle, bs blockstore.Blockstore, rem exchange.Interface, idx coding.Index) blockservice.BlockService {
+	bsvc := blockservice.NewCoded(bs, rem, idx)
 
 	lc.Append(fx.Hook{
 		OnStop: func(ctx context.Context) error {
@@ -63,6 +69,12 @@ func Pinning(bstore blockstore.Blockstore, ds format.DAGService, repo repo.Repo)
 	return pinning, nil
 }
 
+// CodedPinning creates the pinner of coded pins, which tells GC which coded
+// blocks should be kept
+func CodedPinning(repo repo.Repo) *codedpin.Pinner {
+	return codedpin.New(repo.Datastore())
+}
+
 var (
 	_ merkledag.SessionMaker = new(syncDagService)
 	_ format.DAGService      = new(syncDagService)
@@ -87,11 +99,39 @@ func Dag(bs blockservice.BlockService) format.DAGService {
 	return merkledag.NewDAGService(bs)
 }
 
-// OnlineExchange creates new LibP2P backed block exchange (BitSwap)
-func OnlineExchange(provide bool) interface{} {
-	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, rt routing.Routing, bs blockstore.GCBlockstore) exchange.Interface {
+// OnlineExchange creates new LibP2P backed block exchange (BitSwap). It
+// fetches blocks from NDN when the consumer is not nil; with a policy, the
+// blockservice also does directly, see NDNExchangePolicy. Coded blocks are
+// verified against the checksums of their generation, unmasked with key
+// when they are keyed.
+func OnlineExchange(provide bool, policy composite.Policy) interface{} {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, host host.Host, rt routing.Routing, bs blockstore.GCBlockstore, idx coding.Index, key ChecksumKey, consumer *ndn.Consumer, names *cidname.Mapper) exchange.Interface {
 		bitswapNetwork := network.NewFromIpfsHost(host, rt)
-		exch := bitswap.New(helpers.LifecycleCtx(mctx, lc), bitswapNetwork, bs, bitswap.ProvideEnabled(provide))
+		opts := []bitswap.Option{
+			bitswap.ProvideEnabled(provide),
+			bitswap.WithCodedIndex(idx),
+			bitswap.WithRecoding(true),
+			bitswap.WithCodedVerification(true),
+			bitswap.WithChecksumKey(key),
+		}
+		if consumer != nil {
+			opts = append(opts, bitswap.WithNDNConsumer(consumer, names))
+		}
+		var exch exchange.Interface = bitswap.New(helpers.LifecycleCtx(mctx, lc), bitswapNetwork, bs, opts...)
+		if consumer != nil && policy != nil {
+			// NDN comes first for the fallback policy: the forwarder
+			// answers from its cache or Nacks quickly. Blocks it
+			// fetches are announced to bitswap, whose sessions and
+			// peers may want them too.
+			ndnx := ndnexchange.New(consumer, names, bs,
+				ndnexchange.CodedIndex(idx),
+				ndnexchange.CodedVerification(true),
+				ndnexchange.ChecksumKey(key),
+				ndnexchange.Notify(exch))
+			exch = composite.New(policy,
+				composite.Source{Name: "ndn", Fetcher: ndnx},
+				composite.Source{Name: "bitswap", Fetcher: exch})
+		}
 		lc.Append(fx.Hook{
 			OnStop: func(ctx context.Context) error {
 				return exch.Close()
@@ -167,9 +167,20 @@ func Storage(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 		finalBstore = fx.Provide(FilestoreBlockstoreCtor)
 	}
 
+	codingCfg, err := ReadCodingConfig(bcfg.Repo)
+	if err != nil {
+		return fx.Error(err)
+	}
+	checksumKey, err := CodingChecksumKey(codingCfg)
+	if err != nil {
+		return fx.Error(err)
+	}
+
 	return fx.Options(
 		fx.Provide(RepoConfig),
 		fx.Provide(Datastore),
+		fx.Provide(CodedIndex),
+		fx.Provide(func() ChecksumKey { return checksumKey }),
 		fx.Provide(BaseBlockstoreCtor(cacheOpts, bcfg.NilRepo, cfg.Datastore.HashOnRead)),
 		finalBstore,
 	)
@@ -259,11 +270,21 @@ func Online(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 		recordLifetime = d
 	}
 
+	ndnCfg, err := ReadNDNConfig(bcfg.Repo)
+	if err != nil {
+		return fx.Error(err)
+	}
+	ndnPolicy, err := NDNExchangePolicy(ndnCfg)
+	if err != nil {
+		return fx.Error(err)
+	}
+
 	/* don't provide from bitswap when the strategic provider service is active */
 	shouldBitswapProvide := !cfg.Experimental.StrategicProviding
 
 	return fx.Options(
-		fx.Provide(OnlineExchange(shouldBitswapProvide)),
+		NDN(ndnCfg),
+		fx.Provide(OnlineExchange(shouldBitswapProvide, ndnPolicy)),
 		maybeProvide(Graphsync, cfg.Experimental.GraphsyncEnabled),
 		fx.Provide(DNSResolver),
 		fx.Provide(Namesys(ipnsCacheSize)),
@@ -296,6 +317,7 @@ var Core = fx.Options(
 	fx.Provide(Dag),
 	fx.Provide(resolver.NewBasicResolver),
 	fx.Provide(Pinning),
+	fx.Provide(CodedPinning),
 	fx.Provide(Files),
 )
 
@@ -0,0 +1,194 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-bitswap/ndn"
+	"github.com/ipfs/go-bitswap/ndn/cidname"
+	"github.com/ipfs/go-bitswap/ndn/producer"
+	"github.com/ipfs/go-block-format/coding"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/go-ipfs/exchange/composite"
+	"github.com/ipfs/go-ipfs/repo"
+	"github.com/ipfs/go-ipfs/repo/common"
+)
+
+// NDNConfigKey is the key of the NDN section in the repo config. The section
+// is not part of go-ipfs-config, so it is read as a raw config key.
+const NDNConfigKey = "Ndn"
+
+// NDNConfig configures how the node talks to the local NDN forwarder.
+type NDNConfig struct {
+	// Enabled connects the node to the forwarder. When disabled, blocks
+	// bitswap peers want but that are missing are answered with DONT_HAVE.
+	Enabled bool
+	// Socket is the path of the forwarder's Unix socket.
+	Socket string
+	// Prefix is the NDN prefix blocks are named under.
+	Prefix string
+	// Producer enables serving the local blockstore to NDN consumers.
+	Producer bool
+	// Congestion is the congestion control algorithm of the consumer,
+	// "aimd" or "cubic".
+	Congestion string
+	// InterestLifetime is the lifetime of the Interests sent by the node.
+	InterestLifetime string
+	// Retries is how many times a timed out Interest is retransmitted.
+	Retries int
+	// Exchange lets the blockservice fetch from NDN directly, next to
+	// bitswap: "race", "fallback" or "fastest". Empty only reaches NDN
+	// through bitswap.
+	Exchange string
+	// ExchangeTimeout is how long the "fallback" and "fastest" exchanges
+	// wait for one network before asking the other.
+	ExchangeTimeout string
+}
+
+// DefaultNDNConfig returns the settings used when the repo config has no
+// NDN section.
+func DefaultNDNConfig() NDNConfig {
+	return NDNConfig{
+		Enabled:          true,
+		Socket:           ndn.DefaultSocketPath,
+		Prefix:           cidname.DefaultPrefix.String(),
+		Congestion:       ndn.AIMD.String(),
+		InterestLifetime: ndn.DefaultInterestLifetime.String(),
+		Retries:          ndn.DefaultRetries,
+		ExchangeTimeout:  "1s",
+	}
+}
+
+// ReadNDNConfig reads the NDN section of the repo config. Settings missing
+// from the config keep their default value.
+func ReadNDNConfig(r repo.Repo) (NDNConfig, error) {
+	cfg := DefaultNDNConfig()
+	raw, err := r.GetConfigKey(NDNConfigKey)
+	if errors.Is(err, common.ErrKeyNotFound) {
+		// The section is optional.
+		return cfg, nil
+	} else if err != nil {
+		return cfg, fmt.Errorf("failure to read config setting %s: %s", NDNConfigKey, err)
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("failure to parse config setting %s: %s", NDNConfigKey, err)
+	}
+	return cfg, nil
+}
+
+// NDNNames returns the mapping between CIDs and NDN names set up by cfg.
+func NDNNames(cfg NDNConfig) (*cidname.Mapper, error) {
+	prefix, err := ndn.ParseName(cfg.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failure to parse config setting %s.Prefix: %s", NDNConfigKey, err)
+	}
+	return cidname.New(prefix)
+}
+
+// NDN groups the units connecting the node to the local NDN forwarder
+func NDN(cfg NDNConfig) fx.Option {
+	names, err := NDNNames(cfg)
+	if err != nil {
+		return fx.Error(err)
+	}
+	if !cfg.Enabled {
+		// The exchange checks for a nil consumer.
+		return fx.Options(
+			fx.Provide(func() *cidname.Mapper { return names }),
+			fx.Provide(func() *ndn.Consumer { return nil }),
+		)
+	}
+	var alg ndn.CongestionAlgorithm
+	switch cfg.Congestion {
+	case "", ndn.AIMD.String():
+		alg = ndn.AIMD
+	case ndn.Cubic.String():
+		alg = ndn.Cubic
+	default:
+		return fx.Error(fmt.Errorf("config setting %s.Congestion must be %q or %q, got %q", NDNConfigKey, ndn.AIMD, ndn.Cubic, cfg.Congestion))
+	}
+	if cfg.Retries < 0 {
+		return fx.Error(fmt.Errorf("config setting %s.Retries must not be negative, got %d", NDNConfigKey, cfg.Retries))
+	}
+	opts := []ndn.ConsumerOption{ndn.Congestion(alg), ndn.Retries(cfg.Retries)}
+	if cfg.InterestLifetime != "" {
+		d, err := time.ParseDuration(cfg.InterestLifetime)
+		if err != nil {
+			return fx.Error(fmt.Errorf("failure to parse config setting %s.InterestLifetime: %s", NDNConfigKey, err))
+		}
+		opts = append(opts, ndn.InterestLifetime(d))
+	}
+	dial := ndn.UnixDialer(cfg.Socket)
+
+	return fx.Options(
+		fx.Provide(func() *cidname.Mapper { return names }),
+		fx.Provide(NDNConsumer(dial, opts...)),
+		maybeProvide(NDNProducer(dial), cfg.Producer),
+	)
+}
+
+// NDNExchangePolicy returns the policy of the exchange combining bitswap and
+// NDN, or nil if the blockservice should only use bitswap.
+func NDNExchangePolicy(cfg NDNConfig) (composite.Policy, error) {
+	if !cfg.Enabled || cfg.Exchange == "" {
+		return nil, nil
+	}
+	timeout, err := time.ParseDuration(cfg.ExchangeTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failure to parse config setting %s.ExchangeTimeout: %s", NDNConfigKey, err)
+	}
+	switch cfg.Exchange {
+	case "race":
+		return composite.Race(), nil
+	case "fallback":
+		return composite.Fallback(timeout), nil
+	case "fastest":
+		return composite.Fastest(timeout), nil
+	default:
+		return nil, fmt.Errorf("config setting %s.Exchange must be \"race\", \"fallback\" or \"fastest\", got %q", NDNConfigKey, cfg.Exchange)
+	}
+}
+
+// NDNConsumer creates the consumer used to fetch blocks from NDN. It only
+// connects to the forwarder once it is first used.
+func NDNConsumer(dial ndn.DialFunc, opts ...ndn.ConsumerOption) interface{} {
+	return func(lc fx.Lifecycle) *ndn.Consumer {
+		c := ndn.NewConsumer(dial, opts...)
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return c.Close()
+			},
+		})
+		return c
+	}
+}
+
+// NDNProducer serves the local blockstore to NDN consumers
+func NDNProducer(dial ndn.DialFunc) interface{} {
+	return func(lc fx.Lifecycle, bs blockstore.GCBlockstore, idx coding.Index, names *cidname.Mapper) *producer.Producer {
+		p := producer.New(bs, names, dial, producer.Coded(idx))
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				// The producer keeps trying to reach the forwarder in the
+				// background, a missing forwarder must not stop the node.
+				if err := p.Start(); err != nil {
+					logger.Warnf("NDN producer could not register %s yet: %s", names.Prefix(), err)
+				}
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				return p.Close()
+			},
+		})
+		return p
+	}
+}
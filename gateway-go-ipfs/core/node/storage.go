@@ -1,6 +1,7 @@
 package node
 
 import (
+	"github.com/ipfs/go-block-format/coding"
 	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	config "github.com/ipfs/go-ipfs-config"
@@ -23,6 +24,12 @@ func Datastore(repo repo.Repo) datastore.Datastore {
 	return repo.Datastore()
 }
 
+// CodedIndex provides the index of the coded blocks held in the blockstore,
+// kept in the repo datastore
+func CodedIndex(repo repo.Repo) coding.Index {
+	return coding.NewIndex(repo.Datastore())
+}
+
 // BaseBlocks is the lower level blockstore without GC or Filestore layers
 type BaseBlocks blockstore.Blockstore
 
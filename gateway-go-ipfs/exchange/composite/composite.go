@@ -0,0 +1,503 @@
+// Package composite implements an exchange fetching blocks from several
+// sources, such as bitswap and NDN.
+//
+// A Policy decides which sources are asked for each CID and in which order:
+// all at once, one after the other with a timeout, or depending on the CID.
+// The first source to deliver a block wins, the same block delivered by
+// another source is dropped, and the requests still running once everything
+// was delivered are canceled. The exchange keeps success and latency
+// counters for each source, which policies such as Fastest use.
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("exchange/composite")
+
+var _ exchange.SessionExchange = (*Exchange)(nil)
+var _ exchange.FetcherC = (*Exchange)(nil)
+
+var errNotCoded = errors.New("source does not fetch coded blocks")
+
+// Source is a named fetcher. Fetchers that implement exchange.Interface also
+// receive HasBlock and Close, those that implement exchange.SessionExchange
+// are asked for a session in NewSession.
+type Source struct {
+	Name    string
+	Fetcher exchange.Fetcher
+}
+
+// SourceStats are the counters of a source.
+type SourceStats struct {
+	Name string
+	// Requests is the number of requests sent to the source, Failures
+	// those that ended without delivering anything and Cancels those
+	// canceled because other sources delivered.
+	Requests uint64
+	Failures uint64
+	Cancels  uint64
+	// Blocks is the number of blocks the source delivered first, and
+	// Duplicates those it delivered after another source.
+	Blocks     uint64
+	Duplicates uint64
+	// Latency is the total time between the requests and the blocks the
+	// source delivered first.
+	Latency time.Duration
+}
+
+// MeanLatency returns the mean time the source took to deliver a block, or
+// zero if it never delivered one.
+func (s SourceStats) MeanLatency() time.Duration {
+	if s.Blocks == 0 {
+		return 0
+	}
+	return s.Latency / time.Duration(s.Blocks)
+}
+
+type source struct {
+	Source
+
+	lk    sync.Mutex
+	stats SourceStats
+}
+
+func (s *source) record(f func(*SourceStats)) {
+	s.lk.Lock()
+	f(&s.stats)
+	s.lk.Unlock()
+}
+
+// Exchange fetches blocks from several sources.
+type Exchange struct {
+	policy  Policy
+	sources []*source
+	byName  map[string]*source
+
+	// fetcher fetches with the sources themselves, sessions with their
+	// sessions.
+	fetcher *fetcher
+}
+
+// New returns an exchange fetching from sources with policy. Source names
+// must be unique.
+func New(policy Policy, sources ...Source) *Exchange {
+	e := &Exchange{
+		policy: policy,
+		byName: make(map[string]*source),
+	}
+	fetchers := make(map[string]exchange.Fetcher)
+	for _, s := range sources {
+		src := &source{Source: s, stats: SourceStats{Name: s.Name}}
+		e.sources = append(e.sources, src)
+		e.byName[s.Name] = src
+		fetchers[s.Name] = s.Fetcher
+	}
+	e.fetcher = &fetcher{e: e, fetchers: fetchers}
+	return e
+}
+
+// Stats returns the counters of each source, in the order given to New.
+func (e *Exchange) Stats() []SourceStats {
+	stats := make([]SourceStats, len(e.sources))
+	for i, s := range e.sources {
+		s.lk.Lock()
+		stats[i] = s.stats
+		s.lk.Unlock()
+	}
+	return stats
+}
+
+// Source returns the fetcher of the source called name, or nil if there is
+// none.
+func (e *Exchange) Source(name string) exchange.Fetcher {
+	if s, ok := e.byName[name]; ok {
+		return s.Fetcher
+	}
+	return nil
+}
+
+// GetBlock fetches a block from the sources planned for it.
+func (e *Exchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return e.fetcher.GetBlock(ctx, c)
+}
+
+// GetBlocks fetches blocks from the sources planned for each of them.
+func (e *Exchange) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
+	return e.fetcher.GetBlocks(ctx, keys)
+}
+
+// GetBlocksC fetches coded blocks of parent from the planned sources that
+// implement exchange.FetcherC.
+func (e *Exchange) GetBlocksC(ctx context.Context, parent cid.Cid, coding string, count int) (<-chan blocks.Block, error) {
+	return e.fetcher.GetBlocksC(ctx, parent, coding, count)
+}
+
+// HasBlock announces a block to every source that is an exchange.Interface.
+func (e *Exchange) HasBlock(b blocks.Block) error {
+	var err error
+	for _, s := range e.sources {
+		if x, ok := s.Fetcher.(exchange.Interface); ok {
+			if herr := x.HasBlock(b); herr != nil && err == nil {
+				err = herr
+			}
+		}
+	}
+	return err
+}
+
+// IsOnline returns true if any source is online. Sources that are only
+// fetchers count as online.
+func (e *Exchange) IsOnline() bool {
+	for _, s := range e.sources {
+		x, ok := s.Fetcher.(exchange.Interface)
+		if !ok || x.IsOnline() {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSession returns a fetcher using a session of each source that supports
+// them. Sessions share the policy and counters of the exchange.
+func (e *Exchange) NewSession(ctx context.Context) exchange.Fetcher {
+	fetchers := make(map[string]exchange.Fetcher)
+	for _, s := range e.sources {
+		if x, ok := s.Fetcher.(exchange.SessionExchange); ok {
+			fetchers[s.Name] = x.NewSession(ctx)
+		} else {
+			fetchers[s.Name] = s.Fetcher
+		}
+	}
+	return &fetcher{e: e, fetchers: fetchers}
+}
+
+// Close closes every source that is an exchange.Interface.
+func (e *Exchange) Close() error {
+	var err error
+	for _, s := range e.sources {
+		if x, ok := s.Fetcher.(exchange.Interface); ok {
+			if cerr := x.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
+
+// plan asks the policy for the plan of c among the sources for which keep
+// returns true.
+func (e *Exchange) plan(c cid.Cid, keep func(*source) bool) Plan {
+	var stats []SourceStats
+	for _, s := range e.sources {
+		if keep(s) {
+			s.lk.Lock()
+			stats = append(stats, s.stats)
+			s.lk.Unlock()
+		}
+	}
+	return e.policy.Plan(c, stats)
+}
+
+func allSources(*source) bool { return true }
+
+func codedSources(s *source) bool {
+	_, ok := s.Fetcher.(exchange.FetcherC)
+	return ok
+}
+
+// fetcher runs the plans of an exchange with a fetcher for each source.
+type fetcher struct {
+	e        *Exchange
+	fetchers map[string]exchange.Fetcher
+}
+
+var _ exchange.FetcherC = (*fetcher)(nil)
+
+func (f *fetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	out := make(chan blocks.Block, 1)
+	f.run(ctx, f.e.plan(c, allSources), newKeysWant([]cid.Cid{c}), out)
+	select {
+	case blk := <-out:
+		return blk, nil
+	default:
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, blockstore.ErrNotFound
+}
+
+func (f *fetcher) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
+	// Keys with the same plan are requested together, so that sources
+	// get batches rather than single blocks.
+	type group struct {
+		plan Plan
+		keys []cid.Cid
+	}
+	var groups []*group
+	byPlan := make(map[string]*group)
+	seen := cid.NewSet()
+	for _, c := range keys {
+		if !seen.Visit(c) {
+			continue
+		}
+		p := f.e.plan(c, allSources)
+		k := fmt.Sprint(p.Stages, p.Delay)
+		g, ok := byPlan[k]
+		if !ok {
+			g = &group{plan: p}
+			byPlan[k] = g
+			groups = append(groups, g)
+		}
+		g.keys = append(g.keys, c)
+	}
+
+	out := make(chan blocks.Block)
+	var wg sync.WaitGroup
+	for _, g := range groups {
+		wg.Add(1)
+		go func(g *group) {
+			defer wg.Done()
+			f.run(ctx, g.plan, newKeysWant(g.keys), out)
+		}(g)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
+
+func (f *fetcher) GetBlocksC(ctx context.Context, parent cid.Cid, coding string, count int) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		f.run(ctx, f.e.plan(parent, codedSources), newCodedWant(parent, coding, count), out)
+	}()
+	return out, nil
+}
+
+// want tracks the blocks a plan still has to fetch.
+type want interface {
+	// request asks f for what is still missing.
+	request(ctx context.Context, f exchange.Fetcher) (<-chan blocks.Block, error)
+	// accept records b and reports whether it was still missing.
+	accept(b blocks.Block) bool
+	done() bool
+}
+
+type keysWant struct {
+	missing map[cid.Cid]struct{}
+}
+
+func newKeysWant(keys []cid.Cid) *keysWant {
+	w := &keysWant{missing: make(map[cid.Cid]struct{}, len(keys))}
+	for _, c := range keys {
+		w.missing[c] = struct{}{}
+	}
+	return w
+}
+
+func (w *keysWant) request(ctx context.Context, f exchange.Fetcher) (<-chan blocks.Block, error) {
+	keys := make([]cid.Cid, 0, len(w.missing))
+	for c := range w.missing {
+		keys = append(keys, c)
+	}
+	return f.GetBlocks(ctx, keys)
+}
+
+func (w *keysWant) accept(b blocks.Block) bool {
+	if _, ok := w.missing[b.Cid()]; !ok {
+		return false
+	}
+	delete(w.missing, b.Cid())
+	return true
+}
+
+func (w *keysWant) done() bool {
+	return len(w.missing) == 0
+}
+
+type codedWant struct {
+	parent cid.Cid
+	coding string
+	count  int
+	seen   *cid.Set
+}
+
+func newCodedWant(parent cid.Cid, coding string, count int) *codedWant {
+	return &codedWant{parent: parent, coding: coding, count: count, seen: cid.NewSet()}
+}
+
+func (w *codedWant) request(ctx context.Context, f exchange.Fetcher) (<-chan blocks.Block, error) {
+	fc, ok := f.(exchange.FetcherC)
+	if !ok {
+		return nil, errNotCoded
+	}
+	return fc.GetBlocksC(ctx, w.parent, w.coding, w.count)
+}
+
+func (w *codedWant) accept(b blocks.Block) bool {
+	if w.count <= 0 || !w.seen.Visit(b.Cid()) {
+		return false
+	}
+	w.count--
+	return true
+}
+
+func (w *codedWant) done() bool {
+	return w.count <= 0
+}
+
+// request is a running request to a source.
+type request struct {
+	src       *source
+	stage     int
+	cancel    context.CancelFunc
+	start     time.Time
+	delivered bool
+}
+
+type result struct {
+	req *request
+	// blk is nil once the request ended.
+	blk blocks.Block
+}
+
+// run executes plan until w is done, the plan is exhausted or ctx is done,
+// and sends the blocks that were missing to out.
+func (f *fetcher) run(ctx context.Context, plan Plan, w want, out chan<- blocks.Block) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result)
+	running := make(map[*request]struct{})
+
+	// stage is the next stage to start, current counts the running
+	// requests of the last one started.
+	stage, current := 0, 0
+	start := func(name string) {
+		src, ok := f.e.byName[name]
+		if !ok {
+			log.Warnf("the plan names unknown source %s", name)
+			return
+		}
+		rctx, rcancel := context.WithCancel(ctx)
+		ch, err := w.request(rctx, f.fetchers[name])
+		if err != nil {
+			rcancel()
+			if err != errNotCoded {
+				log.Debugf("requesting from %s: %s", name, err)
+				src.record(func(s *SourceStats) {
+					s.Requests++
+					s.Failures++
+				})
+			}
+			return
+		}
+		src.record(func(s *SourceStats) { s.Requests++ })
+		r := &request{src: src, stage: stage, cancel: rcancel, start: time.Now()}
+		running[r] = struct{}{}
+		current++
+		go func() {
+			for blk := range ch {
+				select {
+				case results <- result{req: r, blk: blk}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case results <- result{req: r}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// next starts the following stages until one has a running request.
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	next := func() {
+		for stage < len(plan.Stages) {
+			current = 0
+			for _, name := range plan.Stages[stage] {
+				start(name)
+			}
+			stage++
+			if current > 0 {
+				break
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		timeout = nil
+		if plan.Delay > 0 && stage < len(plan.Stages) {
+			timer = time.NewTimer(plan.Delay)
+			timeout = timer.C
+		}
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	next()
+loop:
+	for !w.done() && len(running) > 0 {
+		select {
+		case r := <-results:
+			if r.blk == nil {
+				delete(running, r.req)
+				r.req.cancel()
+				if !r.req.delivered {
+					r.req.src.record(func(s *SourceStats) { s.Failures++ })
+				}
+				if r.req.stage == stage-1 {
+					if current--; current == 0 {
+						// The last stage failed.
+						next()
+					}
+				}
+				continue
+			}
+			if !w.accept(r.blk) {
+				r.req.src.record(func(s *SourceStats) { s.Duplicates++ })
+				continue
+			}
+			r.req.delivered = true
+			latency := time.Since(r.req.start)
+			r.req.src.record(func(s *SourceStats) {
+				s.Blocks++
+				s.Latency += latency
+			})
+			select {
+			case out <- r.blk:
+			case <-ctx.Done():
+				break loop
+			}
+		case <-timeout:
+			next()
+		case <-ctx.Done():
+			break loop
+		}
+	}
+
+	// Cancel the losers.
+	for r := range running {
+		r.cancel()
+		r.src.record(func(s *SourceStats) { s.Cancels++ })
+	}
+}
@@ -0,0 +1,309 @@
+package composite
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// testFetcher serves the blocks it holds after a delay. A negative delay
+// never answers.
+type testFetcher struct {
+	delay  time.Duration
+	blocks map[cid.Cid]blocks.Block
+	coded  []blocks.Block
+
+	lk       sync.Mutex
+	requests int
+	canceled int
+}
+
+func newTestFetcher(delay time.Duration, blks ...blocks.Block) *testFetcher {
+	f := &testFetcher{delay: delay, blocks: make(map[cid.Cid]blocks.Block)}
+	for _, b := range blks {
+		f.blocks[b.Cid()] = b
+	}
+	return f
+}
+
+func (f *testFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	for b := range f.serve(ctx, func() []blocks.Block { return []blocks.Block{f.blocks[c]} }) {
+		return b, nil
+	}
+	return nil, blockstore.ErrNotFound
+}
+
+func (f *testFetcher) GetBlocks(ctx context.Context, keys []cid.Cid) (<-chan blocks.Block, error) {
+	return f.serve(ctx, func() []blocks.Block {
+		var blks []blocks.Block
+		for _, c := range keys {
+			blks = append(blks, f.blocks[c])
+		}
+		return blks
+	}), nil
+}
+
+func (f *testFetcher) serve(ctx context.Context, blks func() []blocks.Block) <-chan blocks.Block {
+	f.lk.Lock()
+	f.requests++
+	f.lk.Unlock()
+
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		var timeout <-chan time.Time
+		if f.delay >= 0 {
+			timeout = time.After(f.delay)
+		}
+		select {
+		case <-timeout:
+		case <-ctx.Done():
+			f.lk.Lock()
+			f.canceled++
+			f.lk.Unlock()
+			return
+		}
+		for _, b := range blks() {
+			if b == nil {
+				continue
+			}
+			select {
+			case out <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (f *testFetcher) stats() (requests, canceled int) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.requests, f.canceled
+}
+
+// testCodedFetcher also serves coded blocks.
+type testCodedFetcher struct {
+	*testFetcher
+}
+
+func (f testCodedFetcher) GetBlocksC(ctx context.Context, parent cid.Cid, coding string, count int) (<-chan blocks.Block, error) {
+	return f.serve(ctx, func() []blocks.Block {
+		if len(f.coded) > count {
+			return f.coded[:count]
+		}
+		return f.coded
+	}), nil
+}
+
+func sourceStats(t *testing.T, e *Exchange, name string) SourceStats {
+	for _, s := range e.Stats() {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no source %s", name)
+	return SourceStats{}
+}
+
+func TestRace(t *testing.T) {
+	blk := blocks.NewBlock([]byte("raced"))
+	slow := newTestFetcher(time.Second, blk)
+	fast := newTestFetcher(0, blk)
+	e := New(Race(), Source{"slow", slow}, Source{"fast", fast})
+
+	start := time.Now()
+	got, err := e.GetBlock(context.Background(), blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Cid().Equals(blk.Cid()) {
+		t.Fatalf("expected %s, got %s", blk.Cid(), got.Cid())
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("expected the fast source to win")
+	}
+
+	if s := sourceStats(t, e, "fast"); s.Requests != 1 || s.Blocks != 1 {
+		t.Fatalf("unexpected stats for the fast source: %+v", s)
+	}
+	if s := sourceStats(t, e, "slow"); s.Requests != 1 || s.Blocks != 0 || s.Cancels != 1 {
+		t.Fatalf("unexpected stats for the slow source: %+v", s)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, canceled := slow.stats(); canceled != 1 {
+		t.Fatal("expected the slow request to be canceled")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	blk := blocks.NewBlock([]byte("fallback"))
+	stuck := newTestFetcher(-1, blk)
+	empty := newTestFetcher(0)
+	backup := newTestFetcher(0, blk)
+	e := New(Fallback(100*time.Millisecond), Source{"stuck", stuck}, Source{"empty", empty}, Source{"backup", backup})
+
+	start := time.Now()
+	if _, err := e.GetBlock(context.Background(), blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	// The stuck source holds on for the timeout, the empty one fails at
+	// once and the backup is asked right after.
+	if d := time.Since(start); d < 100*time.Millisecond || d > 190*time.Millisecond {
+		t.Fatalf("expected the block after one timeout, got it after %s", d)
+	}
+	if s := sourceStats(t, e, "empty"); s.Requests != 1 || s.Failures != 1 {
+		t.Fatalf("unexpected stats for the empty source: %+v", s)
+	}
+	if s := sourceStats(t, e, "backup"); s.Blocks != 1 {
+		t.Fatalf("unexpected stats for the backup source: %+v", s)
+	}
+	if s := sourceStats(t, e, "stuck"); s.Cancels != 1 {
+		t.Fatalf("unexpected stats for the stuck source: %+v", s)
+	}
+
+	// Nobody has it.
+	missing := blocks.NewBlock([]byte("missing"))
+	e = New(Fallback(10*time.Millisecond), Source{"empty", empty}, Source{"backup", backup})
+	if _, err := e.GetBlock(context.Background(), missing.Cid()); err != blockstore.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestByCodec(t *testing.T) {
+	raw := blocks.NewBlock([]byte("raw"))
+	hash, _ := mh.Sum([]byte("raw block"), mh.SHA2_256, -1)
+	rawBlk, _ := blocks.NewBlockWithCid([]byte("raw block"), cid.NewCidV1(cid.Raw, hash))
+
+	bitswap := newTestFetcher(0, raw, rawBlk)
+	ndn := newTestFetcher(0, raw, rawBlk)
+	e := New(ByCodec(map[uint64][]string{cid.Raw: {"ndn"}}, Race()), Source{"bitswap", bitswap}, Source{"ndn", ndn})
+
+	if _, err := e.GetBlock(context.Background(), rawBlk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := bitswap.stats(); r != 0 {
+		t.Fatal("expected raw blocks to only be fetched from ndn")
+	}
+
+	// Other codecs use every source.
+	if _, err := e.GetBlock(context.Background(), raw.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if r, _ := bitswap.stats(); r != 1 {
+		t.Fatal("expected dag-pb blocks to be fetched from bitswap too")
+	}
+}
+
+func TestFastest(t *testing.T) {
+	sources := []SourceStats{
+		{Name: "unknown"},
+		{Name: "slow", Blocks: 2, Latency: 2 * time.Second},
+		{Name: "fast", Blocks: 4, Latency: 400 * time.Millisecond},
+	}
+	p := Fastest(time.Second).Plan(cid.Undef, sources)
+	got := fmt.Sprint(p.Stages)
+	if got != "[[fast] [slow] [unknown]]" {
+		t.Fatalf("unexpected stages %s", got)
+	}
+	if p.Delay != time.Second {
+		t.Fatalf("unexpected delay %s", p.Delay)
+	}
+}
+
+func TestGetBlocksDeduplicates(t *testing.T) {
+	var blks []blocks.Block
+	var keys []cid.Cid
+	for i := 0; i < 20; i++ {
+		b := blocks.NewBlock([]byte(fmt.Sprintf("block %d", i)))
+		blks = append(blks, b)
+		keys = append(keys, b.Cid())
+	}
+	a := newTestFetcher(0, blks...)
+	b := newTestFetcher(0, blks[10:]...)
+	e := New(Race(), Source{"a", a}, Source{"b", b})
+
+	out, err := e.GetBlocks(context.Background(), append(keys, keys[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(map[cid.Cid]int)
+	for blk := range out {
+		received[blk.Cid()]++
+	}
+	if len(received) != len(keys) {
+		t.Fatalf("expected %d blocks, got %d", len(keys), len(received))
+	}
+	for c, n := range received {
+		if n != 1 {
+			t.Fatalf("received %s %d times", c, n)
+		}
+	}
+	sa, sb := sourceStats(t, e, "a"), sourceStats(t, e, "b")
+	if sa.Blocks+sb.Blocks != uint64(len(keys)) {
+		t.Fatalf("expected %d delivered blocks, got %+v and %+v", len(keys), sa, sb)
+	}
+}
+
+func TestGetBlocksC(t *testing.T) {
+	parent := blocks.NewBlock([]byte("parent"))
+	var coded []blocks.Block
+	for i := 0; i < 6; i++ {
+		coded = append(coded, blocks.NewBlock([]byte(fmt.Sprintf("coded %d", i))))
+	}
+	a := testCodedFetcher{newTestFetcher(0)}
+	a.coded = coded[:4]
+	b := testCodedFetcher{newTestFetcher(0)}
+	b.coded = coded[2:]
+	plain := newTestFetcher(0)
+	e := New(Race(), Source{"a", a}, Source{"b", b}, Source{"plain", plain})
+
+	out, err := e.GetBlocksC(context.Background(), parent.Cid(), "nc", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := cid.NewSet()
+	for blk := range out {
+		if !received.Visit(blk.Cid()) {
+			t.Fatalf("received %s twice", blk.Cid())
+		}
+	}
+	if received.Len() != 5 {
+		t.Fatalf("expected 5 coded blocks, got %d", received.Len())
+	}
+	if r, _ := plain.stats(); r != 0 {
+		t.Fatal("expected no coded request to a plain fetcher")
+	}
+}
+
+func TestSessionsShareStats(t *testing.T) {
+	blk := blocks.NewBlock([]byte("session"))
+	e := New(Race(), Source{"a", newTestFetcher(0, blk)})
+
+	s := e.NewSession(context.Background())
+	if _, err := s.GetBlock(context.Background(), blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if st := sourceStats(t, e, "a"); st.Blocks != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestSource(t *testing.T) {
+	a := newTestFetcher(0)
+	e := New(Race(), Source{"a", a})
+	if e.Source("a") != a {
+		t.Fatal("expected the fetcher of source a")
+	}
+	if e.Source("b") != nil {
+		t.Fatal("expected no source b")
+	}
+}
@@ -0,0 +1,116 @@
+package composite
+
+import (
+	"sort"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// Plan says which sources are asked for a CID, and when.
+type Plan struct {
+	// Stages are the names of the sources asked together. The first stage
+	// starts at once, each following stage once the previous one failed
+	// or Delay elapsed, whichever comes first. Sources of earlier stages
+	// keep running until a source delivers.
+	Stages [][]string
+	// Delay is how long a stage may run before the next one starts, zero
+	// means until it fails.
+	Delay time.Duration
+}
+
+// Policy plans the requests for a CID given the sources of the exchange and
+// their statistics so far, in the order the sources were given to New.
+type Policy interface {
+	Plan(c cid.Cid, sources []SourceStats) Plan
+}
+
+// PolicyFunc adapts a function to a Policy.
+type PolicyFunc func(c cid.Cid, sources []SourceStats) Plan
+
+// Plan calls f.
+func (f PolicyFunc) Plan(c cid.Cid, sources []SourceStats) Plan {
+	return f(c, sources)
+}
+
+// Race asks all sources at once and takes the first block.
+func Race() Policy {
+	return PolicyFunc(func(c cid.Cid, sources []SourceStats) Plan {
+		stage := make([]string, len(sources))
+		for i, s := range sources {
+			stage[i] = s.Name
+		}
+		return Plan{Stages: [][]string{stage}}
+	})
+}
+
+// Fallback asks the sources one after the other, in order, moving to the
+// next source when the current one fails or does not deliver within
+// timeout.
+func Fallback(timeout time.Duration) Policy {
+	return PolicyFunc(func(c cid.Cid, sources []SourceStats) Plan {
+		return sequence(sources, timeout)
+	})
+}
+
+// Fastest is like Fallback, but asks the sources with the lowest mean
+// latency first. Sources that never delivered a block come last, in order.
+func Fastest(timeout time.Duration) Policy {
+	return PolicyFunc(func(c cid.Cid, sources []SourceStats) Plan {
+		sorted := make([]SourceStats, len(sources))
+		copy(sorted, sources)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			li, lj := sorted[i].MeanLatency(), sorted[j].MeanLatency()
+			if li == 0 || lj == 0 {
+				return lj == 0 && li != 0
+			}
+			return li < lj
+		})
+		return sequence(sorted, timeout)
+	})
+}
+
+func sequence(sources []SourceStats, timeout time.Duration) Plan {
+	stages := make([][]string, len(sources))
+	for i, s := range sources {
+		stages[i] = []string{s.Name}
+	}
+	return Plan{Stages: stages, Delay: timeout}
+}
+
+// Select restricts the sources used for each CID to those returned by
+// choose, in the order returned, then plans with p. CIDs for which choose
+// returns no source are planned with all sources.
+func Select(choose func(c cid.Cid) []string, p Policy) Policy {
+	return PolicyFunc(func(c cid.Cid, sources []SourceStats) Plan {
+		names := choose(c)
+		if len(names) == 0 {
+			return p.Plan(c, sources)
+		}
+		var chosen []SourceStats
+		for _, n := range names {
+			for _, s := range sources {
+				if s.Name == n {
+					chosen = append(chosen, s)
+					break
+				}
+			}
+		}
+		return p.Plan(c, chosen)
+	})
+}
+
+// ByCodec selects the sources of a CID by its multicodec, see Select.
+func ByCodec(routes map[uint64][]string, p Policy) Policy {
+	return Select(func(c cid.Cid) []string {
+		return routes[c.Type()]
+	}, p)
+}
+
+// ByPrefix selects the sources of a CID by its prefix, that is its version,
+// codec and hash function, see Select.
+func ByPrefix(routes map[cid.Prefix][]string, p Policy) Policy {
+	return Select(func(c cid.Cid) []string {
+		return routes[c.Prefix()]
+	}, p)
+}
@@ -7,6 +7,10 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ipfs/go-block-format/coding"
+	// Register the built-in coding schemes.
+	_ "github.com/ipfs/go-block-format/coding/rlnc"
+	_ "github.com/ipfs/go-block-format/coding/rs"
 	bserv "github.com/ipfs/go-blockservice"
 	cid "github.com/ipfs/go-cid"
 	dstore "github.com/ipfs/go-datastore"
@@ -16,7 +20,10 @@ import (
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
 	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-unixfs"
 	"github.com/ipfs/go-verifcid"
+
+	"github.com/ipfs/go-ipfs/codedpin"
 )
 
 var log = logging.Logger("gc")
@@ -25,18 +32,26 @@ var log = logging.Logger("gc")
 // run.  It contains either an error, or the cid of a removed object.
 type Result struct {
 	KeyRemoved cid.Cid
+	// CodedBytes is the size of the removed object when it was a coded
+	// block listed in the coded index.
+	CodedBytes uint64
 	Error      error
 }
 
 // GC performs a mark and sweep garbage collection of the blocks in the blockstore
 // first, it creates a 'marked' set and adds to it the following:
-// - all recursively pinned blocks, plus all of their descendants (recursively)
-// - bestEffortRoots, plus all of its descendants (recursively)
-// - all directly pinned blocks
-// - all blocks utilized internally by the pinner
+//   - all recursively pinned blocks, plus all of their descendants (recursively)
+//   - bestEffortRoots, plus all of its descendants (recursively)
+//   - all directly pinned blocks
+//   - all blocks utilized internally by the pinner
+//   - the parents of coded pins, and as many of their coded blocks as the pins
+//     keep
+//   - as many coded blocks of the marked parents as their coded header asks for
 //
 // The routine then iterates over every block in the blockstore and
-// deletes any block that is not found in the marked set.
+// deletes any block that is not found in the marked set. Deleted coded
+// blocks are dropped from the coded index kept in dstor, next to the coded
+// pins, and reported with their size.
 func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn pin.Pinner, bestEffortRoots []cid.Cid) <-chan Result {
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -44,6 +59,7 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 
 	bsrv := bserv.New(bs, offline.Exchange(bs))
 	ds := dag.NewDAGService(bsrv)
+	idx := coding.NewIndex(dstor)
 
 	output := make(chan Result, 128)
 
@@ -60,6 +76,21 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 			}
 			return
 		}
+		if err := CodedSet(ctx, codedpin.New(dstor), idx, ds, bs, gcs); err != nil {
+			select {
+			case output <- Result{Error: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		coded, err := codedBlocks(idx)
+		if err != nil {
+			select {
+			case output <- Result{Error: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
 		keychan, err := bs.AllKeysChan(ctx)
 		if err != nil {
 			select {
@@ -71,6 +102,7 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 
 		errors := false
 		var removed uint64
+		removedKeys := cid.NewSet()
 
 	loop:
 		for ctx.Err() == nil { // select may not notice that we're "done".
@@ -80,6 +112,12 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 					break loop
 				}
 				if !gcs.Has(k) {
+					var codedBytes uint64
+					if coded.Has(k) {
+						if size, err := bs.GetSize(k); err == nil {
+							codedBytes = uint64(size)
+						}
+					}
 					err := bs.DeleteBlock(k)
 					removed++
 					if err != nil {
@@ -92,8 +130,9 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 						// continue as error is non-fatal
 						continue loop
 					}
+					removedKeys.Add(k)
 					select {
-					case output <- Result{KeyRemoved: k}:
+					case output <- Result{KeyRemoved: k, CodedBytes: codedBytes}:
 					case <-ctx.Done():
 						break loop
 					}
@@ -102,6 +141,13 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 				break loop
 			}
 		}
+		if err := pruneCodedIndex(idx, removedKeys); err != nil {
+			select {
+			case output <- Result{Error: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
 		if errors {
 			select {
 			case output <- Result{Error: ErrCannotDeleteSomeBlocks}:
@@ -128,6 +174,145 @@ func GC(ctx context.Context, bs bstore.GCBlockstore, dstor dstore.Datastore, pn
 	return output
 }
 
+// CodedSet adds to set the coded blocks garbage collection keeps, as coded
+// blocks are not linked from any node:
+//   - the parents of the coded pins in cp, and Keep() of their coded blocks
+//   - for the parents already in set, as many coded blocks as their coded
+//     header asks for: the source symbols of their generation plus its
+//     redundancy, or all of them without a header
+//
+// Coded blocks are kept in the order of idx, skipping the ones missing from
+// bs. The ones beyond are excess packets, like the ones recoding peers keep
+// sending, and are left out of set.
+func CodedSet(ctx context.Context, cp *codedpin.Pinner, idx coding.Index, ng ipld.NodeGetter, bs bstore.Blockstore, set *cid.Set) error {
+	keep := make(map[codedKey]int)
+	for _, cd := range indexedCodings() {
+		parents, err := idx.Parents(cd)
+		if err != nil {
+			return err
+		}
+		for _, p := range parents {
+			if set.Has(p) {
+				keep[codedKey{p, cd}] = headerKeep(ctx, ng, p)
+			}
+		}
+	}
+
+	pins, err := cp.Pins()
+	if err != nil {
+		return err
+	}
+	for _, p := range pins {
+		set.Add(p.Parent)
+		k := codedKey{p.Parent, p.Coding}
+		if n, ok := keep[k]; !ok || (n >= 0 && n < p.Keep()) {
+			keep[k] = p.Keep()
+		}
+	}
+
+	for k, n := range keep {
+		children, err := idx.Coded(k.parent, k.coding)
+		if err != nil {
+			return err
+		}
+		kept := 0
+		for _, c := range children {
+			if n >= 0 && kept >= n {
+				break
+			}
+			has, err := bs.Has(c)
+			if err != nil {
+				return err
+			}
+			if has {
+				set.Add(c)
+				kept++
+			}
+		}
+	}
+	return nil
+}
+
+type codedKey struct {
+	parent cid.Cid
+	coding string
+}
+
+// headerKeep returns the number of coded blocks of parent its coded header
+// asks for, or -1 for all of them when parent has no generation header.
+func headerKeep(ctx context.Context, ng ipld.NodeGetter, parent cid.Cid) int {
+	nd, err := ng.Get(ctx, parent)
+	if err != nil {
+		return -1
+	}
+	h, err := unixfs.CodedHeader(nd)
+	if err != nil || h.Generation < 0 {
+		return -1
+	}
+	// The header of a generation parent has the size of its own generation,
+	// the last one of a file being smaller.
+	return h.GenerationSize + h.Redundancy
+}
+
+// codedBlocks returns the coded blocks listed in idx.
+func codedBlocks(idx coding.Index) (*cid.Set, error) {
+	set := cid.NewSet()
+	for _, cd := range indexedCodings() {
+		parents, err := idx.Parents(cd)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parents {
+			children, err := idx.Coded(p, cd)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range children {
+				set.Add(c)
+			}
+		}
+	}
+	return set, nil
+}
+
+// indexedCodings returns the codings whose coded index GC keeps in sync with
+// the blockstore: every registered coding scheme.
+func indexedCodings() []string {
+	return coding.Names()
+}
+
+// pruneCodedIndex forgets the removed coded blocks in idx.
+func pruneCodedIndex(idx coding.Index, removed *cid.Set) error {
+	if removed.Len() == 0 {
+		return nil
+	}
+	for _, cd := range indexedCodings() {
+		parents, err := idx.Parents(cd)
+		if err != nil {
+			return err
+		}
+		for _, p := range parents {
+			children, err := idx.Coded(p, cd)
+			if err != nil {
+				return err
+			}
+			var gone []cid.Cid
+			for _, c := range children {
+				if removed.Has(c) {
+					gone = append(gone, c)
+				}
+			}
+			if len(gone) == 0 {
+				continue
+			}
+			if err := idx.Remove(p, cd, gone...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Descendants recursively finds all the descendants of the given roots and
 // adds them to the given cid.Set, using the provided dag.GetLinks function
 // to walk the tree.
@@ -0,0 +1,17 @@
+package plugin
+
+import (
+	"github.com/ipfs/go-block-format/coding"
+	chunk "github.com/ipfs/go-ipfs-chunker"
+)
+
+// PluginCoding is an interface that can be implemented to add coding schemes
+// for coded files, next to "nc" and "rs"
+type PluginCoding interface {
+	Plugin
+
+	CodingScheme() coding.Scheme
+	// CodedChunker parses the chunker strings of the scheme, like
+	// "<name>-<params>", or is nil when files cannot be added with it.
+	CodedChunker() chunk.CodedParser
+}
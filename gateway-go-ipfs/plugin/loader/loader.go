@@ -17,6 +17,8 @@ import (
 	plugin "github.com/ipfs/go-ipfs/plugin"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 
+	"github.com/ipfs/go-block-format/coding"
+	chunk "github.com/ipfs/go-ipfs-chunker"
 	ipld "github.com/ipfs/go-ipld-format"
 	logging "github.com/ipfs/go-log"
 	opentracing "github.com/opentracing/opentracing-go"
@@ -248,6 +250,13 @@ func (loader *PluginLoader) Inject() error {
 				return err
 			}
 		}
+		if pl, ok := pl.(plugin.PluginCoding); ok {
+			err := injectCodingPlugin(pl)
+			if err != nil {
+				loader.state = loaderFailed
+				return err
+			}
+		}
 		if pl, ok := pl.(plugin.PluginTracer); ok {
 			err := injectTracerPlugin(pl)
 			if err != nil {
@@ -342,6 +351,17 @@ func injectIPLDPlugin(pl plugin.PluginIPLD) error {
 	return pl.RegisterInputEncParsers(coredag.DefaultInputEncParsers)
 }
 
+func injectCodingPlugin(pl plugin.PluginCoding) error {
+	scheme := pl.CodingScheme()
+	if err := coding.Register(scheme); err != nil {
+		return err
+	}
+	if parse := pl.CodedChunker(); parse != nil {
+		return chunk.RegisterCoded(scheme.Name(), parse)
+	}
+	return nil
+}
+
 func injectTracerPlugin(pl plugin.PluginTracer) error {
 	tracer, err := pl.InitTracer()
 	if err != nil {
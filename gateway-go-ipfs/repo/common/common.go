@@ -1,10 +1,15 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// ErrKeyNotFound is wrapped by the error MapGetKV returns for a key that is
+// not set.
+var ErrKeyNotFound = errors.New("key has no attributes")
+
 func MapGetKV(v map[string]interface{}, key string) (interface{}, error) {
 	var ok bool
 	var mcursor map[string]interface{}
@@ -21,7 +26,7 @@ func MapGetKV(v map[string]interface{}, key string) (interface{}, error) {
 
 		cursor, ok = mcursor[part]
 		if !ok {
-			return nil, fmt.Errorf("%s key has no attributes", sofar)
+			return nil, fmt.Errorf("%s %w", sofar, ErrKeyNotFound)
 		}
 	}
 	return cursor, nil
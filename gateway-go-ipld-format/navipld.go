@@ -67,6 +67,22 @@ func NewNavigableIPLDNodeC(node Node, nodeGetter NodeGetter, cid cid.Cid, coding
 	return nnc
 }
 
+// NewNavigableIPLDNodeCCount returns a `NavigableIPLDNodeC` fetching
+// `count` coded children of `node` instead of as many as it links to.
+// Coded children are interchangeable, so `count` may be larger than the
+// number of links when peers can produce new packets.
+func NewNavigableIPLDNodeCCount(node Node, nodeGetter NodeGetter, cid cid.Cid, coding string, count int) *NavigableIPLDNodeC {
+	nnc := NewNavigableIPLDNodeC(node, nodeGetter, cid, coding)
+	nnc.childPromises = make([]*NodePromise, count)
+	return nnc
+}
+
+// ChildTotal implements the `NavigableNode` interface returning the number
+// of coded children fetched.
+func (nn *NavigableIPLDNodeC) ChildTotal() uint {
+	return uint(len(nn.childPromises))
+}
+
 
 // FetchChild implements the `NavigableNode` interface using node promises
 // to preload the following child nodes to `childIndex` leaving them ready
@@ -121,7 +137,7 @@ func (nn *NavigableIPLDNodeC) FetchChild(ctx context.Context, childIndex uint) (
 	//fmt.Println("Debug: FetchChildC,", childIndex)
 
 	if childIndex == 0{
-		nn.preload(ctx, uint(len(nn.childCIDs)))
+		nn.preload(ctx, 0)
 	}
 
         fmt.Println("Debug: navinodec - FetchChild, get promise", childIndex)
@@ -172,12 +188,13 @@ func (nn *NavigableIPLDNode) preload(ctx context.Context, beg uint) {
 	copy(nn.childPromises[beg:], GetNodes(ctx, nn.nodeGetter, nn.childCIDs[beg:end]))
 }
 
-// Preload at most `preloadSize` child nodes from `beg` through promises
-// created using this `ctx`.
+// Preload the child nodes from `beg` on through promises created using
+// this `ctx`. Coded children are interchangeable, the promises are
+// fulfilled in the order the packets arrive.
 func (nn *NavigableIPLDNodeC) preload(ctx context.Context, beg uint) {
 	//fmt.Println("Debug: preloadC", beg)
 
-	copy(nn.childPromises, GetNodesC(ctx, nn.nodeGetter, nn.cid, nn.coding, int(beg)))
+	copy(nn.childPromises[beg:], GetNodesC(ctx, nn.nodeGetter, nn.cid, nn.coding, len(nn.childPromises)-int(beg)))
 }
 
 
@@ -0,0 +1,53 @@
+package unixfs
+
+import (
+	"github.com/ipfs/go-block-format/coding"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+// CodedNode returns a node of a coded file carrying the header h and
+// linking to children: the generation parents for the root of the file,
+// the coded leaves for a generation parent. The node is a unixfs file whose
+// data is the header, so DAG walkers and MFS handle coded files like any
+// other file.
+func CodedNode(h *coding.Header, children ...ipld.Node) (*dag.ProtoNode, error) {
+	fsn := NewFSNode(TFile)
+	fsn.SetData(h.Marshal())
+
+	nd := new(dag.ProtoNode)
+	for _, child := range children {
+		childFsn, err := ExtractFSNode(child)
+		if err != nil {
+			return nil, err
+		}
+		if err := nd.AddNodeLink("", child); err != nil {
+			return nil, err
+		}
+		fsn.AddBlockSize(childFsn.FileSize())
+	}
+
+	b, err := fsn.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+	nd.SetData(b)
+	return nd, nil
+}
+
+// CodedHeader returns the header carried by a node of a coded file, or
+// `coding.ErrNotHeader` for any other node.
+func CodedHeader(n ipld.Node) (*coding.Header, error) {
+	pn, ok := n.(*dag.ProtoNode)
+	if !ok {
+		return nil, coding.ErrNotHeader
+	}
+	fsn, err := FSNodeFromBytes(pn.Data())
+	if err != nil {
+		return nil, err
+	}
+	if fsn.Type() != TFile {
+		return nil, coding.ErrNotHeader
+	}
+	return coding.UnmarshalHeader(fsn.Data())
+}
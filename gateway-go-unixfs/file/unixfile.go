@@ -12,7 +12,6 @@ import (
 	dag "github.com/ipfs/go-merkledag"
 
 	logging "github.com/ipfs/go-log"
-	cid "github.com/ipfs/go-cid"
 )
 
 var log = logging.Logger("unixfile")
@@ -86,7 +85,7 @@ func (it *ufsIterator) Next() bool {
 	}
 
 	it.curName = l.Name
-	it.curFile, it.err = NewUnixfsFile(it.ctx, it.dserv, nd, "", cid.Cid{})
+	it.curFile, it.err = NewUnixfsFile(it.ctx, it.dserv, nd, "")
 	return it.err == nil
 }
 
@@ -138,6 +137,23 @@ func (f *ufsFile) Size() (int64, error) {
 	return int64(f.DagReader.Size()), nil
 }
 
+// CodedFile is a file decoded from coded packets.
+type CodedFile interface {
+	files.File
+	// Stats returns the packets decoded so far.
+	Stats() uio.CodedStats
+	// Symbols returns the number of packets decoding the file takes.
+	Symbols() int
+}
+
+type ufsCodedFile struct {
+	uio.CodedDagReader
+}
+
+func (f *ufsCodedFile) Size() (int64, error) {
+	return int64(f.CodedDagReader.Size()), nil
+}
+
 func newUnixfsDir(ctx context.Context, dserv ipld.DAGService, nd *dag.ProtoNode) (files.Directory, error) {
 	dir, err := uio.NewDirectoryFromNode(dserv, nd)
 	if err != nil {
@@ -158,7 +174,15 @@ func newUnixfsDir(ctx context.Context, dserv ipld.DAGService, nd *dag.ProtoNode)
 	}, nil
 }
 
-func NewUnixfsFile(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, cd string, p cid.Cid) (files.Node, error) {
+// NewUnixfsFile returns a handle on the file or directory `nd`. A file is
+// read from the coded packets of `cd` when it is set, as a `CodedFile`.
+func NewUnixfsFile(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, cd string) (files.Node, error) {
+	return NewUnixfsFileC(ctx, dserv, nd, cd, -1)
+}
+
+// NewUnixfsFileC is NewUnixfsFile fetching the packets of coded files with
+// the given redundancy, see `uio.NewRedundantDagReaderC`.
+func NewUnixfsFileC(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, cd string, redundancy float64) (files.Node, error) {
 	//fmt.Println("Debug: newfile")
 	switch dn := nd.(type) {
 	case *dag.ProtoNode:
@@ -179,13 +203,13 @@ func NewUnixfsFile(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, cd
 	}
 
 	if cd != "" {
-		dr, err := uio.NewDagReaderC(ctx, nd, dserv, p, cd)
+		dr, err := uio.NewRedundantDagReaderC(ctx, nd, dserv, cd, redundancy)
 		if err != nil {
 			return nil, err
 		}
 
-		return &ufsFile{
-			DagReader: dr,
+		return &ufsCodedFile{
+			CodedDagReader: dr,
 		}, nil
 
 	}
@@ -201,3 +225,4 @@ func NewUnixfsFile(ctx context.Context, dserv ipld.DAGService, nd ipld.Node, cd
 
 var _ files.Directory = &ufsDirectory{}
 var _ files.File = &ufsFile{}
+var _ CodedFile = &ufsCodedFile{}
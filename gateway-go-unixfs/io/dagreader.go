@@ -7,14 +7,16 @@ import (
 	"fmt"
 	"io"
 	"math"
-        "time"
+	"time"
 
+	coding "github.com/ipfs/go-block-format/coding"
 	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
 	mdag "github.com/ipfs/go-merkledag"
 	unixfs "github.com/ipfs/go-unixfs"
-	logging "github.com/ipfs/go-log"
-	cid "github.com/ipfs/go-cid"
-
+	// Register the built-in coding schemes.
+	_ "github.com/ipfs/go-block-format/coding/rlnc"
+	_ "github.com/ipfs/go-block-format/coding/rs"
 )
 
 var log = logging.Logger("unixfs-io")
@@ -24,6 +26,8 @@ var (
 	ErrIsDir            = errors.New("this dag node is a directory")
 	ErrCantReadSymlinks = errors.New("cannot currently read symlinks")
 	ErrUnkownNodeType   = errors.New("unknown node type")
+	ErrUnknownCoding    = errors.New("unknown coding scheme")
+	ErrNotCoded         = errors.New("this dag node is not a coded file")
 )
 
 // TODO: Rename the `DagReader` interface, this doesn't read *any* DAG, just
@@ -147,6 +151,19 @@ func (dr *dagReader) Size() uint64 {
 	return dr.size
 }
 
+// Symbols implements the `CodedDagReader` interface.
+func (dr *dagReaderC) Symbols() int {
+	scheme, err := coding.Lookup(dr.header.Scheme)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for g := 0; g < dr.header.Generations(); g++ {
+		n += scheme.GenerationSymbols(dr.header, g)
+	}
+	return n
+}
+
 // Read implements the `io.Reader` interface through the `CtxReadFull`
 // method using the DAG reader's internal context.
 func (dr *dagReader) Read(b []byte) (int, error) {
@@ -504,125 +521,295 @@ func (dr *dagReader) resetPosition() {
 	// `dr.serv` just for this call) if `Reset` is supported in the `Walker`.
 }
 
+// dagReaderC reads a file stored as coded packets of a registered coding
+// scheme, like network coded ("nc") packets or Reed-Solomon ("rs") shards.
+// The root of the file carries a
+// header describing the coding and links to the parents of its
+// generations, every leaf of which carries one packet. Generations are
+// decoded one at a time: the leaves of the generation covering the read
+// position are fetched in whatever order the exchange delivers them and fed
+// to a decoder, and data is returned as soon as the source symbols covering
+// the read position are solved.
 type dagReaderC struct {
 	dagReader
-	coding string
-	cid cid.Cid
-	rdata int
+	header *coding.Header
+
+	// The generation being decoded, its decoder and the cancel function
+	// of its fetches.
+	generation int
+	decoder    coding.Decoder
+	cancelGen  context.CancelFunc
+
+	// redundancy is the share of packets fetched per generation beyond its
+	// symbols, every linked leaf being fetched when it is negative.
+	redundancy float64
+	stats      CodedStats
+}
+
+// CodedStats describes the packets a coded reader fed to its decoders.
+type CodedStats struct {
+	// Packets is the number of packets received.
+	Packets int
+	// Duplicates is the number of packets that were not innovative, or
+	// arrived once their generation was decoded.
+	Duplicates int
+	// DecodeTime is the time spent decoding the packets.
+	DecodeTime time.Duration
+}
+
+// A CodedDagReader is a DagReader of a coded file.
+type CodedDagReader interface {
+	DagReader
+	// Stats returns the packets received so far.
+	Stats() CodedStats
+	// Symbols returns the number of source symbols of the file, which is
+	// the number of innovative packets decoding it takes.
+	Symbols() int
+}
+
+// NewDagReaderC creates a reader for a coded file with the given coding
+// scheme, `n` being the root of the file. The coding is taken from the
+// header of the root, and the file size is the size of the decoded data, not
+// that of the packets. The parent of the first generation is fetched before
+// returning. Every leaf the generation parents link to may be fetched.
+func NewDagReaderC(ctx context.Context, n ipld.Node, serv ipld.NodeGetter, cd string) (CodedDagReader, error) {
+	return NewRedundantDagReaderC(ctx, n, serv, cd, -1)
+}
+
+// NewRedundantDagReaderC is NewDagReaderC fetching the packets of a
+// generation of `k` symbols `ceil(k*(1+redundancy))` at a time, whatever
+// the number of leaves its parent links to, so that a few lost packets do
+// not stall the reader. A negative redundancy fetches every linked leaf.
+func NewRedundantDagReaderC(ctx context.Context, n ipld.Node, serv ipld.NodeGetter, cd string, redundancy float64) (CodedDagReader, error) {
+	if _, err := coding.Lookup(cd); err != nil {
+		return nil, ErrUnknownCoding
 	}
 
-// NewDagReader creates a new reader object that reads the data represented by
-// the given node, using the passed in DAGService for data retrieval.
-func NewDagReaderC(ctx context.Context, n ipld.Node, serv ipld.NodeGetter, cid cid.Cid, cd string) (DagReader, error) {
-	fmt.Println("Debug: creating coded Dagreader")
+	header, err := unixfs.CodedHeader(n)
+	if err == coding.ErrNotHeader {
+		return nil, ErrNotCoded
+	} else if err != nil {
+		return nil, err
+	}
+	if header.Generation >= 0 {
+		return nil, ErrNotCoded
+	}
+	if header.Scheme != cd {
+		return nil, fmt.Errorf("file is coded with %s, not %s", header.Scheme, cd)
+	}
+	if len(n.Links()) != header.Generations() {
+		return nil, fmt.Errorf("coded file of %d generations has %d generation parents", header.Generations(), len(n.Links()))
+	}
 
-	var size uint64
+	ctxWithCancel, cancel := context.WithCancel(ctx)
+	dr := &dagReaderC{
+		dagReader: dagReader{
+			ctx:      ctxWithCancel,
+			cancel:   cancel,
+			serv:     serv,
+			rootNode: n,
+			size:     header.Length,
+		},
+		header:     header,
+		redundancy: redundancy,
+	}
+	if err := dr.loadGeneration(dr.ctx, 0); err != nil {
+		cancel()
+		return nil, err
+	}
+	return dr, nil
+}
 
-	switch n := n.(type) {
-	case *mdag.RawNode:
-		size = uint64(len(n.RawData()))
+// loadGeneration fetches the parent of generation g with `ctx` and starts
+// decoding it, its packets being fetched with `ctx` too. The generation
+// decoded so far is dropped, and its pending fetches are canceled, so that
+// one generation at most is held in memory.
+func (dr *dagReaderC) loadGeneration(ctx context.Context, g int) error {
+	parent, err := dr.rootNode.Links()[g].GetNode(ctx, dr.serv)
+	if err != nil {
+		return err
+	}
+	h, err := unixfs.CodedHeader(parent)
+	if err != nil || h.Generation != g || h.Scheme != dr.header.Scheme {
+		return fmt.Errorf("parent of generation %d of the coded file is invalid", g)
+	}
+	decoder, err := coding.NewDecoder(h)
+	if err == coding.ErrUnknownScheme {
+		return ErrUnknownCoding
+	} else if err != nil {
+		return err
+	}
 
-	case *mdag.ProtoNode:
-		fsNode, err := unixfs.FSNodeFromBytes(n.Data())
-		if err != nil {
-			return nil, err
-		}
+	if dr.cancelGen != nil {
+		dr.cancelGen()
+	}
+	genCtx, cancelGen := context.WithCancel(ctx)
+	dr.generation = g
+	dr.decoder = decoder
+	dr.cancelGen = cancelGen
+	var nn *ipld.NavigableIPLDNodeC
+	if dr.redundancy < 0 {
+		nn = ipld.NewNavigableIPLDNodeC(parent, dr.serv, parent.Cid(), h.Scheme)
+	} else {
+		count := int(math.Ceil(float64(h.GenerationSize) * (1 + dr.redundancy)))
+		nn = ipld.NewNavigableIPLDNodeCCount(parent, dr.serv, parent.Cid(), h.Scheme, count)
+	}
+	dr.dagWalker = ipld.NewWalker(genCtx, nn)
+	return nil
+}
 
-		switch fsNode.Type() {
-		case unixfs.TFile, unixfs.TRaw:
-			size = fsNode.FileSize()
+// Stats implements the `CodedDagReader` interface.
+func (dr *dagReaderC) Stats() CodedStats {
+	return dr.stats
+}
 
-		case unixfs.TDirectory, unixfs.THAMTShard:
-			// Dont allow reading directories
-			return nil, ErrIsDir
+// Read implements the `io.Reader` interface through the `CtxReadFull`
+// method using the DAG reader's internal context.
+func (dr *dagReaderC) Read(b []byte) (int, error) {
+	return dr.CtxReadFull(dr.ctx, b)
+}
 
-		case unixfs.TMetadata:
-			//fmt.Println("Debug: ndrc-tmetadata")
-			if len(n.Links()) == 0 {
-				return nil, errors.New("incorrectly formatted metadata object")
-			}
-			child, err := n.Links()[0].GetNode(ctx, serv)
-			if err != nil {
-				return nil, err
-			}
+// CtxReadFull reads decoded data from the current offset, fetching packets
+// until the symbols it covers are solved. It fails with the `ErrNotDecoded`
+// of the coding scheme if the file has no more packets to fetch.
+func (dr *dagReaderC) CtxReadFull(ctx context.Context, out []byte) (n int, err error) {
+	dr.dagWalker.SetContext(ctx)
 
-			childpb, ok := child.(*mdag.ProtoNode)
-			if !ok {
-				return nil, mdag.ErrNotProtobuf
-			}
-			return NewDagReader(ctx, childpb, serv)
-		case unixfs.TSymlink:
-			return nil, ErrCantReadSymlinks
-		default:
-			return nil, unixfs.ErrUnrecognizedType
+	for n < len(out) {
+		data, err := dr.dataAt(ctx, dr.offset)
+		if err != nil {
+			return n, err
 		}
-	default:
-		return nil, ErrUnkownNodeType
+
+		m := copy(out[n:], data)
+		n += m
+		dr.offset += int64(m)
 	}
+	return n, nil
+}
 
-	ctxWithCancel, cancel := context.WithCancel(ctx)
+// WriteTo writes the decoded data from the current offset to `w`, every
+// symbol as soon as it and the ones before it are solved.
+func (dr *dagReaderC) WriteTo(w io.Writer) (n int64, err error) {
+	for {
+		data, err := dr.dataAt(dr.ctx, dr.offset)
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
 
-	if cd == "nc" {
-		var  defaultBlockSize float64  = float64(n.Links()[0].Size)-14 //1024*256
-		fmt.Println("Newdagreader: size ",size," new ",uint64(math.Floor(float64(size)/defaultBlockSize)*(defaultBlockSize-3-math.Floor(float64(size)/defaultBlockSize))))
-		fmt.Println("links", len(n.Links()), "link size", n.Links()[0].Size)
-		size=uint64(math.Floor(float64(size)/defaultBlockSize)*(defaultBlockSize-3-math.Floor(float64(size)/defaultBlockSize)))
+		m, err := w.Write(data)
+		n += int64(m)
+		dr.offset += int64(m)
+		if err != nil {
+			return n, err
+		}
 	}
+}
 
-	dr:= dagReader{
-		ctx:       ctxWithCancel,
-		cancel:    cancel,
-		serv:      serv,
-		size:      size,
-		rootNode:  n,
-		dagWalker: ipld.NewWalker(ctxWithCancel, ipld.NewNavigableIPLDNodeC(n, serv, cid, cd)),
+// Seek implements `io.Seeker`. Seeking never fetches anything: packets
+// are only requested when reading from a symbol that is not solved yet, so
+// moving around in the generation decoded so far is free. Reading from
+// another generation decodes it anew.
+func (dr *dagReaderC) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += dr.offset
+	case io.SeekEnd:
+		offset += int64(dr.Size())
+	default:
+		return 0, errors.New("invalid whence")
 	}
-	return &dagReaderC{dr,
-		cd,
-		cid,
-		0,
-	}, nil
+
+	if offset < 0 {
+		return -1, errors.New("invalid offset")
+	}
+
+	dr.offset = offset
+	return offset, nil
 }
 
-// WriteTo writes to the given writer.
-// This follows the `bytes.Reader.WriteTo` implementation
-// where it starts from the internal index that may have
-// been modified by other `Read` calls.
-//
-// TODO: This implementation is very similar to `CtxReadFull`,
-// the common parts should be abstracted away.
-func (dr *dagReaderC) WriteTo(w io.Writer) (n int64, err error) {
-	fmt.Println("Debug: dagreader iterating coded node, size",dr.size)
-	// Use the internal reader's context to fetch the child node promises
-	// (see `ipld.NavigableIPLDNode.FetchChild` for details).
-	dr.dagWalker.SetContext(dr.ctx)
+// dataAt returns the decoded data from `offset` to the end of its symbol,
+// switching to the generation of `offset` and fetching packets with `ctx`
+// until that symbol is solved, or `io.EOF` at the end of the file.
+func (dr *dagReaderC) dataAt(ctx context.Context, offset int64) ([]byte, error) {
+	if offset >= int64(dr.Size()) {
+		return nil, io.EOF
+	}
 
-	var defaultBlockSize uint32 = uint32(dr.rootNode.Links()[0].Size)-14 //1024*256
-	symbols := uint32(math.Ceil(float64(defaultBlockSize-3)/2 - math.Sqrt((float64(defaultBlockSize-3)/2)*(float64(defaultBlockSize-3)/2)-float64(dr.size))))
-	symbolSize := uint32(defaultBlockSize) - symbols - 3
+	capacity := int64(dr.header.GenerationCapacity())
+	if g := int(offset / capacity); g != dr.generation {
+		if err := dr.loadGeneration(ctx, g); err != nil {
+			return nil, err
+		}
+	}
+	genOffset := offset % capacity
 
-	fmt.Println()
+	symbolSize := int64(dr.decoder.SymbolSize())
+	i := int(genOffset / symbolSize)
+	for !dr.decoder.IsDecoded(i) {
+		if err := dr.fetchPacket(); err != nil {
+			return nil, err
+		}
+	}
 
-	// If there was a partially read buffer from the last visited
-	// node read it before visiting a new one.
+	data := dr.decoder.Symbol(i)[genOffset%symbolSize:]
+	if left := int64(dr.Size()) - offset; int64(len(data)) > left {
+		data = data[:left]
+	}
+	return data, nil
+}
+
+// fetchPacket feeds the next coded leaf to the decoder, whether or not it
+// turns out to be innovative. It returns the `ErrNotDecoded` of the coding
+// scheme once every leaf was visited without solving the file.
+func (dr *dagReaderC) fetchPacket() error {
+	payload, err := dr.nextPayload()
+	if err != nil {
+		return err
+	}
+	if payload == nil {
+		return dr.decoder.NotDecoded()
+	}
 
+	start := time.Now()
+	innovative, err := dr.decoder.Add(payload)
+	dr.stats.DecodeTime += time.Since(start)
+	dr.stats.Packets++
+	if !innovative {
+		dr.stats.Duplicates++
+	}
+	return err
+}
 
-	// Iterate the DAG calling the passed `Visitor` function on every node
-	// to read its data into the `out` buffer, stop if there is an error or
-	// if the entire DAG is traversed (`EndOfDag`).
-	err = dr.dagWalker.Iterate(func(visitedNode ipld.NavigableNode) error {
-                // fmt.Println("Debug: dagreaderc - visiting node", visitedNode)
+// nextPayload returns the payload of the next coded leaf, or nil once every
+// leaf was visited or the exchange has no more to send.
+func (dr *dagReaderC) nextPayload() ([]byte, error) {
+	var payload []byte
+	err := dr.dagWalker.Iterate(func(visitedNode ipld.NavigableNode) error {
 		node := ipld.ExtractIPLDNode(visitedNode)
 
+		// Skip the generation parent, the packets are its leaves.
+		if len(node.Links()) > 0 {
+			return nil
+		}
+
+		data, err := unixfs.ReadUnixFSNodeData(node)
+		if err != nil {
+			return err
+		}
+		payload = data
+
+		dr.dagWalker.Pause()
 		return nil
 	})
 
-	// todo decode write buffer to w
-	// todo copy?
-
-	if err == ipld.EndOfDag{
-		return n, nil
+	// The fetch ending before every promised child arrived leaves no more
+	// packets either.
+	if err == ipld.EndOfDag || err == ipld.ErrNotFound {
+		return nil, nil
 	}
-
-	return int64(m), err
+	return payload, err
 }
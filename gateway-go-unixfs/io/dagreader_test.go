@@ -4,9 +4,15 @@ import (
 	"bytes"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"strings"
 	"testing"
 
+	coding "github.com/ipfs/go-block-format/coding"
+	rlnc "github.com/ipfs/go-block-format/coding/rlnc"
+	rs "github.com/ipfs/go-block-format/coding/rs"
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
 	mdag "github.com/ipfs/go-merkledag"
 	"github.com/ipfs/go-unixfs"
 
@@ -239,6 +245,429 @@ func TestReaderSzie(t *testing.T) {
 	}
 }
 
+// codedGetter delivers the children of the generation parents of a coded
+// file in a fixed order, standing in for the exchange.
+type codedGetter struct {
+	ipld.DAGService
+	order map[cid.Cid][]cid.Cid
+}
+
+func (g *codedGetter) GetManyC(ctx context.Context, parent cid.Cid, coding string, count int) <-chan *ipld.NodeOption {
+	order := g.order[parent]
+	out := make(chan *ipld.NodeOption, len(order))
+	for i, c := range order {
+		if i == count {
+			break
+		}
+		nd, err := g.Get(ctx, c)
+		out <- &ipld.NodeOption{Node: nd, Err: err}
+	}
+	close(out)
+	return out
+}
+
+// keep only delivers the first n children of every generation.
+func (g *codedGetter) keep(n int) {
+	for p, order := range g.order {
+		g.order[p] = order[:n]
+	}
+}
+
+// getCodedNode stores `data` as a coded file of one generation of
+// `packets` packets, the first ones being the source symbols if
+// `systematic` is set.
+func getCodedNode(t testing.TB, data []byte, packets int, systematic bool) (ipld.Node, *codedGetter) {
+	symbols := packets
+	symbolSize := (len(data) + symbols - 1) / symbols
+	h := &coding.Header{
+		Scheme:         "nc",
+		Field:          "gf256",
+		GenerationSize: symbols,
+		SymbolSize:     symbolSize,
+		Length:         uint64(len(data)),
+		Generation:     -1,
+	}
+	return storeCodedFile(t, h, [][][]byte{encodeGeneration(t, data, symbols, symbolSize, packets, systematic)})
+}
+
+// getGenerationsNode stores `data` as a coded file of generations of
+// `symbols` symbols of `symbolSize` bytes, with one packet per symbol.
+func getGenerationsNode(t testing.TB, data []byte, symbols, symbolSize int) (ipld.Node, *codedGetter) {
+	h := &coding.Header{
+		Scheme:         "nc",
+		Field:          "gf256",
+		GenerationSize: symbols,
+		SymbolSize:     symbolSize,
+		Length:         uint64(len(data)),
+		Generation:     -1,
+	}
+	capacity := symbols * symbolSize
+	var generations [][][]byte
+	for start := 0; start < len(data); start += capacity {
+		gen := data[start:]
+		if len(gen) > capacity {
+			gen = gen[:capacity]
+		}
+		n := (len(gen) + symbolSize - 1) / symbolSize
+		generations = append(generations, encodeGeneration(t, gen, n, symbolSize, n, false))
+	}
+	return storeCodedFile(t, h, generations)
+}
+
+func encodeGeneration(t testing.TB, data []byte, symbols, symbolSize, packets int, systematic bool) [][]byte {
+	var opts []rlnc.Option
+	if systematic {
+		opts = append(opts, rlnc.Systematic())
+	}
+	enc, err := rlnc.NewEncoder(data, symbols, symbolSize, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make([][]byte, packets)
+	for i := range out {
+		out[i] = enc.Encode().Marshal()
+	}
+	return out
+}
+
+// getRSNode stores `data` as a Reed-Solomon coded file of `k` data and `m`
+// parity shards.
+func getRSNode(t testing.TB, data []byte, k, m int) (ipld.Node, *codedGetter) {
+	enc, err := rs.NewEncoder(data, k, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shards [][]byte
+	for _, s := range enc.Shards() {
+		shards = append(shards, s.Marshal())
+	}
+	return storeCodedFile(t, &coding.Header{
+		Scheme:         "rs",
+		Field:          "gf256",
+		GenerationSize: k,
+		SymbolSize:     enc.ShardSize(),
+		Redundancy:     m,
+		Length:         uint64(len(data)),
+		Generation:     -1,
+	}, [][][]byte{shards})
+}
+
+// storeCodedFile stores a coded file described by `h`, with one leaf per
+// packet of each generation. The leaves are delivered in random order.
+func storeCodedFile(t testing.TB, h *coding.Header, generations [][][]byte) (ipld.Node, *codedGetter) {
+	dserv := testu.GetDAGServ()
+	g := &codedGetter{DAGService: dserv, order: make(map[cid.Cid][]cid.Cid)}
+	var parents []ipld.Node
+	for i, packets := range generations {
+		var leaves []ipld.Node
+		for _, packet := range packets {
+			leaves = append(leaves, mdag.NodeWithData(unixfs.FilePBData(packet, uint64(len(packet)))))
+		}
+		gh := h.ForGeneration(i)
+		if i > 0 && i == len(generations)-1 {
+			// The last of several generations only has the symbols its
+			// data needs.
+			gh.GenerationSize = (int(gh.Length) + h.SymbolSize - 1) / h.SymbolSize
+		}
+		parent, err := unixfs.CodedNode(gh, leaves...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dserv.AddMany(context.Background(), append(leaves, parent)); err != nil {
+			t.Fatal(err)
+		}
+		order := make([]cid.Cid, len(leaves))
+		for j, leaf := range leaves {
+			order[j] = leaf.Cid()
+		}
+		rand.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+		g.order[parent.Cid()] = order
+		parents = append(parents, parent)
+	}
+
+	root, err := unixfs.CodedNode(h, parents...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.Add(context.Background(), root); err != nil {
+		t.Fatal(err)
+	}
+	return root, g
+}
+
+func TestCodedWriteTo(t *testing.T) {
+	for _, systematic := range []bool{true, false} {
+		inbuf := make([]byte, 40*100)
+		rand.Read(inbuf)
+		node, g := getCodedNode(t, inbuf, 40, systematic)
+		ctx, closer := context.WithCancel(context.Background())
+		defer closer()
+
+		reader, err := NewDagReaderC(ctx, node, g, "nc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reader.Size() != uint64(len(inbuf)) {
+			t.Fatalf("expected size %d, got %d", len(inbuf), reader.Size())
+		}
+
+		outbuf := new(bytes.Buffer)
+		if _, err := reader.WriteTo(outbuf); err != nil {
+			t.Fatal(err)
+		}
+		if err := testu.ArrComp(inbuf, outbuf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCodedSeekAndRead(t *testing.T) {
+	inbuf := make([]byte, 16*64)
+	rand.Read(inbuf)
+	node, g := getCodedNode(t, inbuf, 16, true)
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReaderC(ctx, node, g, "nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, i := range []int{700, 3, 1023, 0, 512} {
+		if _, err := reader.Seek(int64(i), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if out := readByte(t, reader); out != inbuf[i] {
+			t.Fatalf("read %d at index %d, expected %d", out, i, inbuf[i])
+		}
+		if getOffset(reader) != int64(i+1) {
+			t.Fatal("expected offset to be increased by one after read")
+		}
+	}
+
+	if _, err := reader.Seek(-10, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testu.ArrComp(inbuf[len(inbuf)-10:], out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodedMissingPackets(t *testing.T) {
+	inbuf := make([]byte, 10*100)
+	node, g := getCodedNode(t, inbuf, 10, false)
+	g.keep(9)
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReaderC(ctx, node, g, "nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(reader); err == nil {
+		t.Fatal("expected an error decoding from too few packets")
+	}
+
+	if _, err := NewDagReaderC(ctx, node, g, "xx"); err != ErrUnknownCoding {
+		t.Fatalf("expected ErrUnknownCoding, got %v", err)
+	}
+	if _, err := NewDagReaderC(ctx, node, g, "rs"); err == nil {
+		t.Fatal("expected an error reading an nc file as rs")
+	}
+	// Only the root carries the header of the whole file.
+	parent, err := node.Links()[0].GetNode(ctx, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDagReaderC(ctx, parent, g, "nc"); err != ErrNotCoded {
+		t.Fatalf("expected ErrNotCoded, got %v", err)
+	}
+	plain := mdag.NodeWithData(unixfs.FilePBData([]byte("plain"), 5))
+	if _, err := NewDagReaderC(ctx, plain, g, "nc"); err != ErrNotCoded {
+		t.Fatalf("expected ErrNotCoded, got %v", err)
+	}
+}
+
+func TestRSWriteTo(t *testing.T) {
+	inbuf := make([]byte, 1000)
+	rand.Read(inbuf)
+	node, g := getRSNode(t, inbuf, 6, 3)
+	// Any 6 of the 9 shards are enough.
+	g.keep(6)
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReaderC(ctx, node, g, "rs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The padding of the last data shard is not part of the file.
+	if reader.Size() != uint64(len(inbuf)) {
+		t.Fatalf("expected size %d, got %d", len(inbuf), reader.Size())
+	}
+
+	outbuf := new(bytes.Buffer)
+	if _, err := reader.WriteTo(outbuf); err != nil {
+		t.Fatal(err)
+	}
+	if err := testu.ArrComp(inbuf, outbuf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRSMissingShards(t *testing.T) {
+	inbuf := make([]byte, 1000)
+	node, g := getRSNode(t, inbuf, 6, 3)
+	g.keep(5)
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReaderC(ctx, node, g, "rs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(reader); err != rs.ErrNotDecoded {
+		t.Fatalf("expected rs.ErrNotDecoded, got %v", err)
+	}
+}
+
+func TestCodedGenerations(t *testing.T) {
+	// Generations of 4 symbols of 100 bytes, the last one of 2 symbols.
+	inbuf := make([]byte, 2*400+150)
+	rand.Read(inbuf)
+	node, g := getGenerationsNode(t, inbuf, 4, 100)
+	if len(node.Links()) != 3 {
+		t.Fatalf("expected 3 generation parents, got %d", len(node.Links()))
+	}
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReaderC(ctx, node, g, "nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := reader.Symbols(); n != 10 {
+		t.Fatalf("expected 10 source symbols, got %d", n)
+	}
+	outbuf := new(bytes.Buffer)
+	if _, err := reader.WriteTo(outbuf); err != nil {
+		t.Fatal(err)
+	}
+	if err := testu.ArrComp(inbuf, outbuf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if st := reader.Stats(); st.Packets != reader.Symbols() {
+		t.Fatalf("expected %d packets, got %d", reader.Symbols(), st.Packets)
+	}
+
+	// Going back to an earlier generation decodes it again.
+	for _, i := range []int{900, 10, 420} {
+		if _, err := reader.Seek(int64(i), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if out := readByte(t, reader); out != inbuf[i] {
+			t.Fatalf("read %d at index %d, expected %d", out, i, inbuf[i])
+		}
+	}
+
+	// Only one generation is decoded at a time.
+	dr := reader.(*dagReaderC)
+	if dr.generation != 1 {
+		t.Fatalf("expected to decode generation 1, got %d", dr.generation)
+	}
+}
+
+type readCtxKey struct{}
+
+// ctxGetter records the read contexts its fetches are made with.
+type ctxGetter struct {
+	*codedGetter
+	reads []interface{}
+}
+
+func (g *ctxGetter) GetManyC(ctx context.Context, parent cid.Cid, coding string, count int) <-chan *ipld.NodeOption {
+	g.reads = append(g.reads, ctx.Value(readCtxKey{}))
+	return g.codedGetter.GetManyC(ctx, parent, coding, count)
+}
+
+func TestCodedGenerationsReadContext(t *testing.T) {
+	inbuf := make([]byte, 2*400)
+	rand.Read(inbuf)
+	node, cg := getGenerationsNode(t, inbuf, 4, 100)
+	g := &ctxGetter{codedGetter: cg}
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewDagReaderC(ctx, node, g, "nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The read crosses into the second generation.
+	readCtx := context.WithValue(ctx, readCtxKey{}, "read")
+	outbuf := make([]byte, len(inbuf))
+	if _, err := reader.CtxReadFull(readCtx, outbuf); err != nil {
+		t.Fatal(err)
+	}
+	if err := testu.ArrComp(inbuf, outbuf); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.reads) != 2 || g.reads[0] != "read" || g.reads[1] != "read" {
+		t.Fatalf("expected both generations to be fetched with the read context, got %v", g.reads)
+	}
+}
+
+// countingGetter records the number of children every fetch asks for.
+type countingGetter struct {
+	*codedGetter
+	counts []int
+}
+
+func (g *countingGetter) GetManyC(ctx context.Context, parent cid.Cid, coding string, count int) <-chan *ipld.NodeOption {
+	g.counts = append(g.counts, count)
+	return g.codedGetter.GetManyC(ctx, parent, coding, count)
+}
+
+func TestCodedRedundancyAndStats(t *testing.T) {
+	inbuf := make([]byte, 10*100)
+	rand.Read(inbuf)
+	node, cg := getCodedNode(t, inbuf, 10, true)
+	// The first packet is delivered twice.
+	for p, order := range cg.order {
+		cg.order[p] = append([]cid.Cid{order[0]}, order...)
+	}
+	g := &countingGetter{codedGetter: cg}
+	ctx, closer := context.WithCancel(context.Background())
+	defer closer()
+
+	reader, err := NewRedundantDagReaderC(ctx, node, g, "nc", 0.25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbuf := new(bytes.Buffer)
+	if _, err := reader.WriteTo(outbuf); err != nil {
+		t.Fatal(err)
+	}
+	if err := testu.ArrComp(inbuf, outbuf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(g.counts) != 1 || g.counts[0] != 13 {
+		t.Fatalf("expected 13 packets to be asked for, got %v", g.counts)
+	}
+	st := reader.Stats()
+	if st.Packets != 11 || st.Duplicates != 1 {
+		t.Fatalf("expected 11 packets with 1 duplicate, got %+v", st)
+	}
+}
+
 func readByte(t testing.TB, reader DagReader) byte {
 	out := make([]byte, 1)
 	c, err := reader.Read(out)
@@ -174,10 +174,10 @@ func (pinLsOpts) Indirect() PinLsOption {
 // * "all" - all pinned objects (default)
 func (pinLsOpts) Type(typeStr string) (PinLsOption, error) {
 	switch typeStr {
-	case "all", "direct", "indirect", "recursive":
+	case "all", "direct", "indirect", "recursive", "coded":
 		return Pin.Ls.pinType(typeStr), nil
 	default:
-		return nil, fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, all}", typeStr)
+		return nil, fmt.Errorf("invalid type '%s', must be one of {direct, indirect, recursive, coded, all}", typeStr)
 	}
 }
 
@@ -189,6 +189,7 @@ func (pinLsOpts) Type(typeStr string) (PinLsOption, error) {
 // * "recursive" - roots of recursive pins
 // * "indirect" - indirectly pinned objects (referenced by recursively pinned
 //    objects)
+// * "coded" - parents of coded pins
 // * "all" - all pinned objects (default)
 func (pinLsOpts) pinType(t string) PinLsOption {
 	return func(settings *PinLsSettings) error {
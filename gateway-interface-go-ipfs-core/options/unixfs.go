@@ -42,8 +42,13 @@ type UnixfsLsSettings struct {
 	ResolveChildren bool
 }
 
+type UnixfsGetSettings struct {
+	Redundancy float64
+}
+
 type UnixfsAddOption func(*UnixfsAddSettings) error
 type UnixfsLsOption func(*UnixfsLsSettings) error
+type UnixfsGetOption func(*UnixfsGetSettings) error
 
 func UnixfsAddOptions(opts ...UnixfsAddOption) (*UnixfsAddSettings, cid.Prefix, error) {
 	options := &UnixfsAddSettings{
@@ -134,6 +139,21 @@ func UnixfsLsOptions(opts ...UnixfsLsOption) (*UnixfsLsSettings, error) {
 	return options, nil
 }
 
+func UnixfsGetOptions(opts ...UnixfsGetOption) (*UnixfsGetSettings, error) {
+	options := &UnixfsGetSettings{
+		Redundancy: -1,
+	}
+
+	for _, opt := range opts {
+		err := opt(options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
 type unixfsOpts struct{}
 
 var Unixfs unixfsOpts
@@ -283,3 +303,17 @@ func (unixfsOpts) ResolveChildren(resolve bool) UnixfsLsOption {
 		return nil
 	}
 }
+
+// Redundancy is an option for Unixfs.GetC which specifies how many extra
+// coded packets are ordered per generation, count*(1+redundancy) packets
+// being ordered for a generation of count symbols. Without it every packet
+// the generation links to is ordered.
+func (unixfsOpts) Redundancy(redundancy float64) UnixfsGetOption {
+	return func(settings *UnixfsGetSettings) error {
+		if redundancy < 0 {
+			return fmt.Errorf("redundancy must not be negative, got %g", redundancy)
+		}
+		settings.Redundancy = redundancy
+		return nil
+	}
+}
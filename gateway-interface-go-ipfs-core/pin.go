@@ -19,6 +19,19 @@ type Pin interface {
 	Err() error
 }
 
+// CodedPin is a Pin keeping coded blocks of the pinned object instead of its
+// DAG. Its Type is "coded".
+type CodedPin interface {
+	Pin
+
+	// Coding is the coding scheme of the kept blocks
+	Coding() string
+
+	// Count is how many coded blocks are kept, per generation of a coded
+	// file
+	Count() int
+}
+
 // PinStatus holds information about pin health
 type PinStatus interface {
 	// Ok indicates whether the pin has been verified to be correct
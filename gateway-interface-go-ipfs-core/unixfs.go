@@ -72,7 +72,11 @@ type UnixfsAPI interface {
 	// Note that some implementations of this API may apply the specified context
 	// to operations performed on the returned file
 	Get(context.Context, path.Path) (files.Node, error)
-	GetC(context.Context, path.Path, string) (files.Node, error)
+
+	// GetC is Get reading files from the coded packets of the given coding.
+	// Files are returned as `unixfile.CodedFile`s, reporting the packets
+	// decoded.
+	GetC(context.Context, path.Path, string, ...options.UnixfsGetOption) (files.Node, error)
 
 	// Ls returns the list of links in a directory. Links aren't guaranteed to be
 	// returned in order